@@ -0,0 +1,91 @@
+// Package address holds the rules of the countries orders can be shipped to. Countries
+// are ISO 3166-1 alpha-2 codes, and every country has the format of its postal codes,
+// or none when it does not use them.
+package address
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+// postalCodes holds the postal code format of every supported country, nil for the
+// countries without postal codes. Codes are matched after Normalize.
+var postalCodes = map[string]*regexp.Regexp{
+	"AE": nil,
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"HK": nil,
+	"IE": regexp.MustCompile(`^([AC-FHKNPRTV-Y]\d{2}|D6W) ?[0-9AC-FHKNPRTV-Y]{4}$`),
+	"IN": regexp.MustCompile(`^[1-9]\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^[1-9]\d{3} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// Countries returns the codes of the supported countries in order
+func Countries() []string {
+	codes := make([]string, 0, len(postalCodes))
+	for code := range postalCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Supported reports whether country is the code of a supported country, in any case
+func Supported(country string) bool {
+	_, ok := postalCodes[normalizeCode(country)]
+	return ok
+}
+
+// ValidPostalCode reports whether postalCode has the format of country. Countries
+// without postal codes only accept an empty one, unsupported countries none.
+func ValidPostalCode(country, postalCode string) bool {
+	format, ok := postalCodes[normalizeCode(country)]
+	if !ok {
+		return false
+	}
+	postalCode = normalizeCode(postalCode)
+	if format == nil {
+		return postalCode == ""
+	}
+	return format.MatchString(postalCode)
+}
+
+// Normalize trims the fields of a and writes its country and postal code in upper case
+// with single spaces
+func Normalize(a models.PostalAddress) models.PostalAddress {
+	a.Name = strings.TrimSpace(a.Name)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.PostalCode = normalizeCode(a.PostalCode)
+	a.Country = normalizeCode(a.Country)
+	return a
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), " "))
+}
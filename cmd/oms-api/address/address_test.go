@@ -0,0 +1,47 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestValidPostalCode(t *testing.T) {
+	tests := []struct {
+		country, postalCode string
+		want                bool
+	}{
+		{"US", "94105", true},
+		{"US", "94105-1234", true},
+		{"us", " 94105 ", true},
+		{"US", "9410", false},
+		{"GB", "SW1A 1AA", true},
+		{"GB", "sw1a  1aa", true},
+		{"GB", "SW1A", false},
+		{"CA", "K1A 0B1", true},
+		{"CA", "D1A 0B1", false},
+		{"IN", "560001", true},
+		{"IN", "060001", false},
+		{"NL", "1012 AB", true},
+		{"DE", "", false},
+		{"HK", "", true},
+		{"HK", "999077", false},
+		{"XX", "12345", false},
+	}
+	for _, tc := range tests {
+		if got := ValidPostalCode(tc.country, tc.postalCode); got != tc.want {
+			t.Errorf("ValidPostalCode(%q, %q) = %v, want %v", tc.country, tc.postalCode, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got := Normalize(models.PostalAddress{Name: " Ada ", Line1: "1 Main St ", City: "London", PostalCode: "sw1a   1aa", Country: "gb"})
+	want := models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"}
+	if got != want {
+		t.Errorf("Normalize = %+v, want %+v", got, want)
+	}
+	if !Supported("gb") || Supported("UK") {
+		t.Error("Supported does not follow the ISO 3166-1 codes")
+	}
+}
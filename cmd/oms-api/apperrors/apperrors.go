@@ -0,0 +1,141 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine readable identifier for an error condition.
+// Clients may switch on it, so existing values must never change.
+type Code string
+
+const (
+	CodeInvalidInput             Code = "invalid_input"
+	CodeInvalidID                Code = "invalid_id"
+	CodeInvalidPatch             Code = "invalid_patch"
+	CodeValidationFailed         Code = "validation_failed"
+	CodeUnsupportedMediaType     Code = "unsupported_media_type"
+	CodeRouteNotFound            Code = "route_not_found"
+	CodeMethodNotAllowed         Code = "method_not_allowed"
+	CodeUserNotFound             Code = "user_not_found"
+	CodeUserDeleted              Code = "user_deleted"
+	CodeUserAlreadyDeleted       Code = "user_already_deleted"
+	CodeEmailTaken               Code = "email_taken"
+	CodeEmailAlreadyVerified     Code = "email_already_verified"
+	CodeInvalidVerificationToken Code = "invalid_verification_token"
+	CodeVerificationTokenExpired Code = "verification_token_expired"
+	CodeAddressNotFound          Code = "address_not_found"
+	CodeItemNotFound             Code = "item_not_found"
+	CodeItemAlreadyDeleted       Code = "item_already_deleted"
+	CodeInvalidItem              Code = "invalid_item"
+	CodeCouponNotFound           Code = "coupon_not_found"
+	CodeCouponCodeTaken          Code = "coupon_code_taken"
+	CodeCouponNotApplicable      Code = "coupon_not_applicable"
+	CodeShippingUnavailable      Code = "shipping_unavailable"
+	CodeOrderNotFound            Code = "order_not_found"
+	CodeOrderAlreadyDeleted      Code = "order_already_deleted"
+	CodeOrderNotPending          Code = "order_not_pending"
+	CodeOrderNotConfirmed        Code = "order_not_confirmed"
+	CodeOrderPaid                Code = "order_paid"
+	CodeOrderInvoiced            Code = "order_invoiced"
+	CodeOrderNotPaid             Code = "order_not_paid"
+	CodeNothingToRefund          Code = "nothing_to_refund"
+	CodeInvalidRefundQuantity    Code = "invalid_refund_quantity"
+	CodeOrderShipped             Code = "order_shipped"
+	CodeOrderNotShippable        Code = "order_not_shippable"
+	CodeNothingToShip            Code = "nothing_to_ship"
+	CodeInvalidShipmentQuantity  Code = "invalid_shipment_quantity"
+	CodeShipmentNotFound         Code = "shipment_not_found"
+	CodeShipmentDelivered        Code = "shipment_already_delivered"
+	CodeTrackingNumberTaken      Code = "tracking_number_taken"
+	CodeUnknownPaymentProvider   Code = "unknown_payment_provider"
+	CodePaymentInProgress        Code = "payment_in_progress"
+	CodePaymentNotAuthorized     Code = "payment_not_authorized"
+	CodePaymentDeclined          Code = "payment_declined"
+	CodePaymentProviderError     Code = "payment_provider_error"
+	CodePaymentNotFound          Code = "payment_not_found"
+	CodeInvalidPaymentTransition Code = "invalid_payment_transition"
+	CodeInvalidWebhookSignature  Code = "invalid_webhook_signature"
+	CodeInvalidWebhookEvent      Code = "invalid_webhook_event"
+	CodeNotAcceptable            Code = "not_acceptable"
+	CodeRequestTimeout           Code = "request_timeout"
+	CodeRequestCanceled          Code = "request_canceled"
+	CodeInternal                 Code = "internal_error"
+)
+
+// Error is the error type shared by all handlers. Message and Fields are safe to
+// show to clients, Err is the underlying cause and is only ever logged.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error with a client facing message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap attaches an internal cause to a client facing error
+func Wrap(err error, status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+// BadRequest reports invalid client input
+func BadRequest(code Code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// NotFound reports a missing resource
+func NotFound(code Code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict reports a request that conflicts with the current state of a resource
+func Conflict(code Code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// Internal hides err behind a generic message, the cause is logged when rendered
+func Internal(err error, message string) *Error {
+	return Wrap(err, http.StatusInternalServerError, CodeInternal, message)
+}
+
+// From converts any error to an *Error. Errors caused by an expired or canceled
+// request context become 504 and 503 errors, unknown errors become internal errors.
+func From(err error) *Error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(err, http.StatusGatewayTimeout, CodeRequestTimeout, "The request took too long to complete")
+	case errors.Is(err, context.Canceled):
+		return Wrap(err, http.StatusServiceUnavailable, CodeRequestCanceled, "The request was canceled")
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err, "An unexpected error occurred")
+}
@@ -0,0 +1,126 @@
+package apperrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("disk full")
+	conflict := Conflict(CodeEmailTaken, "Email is already in use")
+
+	for name, tc := range map[string]struct {
+		err    error
+		status int
+		code   Code
+	}{
+		"app error":         {conflict, http.StatusConflict, CodeEmailTaken},
+		"wrapped app error": {fmt.Errorf("create user: %w", conflict), http.StatusConflict, CodeEmailTaken},
+		"deadline":          {fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeRequestTimeout},
+		"canceled":          {context.Canceled, http.StatusServiceUnavailable, CodeRequestCanceled},
+		"unknown":           {cause, http.StatusInternalServerError, CodeInternal},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := From(tc.err)
+			if got.Status != tc.status || got.Code != tc.code {
+				t.Fatalf("From(%v) = %d %s, want %d %s", tc.err, got.Status, got.Code, tc.status, tc.code)
+			}
+		})
+	}
+
+	internal := From(cause)
+	if !errors.Is(internal, cause) || strings.Contains(internal.Message, "disk") {
+		t.Fatalf("internal error %+v, want the cause kept but not shown", internal)
+	}
+}
+
+// render renders err for a request to path and decodes the problem
+func render(t *testing.T, ctx context.Context, path string, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+	Render(c, err)
+	if !c.IsAborted() {
+		t.Fatal("the request was not aborted")
+	}
+	var problem Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem %s: %v", w.Body.String(), err)
+	}
+	return w, problem
+}
+
+func TestRenderWritesProblemDetails(t *testing.T) {
+	err := New(http.StatusUnprocessableEntity, CodeValidationFailed, "One or more fields are invalid")
+	err.Fields = []FieldError{{Field: "email", Rule: "email", Message: "must be a valid email address"}}
+
+	w, problem := render(t, context.Background(), "/api/createUser", err)
+	if w.Code != http.StatusUnprocessableEntity || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("response %d %s, want 422 %s", w.Code, w.Header().Get("Content-Type"), ProblemContentType)
+	}
+	want := Problem{
+		Type:     "/problems/validation_failed",
+		Title:    "Unprocessable Entity",
+		Status:   http.StatusUnprocessableEntity,
+		Detail:   "One or more fields are invalid",
+		Instance: "/api/createUser",
+		Code:     CodeValidationFailed,
+		Errors:   err.Fields,
+	}
+	if !reflect.DeepEqual(problem, want) {
+		t.Fatalf("problem %+v, want %+v", problem, want)
+	}
+}
+
+func TestRenderHidesInternalCauses(t *testing.T) {
+	w, problem := render(t, context.Background(), "/", Internal(errors.New("password authentication failed"), "Failed to fetch user"))
+	if w.Code != http.StatusInternalServerError || problem.Code != CodeInternal || problem.Detail != "Failed to fetch user" {
+		t.Fatalf("problem %+v", problem)
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Fatalf("response %s shows the internal cause", w.Body.String())
+	}
+}
+
+func TestRenderReportsFinishedRequests(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	// A driver error on an expired request is a timeout, client errors stay as they are
+	if w, problem := render(t, ctx, "/", errors.New("driver: bad connection")); w.Code != http.StatusGatewayTimeout || problem.Code != CodeRequestTimeout {
+		t.Fatalf("server error on an expired request rendered as %d %s, want 504 %s", w.Code, problem.Code, CodeRequestTimeout)
+	}
+	if w, problem := render(t, ctx, "/", NotFound(CodeUserNotFound, "User not found")); w.Code != http.StatusNotFound || problem.Code != CodeUserNotFound {
+		t.Fatalf("client error on an expired request rendered as %d %s, want 404 %s", w.Code, problem.Code, CodeUserNotFound)
+	}
+}
+
+func TestBinding(t *testing.T) {
+	var target struct {
+		Quantity int `json:"quantity"`
+	}
+	typeErr := json.Unmarshal([]byte(`{"quantity":"two"}`), &target)
+	syntaxErr := json.Unmarshal([]byte(`{"quantity":`), &target)
+
+	if got := Binding(typeErr); got.Code != CodeInvalidInput || got.Message != `Field "quantity" must be an integer` {
+		t.Errorf("type mismatch = %+v", got)
+	}
+	if got := Binding(syntaxErr); got.Code != CodeInvalidInput || !strings.HasPrefix(got.Message, "Request body is not valid JSON") {
+		t.Errorf("syntax error = %+v", got)
+	}
+	if got := Binding(errors.New("EOF")); got.Status != http.StatusBadRequest || got.Message != "Invalid input" {
+		t.Errorf("other error = %+v", got)
+	}
+}
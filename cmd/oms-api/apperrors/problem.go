@@ -0,0 +1,177 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+)
+
+// ProblemContentType is the media type of an RFC 7807 problem details document
+const ProblemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 representation of an Error
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// Render writes err as an application/problem+json response and aborts the request.
+// Internal causes are logged and never included in the response.
+func Render(c *gin.Context, err error) {
+	// Drivers do not always wrap the context error when a query is interrupted,
+	// so a server side failure on a finished request context is reported as such.
+	if ctxErr := c.Request.Context().Err(); ctxErr != nil && From(err).Status >= http.StatusInternalServerError {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	appErr := From(err)
+	if appErr.Err != nil {
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).ErrorContext(ctx, appErr.Message,
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", appErr.Status),
+			slog.String("code", string(appErr.Code)),
+			slog.Any("error", appErr.Err),
+		)
+	}
+
+	problem := Problem{
+		Type:     "/problems/" + string(appErr.Code),
+		Title:    http.StatusText(appErr.Status),
+		Status:   appErr.Status,
+		Detail:   appErr.Message,
+		Instance: c.Request.URL.Path,
+		Code:     appErr.Code,
+		Errors:   appErr.Fields,
+	}
+
+	// Gin keeps an explicitly set content type when rendering JSON
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(appErr.Status, problem)
+}
+
+// Binding converts a Gin binding failure into a client error
+// without echoing decoder internals back to the client.
+func Binding(err error) *Error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		return Validation(err)
+	case errors.As(err, &syntaxErr):
+		return Wrap(err, http.StatusBadRequest, CodeInvalidInput, fmt.Sprintf("Request body is not valid JSON (at offset %d)", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return Wrap(err, http.StatusBadRequest, CodeInvalidInput, TypeMismatchMessage(typeErr))
+	default:
+		return Wrap(err, http.StatusBadRequest, CodeInvalidInput, "Invalid input")
+	}
+}
+
+// TypeMismatchMessage describes a JSON type error using JSON type names instead of Go types
+func TypeMismatchMessage(typeErr *json.UnmarshalTypeError) string {
+	jsonType := "a string"
+	switch typeErr.Type.Kind() {
+	case reflect.Bool:
+		jsonType = "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		jsonType = "an integer"
+	case reflect.Float32, reflect.Float64:
+		jsonType = "a number"
+	case reflect.Slice, reflect.Array:
+		jsonType = "an array"
+	case reflect.Struct, reflect.Map:
+		jsonType = "an object"
+	}
+	return fmt.Sprintf("Field %q must be %s", typeErr.Field, jsonType)
+}
+
+// Validation converts a failed struct validation into a client error with one entry per invalid field
+func Validation(err error) *Error {
+	appErr := New(http.StatusUnprocessableEntity, CodeValidationFailed, "One or more fields are invalid")
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		appErr.Err = err
+		return appErr
+	}
+	for _, fieldErr := range validationErrs {
+		appErr.Fields = append(appErr.Fields, FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Message: fieldMessage(fieldErr),
+		})
+	}
+	return appErr
+}
+
+// fieldPath strips the struct name from the namespace, e.g. "items[0].quantity"
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "email":
+		return "must be a valid email address"
+	case "gt":
+		return "must be greater than " + param
+	case "country":
+		return "must be a supported ISO 3166-1 alpha-2 country code"
+	case "postal_code":
+		return "must be a valid postal code of the country"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "min", "max":
+		limit := "at least"
+		if fieldErr.Tag() == "max" {
+			limit = "at most"
+		}
+		switch fieldErr.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", limit, param)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			if param == "1" {
+				return fmt.Sprintf("must contain %s 1 entry", limit)
+			}
+			return fmt.Sprintf("must contain %s %s entries", limit, param)
+		default:
+			return fmt.Sprintf("must be %s %s", limit, param)
+		}
+	default:
+		return "is invalid"
+	}
+}
+
+// NoRoute renders unknown routes as problems
+func NoRoute(c *gin.Context) {
+	Render(c, NotFound(CodeRouteNotFound, "Route not found"))
+}
+
+// NoMethod renders unsupported methods as problems
+func NoMethod(c *gin.Context) {
+	Render(c, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"))
+}
@@ -0,0 +1,217 @@
+// Package campaign decides which seasonal discount applies to a customer. Campaign
+// windows are calendar dates, they open at local midnight in the region of the
+// customer and close at the end of their last day there. Customers without a known
+// region use the business timezone.
+package campaign
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Campaign is a discount rate that applies between two dates, both included.
+// Dates are either "MM-DD", which repeats every year, or "YYYY-MM-DD" for a single window.
+type Campaign struct {
+	Name    string   `json:"name"`
+	Regions []string `json:"regions"` // Every region when empty
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Rate    float64  `json:"rate"` // Fraction of the order total, 0.15 is 15%
+
+	from, to day
+}
+
+// Calendar holds the campaigns and the timezone of every region
+type Calendar struct {
+	location  *time.Location
+	regions   map[string]*time.Location
+	campaigns []Campaign
+}
+
+// file is the format of a campaign calendar file
+type file struct {
+	Regions   map[string]string `json:"regions"` // Region name to IANA timezone
+	Campaigns []Campaign        `json:"campaigns"`
+}
+
+// DefaultCampaigns is the calendar used when no file is configured: 15% off
+// from December 3rd to December 31st everywhere
+var DefaultCampaigns = []Campaign{
+	{Name: "seasonal", From: "12-03", To: "12-31", Rate: 0.15},
+}
+
+// New creates a calendar. location is the business timezone, regions maps region names to timezones.
+func New(location *time.Location, regions map[string]*time.Location, campaigns []Campaign) (*Calendar, error) {
+	if location == nil {
+		location = time.UTC
+	}
+	c := &Calendar{location: location, regions: map[string]*time.Location{}}
+	for name, loc := range regions {
+		c.regions[name] = loc
+	}
+	for _, campaign := range campaigns {
+		if err := campaign.parse(); err != nil {
+			return nil, err
+		}
+		for _, region := range campaign.Regions {
+			if _, ok := c.regions[region]; !ok {
+				return nil, fmt.Errorf("campaign %q: unknown region %q", campaign.Name, region)
+			}
+		}
+		c.campaigns = append(c.campaigns, campaign)
+	}
+	return c, nil
+}
+
+// Load reads a calendar file. Without a path the default campaigns are used.
+func Load(path string, location *time.Location) (*Calendar, error) {
+	if path == "" {
+		return New(location, nil, DefaultCampaigns)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse campaign calendar %s: %w", path, err)
+	}
+	regions := make(map[string]*time.Location, len(f.Regions))
+	for name, tz := range f.Regions {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("region %q: %w", name, err)
+		}
+		regions[name] = loc
+	}
+	calendar, err := New(location, regions, f.Campaigns)
+	if err != nil {
+		return nil, fmt.Errorf("campaign calendar %s: %w", path, err)
+	}
+	return calendar, nil
+}
+
+// HasRegion reports whether region is known, the empty region always is
+func (c *Calendar) HasRegion(region string) bool {
+	if region == "" {
+		return true
+	}
+	_, ok := c.regions[region]
+	return ok
+}
+
+// Regions returns the names of the known regions in order
+func (c *Calendar) Regions() []string {
+	names := make([]string, 0, len(c.regions))
+	for name := range c.regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Location returns the timezone of region, the business timezone for unknown regions
+func (c *Calendar) Location(region string) *time.Location {
+	if loc, ok := c.regions[region]; ok {
+		return loc
+	}
+	return c.location
+}
+
+// Active returns the campaign with the highest rate that is running for a customer in
+// region at now. ok is false when none is running.
+func (c *Calendar) Active(region string, now time.Time) (active Campaign, ok bool) {
+	year, month, date := now.In(c.Location(region)).Date()
+	today := day{year: year, month: month, day: date}
+	for _, campaign := range c.campaigns {
+		if !campaign.appliesTo(region) || !campaign.runsOn(today) {
+			continue
+		}
+		if !ok || campaign.Rate > active.Rate {
+			active, ok = campaign, true
+		}
+	}
+	return active, ok
+}
+
+func (c Campaign) appliesTo(region string) bool {
+	if len(c.Regions) == 0 {
+		return true
+	}
+	for _, r := range c.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// runsOn reports whether today is inside the window. Yearly windows may wrap around
+// the new year, like "12-20" to "01-05".
+func (c Campaign) runsOn(today day) bool {
+	if c.from.year != 0 {
+		return !today.before(c.from) && !c.to.before(today)
+	}
+	today.year = 0
+	if c.to.before(c.from) {
+		return !today.before(c.from) || !c.to.before(today)
+	}
+	return !today.before(c.from) && !c.to.before(today)
+}
+
+// parse reads the dates of the window and checks the rate
+func (c *Campaign) parse() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("campaign without a name")
+	}
+	if c.Rate <= 0 || c.Rate > 1 {
+		return fmt.Errorf("campaign %q: rate %v must be above 0 and at most 1", c.Name, c.Rate)
+	}
+	var err error
+	if c.from, err = parseDay(c.From); err != nil {
+		return fmt.Errorf("campaign %q: from: %w", c.Name, err)
+	}
+	if c.to, err = parseDay(c.To); err != nil {
+		return fmt.Errorf("campaign %q: to: %w", c.Name, err)
+	}
+	if (c.from.year == 0) != (c.to.year == 0) {
+		return fmt.Errorf("campaign %q: from and to must both be yearly (MM-DD) or both be dates (YYYY-MM-DD)", c.Name)
+	}
+	if c.from.year != 0 && c.to.before(c.from) {
+		return fmt.Errorf("campaign %q: ends before it starts", c.Name)
+	}
+	return nil
+}
+
+// day is a calendar date, year is zero for dates that repeat every year
+type day struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func parseDay(s string) (day, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return day{year: t.Year(), month: t.Month(), day: t.Day()}, nil
+	}
+	// A leap year, so that "02-29" is accepted
+	t, err := time.Parse("2006-01-02", "2000-"+s)
+	if err != nil {
+		return day{}, fmt.Errorf("%q is neither MM-DD nor YYYY-MM-DD", s)
+	}
+	return day{month: t.Month(), day: t.Day()}, nil
+}
+
+func (d day) before(other day) bool {
+	if d.year != other.year {
+		return d.year < other.year
+	}
+	if d.month != other.month {
+		return d.month < other.month
+	}
+	return d.day < other.day
+}
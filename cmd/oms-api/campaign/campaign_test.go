@@ -0,0 +1,136 @@
+package campaign
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestDefaultCampaignFollowsTheBusinessTimezone(t *testing.T) {
+	calendar, err := Load("", mustLocation(t, "Asia/Kolkata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Midnight on December 3rd in India is 18:30 UTC the day before
+	opens := time.Date(2024, time.December, 2, 18, 30, 0, 0, time.UTC)
+	if _, ok := calendar.Active("", opens.Add(-time.Minute)); ok {
+		t.Error("campaign running before local midnight")
+	}
+	active, ok := calendar.Active("", opens)
+	if !ok || active.Name != "seasonal" || active.Rate != 0.15 {
+		t.Errorf("Active at local midnight = %+v, %v, want the seasonal campaign", active, ok)
+	}
+	closes := time.Date(2024, time.December, 31, 18, 30, 0, 0, time.UTC)
+	if _, ok := calendar.Active("", closes.Add(-time.Minute)); !ok {
+		t.Error("campaign not running in the last minute of December 31st")
+	}
+	if _, ok := calendar.Active("", closes); ok {
+		t.Error("campaign still running on January 1st")
+	}
+}
+
+func TestRegionsHaveTheirOwnMidnight(t *testing.T) {
+	regions := map[string]*time.Location{
+		"in":      mustLocation(t, "Asia/Kolkata"),
+		"us-east": mustLocation(t, "America/New_York"),
+	}
+	calendar, err := New(time.UTC, regions, []Campaign{
+		{Name: "seasonal", From: "12-03", To: "12-31", Rate: 0.15},
+		{Name: "diwali", Regions: []string{"in"}, From: "2024-10-28", To: "2024-11-03", Rate: 0.10},
+		{Name: "holidays", From: "12-20", To: "01-05", Rate: 0.20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		region string
+		now    time.Time
+		want   string
+	}{
+		{"", time.Date(2024, time.December, 2, 20, 0, 0, 0, time.UTC), ""},
+		{"in", time.Date(2024, time.December, 2, 20, 0, 0, 0, time.UTC), "seasonal"},
+		{"us-east", time.Date(2024, time.December, 3, 3, 0, 0, 0, time.UTC), ""},
+		{"unknown", time.Date(2024, time.December, 3, 3, 0, 0, 0, time.UTC), "seasonal"}, // Business timezone
+		{"in", time.Date(2024, time.November, 3, 12, 0, 0, 0, time.UTC), "diwali"},
+		{"in", time.Date(2025, time.November, 3, 12, 0, 0, 0, time.UTC), ""},
+		{"us-east", time.Date(2024, time.November, 3, 12, 0, 0, 0, time.UTC), ""},
+		{"", time.Date(2024, time.December, 24, 12, 0, 0, 0, time.UTC), "holidays"}, // The higher rate wins
+		{"", time.Date(2025, time.January, 5, 23, 0, 0, 0, time.UTC), "holidays"},
+		{"in", time.Date(2025, time.January, 5, 23, 0, 0, 0, time.UTC), ""},
+		{"", time.Date(2025, time.December, 19, 12, 0, 0, 0, time.UTC), "seasonal"},
+		{"", time.Date(2025, time.January, 6, 12, 0, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		active, ok := calendar.Active(tt.region, tt.now)
+		if got := active.Name; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Active(%q, %s) = %q, want %q", tt.region, tt.now.Format(time.RFC3339), got, tt.want)
+		}
+	}
+
+	if !calendar.HasRegion("in") || !calendar.HasRegion("") || calendar.HasRegion("eu") {
+		t.Error("HasRegion does not match the configured regions")
+	}
+	if got := strings.Join(calendar.Regions(), ","); got != "in,us-east" {
+		t.Errorf("Regions() = %s", got)
+	}
+}
+
+func TestNewRejectsInvalidCampaigns(t *testing.T) {
+	for _, c := range []Campaign{
+		{Name: "", From: "12-03", To: "12-31", Rate: 0.1},
+		{Name: "free", From: "12-03", To: "12-31", Rate: 1.5},
+		{Name: "nothing", From: "12-03", To: "12-31"},
+		{Name: "typo", From: "12-32", To: "12-31", Rate: 0.1},
+		{Name: "mixed", From: "2024-12-03", To: "12-31", Rate: 0.1},
+		{Name: "backwards", From: "2024-12-31", To: "2024-12-03", Rate: 0.1},
+		{Name: "abroad", Regions: []string{"eu"}, From: "12-03", To: "12-31", Rate: 0.1},
+	} {
+		if _, err := New(time.UTC, nil, []Campaign{c}); err == nil {
+			t.Errorf("campaign %+v accepted", c)
+		}
+	}
+}
+
+func TestLoadReadsCalendarFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "campaigns.json")
+	content := `{
+		"regions": {"in": "Asia/Kolkata"},
+		"campaigns": [{"name": "diwali", "regions": ["in"], "from": "10-28", "to": "11-03", "rate": 0.1}]
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	calendar, err := Load(path, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := calendar.Active("in", time.Date(2024, time.October, 27, 19, 0, 0, 0, time.UTC)); !ok {
+		t.Error("diwali not running at midnight in India")
+	}
+	if _, ok := calendar.Active("", time.Date(2024, time.December, 10, 12, 0, 0, 0, time.UTC)); ok {
+		t.Error("a calendar file replaces the default campaigns")
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"regions": {"mars": "Mars/Olympus_Mons"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(bad, time.UTC); err == nil || !strings.Contains(err.Error(), "mars") {
+		t.Errorf("Load with an unknown timezone = %v, want an error naming the region", err)
+	}
+}
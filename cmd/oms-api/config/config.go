@@ -0,0 +1,304 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings of the API, read from the environment
+type Config struct {
+	Server   ServerConfig
+	Database DatabaseConfig
+	Mail     MailConfig
+	Log      LogConfig
+	Tracing  TracingConfig
+	Pricing  PricingConfig
+	Webhooks WebhookConfig
+	// PublicURL is the externally reachable base URL used in links sent to users
+	PublicURL string
+	// EmailVerificationTTL is how long an email verification token stays valid
+	EmailVerificationTTL time.Duration
+	// RequestTimeout bounds every request unless the route has its own entry in RouteTimeouts
+	RequestTimeout time.Duration
+	// RouteTimeouts holds per-route timeouts keyed by "METHOD /route/template"
+	RouteTimeouts map[string]time.Duration
+}
+
+// ServerConfig holds the HTTP server settings. A zero timeout disables it.
+type ServerConfig struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout has to be longer than every request timeout, otherwise responses are cut off
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// DrainDelay keeps serving after /readyz turned 503 so load balancers can stop routing here
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may run after the shutdown signal
+	ShutdownTimeout time.Duration
+}
+
+// DatabaseConfig holds the PostgreSQL connection settings
+type DatabaseConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	// Schema is the PostgreSQL schema holding the tables, it is set as search_path on every connection
+	Schema string
+}
+
+// DSN returns the connection string for the PostgreSQL driver
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s search_path=%s sslmode=disable", c.Host, c.Port, c.User, c.Password, c.Name, c.Schema)
+}
+
+// MailConfig selects and configures the mail sender.
+// Driver is one of "smtp", "file" or "log".
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+// LogConfig controls the application logger.
+// Level is one of "debug", "info", "warn" or "error", Format is "json" or "text".
+type LogConfig struct {
+	Level  string
+	Format string
+	// SlowQueryThreshold is the duration after which a SQL statement is logged as a warning
+	SlowQueryThreshold time.Duration
+}
+
+// TracingConfig selects the OpenTelemetry span exporter.
+// Exporter is one of "none", "stdout", "file" or "otlp". The OTLP exporter is configured
+// through the standard OTEL_EXPORTER_OTLP_* environment variables.
+type TracingConfig struct {
+	Exporter    string
+	FilePath    string
+	ServiceName string
+	// SampleRatio is the fraction of new traces that are recorded, a sampled parent is always followed
+	SampleRatio float64
+}
+
+// PricingConfig holds the settings of the discount rules
+type PricingConfig struct {
+	// Timezone is the business timezone, seasonal campaigns follow it for customers without a region
+	Timezone *time.Location
+	// CampaignsFile is a JSON campaign calendar with regions and their timezones, the
+	// built-in December campaign is used when it is empty
+	CampaignsFile string
+	// ShippingRatesFile is a JSON table of shipping zones and their rates, the built-in
+	// rates with free standard shipping are used when it is empty
+	ShippingRatesFile string
+	// TaxRatesFile is a JSON table of tax jurisdictions and their rates, and of whether
+	// catalog prices include tax. Nothing is taxed when it is empty.
+	TaxRatesFile string
+	// LoyaltyTiersFile is a JSON loyalty program with its window and tiers, the built-in
+	// Silver, Gold and Platinum tiers over a year are used when it is empty
+	LoyaltyTiersFile string
+}
+
+// WebhookConfig holds the settings of the inbound payment webhooks
+type WebhookConfig struct {
+	// Secrets holds the signing secret of every provider allowed to send webhooks, by provider name
+	Secrets map[string]string
+	// Tolerance is how far the signed timestamp of a webhook may be from the current time
+	Tolerance time.Duration
+}
+
+// Load reads the configuration from environment variables, falling back to local development defaults
+func Load() (Config, error) {
+	var cfg Config
+	var err error
+
+	cfg.Server.Addr = getEnv("HTTP_ADDR", ":8080")
+	for _, d := range []struct {
+		target   *time.Duration
+		key      string
+		fallback time.Duration
+	}{
+		{&cfg.Server.ReadHeaderTimeout, "HTTP_READ_HEADER_TIMEOUT", 5 * time.Second},
+		{&cfg.Server.ReadTimeout, "HTTP_READ_TIMEOUT", 15 * time.Second},
+		{&cfg.Server.WriteTimeout, "HTTP_WRITE_TIMEOUT", 30 * time.Second},
+		{&cfg.Server.IdleTimeout, "HTTP_IDLE_TIMEOUT", 60 * time.Second},
+		{&cfg.Server.DrainDelay, "SHUTDOWN_DRAIN_DELAY", 0},
+		{&cfg.Server.ShutdownTimeout, "SHUTDOWN_TIMEOUT", 20 * time.Second},
+	} {
+		if *d.target, err = getEnvDuration(d.key, d.fallback); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.Database.Host = getEnv("DB_HOST", "localhost")
+	if cfg.Database.Port, err = getEnvInt("DB_PORT", 5432); err != nil {
+		return cfg, err
+	}
+	cfg.Database.User = getEnv("DB_USER", "root")
+	cfg.Database.Password = getEnv("DB_PASSWORD", "root")
+	cfg.Database.Name = getEnv("DB_NAME", "oms")
+	cfg.Database.Schema = getEnv("DB_SCHEMA", "gorm")
+
+	cfg.Mail.Driver = getEnv("MAIL_DRIVER", "log")
+	cfg.Mail.From = getEnv("MAIL_FROM", "no-reply@oms.local")
+	cfg.Mail.SMTPHost = getEnv("SMTP_HOST", "localhost")
+	if cfg.Mail.SMTPPort, err = getEnvInt("SMTP_PORT", 25); err != nil {
+		return cfg, err
+	}
+	cfg.Mail.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	cfg.Mail.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	cfg.Mail.FileDir = getEnv("MAIL_FILE_DIR", "storage/mail")
+
+	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
+	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
+	if cfg.Log.SlowQueryThreshold, err = getEnvDuration("LOG_SLOW_QUERY_THRESHOLD", 200*time.Millisecond); err != nil {
+		return cfg, err
+	}
+
+	cfg.Tracing.Exporter = getEnv("TRACING_EXPORTER", "none")
+	cfg.Tracing.FilePath = getEnv("TRACING_FILE", "storage/traces.jsonl")
+	cfg.Tracing.ServiceName = getEnv("OTEL_SERVICE_NAME", "oms-api")
+	if cfg.Tracing.SampleRatio, err = getEnvFloat("TRACING_SAMPLE_RATIO", 1); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Pricing.Timezone, err = time.LoadLocation(getEnv("BUSINESS_TIMEZONE", "UTC")); err != nil {
+		return cfg, fmt.Errorf("invalid BUSINESS_TIMEZONE: %w", err)
+	}
+	cfg.Pricing.CampaignsFile = getEnv("CAMPAIGNS_FILE", "")
+	cfg.Pricing.ShippingRatesFile = getEnv("SHIPPING_RATES_FILE", "")
+	cfg.Pricing.TaxRatesFile = getEnv("TAX_RATES_FILE", "")
+	cfg.Pricing.LoyaltyTiersFile = getEnv("LOYALTY_TIERS_FILE", "")
+
+	if cfg.Webhooks.Secrets, err = parseWebhookSecrets(getEnv("PAYMENT_WEBHOOK_SECRETS", "")); err != nil {
+		return cfg, err
+	}
+	if cfg.Webhooks.Tolerance, err = getEnvDuration("PAYMENT_WEBHOOK_TOLERANCE", 5*time.Minute); err != nil {
+		return cfg, err
+	}
+
+	cfg.PublicURL = getEnv("PUBLIC_URL", "http://localhost:8080")
+	if cfg.EmailVerificationTTL, err = getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	if cfg.RequestTimeout, err = getEnvDuration("REQUEST_TIMEOUT", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.RouteTimeouts, err = parseRouteTimeouts(getEnv("ROUTE_TIMEOUTS", "")); err != nil {
+		return cfg, err
+	}
+	if err := checkWriteTimeout(cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// checkWriteTimeout makes sure a request that times out can still write its 504 response
+func checkWriteTimeout(cfg Config) error {
+	if cfg.Server.WriteTimeout == 0 {
+		return nil
+	}
+	if cfg.RequestTimeout == 0 || cfg.RequestTimeout >= cfg.Server.WriteTimeout {
+		return fmt.Errorf("REQUEST_TIMEOUT (%s) must be set and shorter than HTTP_WRITE_TIMEOUT (%s)", cfg.RequestTimeout, cfg.Server.WriteTimeout)
+	}
+	for route, d := range cfg.RouteTimeouts {
+		if d == 0 || d >= cfg.Server.WriteTimeout {
+			return fmt.Errorf("ROUTE_TIMEOUTS entry %q (%s) must be set and shorter than HTTP_WRITE_TIMEOUT (%s)", route, d, cfg.Server.WriteTimeout)
+		}
+	}
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// parseRouteTimeouts reads a comma separated list such as
+// "POST /api/createOrder=30s,GET /api/getOrders=5s"
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, duration, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q, expected METHOD /path=duration", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: %w", entry, err)
+		}
+		timeouts[strings.Join(strings.Fields(route), " ")] = d
+	}
+	return timeouts, nil
+}
+
+// parseWebhookSecrets reads a comma separated list such as "mock=whsec_local,acme=whsec_123"
+func parseWebhookSecrets(value string) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		provider, secret, ok := strings.Cut(entry, "=")
+		provider, secret = strings.TrimSpace(provider), strings.TrimSpace(secret)
+		if !ok || provider == "" || secret == "" {
+			// The entry is not repeated, it holds a secret
+			return nil, fmt.Errorf("invalid PAYMENT_WEBHOOK_SECRETS entry for %q, expected provider=secret", provider)
+		}
+		secrets[provider] = secret
+	}
+	return secrets, nil
+}
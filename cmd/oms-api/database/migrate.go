@@ -0,0 +1,184 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Applied migrations are recorded in
+// schema_migrations and never run again, so a released migration must not be edited;
+// later changes are appended as a new migration with the next version. Migrations use
+// their own copies of the tables (schema.go), not the models, which keep changing.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// migrations lists every schema change in the order it has to be applied
+var migrations = []Migration{
+	{Version: 1, Name: "create_tables", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v1Item{}, &v1Order{}, &v1OrderItem{}, &v1User{}, &v1UserOrder{})
+	}},
+	{Version: 2, Name: "unique_user_emails", Up: migrateUserEmails},
+	{Version: 3, Name: "create_email_verifications", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v3EmailVerification{})
+	}},
+	{Version: 4, Name: "add_user_regions", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v4User{})
+	}},
+	{Version: 5, Name: "create_payments", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v5Payment{}, &v5PaymentAttempt{})
+	}},
+	{Version: 6, Name: "create_webhook_events", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v6Payment{}, &v6PaymentAttempt{}, &v6WebhookEvent{})
+	}},
+	{Version: 7, Name: "create_refunds", Up: migrateRefunds},
+	{Version: 8, Name: "create_invoices", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v8Invoice{}, &v8InvoiceLine{}, &v8InvoiceSequence{})
+	}},
+	{Version: 9, Name: "create_shipments", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v9Shipment{}, &v9ShipmentLine{})
+	}},
+	{Version: 10, Name: "add_shipping_charges", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v10Item{}, &v10Order{}, &v10Invoice{}, &v10Refund{})
+	}},
+	{Version: 11, Name: "create_addresses", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v11Address{}, &v11Order{}, &v11Invoice{})
+	}},
+	{Version: 12, Name: "add_taxes", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v12Item{}, &v12Order{}, &v12OrderItem{}, &v12Invoice{}, &v12InvoiceLine{}, &v12RefundLine{})
+	}},
+	{Version: 13, Name: "create_coupons", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v13Coupon{}, &v13CouponRedemption{}, &v13Order{})
+	}},
+	{Version: 14, Name: "add_refund_statuses", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v14Refund{})
+	}},
+	{Version: 15, Name: "keep_redeemed_coupon_terms", Up: migrateRedeemedCouponTerms},
+	{Version: 16, Name: "add_payment_capture_times", Up: migrateCaptureTimes},
+}
+
+// SchemaVersion is the schema version this build expects, the version of the last migration
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// Migrate applies all pending migrations, each one in its own transaction
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// CurrentVersion returns the version of the last applied migration, 0 if none was applied
+func CurrentVersion(db *gorm.DB) (int, error) {
+	var version int
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// migrateUserEmails normalizes stored emails and makes them unique (case-insensitively)
+// among non-deleted users. Active users sharing an address are merged into the oldest of
+// them: it takes over their orders and their verification, and they are soft deleted.
+// Releases before merged nothing and stopped at duplicates, so databases that applied
+// this migration had none and end up the same.
+func migrateUserEmails(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email))`).Error; err != nil {
+		return err
+	}
+
+	// A duplicate is an active user with the address of an older active one
+	const duplicates = `SELECT d.id FROM users d WHERE d.deleted_at IS NULL AND EXISTS (
+		SELECT 1 FROM users k WHERE k.email = d.email AND k.deleted_at IS NULL AND k.id < d.id)`
+	for _, table := range []string{"orders", "user_orders"} {
+		if err := db.Exec(fmt.Sprintf(`UPDATE %[1]s SET user_id = (
+			SELECT MIN(k.id) FROM users k, users d WHERE d.id = %[1]s.user_id AND k.email = d.email AND k.deleted_at IS NULL
+		) WHERE user_id IN (%[2]s)`, table, duplicates)).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Exec(`UPDATE users SET email_verified_at = (
+		SELECT MIN(d.email_verified_at) FROM users d WHERE d.email = users.email AND d.deleted_at IS NULL
+	) WHERE email_verified_at IS NULL AND deleted_at IS NULL`).Error; err != nil {
+		return err
+	}
+	merged := db.Exec(`UPDATE users SET deleted_at = ? WHERE id IN (SELECT id FROM (`+duplicates+`) AS duplicates)`, time.Now())
+	if merged.Error != nil {
+		return merged.Error
+	}
+	if merged.RowsAffected > 0 {
+		slog.Warn("merged users sharing an email address into the oldest of them", slog.Int64("merged", merged.RowsAffected))
+	}
+
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (LOWER(email)) WHERE deleted_at IS NULL`).Error
+}
+
+// migrateRefunds creates the refund tables and the discount share of order lines. Lines
+// priced before get a share of their order's discount proportional to their price.
+func migrateRefunds(db *gorm.DB) error {
+	if err := db.AutoMigrate(&v7OrderItem{}, &v7Refund{}, &v7RefundLine{}); err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE order_items SET discount = COALESCE(ROUND(CAST(price * quantity * (
+		SELECT CASE WHEN orders.total_price > 0 THEN (orders.total_price - orders.final_price) / orders.total_price ELSE 0 END
+		FROM orders WHERE orders.id = order_items.order_id
+	) AS NUMERIC), 2), 0) WHERE COALESCE(discount, 0) = 0`).Error
+}
+
+// migrateRedeemedCouponTerms adds the terms of the coupon to its redemptions. Redemptions
+// made before get the current terms of their coupon, deleted ones included.
+func migrateRedeemedCouponTerms(db *gorm.DB) error {
+	if err := db.AutoMigrate(&v15CouponRedemption{}); err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE coupon_redemptions SET
+		code = (SELECT code FROM coupons WHERE coupons.id = coupon_redemptions.coupon_id),
+		effect = (SELECT effect FROM coupons WHERE coupons.id = coupon_redemptions.coupon_id),
+		value = (SELECT value FROM coupons WHERE coupons.id = coupon_redemptions.coupon_id),
+		minimum_spend = (SELECT minimum_spend FROM coupons WHERE coupons.id = coupon_redemptions.coupon_id),
+		eligible_item_ids = (SELECT eligible_item_ids FROM coupons WHERE coupons.id = coupon_redemptions.coupon_id)
+	WHERE COALESCE(effect, '') = ''`).Error
+}
+
+// migrateCaptureTimes adds the capture time to payments. Payments captured before take
+// the time of their last successful capture attempt, or of their last update when they
+// have none.
+func migrateCaptureTimes(db *gorm.DB) error {
+	if err := db.AutoMigrate(&v16Payment{}); err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE payments SET captured_at = COALESCE(
+		(SELECT MAX(created_at) FROM payment_attempts
+			WHERE payment_attempts.payment_id = payments.id AND operation = ? AND succeeded),
+		updated_at)
+	WHERE captured_at IS NULL AND status IN ?`, "capture", []string{"Captured", "Refunded"}).Error
+}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // Every connection would get its own in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestMigrateRecordsVersionsAndRunsOnce(t *testing.T) {
+	db := openTestDB(t)
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(db); err != nil {
+			t.Fatalf("migrate run %d: %v", i+1, err)
+		}
+	}
+
+	version, err := CurrentVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("schema version = %d, want %d", version, SchemaVersion)
+	}
+
+	var applied []SchemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("%d migrations recorded, want %d", len(applied), len(migrations))
+	}
+	for i, m := range applied {
+		if m.Version != migrations[i].Version || m.Name != migrations[i].Name {
+			t.Errorf("migration %d recorded as %d %s", migrations[i].Version, m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migration %s has version %d, after %d", migrations[i].Name, migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+// schema returns the SQL of every table and index
+func schema(t *testing.T, db *gorm.DB) map[string]string {
+	t.Helper()
+	var objects []struct{ Name, SQL string }
+	if err := db.Raw(`SELECT name, COALESCE(sql, '') AS sql FROM sqlite_master WHERE type IN ('table', 'index')`).Scan(&objects).Error; err != nil {
+		t.Fatal(err)
+	}
+	sqls := make(map[string]string, len(objects))
+	for _, object := range objects {
+		sqls[object.Name] = object.SQL
+	}
+	return sqls
+}
+
+// The migrations keep their own copies of the tables, a model change without a
+// migration for it shows up as a difference here
+func TestMigrationsMatchTheModels(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	migrated := schema(t, db)
+
+	if err := db.AutoMigrate(
+		&models.Item{}, &models.Order{}, &models.OrderItem{}, &models.User{}, &models.UserOrder{},
+		&models.EmailVerification{}, &models.Address{}, &models.Payment{}, &models.PaymentAttempt{},
+		&models.WebhookEvent{}, &models.Refund{}, &models.RefundLine{}, &models.Invoice{},
+		&models.InvoiceLine{}, &models.InvoiceSequence{}, &models.Shipment{}, &models.ShipmentLine{},
+		&models.Coupon{}, &models.CouponRedemption{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	for name, sql := range schema(t, db) {
+		if migrated[name] != sql {
+			t.Errorf("the models change %s\nmigrated: %s\nmodels:   %s", name, migrated[name], sql)
+		}
+	}
+}
+
+func TestRefundMigrationSharesExistingDiscounts(t *testing.T) {
+	db := openTestDB(t)
+	for _, m := range migrations {
+		if m.Name == "create_refunds" {
+			break
+		}
+		if err := m.Up(db); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+
+	// A 20% discount priced before lines had their own share
+	if err := db.Exec(`INSERT INTO orders (id, user_id, total_price, final_price, status) VALUES (1, 1, 50, 40, 'Paid')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO order_items (order_id, item_id, quantity, price) VALUES (1, 1, 2, 10), (1, 2, 1, 30)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateRefunds(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []models.OrderItem
+	if err := db.Order("id").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Discount != 4 || items[1].Discount != 6 {
+		t.Fatalf("order items after the migration %+v, want discounts of 4 and 6", items)
+	}
+}
+
+func TestRedeemedCouponTermsAreKept(t *testing.T) {
+	db := openTestDB(t)
+	for _, m := range migrations {
+		if m.Name == "keep_redeemed_coupon_terms" {
+			break
+		}
+		if err := m.Up(db); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+
+	// A redemption made before its coupon was deleted
+	if err := db.Exec(`INSERT INTO coupons (id, code, effect, value, minimum_spend, eligible_item_ids, deleted_at) VALUES (1, 'SAVE5', 'fixed', 5, 20, '[2]', CURRENT_TIMESTAMP)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO coupon_redemptions (coupon_id, order_id, user_id) VALUES (1, 1, 1)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateRedeemedCouponTerms(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var redemption models.CouponRedemption
+	if err := db.Take(&redemption).Error; err != nil {
+		t.Fatal(err)
+	}
+	if terms := redemption.Terms(); terms.Code != "SAVE5" || terms.Effect != models.CouponFixed || terms.Value != 5 || terms.MinimumSpend != 20 || len(terms.EligibleItemIDs) != 1 || terms.EligibleItemIDs[0] != 2 {
+		t.Fatalf("terms after the migration = %+v", terms)
+	}
+}
+
+func TestCaptureTimesAreBackfilled(t *testing.T) {
+	db := openTestDB(t)
+	for _, m := range migrations {
+		if m.Name == "add_payment_capture_times" {
+			break
+		}
+		if err := m.Up(db); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+
+	// A payment captured after a declined capture, one captured by a webhook before
+	// attempts were kept and one that is only authorized
+	if err := db.Exec(`INSERT INTO orders (id, user_id, total_price, final_price, status) VALUES (1, 1, 50, 50, 'Paid')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO payments (id, order_id, provider, amount, captured_amount, status, updated_at) VALUES
+		(1, 1, 'mock', 50, 50, 'Captured', '2024-06-05 00:00:00'),
+		(2, 1, 'mock', 50, 50, 'Refunded', '2024-06-03 00:00:00'),
+		(3, 1, 'mock', 50, 0, 'Authorized', '2024-06-03 00:00:00')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO payment_attempts (payment_id, operation, amount, succeeded, created_at) VALUES
+		(1, 'capture', 50, false, '2024-06-01 00:00:00'),
+		(1, 'capture', 50, true, '2024-06-02 00:00:00')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateCaptureTimes(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var payments []models.Payment
+	if err := db.Order("id").Find(&payments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if payments[0].CapturedAt == nil || payments[0].CapturedAt.Day() != 2 {
+		t.Errorf("captured payment captured at %v, want the time of its successful attempt", payments[0].CapturedAt)
+	}
+	if payments[1].CapturedAt == nil || payments[1].CapturedAt.Day() != 3 {
+		t.Errorf("payment without attempts captured at %v, want its last update", payments[1].CapturedAt)
+	}
+	if payments[2].CapturedAt != nil {
+		t.Errorf("authorized payment captured at %v, want nil", payments[2].CapturedAt)
+	}
+}
+
+func TestUserEmailsAreNormalizedAndUniqueAmongActiveUsers(t *testing.T) {
+	db := openTestDB(t)
+	for _, m := range migrations {
+		if m.Name == "unique_user_emails" {
+			break
+		}
+		if err := m.Up(db); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+
+	// A deleted user may share the address of an active one
+	if err := db.Exec(`INSERT INTO users (id, name, email, deleted_at) VALUES
+		(1, 'Ada', ' Ada@Example.com ', NULL),
+		(2, 'Ada', 'ada@example.com', CURRENT_TIMESTAMP)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateUserEmails(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var users []models.User
+	if err := db.Unscoped().Order("id").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Email != "ada@example.com" {
+		t.Fatalf("users after the migration %+v, want the email normalized", users)
+	}
+	if err := db.Exec(`INSERT INTO users (name, email) VALUES ('Imposter', 'ADA@example.com')`).Error; err == nil {
+		t.Fatal("a second active user got the address in another case")
+	}
+	if err := db.Exec(`INSERT INTO users (name, email, deleted_at) VALUES ('Ada', 'ada@example.com', CURRENT_TIMESTAMP)`).Error; err != nil {
+		t.Fatalf("another deleted user with the address: %v", err)
+	}
+}
+
+func TestUserEmailMigrationMergesDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	if err := migrations[0].Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO users (id, name, email, email_verified_at) VALUES
+		(1, 'Ada', 'ada@example.com', NULL),
+		(2, 'Ada', 'ADA@example.com ', CURRENT_TIMESTAMP),
+		(3, 'Grace', 'grace@example.com', NULL)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO orders (id, user_id, status) VALUES (1, 1, 'Pending'), (2, 2, 'Pending'), (3, 3, 'Pending')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO user_orders (user_id, order_id) VALUES (1, 1), (2, 2), (3, 3)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateUserEmails(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var active []models.User
+	if err := db.Order("id").Find(&active).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 || active[0].ID != 1 || active[0].EmailVerifiedAt == nil || active[1].ID != 3 {
+		t.Fatalf("active users %+v, want the oldest Ada verified and Grace", active)
+	}
+	var owners []int
+	if err := db.Raw(`SELECT user_id FROM orders ORDER BY id`).Scan(&owners).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(owners) != 3 || owners[0] != 1 || owners[1] != 1 || owners[2] != 3 {
+		t.Fatalf("order owners %v, want the orders of the duplicate moved to the oldest user", owners)
+	}
+	if err := db.Raw(`SELECT user_id FROM user_orders ORDER BY order_id`).Scan(&owners).Error; err != nil {
+		t.Fatal(err)
+	}
+	if owners[1] != 1 {
+		t.Fatalf("user orders %v, want the duplicate's moved", owners)
+	}
+}
@@ -0,0 +1,443 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The tables as each migration left them. They are copies of the models of the release
+// that added the migration, so that running a migration gives the same schema whatever
+// the models look like today. Migrations that add columns only list those. These types
+// must not be edited once released, a change of the models needs a new migration.
+
+// orderRef and paymentRef are the targets of foreign keys, only their primary key is used
+type orderRef struct{ ID int }
+
+func (orderRef) TableName() string { return "orders" }
+
+type paymentRef struct{ ID int }
+
+func (paymentRef) TableName() string { return "payments" }
+
+// Version 1, create_tables
+
+type v1Item struct {
+	ID          int
+	Name        string
+	Description string
+	Price       float64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt
+}
+
+func (v1Item) TableName() string { return "items" }
+
+type v1Order struct {
+	ID         int
+	UserID     int
+	TotalPrice float64
+	Status     string
+	FinalPrice float64
+	Items      []v1OrderItem `gorm:"foreignKey:OrderID"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt
+}
+
+func (v1Order) TableName() string { return "orders" }
+
+type v1OrderItem struct {
+	ID        int
+	OrderID   int
+	ItemID    int
+	Quantity  int
+	Price     float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func (v1OrderItem) TableName() string { return "order_items" }
+
+type v1User struct {
+	ID              int
+	Name            string
+	Email           string
+	EmailVerifiedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt
+	Orders          []v1Order `gorm:"foreignKey:UserID"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1UserOrder struct {
+	UserID  int
+	OrderID int
+}
+
+func (v1UserOrder) TableName() string { return "user_orders" }
+
+// Version 3, create_email_verifications
+
+type v3EmailVerification struct {
+	ID        int
+	UserID    int `gorm:"index"`
+	Email     string
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v3EmailVerification) TableName() string { return "email_verifications" }
+
+// Version 4, add_user_regions
+
+type v4User struct {
+	Region string `gorm:"not null;default:''"`
+}
+
+func (v4User) TableName() string { return "users" }
+
+// Version 5, create_payments
+
+type v5Payment struct {
+	ID                int
+	OrderID           int       `gorm:"index"`
+	Order             *orderRef `gorm:"constraint:OnDelete:RESTRICT"`
+	Provider          string
+	ProviderReference string `gorm:"index"`
+	Amount            float64
+	CapturedAmount    float64
+	Status            string
+	Attempts          []v5PaymentAttempt `gorm:"foreignKey:PaymentID"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (v5Payment) TableName() string { return "payments" }
+
+type v5PaymentAttempt struct {
+	ID                int
+	PaymentID         int `gorm:"index"`
+	Operation         string
+	Amount            float64
+	Succeeded         bool
+	ProviderReference string
+	ErrorCode         string
+	ErrorMessage      string
+	CreatedAt         time.Time
+}
+
+func (v5PaymentAttempt) TableName() string { return "payment_attempts" }
+
+// Version 6, create_webhook_events
+
+type v6Payment struct {
+	RefundedAmount float64
+}
+
+func (v6Payment) TableName() string { return "payments" }
+
+type v6PaymentAttempt struct {
+	EventID string
+}
+
+func (v6PaymentAttempt) TableName() string { return "payment_attempts" }
+
+type v6WebhookEvent struct {
+	ID        int
+	Provider  string `gorm:"uniqueIndex:idx_webhook_events_provider_event"`
+	EventID   string `gorm:"uniqueIndex:idx_webhook_events_provider_event"`
+	Type      string
+	PaymentID int `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (v6WebhookEvent) TableName() string { return "webhook_events" }
+
+// Version 7, create_refunds
+
+type v7OrderItem struct {
+	Discount float64 `gorm:"not null;default:0"`
+}
+
+func (v7OrderItem) TableName() string { return "order_items" }
+
+type v7Refund struct {
+	ID                int
+	OrderID           int         `gorm:"index"`
+	PaymentID         int         `gorm:"index"`
+	Payment           *paymentRef `gorm:"constraint:OnDelete:RESTRICT"`
+	Amount            float64
+	Reason            string
+	ProviderReference string         `gorm:"index"`
+	Lines             []v7RefundLine `gorm:"foreignKey:RefundID"`
+	CreatedAt         time.Time
+}
+
+func (v7Refund) TableName() string { return "refunds" }
+
+type v7RefundLine struct {
+	ID          int
+	RefundID    int `gorm:"index"`
+	OrderItemID int `gorm:"index"`
+	ItemID      int
+	Quantity    int
+	Price       float64
+	Discount    float64
+	Amount      float64
+}
+
+func (v7RefundLine) TableName() string { return "refund_lines" }
+
+// Version 8, create_invoices
+
+type v8Invoice struct {
+	ID            int
+	Number        string    `gorm:"uniqueIndex"`
+	Year          int       `gorm:"uniqueIndex:idx_invoices_year_sequence"`
+	Sequence      int       `gorm:"uniqueIndex:idx_invoices_year_sequence"`
+	OrderID       int       `gorm:"uniqueIndex"`
+	Order         *orderRef `gorm:"constraint:OnDelete:RESTRICT"`
+	UserID        int
+	CustomerName  string
+	CustomerEmail string
+	Subtotal      float64
+	Discount      float64
+	Total         float64
+	IssuedAt      time.Time
+	Lines         []v8InvoiceLine `gorm:"foreignKey:InvoiceID"`
+	CreatedAt     time.Time
+}
+
+func (v8Invoice) TableName() string { return "invoices" }
+
+type v8InvoiceLine struct {
+	ID          int
+	InvoiceID   int `gorm:"index"`
+	ItemID      int
+	Description string
+	Quantity    int
+	UnitPrice   float64
+	Discount    float64
+	Amount      float64
+}
+
+func (v8InvoiceLine) TableName() string { return "invoice_lines" }
+
+type v8InvoiceSequence struct {
+	Year       int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null;default:0"`
+}
+
+func (v8InvoiceSequence) TableName() string { return "invoice_sequences" }
+
+// Version 9, create_shipments
+
+type v9Shipment struct {
+	ID             int
+	OrderID        int       `gorm:"index"`
+	Order          *orderRef `gorm:"constraint:OnDelete:RESTRICT"`
+	Carrier        string    `gorm:"uniqueIndex:idx_shipments_carrier_tracking"`
+	TrackingNumber string    `gorm:"uniqueIndex:idx_shipments_carrier_tracking"`
+	Status         string
+	ShippedAt      time.Time
+	DeliveredAt    *time.Time
+	Lines          []v9ShipmentLine `gorm:"foreignKey:ShipmentID"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v9Shipment) TableName() string { return "shipments" }
+
+type v9ShipmentLine struct {
+	ID          int
+	ShipmentID  int `gorm:"index"`
+	OrderItemID int `gorm:"index"`
+	ItemID      int
+	Quantity    int
+}
+
+func (v9ShipmentLine) TableName() string { return "shipment_lines" }
+
+// Version 10, add_shipping_charges
+
+type v10Item struct {
+	Weight float64 `gorm:"not null;default:0"`
+}
+
+func (v10Item) TableName() string { return "items" }
+
+type v10Order struct {
+	Shipping struct {
+		Method string
+		Zone   string
+		Price  float64 `gorm:"not null;default:0"`
+	} `gorm:"embedded;embeddedPrefix:shipping_"`
+}
+
+func (v10Order) TableName() string { return "orders" }
+
+type v10Invoice struct {
+	Shipping float64 `gorm:"not null;default:0"`
+}
+
+func (v10Invoice) TableName() string { return "invoices" }
+
+type v10Refund struct {
+	Shipping float64 `gorm:"not null;default:0"`
+}
+
+func (v10Refund) TableName() string { return "refunds" }
+
+// Version 11, create_addresses
+
+type v11PostalAddress struct {
+	Name       string
+	Line1      string
+	Line2      string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+type v11Address struct {
+	ID              int
+	UserID          int              `gorm:"index"`
+	Address         v11PostalAddress `gorm:"embedded"`
+	DefaultShipping bool             `gorm:"not null;default:false"`
+	DefaultBilling  bool             `gorm:"not null;default:false"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt
+}
+
+func (v11Address) TableName() string { return "addresses" }
+
+type v11Order struct {
+	ShippingAddress v11PostalAddress `gorm:"embedded;embeddedPrefix:shipping_address_"`
+	BillingAddress  v11PostalAddress `gorm:"embedded;embeddedPrefix:billing_address_"`
+}
+
+func (v11Order) TableName() string { return "orders" }
+
+type v11Invoice struct {
+	BillingAddress v11PostalAddress `gorm:"embedded;embeddedPrefix:billing_address_"`
+}
+
+func (v11Invoice) TableName() string { return "invoices" }
+
+// Version 12, add_taxes
+
+type v12Item struct {
+	TaxCategory string `gorm:"not null;default:'standard'"`
+}
+
+func (v12Item) TableName() string { return "items" }
+
+type v12Order struct {
+	ShippingTax      float64 `gorm:"not null;default:0"`
+	Tax              float64 `gorm:"not null;default:0"`
+	PricesIncludeTax bool    `gorm:"not null;default:false"`
+}
+
+func (v12Order) TableName() string { return "orders" }
+
+type v12OrderItem struct {
+	TaxRate float64 `gorm:"not null;default:0"`
+	Tax     float64 `gorm:"not null;default:0"`
+}
+
+func (v12OrderItem) TableName() string { return "order_items" }
+
+type v12Invoice struct {
+	Tax              float64 `gorm:"not null;default:0"`
+	PricesIncludeTax bool    `gorm:"not null;default:false"`
+}
+
+func (v12Invoice) TableName() string { return "invoices" }
+
+type v12InvoiceLine struct {
+	TaxRate float64 `gorm:"not null;default:0"`
+	Tax     float64 `gorm:"not null;default:0"`
+}
+
+func (v12InvoiceLine) TableName() string { return "invoice_lines" }
+
+type v12RefundLine struct {
+	Tax float64 `gorm:"not null;default:0"`
+}
+
+func (v12RefundLine) TableName() string { return "refund_lines" }
+
+// Version 13, create_coupons
+
+type v13Coupon struct {
+	ID                    int
+	Code                  string `gorm:"uniqueIndex"`
+	Effect                string
+	Value                 float64
+	StartsAt              *time.Time
+	EndsAt                *time.Time
+	MinimumSpend          float64
+	EligibleItemIDs       []int `gorm:"serializer:json"`
+	MaxRedemptions        int
+	MaxRedemptionsPerUser int
+	Redemptions           int `gorm:"not null;default:0"`
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	DeletedAt             gorm.DeletedAt
+}
+
+func (v13Coupon) TableName() string { return "coupons" }
+
+type v13CouponRedemption struct {
+	ID        int
+	CouponID  int `gorm:"index"`
+	OrderID   int `gorm:"uniqueIndex"`
+	UserID    int `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (v13CouponRedemption) TableName() string { return "coupon_redemptions" }
+
+type v13Order struct {
+	CouponCode     string  `gorm:"index"`
+	CouponDiscount float64 `gorm:"not null;default:0"`
+}
+
+func (v13Order) TableName() string { return "orders" }
+
+// Version 14, add_refund_statuses
+
+type v14Refund struct {
+	Status string `gorm:"not null;default:'Succeeded'"`
+}
+
+func (v14Refund) TableName() string { return "refunds" }
+
+// Version 15, keep_redeemed_coupon_terms
+
+type v15CouponRedemption struct {
+	Code            string
+	Effect          string
+	Value           float64
+	MinimumSpend    float64
+	EligibleItemIDs []int `gorm:"serializer:json"`
+}
+
+func (v15CouponRedemption) TableName() string { return "coupon_redemptions" }
+
+// Version 16, add_payment_capture_times
+
+type v16Payment struct {
+	CapturedAt *time.Time
+}
+
+func (v16Payment) TableName() string { return "payments" }
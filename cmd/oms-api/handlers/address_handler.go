@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// CreateAddressByUserId adds an address to the address book of a user
+func (a *Application) CreateAddressByUserId(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	address, err := a.Addresses.Create(c.Request.Context(), id, addressInput(req))
+	if err != nil {
+		renderAddressError(c, err, "Failed to create address")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Address added successfully",
+		"address": address,
+	})
+}
+
+// GetAddressesByUserId lists the address book of a user
+func (a *Application) GetAddressesByUserId(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	addresses, err := a.Addresses.List(c.Request.Context(), id)
+	if err != nil {
+		renderAddressError(c, err, "Unable to fetch addresses")
+		return
+	}
+	if addresses == nil {
+		addresses = []models.Address{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"addresses": addresses,
+	})
+}
+
+// UpdateAddressByAddressId replaces an address and its default flags
+func (a *Application) UpdateAddressByAddressId(c *gin.Context) {
+	id, ok := pathID(c, "address")
+	if !ok {
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	address, err := a.Addresses.Update(c.Request.Context(), id, addressInput(req))
+	if err != nil {
+		renderAddressError(c, err, "Failed to update address")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Address updated successfully",
+		"address": address,
+	})
+}
+
+// DeleteAddressByAddressId removes an address from its address book, orders keep their copy
+func (a *Application) DeleteAddressByAddressId(c *gin.Context) {
+	id, ok := pathID(c, "address")
+	if !ok {
+		return
+	}
+
+	if err := a.Addresses.Delete(c.Request.Context(), id); err != nil {
+		renderAddressError(c, err, "Failed to delete address")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Address deleted successfully",
+	})
+}
+
+// addressInput maps an address request to an address book entry
+func addressInput(req models.AddressRequest) service.AddressInput {
+	return service.AddressInput{Address: req.PostalAddress(), DefaultShipping: req.DefaultShipping, DefaultBilling: req.DefaultBilling}
+}
+
+// addressChoice maps the address ID or inline address of an order request to an address choice
+func addressChoice(id int, req *models.AddressRequest) service.AddressChoice {
+	choice := service.AddressChoice{ID: id}
+	if req != nil {
+		postal := req.PostalAddress()
+		choice.Address = &postal
+	}
+	return choice
+}
+
+// renderAddressError maps the errors of the address service to problems, unknown errors become internal errors with message
+func renderAddressError(c *gin.Context, err error, message string) {
+	var invalid *service.InvalidAddressError
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
+	case errors.Is(err, service.ErrAddressNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeAddressNotFound, "Address not found"))
+	case errors.As(err, &invalid):
+		appErr := apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed, "One or more fields are invalid")
+		appErr.Fields = []apperrors.FieldError{{Field: invalid.Field, Rule: invalid.Rule, Message: invalid.Message}}
+		apperrors.Render(c, appErr)
+	default:
+		apperrors.Render(c, apperrors.Internal(err, message))
+	}
+}
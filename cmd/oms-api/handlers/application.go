@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// Application holds the dependencies of the handlers, which are its methods
+type Application struct {
+	Store             repository.Store
+	Orders            *service.OrderService
+	Payments          *service.PaymentService
+	Shipments         *service.ShipmentService
+	Addresses         *service.AddressService
+	Coupons           *service.CouponService
+	EmailVerification *EmailVerification
+	Webhooks          *Webhooks
+	Readiness         *health.Readiness
+	// SchemaVersion reports the applied schema version for /version, it may be nil
+	SchemaVersion func(ctx context.Context) (int, error)
+}
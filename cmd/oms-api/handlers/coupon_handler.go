@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// CreateCoupon creates a coupon customers can enter on their orders
+func (a *Application) CreateCoupon(c *gin.Context) {
+	var req models.CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	coupon, err := a.Coupons.Create(c.Request.Context(), couponInput(req))
+	if err != nil {
+		renderCouponError(c, err, "Failed to create coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Coupon created successfully",
+		"coupon":  coupon,
+	})
+}
+
+// GetCoupons lists the coupons that have not been deleted with their redemption counts
+func (a *Application) GetCoupons(c *gin.Context) {
+	coupons, err := a.Coupons.List(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch coupons"))
+		return
+	}
+	if coupons == nil {
+		coupons = []models.Coupon{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coupons": coupons,
+	})
+}
+
+// UpdateCouponByCouponId replaces the terms of a coupon, its code is kept
+func (a *Application) UpdateCouponByCouponId(c *gin.Context) {
+	id, ok := pathID(c, "coupon")
+	if !ok {
+		return
+	}
+
+	var req models.CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	coupon, err := a.Coupons.Update(c.Request.Context(), id, couponInput(req))
+	if err != nil {
+		renderCouponError(c, err, "Failed to update coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Coupon updated successfully",
+		"coupon":  coupon,
+	})
+}
+
+// DeleteCouponByCouponId ends a coupon, orders that redeemed it keep their discount
+func (a *Application) DeleteCouponByCouponId(c *gin.Context) {
+	id, ok := pathID(c, "coupon")
+	if !ok {
+		return
+	}
+
+	if err := a.Coupons.Delete(c.Request.Context(), id); err != nil {
+		renderCouponError(c, err, "Failed to delete coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Coupon deleted successfully",
+	})
+}
+
+// couponInput maps a coupon request to the terms of a coupon
+func couponInput(req models.CouponRequest) service.CouponInput {
+	return service.CouponInput{
+		Code:                  req.Code,
+		Effect:                req.Effect,
+		Value:                 req.Value,
+		StartsAt:              req.StartsAt,
+		EndsAt:                req.EndsAt,
+		MinimumSpend:          req.MinimumSpend,
+		EligibleItemIDs:       req.EligibleItemIDs,
+		MaxRedemptions:        req.MaxRedemptions,
+		MaxRedemptionsPerUser: req.MaxRedemptionsPerUser,
+	}
+}
+
+// renderCouponError maps the errors of the coupon service to problems, unknown errors become internal errors with message
+func renderCouponError(c *gin.Context, err error, message string) {
+	var invalid *service.InvalidCouponError
+	switch {
+	case errors.Is(err, service.ErrCouponNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeCouponNotFound, "Coupon not found"))
+	case errors.Is(err, service.ErrCouponCodeTaken):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeCouponCodeTaken, "Coupon code already in use"))
+	case errors.As(err, &invalid):
+		appErr := apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed, "One or more fields are invalid")
+		appErr.Fields = []apperrors.FieldError{{Field: invalid.Field, Rule: invalid.Rule, Message: invalid.Message}}
+		apperrors.Render(c, appErr)
+	default:
+		apperrors.Render(c, apperrors.Internal(err, message))
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// EmailVerification configures how verification emails are issued
+type EmailVerification struct {
+	Mailer    mailer.Sender
+	PublicURL string        // Base URL of the verification link
+	TokenTTL  time.Duration // How long a token stays valid
+}
+
+// SendVerificationEmail issues a new verification token for the user's current email address
+func (a *Application) SendVerificationEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	user, err := a.Store.Users().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
+			return
+		}
+		apperrors.Render(c, apperrors.Internal(err, "Failed to fetch user"))
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeEmailAlreadyVerified, "Email is already verified"))
+		return
+	}
+
+	if err := a.sendVerificationEmail(ctx, user); err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Failed to send verification email"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+// VerifyEmail redeems a verification token and marks the user's email as verified
+func (a *Application) VerifyEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	token := c.Query("token")
+	if token == "" {
+		apperrors.Render(c, apperrors.BadRequest(apperrors.CodeInvalidVerificationToken, "Verification token is missing"))
+		return
+	}
+	invalidToken := apperrors.BadRequest(apperrors.CodeInvalidVerificationToken, "Verification token is invalid or has already been used")
+
+	err := a.Store.Transaction(ctx, func(tx repository.Store) error {
+		// Look up the unused token by its hash
+		verification, err := tx.EmailVerifications().FindUnused(ctx, hashVerificationToken(token))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return invalidToken
+			}
+			return apperrors.Internal(err, "Failed to fetch verification token")
+		}
+
+		now := time.Now()
+		if now.After(verification.ExpiresAt) {
+			return apperrors.New(http.StatusGone, apperrors.CodeVerificationTokenExpired, "Verification token has expired")
+		}
+
+		// The token only verifies the address it was issued for
+		user, err := tx.Users().Get(ctx, verification.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return invalidToken
+			}
+			return apperrors.Internal(err, "Failed to fetch user")
+		}
+		if user.Email != verification.Email {
+			return apperrors.BadRequest(apperrors.CodeInvalidVerificationToken, "Verification token was issued for a different email address")
+		}
+
+		// Mark the token as used, a concurrent redemption makes this fail
+		if err := tx.EmailVerifications().MarkUsed(ctx, verification.ID, now); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return invalidToken
+			}
+			return apperrors.Internal(err, "Failed to redeem verification token")
+		}
+
+		user.EmailVerifiedAt = &now
+		if err := tx.Users().Update(ctx, &user, "email_verified_at"); err != nil {
+			return apperrors.Internal(err, "Failed to verify email")
+		}
+		return nil
+	})
+	if err != nil {
+		apperrors.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+// sendVerificationEmail stores a new token for the user's current email and mails the verification link
+func (a *Application) sendVerificationEmail(ctx context.Context, user models.User) error {
+	ev := a.EmailVerification
+	token, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashVerificationToken(token),
+		ExpiresAt: time.Now().Add(ev.TokenTTL),
+	}
+	if err := a.Store.EmailVerifications().Create(ctx, &verification); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/VerifyEmail?token=%s", ev.PublicURL, url.QueryEscape(token))
+	return ev.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, link, ev.TokenTTL),
+	})
+}
+
+// sendVerificationEmailBestEffort is used after user writes, a failed delivery can be retried with SendVerificationEmail
+func (a *Application) sendVerificationEmailBestEffort(ctx context.Context, user models.User) {
+	if err := a.sendVerificationEmail(ctx, user); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "sending verification email failed", slog.Int("user_id", user.ID), slog.Any("error", err))
+	}
+}
+
+func newVerificationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+)
+
+// outbox keeps the messages sent through it
+type outbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// lastToken returns the token of the last verification link mailed to to
+func (o *outbox) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		msg := o.messages[i]
+		if msg.To != to {
+			continue
+		}
+		start := strings.Index(msg.Body, "http://localhost/api/VerifyEmail?")
+		if start < 0 {
+			t.Fatalf("message to %s has no verification link: %q", to, msg.Body)
+		}
+		link, err := url.Parse(strings.Fields(msg.Body[start:])[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("no message sent to %s", to)
+	return ""
+}
+
+// verify runs VerifyEmail for token
+func verify(app *Application, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/VerifyEmail?token="+url.QueryEscape(token), nil)
+	app.VerifyEmail(c)
+	return w
+}
+
+func TestVerificationTokens(t *testing.T) {
+	app := newTestApp(t)
+	mail := &outbox{}
+	app.EmailVerification.Mailer = mail
+
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"Ada@Example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	token := mail.lastToken(t, "ada@example.com")
+
+	// Only the hash of the token is stored
+	if _, err := app.Store.EmailVerifications().FindUnused(context.Background(), token); err == nil {
+		t.Fatal("the token was found by its plain value")
+	}
+	if _, err := app.Store.EmailVerifications().FindUnused(context.Background(), hashVerificationToken(token)); err != nil {
+		t.Fatalf("the token was not stored by its hash: %v", err)
+	}
+
+	if w := verify(app, "forged"); w.Code != http.StatusBadRequest {
+		t.Fatalf("verify a forged token: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := verify(app, token); w.Code != http.StatusOK {
+		t.Fatalf("verify: status %d: %s", w.Code, w.Body.String())
+	}
+	user, err := app.Store.Users().Get(context.Background(), 1)
+	if err != nil || user.EmailVerifiedAt == nil {
+		t.Fatalf("user after verifying %+v, %v, want the email verified", user, err)
+	}
+	if w := verify(app, token); w.Code != http.StatusBadRequest {
+		t.Fatalf("verify a used token: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(app.SendVerificationEmail, http.MethodPost, "1", ""); w.Code != http.StatusConflict {
+		t.Fatalf("send to a verified user: status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestExpiredVerificationTokens(t *testing.T) {
+	app := newTestApp(t)
+	mail := &outbox{}
+	app.EmailVerification.Mailer = mail
+	app.EmailVerification.TokenTTL = -time.Minute
+
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"ada@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	expired := mail.lastToken(t, "ada@example.com")
+	if w := verify(app, expired); w.Code != http.StatusGone {
+		t.Fatalf("verify an expired token: status %d, want %d", w.Code, http.StatusGone)
+	}
+
+	// A new token can be sent and redeemed
+	app.EmailVerification.TokenTTL = time.Hour
+	if w := serve(app.SendVerificationEmail, http.MethodPost, "1", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("send again: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := verify(app, mail.lastToken(t, "ada@example.com")); w.Code != http.StatusOK {
+		t.Fatalf("verify the new token: status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDuplicateEmailsAfterNormalization(t *testing.T) {
+	app := newTestApp(t)
+
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"ada@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Grace","email":"grace@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	for _, email := range []string{"ADA@example.com", "ada@Example.COM"} {
+		if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"`+email+`"}`); w.Code != http.StatusConflict {
+			t.Errorf("create with %q: status %d, want %d", email, w.Code, http.StatusConflict)
+		}
+	}
+	if w := serve(app.UpdateUserDetails, http.MethodPut, "2", `{"name":"Grace","email":"Ada@Example.com"}`); w.Code != http.StatusConflict {
+		t.Errorf("update to a taken email: status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/version"
+)
+
+// Healthz reports that the process is alive, it does not look at any dependency
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the service can handle traffic, answering 503 with the
+// failed checks when a dependency is down or the service is shutting down
+func (a *Application) Readyz(c *gin.Context) {
+	if a.Readiness.ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	ready, results := a.Readiness.Check(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "checks": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": results})
+}
+
+// Version reports the build of the running binary and the schema versions it expects and finds
+func (a *Application) Version(c *gin.Context) {
+	info := version.Get()
+	response := gin.H{
+		"commit":                  info.Commit,
+		"build_time":              info.BuildTime,
+		"go_version":              info.GoVersion,
+		"expected_schema_version": database.SchemaVersion,
+	}
+
+	// The applied version is informational, /version stays available while the database is down
+	if a.SchemaVersion != nil {
+		if applied, err := a.SchemaVersion(c.Request.Context()); err == nil {
+			response["schema_version"] = applied
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/invoice"
+)
+
+// invoiceFormats are the representations of an invoice, JSON when the client accepts anything
+var invoiceFormats = []string{binding.MIMEJSON, invoice.ContentTypeHTML, invoice.ContentTypePDF}
+
+// GetOrderInvoice returns the invoice of a confirmed order as JSON, as an HTML page or as
+// a PDF document, following the Accept header
+func (a *Application) GetOrderInvoice(c *gin.Context) {
+	c.Header("Vary", "Accept")
+	format := c.NegotiateFormat(invoiceFormats...)
+	if format == "" {
+		apperrors.Render(c, apperrors.New(http.StatusNotAcceptable, apperrors.CodeNotAcceptable,
+			fmt.Sprintf("Invoices are available as %s, %s and %s", invoiceFormats[0], invoiceFormats[1], invoiceFormats[2])))
+		return
+	}
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	inv, err := a.Orders.Invoice(c.Request.Context(), id)
+	if err != nil {
+		renderPaymentError(c, err, "Unable to fetch invoice")
+		return
+	}
+
+	if format == binding.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{
+			"invoice": inv,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := format
+	if format == invoice.ContentTypePDF {
+		err = invoice.PDF(&buf, inv)
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, inv.Number))
+	} else {
+		err = invoice.HTML(&buf, inv)
+		contentType += "; charset=utf-8"
+	}
+	if err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Unable to render invoice"))
+		return
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -1,38 +1,29 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
 	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
-	"gorm.io/gorm"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/utils"
 )
 
-// var db *sql.DB // Global variable to hold the database connection
-//
-
-func AddItem(c *gin.Context, db *gorm.DB) {
-	var newItem models.Item
-	// Bind incoming JSON data to the Item struct
-	if err := c.ShouldBindJSON(&newItem); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+func (a *Application) AddItem(c *gin.Context) {
+	var req models.ItemRequest
+	// Bind and validate the incoming JSON data (non-blank fields, positive price)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
 		return
 	}
+	newItem := models.Item{Name: req.Name, Description: req.Description, Price: req.Price, Weight: req.Weight, TaxCategory: req.Category()}
 
-	// Validate the fields (check for empty strings or invalid price)
-	if newItem.Name == "" || newItem.Description == "" || newItem.Price <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "All fields must be filled and price must be positive"})
-		return
-	}
-
-	// Log the item data to ensure it's being received correctly
-	fmt.Println(newItem.Name, newItem.Description, newItem.Price)
-
-	// Use GORM to insert the new item into the database
-	if err := db.Create(&newItem).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert item"})
+	// Insert the new item
+	if err := a.Store.Items().Create(c.Request.Context(), &newItem); err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Failed to insert item"))
 		return
 	}
 
@@ -44,30 +35,35 @@ func AddItem(c *gin.Context, db *gorm.DB) {
 }
 
 // GetItems responds with the list of all items as JSON
-func GetItems(c *gin.Context, db *gorm.DB) {
-	var items []models.Item
-
-	// Fetch all non-deleted items from the database
-	if err := db.Where("deleted_at IS NULL").Find(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch data"})
+func (a *Application) GetItems(c *gin.Context) {
+	// Fetch all non-deleted items
+	items, err := a.Store.Items().List(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch data"))
 		return
 	}
+	if items == nil {
+		items = []models.Item{}
+	}
 
 	c.JSON(http.StatusOK, items)
 }
 
 // GetItemByItemId retrieves a single item by its ID
-func GetItemByItemId(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	var item models.Item
+func (a *Application) GetItemByItemId(c *gin.Context) {
+	id, ok := pathID(c, "item")
+	if !ok {
+		return
+	}
 
 	// Fetch the item by ID
-	if err := db.Where("id = ? AND deleted_at IS NULL", id).First(&item).Error; err != nil {
+	item, err := a.Store.Items().Get(c.Request.Context(), id)
+	if err != nil {
 		// Handle different error cases
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		if errors.Is(err, repository.ErrNotFound) {
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeItemNotFound, "Item not found"))
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch data"})
+			apperrors.Render(c, apperrors.Internal(err, "Unable to fetch data"))
 		}
 		return
 	}
@@ -76,30 +72,35 @@ func GetItemByItemId(c *gin.Context, db *gorm.DB) {
 }
 
 // UpdateItem updates an existing item
-func UpdateItemByItemId(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	var updatedItem models.Item
+func (a *Application) UpdateItemByItemId(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c, "item")
+	if !ok {
+		return
+	}
+	var updatedItem models.ItemRequest
 	if err := c.ShouldBindJSON(&updatedItem); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apperrors.Render(c, apperrors.Binding(err))
 		return
 	}
 
 	// Find the item by ID
-	var item models.Item
-	if err := db.First(&item, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	item, err := a.Store.Items().Get(ctx, id)
+	if err != nil {
+		renderItemError(c, err, "Unable to fetch data")
 		return
 	}
 
-	// Update the item's fields
+	// Update the item's fields, UpdatedAt is maintained by the repository
 	item.Name = updatedItem.Name
 	item.Description = updatedItem.Description
 	item.Price = updatedItem.Price
-	item.UpdatedAt = time.Now() // Ensure UpdatedAt is set to the current time
+	item.Weight = updatedItem.Weight
+	item.TaxCategory = updatedItem.Category()
 
 	// Save the updated item
-	if err := db.Save(&item).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item"})
+	if err := a.Store.Items().Update(ctx, &item); err != nil {
+		renderItemError(c, err, "Failed to update item")
 		return
 	}
 
@@ -108,27 +109,86 @@ func UpdateItemByItemId(c *gin.Context, db *gorm.DB) {
 	})
 }
 
-// DeleteItem deletes an item
-func DeleteItemByItemId(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
+// PatchItemByItemId applies a JSON merge patch to the item, only the fields present in the patch are changed
+func (a *Application) PatchItemByItemId(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c, "item")
+	if !ok {
+		return
+	}
+	patch, err := utils.ReadMergePatch(c, []string{"name", "description", "price", "weight", "tax_category"})
+	if err != nil {
+		apperrors.Render(c, err)
+		return
+	}
 
 	// Find the item by ID
-	var item models.Item
-	if err := db.First(&item, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	item, err := a.Store.Items().Get(ctx, id)
+	if err != nil {
+		renderItemError(c, err, "Unable to fetch data")
+		return
+	}
+
+	// Merge the patch into the current values and validate the result
+	input := models.ItemRequest{Name: item.Name, Description: item.Description, Price: item.Price, Weight: item.Weight, TaxCategory: item.TaxCategory}
+	if err := patch.Apply(&input); err != nil {
+		apperrors.Render(c, err)
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		apperrors.Render(c, apperrors.Validation(err))
 		return
 	}
 
-	// Check if the item is already soft-deleted
-	if item.DeletedAt.Valid {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Item is already deleted"})
+	// Only write the columns that are present in the patch
+	var columns []string
+	if patch.Has("name") {
+		item.Name = input.Name
+		columns = append(columns, "name")
+	}
+	if patch.Has("description") {
+		item.Description = input.Description
+		columns = append(columns, "description")
+	}
+	if patch.Has("price") {
+		item.Price = input.Price
+		columns = append(columns, "price")
+	}
+	if patch.Has("weight") {
+		item.Weight = input.Weight
+		columns = append(columns, "weight")
+	}
+	if patch.Has("tax_category") {
+		item.TaxCategory = input.Category()
+		columns = append(columns, "tax_category")
+	}
+	if len(columns) > 0 {
+		if err := a.Store.Items().Update(ctx, &item, columns...); err != nil {
+			renderItemError(c, err, "Failed to update item")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item updated successfully",
+		"item":    item,
+	})
+}
+
+// DeleteItem deletes an item
+func (a *Application) DeleteItemByItemId(c *gin.Context) {
+	id, ok := pathID(c, "item")
+	if !ok {
 		return
 	}
 
-	// Proceed with soft delete (setting deleted_at to the current time)
-	item.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
-	if err := db.Save(&item).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete item"})
+	// Soft delete the item, deleting it twice is a conflict
+	if err := a.Store.Items().Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrAlreadyDeleted) {
+			apperrors.Render(c, apperrors.Conflict(apperrors.CodeItemAlreadyDeleted, "Item is already deleted"))
+			return
+		}
+		renderItemError(c, err, "Failed to delete item")
 		return
 	}
 
@@ -136,3 +196,12 @@ func DeleteItemByItemId(c *gin.Context, db *gorm.DB) {
 		"message": "Item deleted successfully",
 	})
 }
+
+// renderItemError renders a missing item as 404 and anything else as an internal error with message
+func renderItemError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeItemNotFound, "Item not found"))
+		return
+	}
+	apperrors.Render(c, apperrors.Internal(err, message))
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/utils"
+)
+
+// newTestApp returns an application on an empty in-memory store
+func newTestApp(t *testing.T) *Application {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	utils.SetupValidator()
+	store := repository.NewMemoryStore()
+	return &Application{
+		Store:             store,
+		Orders:            service.NewOrderService(store),
+		EmailVerification: &EmailVerification{Mailer: mailer.LogSender{}, PublicURL: "http://localhost", TokenTTL: time.Hour},
+	}
+}
+
+// serve runs handler for a request with the given id path parameter and JSON body
+func serve(handler gin.HandlerFunc, method, id, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if id != "" {
+		c.Params = gin.Params{{Key: "id", Value: id}}
+	}
+	handler(c)
+	return w
+}
+
+func TestCreateOrderPricesItems(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	shirt := models.Item{Name: "Shirt", Description: "Cotton", Price: 10}
+	if err := app.Store.Items().Create(ctx, &shirt); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(app.CreateOrder, http.MethodPost, "", `{"user_id":1,"items":[{"item_id":1,"quantity":10}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct{ Order models.Order }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Order.TotalPrice != 100 {
+		t.Errorf("total price = %v, want 100", resp.Order.TotalPrice)
+	}
+	// 10 units of one item earn the volume discount, other discounts depend on the date
+	if resp.Order.FinalPrice > 90 {
+		t.Errorf("final price = %v, want the volume discount applied", resp.Order.FinalPrice)
+	}
+
+	stored, err := app.Store.Orders().Get(ctx, resp.Order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Items) != 1 || stored.Items[0].Price != 10 {
+		t.Fatalf("stored items = %+v", stored.Items)
+	}
+}
+
+func TestCreateOrderRejectsDeletedItem(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	shirt := models.Item{Name: "Shirt", Description: "Cotton", Price: 10}
+	if err := app.Store.Items().Create(ctx, &shirt); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Store.Items().Delete(ctx, shirt.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(app.CreateOrder, http.MethodPost, "", `{"user_id":1,"items":[{"item_id":1,"quantity":1}]}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body.String())
+	}
+	if orders, _ := app.Store.Orders().List(ctx); len(orders) != 0 {
+		t.Fatalf("%d orders stored for a rejected request", len(orders))
+	}
+}
+
+func TestDeleteOrderCancelsIt(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	order := models.Order{UserID: 1, Status: "Pending"}
+	if err := app.Store.Orders().Create(ctx, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(app.DeleteOrderByOrderId, http.MethodDelete, "1", ""); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(app.DeleteOrderByOrderId, http.MethodDelete, "1", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("deleting again: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(app.UpdateOrderStatusByOrderId, http.MethodPut, "1", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("confirming a deleted order: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// StartPaymentByOrderId authorizes the final price of an order with the payment provider
+func (a *Application) StartPaymentByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	var req models.StartPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	payment, err := a.Payments.Start(c.Request.Context(), id, service.StartPaymentInput{Provider: req.Provider, Method: req.PaymentMethod})
+	if err != nil {
+		renderPaymentError(c, err, "Failed to start payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment has been authorized",
+		"payment": payment,
+	})
+}
+
+// CapturePaymentByOrderId captures the authorized payment of a confirmed order, the order is paid afterwards
+func (a *Application) CapturePaymentByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	payment, err := a.Payments.Capture(c.Request.Context(), id)
+	if err != nil {
+		renderPaymentError(c, err, "Failed to capture payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment has been captured and the order is paid",
+		"payment": payment,
+	})
+}
+
+// GetPaymentsByOrderId lists the payments of an order with every call made to the provider
+func (a *Application) GetPaymentsByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	payments, err := a.Payments.List(c.Request.Context(), id)
+	if err != nil {
+		renderPaymentError(c, err, "Unable to fetch payments")
+		return
+	}
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payments": payments,
+	})
+}
+
+// renderPaymentError maps the payment errors of the services to problems, other errors are rendered as order errors
+func renderPaymentError(c *gin.Context, err error, message string) {
+	var declined *service.PaymentDeclinedError
+	var providerErr *service.ProviderError
+	var unknownProvider *service.UnknownProviderError
+	var notConfirmed *service.NotConfirmedError
+	switch {
+	case errors.As(err, &declined):
+		apperrors.Render(c, apperrors.New(http.StatusPaymentRequired, apperrors.CodePaymentDeclined, fmt.Sprintf("Payment was declined: %s (%s)", declined.Message, declined.Code)))
+	case errors.As(err, &providerErr):
+		apperrors.Render(c, apperrors.Wrap(err, http.StatusBadGateway, apperrors.CodePaymentProviderError, "The payment provider could not be reached"))
+	case errors.As(err, &unknownProvider):
+		apperrors.Render(c, apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeUnknownPaymentProvider, fmt.Sprintf("Unknown payment provider: %s", unknownProvider.Provider)))
+	case errors.As(err, &notConfirmed):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeOrderNotConfirmed, fmt.Sprintf("Order status is not 'Confirm' (current status: %s)", notConfirmed.Status)))
+	case errors.Is(err, service.ErrNoAuthorizedPayment):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodePaymentNotAuthorized, "Order has no authorized payment"))
+	default:
+		renderOrderError(c, err, message)
+	}
+}
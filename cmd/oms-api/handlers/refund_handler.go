@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// RefundOrderByOrderId refunds units of the items of a paid order, or everything that
+// has not been refunded yet when the request lists no items
+func (a *Application) RefundOrderByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	var req models.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	refund, err := a.Payments.Refund(c.Request.Context(), id, service.RefundInput{Lines: orderLines(req.Items), Reason: req.Reason})
+	if err != nil {
+		renderRefundError(c, err, "Failed to refund order")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order has been refunded",
+		"refund":  refund,
+	})
+}
+
+// GetRefundsByOrderId lists the refunds of an order with the lines they gave back
+func (a *Application) GetRefundsByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	refunds, err := a.Payments.ListRefunds(c.Request.Context(), id)
+	if err != nil {
+		renderRefundError(c, err, "Unable to fetch refunds")
+		return
+	}
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": refunds,
+	})
+}
+
+// renderRefundError maps the refund errors of the payment service to problems, other errors are rendered as payment errors
+func renderRefundError(c *gin.Context, err error, message string) {
+	var notPaid *service.NotPaidError
+	var quantity *service.RefundQuantityError
+	switch {
+	case errors.As(err, &notPaid):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeOrderNotPaid, fmt.Sprintf("Order has not been paid (current status: %s)", notPaid.Status)))
+	case errors.As(err, &quantity):
+		apperrors.Render(c, apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeInvalidRefundQuantity,
+			fmt.Sprintf("Cannot refund %d of item %d, %d can be refunded", quantity.Quantity, quantity.ItemID, quantity.Refundable)))
+	case errors.Is(err, service.ErrNothingToRefund):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeNothingToRefund, "Everything paid for the order has been refunded"))
+	default:
+		renderPaymentError(c, err, message)
+	}
+}
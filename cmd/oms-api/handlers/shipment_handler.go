@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// CreateShipmentByOrderId ships units of the items of a confirmed order, or everything
+// that has not been shipped yet when the request lists no items
+func (a *Application) CreateShipmentByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	var req models.CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	shipment, err := a.Shipments.Create(c.Request.Context(), id, service.CreateShipmentInput{
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Lines:          orderLines(req.Items),
+	})
+	if err != nil {
+		renderShipmentError(c, err, "Failed to create shipment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Shipment created successfully",
+		"shipment": shipment,
+	})
+}
+
+// DeliverShipmentByShipmentId marks a shipment as delivered
+func (a *Application) DeliverShipmentByShipmentId(c *gin.Context) {
+	id, ok := pathID(c, "shipment")
+	if !ok {
+		return
+	}
+
+	shipment, err := a.Shipments.Deliver(c.Request.Context(), id)
+	if err != nil {
+		renderShipmentError(c, err, "Failed to deliver shipment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Shipment has been delivered",
+		"shipment": shipment,
+	})
+}
+
+// GetShipmentsByOrderId lists the shipments of an order with the lines they carry
+func (a *Application) GetShipmentsByOrderId(c *gin.Context) {
+	id, ok := pathID(c, "order")
+	if !ok {
+		return
+	}
+
+	shipments, err := a.Shipments.List(c.Request.Context(), id)
+	if err != nil {
+		renderShipmentError(c, err, "Unable to fetch shipments")
+		return
+	}
+	if shipments == nil {
+		shipments = []models.Shipment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shipments": shipments,
+	})
+}
+
+// renderShipmentError maps the errors of the shipment service to problems, other errors are rendered as order errors
+func renderShipmentError(c *gin.Context, err error, message string) {
+	var notShippable *service.NotShippableError
+	var quantity *service.ShipmentQuantityError
+	switch {
+	case errors.As(err, &notShippable):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeOrderNotShippable, fmt.Sprintf("Order cannot be shipped (current status: %s)", notShippable.Status)))
+	case errors.As(err, &quantity):
+		apperrors.Render(c, apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeInvalidShipmentQuantity,
+			fmt.Sprintf("Cannot ship %d of item %d, %d can be shipped", quantity.Quantity, quantity.ItemID, quantity.Shippable)))
+	case errors.Is(err, service.ErrNothingToShip):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeNothingToShip, "Every item of the order has been shipped"))
+	case errors.Is(err, service.ErrShipmentNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeShipmentNotFound, "Shipment not found"))
+	case errors.Is(err, service.ErrShipmentDelivered):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeShipmentDelivered, "Shipment has already been delivered"))
+	case errors.Is(err, service.ErrTrackingNumberTaken):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeTrackingNumberTaken, "Tracking number is already used by another shipment of the carrier"))
+	default:
+		renderOrderError(c, err, message)
+	}
+}
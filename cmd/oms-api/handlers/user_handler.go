@@ -1,54 +1,61 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
 	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
-	"gorm.io/gorm"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/utils"
 )
 
-// var db *gorm.DB // Global variable to hold the database connection
-//
-
 // addUser creates a new user
-// AddUser adds a new user to the database using GORM
-func AddUser(c *gin.Context, db *gorm.DB) {
-
-	if db == nil {
-		log.Println("Database connection is nil!")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection not initialized"})
+// AddUser adds a new user to the user repository
+func (a *Application) AddUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.UserRequest
+	// Bind and validate the incoming JSON data
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Binding(err))
 		return
 	}
-
-	var newUser models.User
-	// Bind incoming JSON data to the User struct
-	if err := c.ShouldBindJSON(&newUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
-		log.Println("Error binding JSON:", err)
+	if !a.checkRegion(c, req.Region) {
 		return
 	}
+	newUser := models.User{Name: req.Name, Email: utils.NormalizeEmail(req.Email), Region: req.Region}
 
-	// Log the user data to ensure it's being received correctly
-	log.Println("Received user data:", newUser)
-
-	// Insert the new user into the database using GORM
-	if err := db.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert user", "details": err.Error()})
-		log.Println("Error inserting user:", err)
+	// Insert the new user
+	if err := a.Store.Users().Create(ctx, &newUser); err != nil {
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			apperrors.Render(c, apperrors.Conflict(apperrors.CodeEmailTaken, "Email is already in use"))
+			return
+		}
+		apperrors.Render(c, apperrors.Internal(err, "Failed to insert user"))
 		return
 	}
 	var resUser models.UserResponse
 	resUser.ID = newUser.ID
 	resUser.Name = newUser.Name
 	resUser.Email = newUser.Email
+	resUser.EmailVerifiedAt = newUser.EmailVerifiedAt
+	resUser.Region = newUser.Region
 	resUser.CreatedAt = newUser.CreatedAt
 	resUser.UpdatedAt = newUser.UpdatedAt
 	resUser.DeletedAt = newUser.DeletedAt
 
-	// Log the successful insertion
-	log.Println("User added successfully:", newUser)
+	// Log the successful insertion, only the ID since name and email are personal data
+	logging.FromContext(ctx).InfoContext(ctx, "user created", slog.Int("user_id", newUser.ID))
+
+	// Ask the user to verify the new address
+	a.sendVerificationEmailBestEffort(ctx, newUser)
 
 	// Return the response with the new user details
 	c.JSON(http.StatusOK, gin.H{
@@ -57,16 +64,13 @@ func AddUser(c *gin.Context, db *gorm.DB) {
 	})
 }
 
-// FetchUsers fetches all users that are not deleted from the database using GORM
-func FetchUsers(c *gin.Context, db *gorm.DB) {
-	// Define a slice to hold the response users
-	var users []models.User
-
-	// Query to fetch only non-deleted users using GORM's Where method
-	if err := db.Where("deleted_at IS NULL").Find(&users).Error; err != nil {
-		// Log the error and respond with an internal server error
-		log.Println("Error fetching users:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch users", "details": err.Error()})
+// FetchUsers fetches all users that are not deleted
+func (a *Application) FetchUsers(c *gin.Context) {
+	// Only non-deleted users are listed
+	users, err := a.Store.Users().List(c.Request.Context())
+	if err != nil {
+		// Respond with an internal server error, the cause is logged by the renderer
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch users"))
 		return
 	}
 
@@ -74,11 +78,13 @@ func FetchUsers(c *gin.Context, db *gorm.DB) {
 	var userResponses []models.UserResponse
 	for _, user := range users {
 		userResponses = append(userResponses, models.UserResponse{
-			ID:        user.ID,
-			Name:      user.Name,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+			ID:              user.ID,
+			Name:            user.Name,
+			Email:           user.Email,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			Region:          user.Region,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
 		})
 	}
 
@@ -89,27 +95,22 @@ func FetchUsers(c *gin.Context, db *gorm.DB) {
 }
 
 // GetUserDetailByUserId fetches the details of a user by their ID
-func GetUserDetailByUserId(c *gin.Context, db *gorm.DB) {
+func (a *Application) GetUserDetailByUserId(c *gin.Context) {
 	// Get the user ID from the URL parameter
-	id := c.Param("id")
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
 
-	var user models.User
-	// Query the database to fetch the user details using GORM
-	if err := db.Where("id = ? AND deleted_at IS NULL", id).First(&user).Error; err != nil {
+	user, err := a.Store.Users().Get(c.Request.Context(), id)
+	if err != nil {
 		// Handle case where user does not exist or any other error
-		if err == gorm.ErrRecordNotFound {
-			// User not found in the database
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    http.StatusNotFound,
-				"message": "User not found",
-			})
+		if errors.Is(err, repository.ErrNotFound) {
+			// User not found or soft-deleted
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
 		} else {
 			// Internal server error occurred while querying the database
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code":    http.StatusInternalServerError,
-				"message": "Unable to fetch user data",
-				"details": err.Error(),
-			})
+			apperrors.Render(c, apperrors.Internal(err, "Unable to fetch user data"))
 		}
 		return
 	}
@@ -118,37 +119,42 @@ func GetUserDetailByUserId(c *gin.Context, db *gorm.DB) {
 	userResponse.ID = user.ID
 	userResponse.Name = user.Name
 	userResponse.Email = user.Email
+	userResponse.EmailVerifiedAt = user.EmailVerifiedAt
+	userResponse.Region = user.Region
 	userResponse.CreatedAt = user.CreatedAt
 	userResponse.UpdatedAt = user.UpdatedAt
 	userResponse.DeletedAt = user.DeletedAt
 
+	standing, err := a.Orders.LoyaltyStatus(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch user data"))
+		return
+	}
+
 	// Successfully found the user and it is not soft-deleted
 	c.JSON(http.StatusOK, gin.H{
 		"code":    http.StatusOK,
 		"message": "User found",
 		"user":    userResponse,
+		"loyalty": standing,
 	})
 }
 
 // getUserDetailsWithOrders retrieves a user by their ID along with their orders and order items.
-func GetUserDetailsWithOrdersByUserId(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-
-	// Fetch user details and associated orders in one query using Preload
-	var user models.User
-	if err := db.Preload("Orders.Items").First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch user data"})
-		log.Println("Error fetching user:", err)
+func (a *Application) GetUserDetailsWithOrdersByUserId(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
 		return
 	}
 
-	// Check if the user is soft-deleted
-	if user.DeletedAt.Valid {
-		c.JSON(http.StatusGone, gin.H{"message": "User has been soft deleted"})
+	// Fetch user details together with the associated orders and their items
+	user, err := a.Store.Users().GetWithOrders(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
+			return
+		}
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch user data"))
 		return
 	}
 
@@ -159,6 +165,8 @@ func GetUserDetailsWithOrdersByUserId(c *gin.Context, db *gorm.DB) {
 			ID:         order.ID,
 			TotalPrice: order.TotalPrice,
 			FinalPrice: order.FinalPrice,
+			Shipping:   order.Shipping,
+			Totals:     order.Totals(),
 			Status:     order.Status,
 		}
 
@@ -166,7 +174,7 @@ func GetUserDetailsWithOrdersByUserId(c *gin.Context, db *gorm.DB) {
 		itemsResponse := make([]models.ItemResponse, len(order.Items))
 		for j, item := range order.Items {
 			itemsResponse[j] = models.ItemResponse{
-				ItemID:   item.ID,
+				ItemID:   item.ItemID,
 				Price:    item.Price,
 				Quantity: item.Quantity,
 			}
@@ -186,72 +194,170 @@ func GetUserDetailsWithOrdersByUserId(c *gin.Context, db *gorm.DB) {
 		OrderResponse: ordersResponse,
 	}
 
-	// Send the final response with user and order details
+	standing, err := a.Orders.LoyaltyStatus(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Render(c, apperrors.Internal(err, "Unable to fetch user data"))
+		return
+	}
+
+	// Send the final response with user and order details, and the loyalty tier of the user
 	c.JSON(http.StatusOK, gin.H{
-		"user": userResponse,
+		"user":    userResponse,
+		"loyalty": standing,
 	})
 }
 
-// UpdateUserDetails updates the user's details in the database using GORM
-func UpdateUserDetails(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	var updatedUser models.User
+// UpdateUserDetails updates the user's details
+func (a *Application) UpdateUserDetails(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+	var updatedUser models.UserRequest
 	if err := c.ShouldBindJSON(&updatedUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+	if !a.checkRegion(c, updatedUser.Region) {
 		return
 	}
 
 	// Find the user by ID
-	var user models.User
-	if err := db.First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	user, err := a.Store.Users().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		log.Println("Error fetching user:", err)
+		apperrors.Render(c, apperrors.Internal(err, "Failed to fetch user"))
 		return
 	}
 
-	// Update user details
+	// Update user details, UpdatedAt is maintained by the repository.
+	// A new email address has to be verified again.
+	email := utils.NormalizeEmail(updatedUser.Email)
+	emailChanged := email != user.Email
 	user.Name = updatedUser.Name
-	user.Email = updatedUser.Email
-	user.UpdatedAt = updatedUser.UpdatedAt // assuming updatedAt is passed as part of the request
-	if err := db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	user.Email = email
+	user.Region = updatedUser.Region
+	if emailChanged {
+		user.EmailVerifiedAt = nil
+	}
+	if err := a.Store.Users().Update(ctx, &user); err != nil {
+		renderUserUpdateError(c, err)
 		return
 	}
+	if emailChanged {
+		a.sendVerificationEmailBestEffort(ctx, user)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User updated successfully",
 	})
 }
 
-// DeleteUserByUserId deletes a user (soft delete) using GORM
-func DeleteUserByUserId(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
+// PatchUserDetails applies a JSON merge patch to the user, only the fields present in the patch are changed
+func (a *Application) PatchUserDetails(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+	patch, err := utils.ReadMergePatch(c, []string{"name", "email", "region"})
+	if err != nil {
+		apperrors.Render(c, err)
+		return
+	}
 
 	// Find the user by ID
-	var user models.User
-	if err := db.First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	user, err := a.Store.Users().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		log.Println("Error fetching user:", err)
+		apperrors.Render(c, apperrors.Internal(err, "Failed to fetch user"))
 		return
 	}
 
-	// Check if the user is already soft deleted
-	if user.DeletedAt.Valid {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User is already deleted"})
+	// Merge the patch into the current values and validate the result
+	input := models.UserRequest{Name: user.Name, Email: user.Email, Region: user.Region}
+	if err := patch.Apply(&input); err != nil {
+		apperrors.Render(c, err)
 		return
 	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		apperrors.Render(c, apperrors.Validation(err))
+		return
+	}
+	if !a.checkRegion(c, input.Region) {
+		return
+	}
+
+	// Only write the columns that are present in the patch, a new email address has to be verified again
+	var columns []string
+	if patch.Has("name") {
+		user.Name = input.Name
+		columns = append(columns, "name")
+	}
+	if patch.Has("region") {
+		user.Region = input.Region
+		columns = append(columns, "region")
+	}
+	emailChanged := false
+	if patch.Has("email") {
+		email := utils.NormalizeEmail(input.Email)
+		emailChanged = email != user.Email
+		user.Email = email
+		columns = append(columns, "email")
+		if emailChanged {
+			user.EmailVerifiedAt = nil
+			columns = append(columns, "email_verified_at")
+		}
+	}
+	if len(columns) > 0 {
+		if err := a.Store.Users().Update(ctx, &user, columns...); err != nil {
+			renderUserUpdateError(c, err)
+			return
+		}
+	}
+	if emailChanged {
+		a.sendVerificationEmailBestEffort(ctx, user)
+	}
 
-	// Proceed with soft delete (set deleted_at field)
-	if err := db.Model(&user).Update("deleted_at", gorm.Expr("NOW()")).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User updated successfully",
+		"user": models.UserResponse{
+			ID:              user.ID,
+			Name:            user.Name,
+			Email:           user.Email,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			Region:          user.Region,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+			DeletedAt:       user.DeletedAt,
+		},
+	})
+}
+
+// DeleteUserByUserId deletes a user (soft delete)
+func (a *Application) DeleteUserByUserId(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	// Soft delete the user, deleting it twice is a conflict
+	if err := a.Store.Users().Delete(c.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
+		case errors.Is(err, repository.ErrAlreadyDeleted):
+			apperrors.Render(c, apperrors.Conflict(apperrors.CodeUserAlreadyDeleted, "User is already deleted"))
+		default:
+			apperrors.Render(c, apperrors.Internal(err, "Failed to delete user"))
+		}
 		return
 	}
 
@@ -259,3 +365,40 @@ func DeleteUserByUserId(c *gin.Context, db *gorm.DB) {
 		"message": "User deleted successfully",
 	})
 }
+
+// renderUserUpdateError renders a failed user update
+func renderUserUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeEmailTaken, "Email is already in use"))
+	case errors.Is(err, repository.ErrNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeUserNotFound, "User not found"))
+	default:
+		apperrors.Render(c, apperrors.Internal(err, "Failed to update user"))
+	}
+}
+
+// pathID reads the numeric id URL parameter, rendering a 400 naming the resource when it is not a number
+func pathID(c *gin.Context, resource string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperrors.Render(c, apperrors.BadRequest(apperrors.CodeInvalidID, "Invalid "+resource+" ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// checkRegion renders a validation problem unless region is known to the campaign calendar
+func (a *Application) checkRegion(c *gin.Context, region string) bool {
+	if a.Orders.Calendar.HasRegion(region) {
+		return true
+	}
+	message := "must be empty"
+	if regions := a.Orders.Calendar.Regions(); len(regions) > 0 {
+		message = "must be one of: " + strings.Join(regions, ", ")
+	}
+	appErr := apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed, "One or more fields are invalid")
+	appErr.Fields = []apperrors.FieldError{{Field: "region", Rule: "region", Message: message}}
+	apperrors.Render(c, appErr)
+	return false
+}
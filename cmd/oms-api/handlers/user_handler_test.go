@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUserLifecycle(t *testing.T) {
+	app := newTestApp(t)
+
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"Ada@Example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(app.AddUser, http.MethodPost, "", `{"name":"Ada","email":"ada@example.com"}`); w.Code != http.StatusConflict {
+		t.Fatalf("create with a taken email: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := serve(app.GetUserDetailByUserId, http.MethodGet, "abc", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("get with an invalid ID: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := serve(app.DeleteUserByUserId, http.MethodDelete, "1", ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(app.DeleteUserByUserId, http.MethodDelete, "1", ""); w.Code != http.StatusConflict {
+		t.Fatalf("delete again: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := serve(app.GetUserDetailByUserId, http.MethodGet, "1", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("get a deleted user: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(app.DeleteUserByUserId, http.MethodDelete, "2", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("delete a missing user: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/metrics"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+// maxWebhookBody bounds the size of a webhook request, events are small
+const maxWebhookBody = 64 << 10
+
+// Webhooks configures which providers may send payment webhooks
+type Webhooks struct {
+	Secrets   map[string]string // Signing secret by provider name, providers without one are rejected
+	Tolerance time.Duration     // How far the signed timestamp may be from the current time
+	Clock     clock.Clock
+}
+
+// ReceivePaymentWebhook applies a signed payment event of a provider. Deliveries of an
+// event that has been applied before are acknowledged without changing anything.
+func (a *Application) ReceivePaymentWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	provider := c.Param("provider")
+	secret, ok := a.Webhooks.Secrets[provider]
+	if !ok {
+		metrics.PaymentWebhooks.WithLabelValues("unknown", metrics.WebhookRejected).Inc()
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodeUnknownPaymentProvider, "Unknown payment provider: "+provider))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, http.StatusBadRequest, apperrors.CodeInvalidInput, "Request body could not be read"))
+		return
+	}
+	if err := webhook.Verify(secret, c.GetHeader(webhook.SignatureHeader), body, a.Webhooks.Clock.Now(), a.Webhooks.Tolerance); err != nil {
+		metrics.PaymentWebhooks.WithLabelValues(provider, metrics.WebhookRejected).Inc()
+		logging.FromContext(ctx).WarnContext(ctx, "webhook rejected", slog.String("provider", provider), slog.Any("error", err))
+		apperrors.Render(c, apperrors.New(http.StatusUnauthorized, apperrors.CodeInvalidWebhookSignature, "Webhook signature is invalid: "+err.Error()))
+		return
+	}
+
+	var event webhook.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		metrics.PaymentWebhooks.WithLabelValues(provider, metrics.WebhookFailed).Inc()
+		apperrors.Render(c, apperrors.Binding(err))
+		return
+	}
+
+	payment, err := a.Payments.ApplyEvent(ctx, provider, event)
+	if errors.Is(err, service.ErrDuplicateEvent) {
+		metrics.PaymentWebhooks.WithLabelValues(provider, metrics.WebhookDuplicate).Inc()
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Event has already been applied",
+			"duplicate": true,
+		})
+		return
+	}
+	if err != nil {
+		metrics.PaymentWebhooks.WithLabelValues(provider, metrics.WebhookFailed).Inc()
+		renderWebhookError(c, err)
+		return
+	}
+
+	metrics.PaymentWebhooks.WithLabelValues(provider, metrics.WebhookApplied).Inc()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Event applied",
+		"payment": payment,
+	})
+}
+
+// renderWebhookError maps the errors of applying an event to problems
+func renderWebhookError(c *gin.Context, err error) {
+	var invalid *service.InvalidEventError
+	var transition *service.PaymentTransitionError
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		apperrors.Render(c, apperrors.NotFound(apperrors.CodePaymentNotFound, "No payment has the provider reference of the event"))
+	case errors.As(err, &invalid):
+		apperrors.Render(c, apperrors.New(http.StatusUnprocessableEntity, apperrors.CodeInvalidWebhookEvent, "Webhook event is invalid: "+invalid.Reason))
+	case errors.As(err, &transition):
+		apperrors.Render(c, apperrors.Conflict(apperrors.CodeInvalidPaymentTransition, "Event does not apply to a payment that is "+transition.Status))
+	default:
+		renderPaymentError(c, err, "Failed to apply webhook event")
+	}
+}
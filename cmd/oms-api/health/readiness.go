@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"gorm.io/gorm"
+)
+
+// CheckTimeout bounds every readiness check
+const CheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable, a nil error means it is
+type Check func(ctx context.Context) error
+
+// Statuses of a check. The error of a failed check is logged, not reported, it may
+// show addresses and credentials of the dependency.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusTimeout = "timeout"
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Status string `json:"status"`
+}
+
+// Readiness decides whether the service should receive traffic. It always checks the
+// database connection and the schema version, other dependencies are added with AddCheck.
+// A schema newer than this build expects is accepted: migrations only add to it, and a
+// release applying its migrations must not take the pods of the one before out of
+// rotation while it rolls out.
+type Readiness struct {
+	mu           sync.RWMutex
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewReadiness creates the readiness probe for db
+func NewReadiness(db *gorm.DB) *Readiness {
+	r := &Readiness{checks: map[string]Check{}}
+	r.AddCheck("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	r.AddCheck("migrations", func(ctx context.Context) error {
+		version, err := database.CurrentVersion(db.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if version < database.SchemaVersion {
+			return fmt.Errorf("schema version is %d, expected at least %d", version, database.SchemaVersion)
+		}
+		return nil
+	})
+	return r
+}
+
+// AddCheck registers a dependency check, a check with the same name is replaced
+func (r *Readiness) AddCheck(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// SetShuttingDown makes the service report not ready, so load balancers stop
+// sending new requests while the ones in flight are drained
+func (r *Readiness) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown was called
+func (r *Readiness) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Check runs all checks concurrently and reports whether every one of them passed
+func (r *Readiness) Check(ctx context.Context) (bool, []Result) {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = Result{Name: names[i], OK: true, Status: StatusOK}
+			if err := checks[i](ctx); err != nil {
+				results[i].OK, results[i].Status = false, StatusFailed
+				if errors.Is(err, context.DeadlineExceeded) {
+					results[i].Status = StatusTimeout
+				}
+				logging.FromContext(ctx).WarnContext(ctx, "readiness check failed", slog.String("check", names[i]), slog.Any("error", err))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		ready = ready && result.OK
+	}
+	return ready, results
+}
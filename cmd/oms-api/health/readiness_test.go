@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // Every connection would get its own in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func failed(results []Result) []string {
+	var names []string
+	for _, r := range results {
+		if !r.OK {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+func TestReadinessRequiresMigratedSchema(t *testing.T) {
+	db := openTestDB(t)
+	readiness := NewReadiness(db)
+
+	if ready, results := readiness.Check(context.Background()); ready {
+		t.Fatal("ready before migrating")
+	} else if got := failed(results); len(got) != 1 || got[0] != "migrations" {
+		t.Fatalf("failed checks = %v, want [migrations]", got)
+	}
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if ready, results := readiness.Check(context.Background()); !ready {
+		t.Fatalf("not ready after migrating: %+v", results)
+	}
+
+	// The migration of the next release keeps this one ready while it rolls out
+	if err := db.Create(&database.SchemaMigration{Version: database.SchemaVersion + 1, Name: "next_release"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ready, results := readiness.Check(context.Background()); !ready {
+		t.Fatalf("not ready on a newer schema: %+v", results)
+	}
+}
+
+func TestReadinessDependencyHook(t *testing.T) {
+	db := openTestDB(t)
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	readiness := NewReadiness(db)
+	readiness.AddCheck("mailer", func(context.Context) error { return errors.New("connection refused") })
+
+	ready, results := readiness.Check(context.Background())
+	if ready {
+		t.Fatal("ready with a failing dependency")
+	}
+	if got := failed(results); len(got) != 1 || got[0] != "mailer" {
+		t.Fatalf("failed checks = %v, want [mailer]", got)
+	}
+	for _, result := range results {
+		if result.Name == "mailer" && result.Status != StatusFailed {
+			t.Errorf("mailer status = %q, want %q without the error", result.Status, StatusFailed)
+		}
+	}
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	readiness := NewReadiness(openTestDB(t))
+	if readiness.ShuttingDown() {
+		t.Fatal("shutting down before SetShuttingDown")
+	}
+	readiness.SetShuttingDown()
+	if !readiness.ShuttingDown() {
+		t.Fatal("not shutting down after SetShuttingDown")
+	}
+}
@@ -0,0 +1,170 @@
+// Package invoice renders invoices for customers, as HTML pages and as PDF documents
+package invoice
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+// Content types of the rendered invoices
+const (
+	ContentTypeHTML = "text/html"
+	ContentTypePDF  = "application/pdf"
+)
+
+const dateLayout = "2 January 2006"
+
+var page = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"money":        money,
+	"taxLabel":     taxLabel,
+	"percent":      percent,
+	"addressLines": addressLines,
+	"date":         func(inv models.Invoice) string { return inv.IssuedAt.Format(dateLayout) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-top: 1.5em; }
+th, td { padding: 0.4em 0.6em; border-bottom: 1px solid #ddd; text-align: left; }
+.amount { text-align: right; }
+tfoot td { border: none; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>Issued {{date .}} for order {{.OrderID}}</p>
+<p>{{with .CustomerName}}{{.}}<br>{{end}}{{.CustomerEmail}}</p>
+{{- with addressLines .BillingAddress}}
+<p><strong>Bill to</strong>{{range .}}<br>{{.}}{{end}}</p>
+{{- end}}
+<table>
+<thead><tr><th>Description</th><th class="amount">Quantity</th><th class="amount">Unit price</th><th class="amount">Discount</th><th class="amount">Tax rate</th><th class="amount">Amount</th></tr></thead>
+<tbody>
+{{- range .Lines}}
+<tr><td>{{.Description}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{money .UnitPrice}}</td><td class="amount">{{money .Discount}}</td><td class="amount">{{percent .TaxRate}}</td><td class="amount">{{money .Amount}}</td></tr>
+{{- end}}
+</tbody>
+<tfoot>
+<tr><td colspan="5" class="amount">Subtotal</td><td class="amount">{{money .Subtotal}}</td></tr>
+<tr><td colspan="5" class="amount">Discount</td><td class="amount">-{{money .Discount}}</td></tr>
+<tr><td colspan="5" class="amount">Shipping</td><td class="amount">{{money .Shipping}}</td></tr>
+<tr><td colspan="5" class="amount">{{taxLabel .}}</td><td class="amount">{{money .Tax}}</td></tr>
+<tr><td colspan="5" class="amount"><strong>Total</strong></td><td class="amount"><strong>{{money .Total}}</strong></td></tr>
+</tfoot>
+</table>
+</body>
+</html>
+`))
+
+// HTML writes inv as an HTML page
+func HTML(w io.Writer, inv models.Invoice) error {
+	return page.Execute(w, inv)
+}
+
+// PDF writes inv as an A4 PDF document. The document only depends on the invoice, so
+// rendering the same invoice twice gives the same bytes.
+func PDF(w io.Writer, inv models.Invoice) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Invoice "+inv.Number, false)
+	pdf.SetCreationDate(inv.IssuedAt)
+	pdf.SetModificationDate(inv.IssuedAt)
+	pdf.SetCatalogSort(true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // The core fonts are encoded in cp1252
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Invoice "+inv.Number, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Issued %s for order %d", inv.IssuedAt.Format(dateLayout), inv.OrderID), "", 1, "L", false, 0, "")
+	if inv.CustomerName != "" {
+		pdf.CellFormat(0, 6, tr(inv.CustomerName), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 6, tr(inv.CustomerEmail), "", 1, "L", false, 0, "")
+	if lines := addressLines(inv.BillingAddress); len(lines) > 0 {
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 6, "Bill to", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, line := range lines {
+			pdf.CellFormat(0, 6, tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Ln(6)
+
+	widths := []float64{70, 20, 25, 25, 25, 25}
+	pdf.SetFont("Helvetica", "B", 10)
+	for i, heading := range []string{"Description", "Quantity", "Unit price", "Discount", "Tax rate", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, heading, "B", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range inv.Lines {
+		pdf.CellFormat(widths[0], 7, tr(line.Description), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, fmt.Sprint(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 7, money(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, money(line.Discount), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, percent(line.TaxRate), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 7, money(line.Amount), "", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(4)
+	totals := []struct {
+		label, amount string
+	}{
+		{"Subtotal", money(inv.Subtotal)},
+		{"Discount", "-" + money(inv.Discount)},
+		{"Shipping", money(inv.Shipping)},
+		{taxLabel(inv), money(inv.Tax)},
+		{"Total", money(inv.Total)},
+	}
+	for i, total := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 10)
+		}
+		pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3]+widths[4], 7, total.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 7, total.amount, "", 1, "R", false, 0, "")
+	}
+	return pdf.Output(w)
+}
+
+// taxLabel labels the tax of inv, which is part of the total rather than added to it when prices include tax
+func taxLabel(inv models.Invoice) string {
+	if inv.PricesIncludeTax {
+		return "Included tax"
+	}
+	return "Tax"
+}
+
+// percent formats a tax rate, such as 7.25% for 0.0725
+func percent(rate float64) string {
+	return strconv.FormatFloat(math.Round(rate*10000)/100, 'f', -1, 64) + "%"
+}
+
+// addressLines returns the lines an address is printed on, none for an empty address
+func addressLines(a models.PostalAddress) []string {
+	var lines []string
+	for _, line := range []string{a.Name, a.Line1, a.Line2, strings.Join(strings.Fields(a.City+" "+a.State+" "+a.PostalCode), " "), a.Country} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
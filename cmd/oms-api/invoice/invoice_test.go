@@ -0,0 +1,66 @@
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+var testInvoice = models.Invoice{
+	Number:         "INV-2024-000042",
+	OrderID:        7,
+	CustomerName:   "Zoë <script>",
+	CustomerEmail:  "zoe@example.com",
+	BillingAddress: models.PostalAddress{Name: "Zoë Smith", Line1: "1 Main St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"},
+	Subtotal:       150,
+	Discount:       10,
+	Shipping:       5,
+	Tax:            29,
+	Total:          174,
+	IssuedAt:       time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC),
+	Lines: []models.InvoiceLine{
+		{ItemID: 1, Description: "Shirt", Quantity: 10, UnitPrice: 10, Discount: 10, Amount: 90, TaxRate: 0.2, Tax: 18},
+		{ItemID: 2, Description: "Shoes", Quantity: 1, UnitPrice: 50, Amount: 50, TaxRate: 0.2, Tax: 10},
+	},
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, testInvoice); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{"<h1>Invoice INV-2024-000042</h1>", "Issued 1 June 2024 for order 7", "Zoë &lt;script&gt;", "<strong>Bill to</strong><br>Zoë Smith<br>1 Main St<br>London SW1A 1AA<br>GB</p>", "<td>Shoes</td>", "-10.00", ">5.00<", ">20%<", ">Tax</td><td class=\"amount\">29.00<", "<strong>174.00</strong>"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q:\n%s", want, page)
+		}
+	}
+	inclusive := testInvoice
+	inclusive.PricesIncludeTax = true
+	buf.Reset()
+	if err := HTML(&buf, inclusive); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ">Included tax<") {
+		t.Errorf("page of an invoice with prices including tax does not label the tax as included:\n%s", buf.String())
+	}
+}
+
+func TestPDF(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := PDF(&first, testInvoice); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(first.Bytes(), []byte("%PDF-")) || !bytes.Contains(first.Bytes(), []byte("/Title (Invoice INV-2024-000042)")) {
+		t.Fatalf("document is not the PDF of the invoice: %.200q", first.Bytes())
+	}
+	if err := PDF(&second, testInvoice); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("rendering an invoice twice gave different documents")
+	}
+}
@@ -0,0 +1,129 @@
+// Package loyalty places customers in tiers by what they spent in a rolling window.
+// Spend counts completed payments: what the payments of their orders captured within the
+// window, less what was refunded of them. Cancelled, unpaid and refunded orders do not
+// count. Every tier has its own benefits, a discount rate off every line and optionally
+// free standard shipping.
+package loyalty
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Tier is reached with at least MinimumSpend within the window
+type Tier struct {
+	Name         string  `json:"name"`
+	MinimumSpend float64 `json:"minimum_spend"`
+	DiscountRate float64 `json:"discount_rate"` // Off every line, 0.05 for 5%
+	FreeShipping bool    `json:"free_shipping"` // Standard shipping is free
+}
+
+// DefaultWindowDays is the window of the default program
+const DefaultWindowDays = 365
+
+// DefaultTiers are the tiers used when no file is configured
+var DefaultTiers = []Tier{
+	{Name: "Silver", MinimumSpend: 500, DiscountRate: 0.02},
+	{Name: "Gold", MinimumSpend: 1500, DiscountRate: 0.05},
+	{Name: "Platinum", MinimumSpend: 5000, DiscountRate: 0.08, FreeShipping: true},
+}
+
+// Program holds the tiers and the window spend is counted in
+type Program struct {
+	windowDays int
+	tiers      []Tier // By increasing minimum spend
+}
+
+// Status is the standing of a customer: their tier, its benefits and how far they are
+// from the next one
+type Status struct {
+	Tier            string  `json:"tier"` // Empty below the first tier
+	Spend           float64 `json:"spend"`
+	WindowDays      int     `json:"window_days"`
+	DiscountRate    float64 `json:"discount_rate"`
+	FreeShipping    bool    `json:"free_shipping"`
+	NextTier        string  `json:"next_tier"`          // Empty in the highest tier
+	SpendToNextTier float64 `json:"spend_to_next_tier"` // Zero in the highest tier
+	Progress        float64 `json:"progress"`           // Fraction of the way from the current tier to the next, 1 in the highest tier
+}
+
+// file is the format of a loyalty program file
+type file struct {
+	WindowDays int    `json:"window_days"`
+	Tiers      []Tier `json:"tiers"`
+}
+
+// New creates a program counting spend over the last windowDays days. Without tiers no
+// customer gets any benefits.
+func New(windowDays int, tiers []Tier) (*Program, error) {
+	if windowDays <= 0 {
+		return nil, fmt.Errorf("window of %d days must be positive", windowDays)
+	}
+	names := map[string]bool{}
+	for i, tier := range tiers {
+		switch {
+		case tier.Name == "":
+			return nil, fmt.Errorf("tier %d has no name", i+1)
+		case names[tier.Name]:
+			return nil, fmt.Errorf("tier %s is listed twice", tier.Name)
+		case tier.MinimumSpend <= 0:
+			return nil, fmt.Errorf("tier %s: minimum spend %v must be positive", tier.Name, tier.MinimumSpend)
+		case i > 0 && tier.MinimumSpend <= tiers[i-1].MinimumSpend:
+			return nil, fmt.Errorf("tier %s: tiers must be listed by increasing minimum spend", tier.Name)
+		case tier.DiscountRate < 0 || tier.DiscountRate >= 1:
+			return nil, fmt.Errorf("tier %s: discount rate %v must be at least 0 and below 1", tier.Name, tier.DiscountRate)
+		}
+		names[tier.Name] = true
+	}
+	return &Program{windowDays: windowDays, tiers: append([]Tier(nil), tiers...)}, nil
+}
+
+// Load reads a loyalty program file. Without a path the default tiers apply.
+func Load(path string) (*Program, error) {
+	if path == "" {
+		return New(DefaultWindowDays, DefaultTiers)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse loyalty tiers %s: %w", path, err)
+	}
+	program, err := New(f.WindowDays, f.Tiers)
+	if err != nil {
+		return nil, fmt.Errorf("loyalty tiers %s: %w", path, err)
+	}
+	return program, nil
+}
+
+// Since returns the start of the window ending at now
+func (p *Program) Since(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.windowDays)
+}
+
+// Status returns the standing of a customer who spent spend within the window
+func (p *Program) Status(spend float64) Status {
+	spend = roundCents(spend)
+	status := Status{Spend: spend, WindowDays: p.windowDays, Progress: 1}
+	floor := 0.0
+	for _, tier := range p.tiers {
+		if spend < tier.MinimumSpend {
+			status.NextTier = tier.Name
+			status.SpendToNextTier = roundCents(tier.MinimumSpend - spend)
+			status.Progress = math.Round((spend-floor)/(tier.MinimumSpend-floor)*100) / 100
+			break
+		}
+		status.Tier, status.DiscountRate, status.FreeShipping = tier.Name, tier.DiscountRate, tier.FreeShipping
+		floor = tier.MinimumSpend
+	}
+	return status
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,79 @@
+package loyalty
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusFollowsSpend(t *testing.T) {
+	program, err := New(DefaultWindowDays, DefaultTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spend float64
+		want  Status
+	}{
+		{0, Status{NextTier: "Silver", SpendToNextTier: 500}},
+		{125, Status{Spend: 125, NextTier: "Silver", SpendToNextTier: 375, Progress: 0.25}},
+		{500, Status{Tier: "Silver", Spend: 500, DiscountRate: 0.02, NextTier: "Gold", SpendToNextTier: 1000}},
+		{1249.999, Status{Tier: "Silver", Spend: 1250, DiscountRate: 0.02, NextTier: "Gold", SpendToNextTier: 250, Progress: 0.75}},
+		{1500, Status{Tier: "Gold", Spend: 1500, DiscountRate: 0.05, NextTier: "Platinum", SpendToNextTier: 3500}},
+		{7200, Status{Tier: "Platinum", Spend: 7200, DiscountRate: 0.08, FreeShipping: true, Progress: 1}},
+	}
+	for _, tc := range tests {
+		tc.want.WindowDays = DefaultWindowDays
+		if got := program.Status(tc.spend); got != tc.want {
+			t.Errorf("Status(%v) = %+v, want %+v", tc.spend, got, tc.want)
+		}
+	}
+
+	none, err := New(30, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := none.Status(100); got != (Status{Spend: 100, WindowDays: 30, Progress: 1}) {
+		t.Errorf("Status without tiers = %+v, want no benefits", got)
+	}
+}
+
+func TestSinceIsTheStartOfTheWindow(t *testing.T) {
+	program, err := New(30, DefaultTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	if got, want := program.Since(now), time.Date(2024, time.February, 14, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Since(%v) = %v, want %v", now, got, want)
+	}
+}
+
+func TestLoadRejectsInvalidPrograms(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		want    string
+	}{
+		{"no window", `{"tiers":[{"name":"Silver","minimum_spend":500}]}`, "must be positive"},
+		{"unnamed tier", `{"window_days":365,"tiers":[{"minimum_spend":500}]}`, "no name"},
+		{"listed twice", `{"window_days":365,"tiers":[{"name":"Silver","minimum_spend":500},{"name":"Silver","minimum_spend":900}]}`, "twice"},
+		{"out of order", `{"window_days":365,"tiers":[{"name":"Gold","minimum_spend":1500},{"name":"Silver","minimum_spend":500}]}`, "increasing minimum spend"},
+		{"rate as a percentage", `{"window_days":365,"tiers":[{"name":"Silver","minimum_spend":500,"discount_rate":5}]}`, "below 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "loyalty.json")
+			if err := os.WriteFile(path, []byte(tc.program), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+)
+
+// LogSender writes messages to the application log, meant for local development
+type LogSender struct {
+	From string
+}
+
+func (s LogSender) Send(ctx context.Context, msg Message) error {
+	// The recipient is redacted by the logger, the body is kept so links can be followed locally
+	logging.FromContext(ctx).InfoContext(ctx, "mail sent to log",
+		slog.String("from", s.From),
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
+
+// FileSender stores every message as an .eml file in a directory, meant for local development and tests
+type FileSender struct {
+	dir  string
+	from string
+	seq  atomic.Uint64
+}
+
+// NewFileSender creates a sender writing into dir, the directory is created on first use
+func NewFileSender(dir, from string) *FileSender {
+	return &FileSender{dir: dir, from: from}
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405"), s.seq.Add(1))
+	return os.WriteFile(filepath.Join(s.dir, name), format(s.from, msg), 0o644)
+}
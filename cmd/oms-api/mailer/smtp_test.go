@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/config"
+)
+
+func TestSMTPSenderGivesUpWhenTheContextEnds(t *testing.T) {
+	// A relay that accepts connections and never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	sender := NewSMTPSender(config.MailConfig{SMTPHost: "127.0.0.1", SMTPPort: addr.Port, From: "no-reply@oms.local"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- sender.Send(ctx, Message{To: "ada@example.com", Subject: "Hi", Body: "Hello"}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Send returned %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send still blocked after its context ended")
+	}
+}
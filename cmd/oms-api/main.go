@@ -1,41 +1,63 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	_ "time/tzdata" // Business and region timezones do not depend on the zoneinfo of the image
 
 	"github.com/gin-gonic/gin"
-	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/campaign"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/config"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/handlers"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/loyalty"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/metrics"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/middleware"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
 	"github.com/keyurKalariya/OMS/cmd/oms-api/routes"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/server"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var db *gorm.DB
-
 // Initialize database connection with schema setup
-func initDB() (*gorm.DB, error) {
-	// Connection string for PostgreSQL
-	// connStr := "host=postgres-container-35 user=root password=root dbname=oms sslmode=disable"
-	connStr := "host=localhost user=root password=root dbname=oms sslmode=disable"
-
-	// Open a connection to the database using GORM v2
-	db, err := gorm.Open(postgres.Open(connStr), &gorm.Config{})
+func initDB(cfg config.DatabaseConfig, logCfg config.LogConfig) (*gorm.DB, error) {
+	// Open a connection to the database using GORM v2.
+	// TranslateError maps driver errors such as unique violations to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		TranslateError: true,
+		Logger:         logging.NewGormLogger(logCfg.SlowQueryThreshold),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Get the raw SQL DB connection
-	sqlDB, err := db.DB()
-	if err != nil {
+	// Record query metrics and export the connection pool statistics, trace every statement
+	if err := db.Use(metrics.NewGormPlugin(cfg.Name)); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.NewGormPlugin()); err != nil {
 		return nil, err
 	}
 
-	if _, err := sqlDB.Exec(`CREATE SCHEMA IF NOT EXISTS gorm`); err != nil {
+	// Get the raw SQL DB connection
+	sqlDB, err := db.DB()
+	if err != nil {
 		return nil, err
 	}
 
-	// Handle both return values: sql.Result and error
-	if _, err := sqlDB.Exec("SET search_path TO gorm"); err != nil {
+	// The search_path in the DSN points every pooled connection at this schema
+	if err := db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + db.Statement.Quote(cfg.Schema)).Error; err != nil {
 		return nil, err
 	}
 
@@ -44,37 +66,121 @@ func initDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	// Run AutoMigrate to automatically migrate the models
-	err = db.AutoMigrate(&models.Item{}, &models.Order{}, &models.OrderItem{}, &models.User{}, &models.UserOrder{})
-	if err != nil {
+	// Migrate the models and the constraints that go with them
+	if err := database.Migrate(db); err != nil {
 		return nil, err
 	}
 
-	log.Println("Connected to the PostgreSQL database using GORM v2")
+	slog.Info("connected to the PostgreSQL database", slog.String("host", cfg.Host), slog.String("database", cfg.Name))
 	return db, nil
 }
 
 func main() {
-	var err error
-	// Initialize the global db variable
-	db, err = initDB()
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+		slog.Error("invalid configuration", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	if db == nil {
-		log.Println("Database connection is nil after initialization!")
-		return
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		slog.Error("invalid log configuration", slog.Any("error", err))
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
 
-	log.Println("Database connection is initialized successfully")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Error("failed to set up tracing", slog.Any("error", err))
+		os.Exit(1)
+	}
 
-	// Your Gin app setup
-	r := gin.Default()
-	routes.SetupRoutes(r, db) // Pass the GORM db instance to the routes
+	db, err := initDB(cfg.Database, cfg.Log)
+	if err != nil {
+		logger.Error("failed to connect to the database", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		logger.Error("failed to set up the mailer", slog.Any("error", err))
+		os.Exit(1)
+	}
+	calendar, err := campaign.Load(cfg.Pricing.CampaignsFile, cfg.Pricing.Timezone)
+	if err != nil {
+		logger.Error("failed to load the campaign calendar", slog.Any("error", err))
+		os.Exit(1)
+	}
+	rates, err := shipping.Load(cfg.Pricing.ShippingRatesFile)
+	if err != nil {
+		logger.Error("failed to load the shipping rates", slog.Any("error", err))
+		os.Exit(1)
+	}
+	taxes, err := tax.Load(cfg.Pricing.TaxRatesFile)
+	if err != nil {
+		logger.Error("failed to load the tax rates", slog.Any("error", err))
+		os.Exit(1)
+	}
+	program, err := loyalty.Load(cfg.Pricing.LoyaltyTiersFile)
+	if err != nil {
+		logger.Error("failed to load the loyalty tiers", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	readiness := health.NewReadiness(db)
+	store := repository.NewGormStore(db)
+	orders := service.NewOrderService(store)
+	orders.Calendar = calendar
+	orders.Shipping = rates
+	orders.Tax = taxes
+	orders.Loyalty = program
+	payments := service.NewPaymentService(store, payment.NewMock())
+	orders.Payments = payments
+	app := &handlers.Application{
+		Store:     store,
+		Orders:    orders,
+		Payments:  payments,
+		Shipments: service.NewShipmentService(store),
+		Addresses: service.NewAddressService(store),
+		Coupons:   service.NewCouponService(store),
+		EmailVerification: &handlers.EmailVerification{
+			Mailer:    mail,
+			PublicURL: cfg.PublicURL,
+			TokenTTL:  cfg.EmailVerificationTTL,
+		},
+		Webhooks: &handlers.Webhooks{
+			Secrets:   cfg.Webhooks.Secrets,
+			Tolerance: cfg.Webhooks.Tolerance,
+			Clock:     clock.System,
+		},
+		Readiness: readiness,
+		SchemaVersion: func(ctx context.Context) (int, error) {
+			return database.CurrentVersion(db.WithContext(ctx))
+		},
+	}
 
-	log.Println("Server is running on port 8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal(err)
+	// Gin's own logger is replaced by the structured access log, the request ID comes first
+	// so that every later log line of a request carries it
+	r := gin.New()
+	r.Use(middleware.RequestID(logger), middleware.Tracing(), middleware.AccessLog(), middleware.Metrics(), gin.Recovery())
+	r.Use(middleware.Timeout(cfg.RequestTimeout, cfg.RouteTimeouts))
+	routes.SetupRoutes(r, app)
+
+	// Background workers register their stop function here before the tracer and the
+	// database they depend on, hooks run in registration order once requests are drained
+	srv := server.New(r, cfg.Server, readiness)
+	srv.OnShutdown("tracing", shutdownTracing)
+	srv.OnShutdown("database", func(context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	if err := srv.Run(context.Background()); err != nil {
+		logger.Error("server stopped with an error", slog.Any("error", err))
+		os.Exit(1)
 	}
+	logger.Info("server stopped")
 }
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// GormPlugin records the duration and errors of every GORM statement and,
+// when DBName is set, exports the connection pool statistics of the sql.DB.
+type GormPlugin struct {
+	DBName string
+}
+
+// NewGormPlugin creates the plugin, dbName labels the pool statistics
+func NewGormPlugin(dbName string) *GormPlugin {
+	return &GormPlugin{DBName: dbName}
+}
+
+func (p *GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	// Before("*") and After("*") wrap the whole callback chain of each operation
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"row", cb.Row().Before("*"), cb.Row().After("*")},
+		{"raw", cb.Raw().Before("*"), cb.Raw().After("*")},
+	}
+	for _, h := range hooks {
+		operation := h.operation
+		if err := h.before.Register("metrics:before_"+operation, recordStart); err != nil {
+			return err
+		}
+		if err := h.after.Register("metrics:after_"+operation, func(db *gorm.DB) { observe(db, operation) }); err != nil {
+			return err
+		}
+	}
+
+	if p.DBName == "" {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return Registry.Register(collectors.NewDBStatsCollector(sqlDB, p.DBName))
+}
+
+// callbackRegistrar is implemented by the callbacks returned by Before and After
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+func recordStart(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observe(db *gorm.DB, operation string) {
+	value, ok := db.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+
+	// Raw statements have no table, they are grouped together
+	table := db.Statement.Table
+	if table == "" {
+		table = "unknown"
+	}
+	DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		DBQueryErrors.WithLabelValues(operation, table).Inc()
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector of the API and is served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+// HTTP metrics, labelled with the route template so that IDs in the path do not create new series
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "oms_http_requests_total",
+		Help: "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oms_http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Database metrics, recorded by GormPlugin
+var (
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oms_db_query_duration_seconds",
+		Help:    "Duration of GORM statements, by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "oms_db_query_errors_total",
+		Help: "GORM statements that failed, by operation and table. Record not found is not an error.",
+	}, []string{"operation", "table"})
+)
+
+// Business metrics
+var (
+	OrdersCreated = factory.NewCounter(prometheus.CounterOpts{
+		Name: "oms_orders_created_total",
+		Help: "Orders created.",
+	})
+
+	OrdersConfirmed = factory.NewCounter(prometheus.CounterOpts{
+		Name: "oms_orders_confirmed_total",
+		Help: "Orders moved to the Confirm status.",
+	})
+
+	OrdersCancelled = factory.NewCounter(prometheus.CounterOpts{
+		Name: "oms_orders_cancelled_total",
+		Help: "Orders moved to the Cancelled status.",
+	})
+
+	DiscountAmount = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "oms_order_discount_amount_total",
+		Help: "Discount amount granted on created orders, by discount type.",
+	}, []string{"type"})
+
+	PaymentOperations = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "oms_payment_operations_total",
+		Help: "Calls to payment providers, by provider, operation and outcome.",
+	}, []string{"provider", "operation", "outcome"})
+
+	PaymentWebhooks = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "oms_payment_webhooks_total",
+		Help: "Payment webhook deliveries, by provider and outcome.",
+	}, []string{"provider", "outcome"})
+)
+
+// Outcomes used as the "outcome" label of PaymentOperations
+const (
+	PaymentSucceeded = "succeeded"
+	PaymentDeclined  = "declined"
+	PaymentError     = "error"
+)
+
+// Outcomes used as the "outcome" label of PaymentWebhooks
+const (
+	WebhookApplied   = "applied"
+	WebhookDuplicate = "duplicate"
+	WebhookRejected  = "rejected" // Unknown provider, invalid signature or expired timestamp
+	WebhookFailed    = "failed"   // The event could not be applied
+)
+
+// Discount types used as the "type" label of DiscountAmount
+const (
+	DiscountSeasonal = "seasonal"
+	DiscountVolume   = "volume"
+	DiscountLoyalty  = "loyalty"
+	DiscountCoupon   = "coupon"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type widget struct {
+	ID   int
+	Name string
+}
+
+func TestGormPluginExportsQueryAndPoolMetrics(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.Use(NewGormPlugin("metrics_test")); err != nil {
+		t.Fatalf("use plugin: %v", err)
+	}
+	if err := db.AutoMigrate(&widget{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&widget{Name: "gear"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var found widget
+	if err := db.First(&found, 42).Error; err == nil {
+		t.Fatal("expected record not found")
+	}
+	db.Exec("SELECT * FROM missing_table")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	out := string(body)
+
+	for _, want := range []string{
+		`oms_db_query_duration_seconds_count{operation="create",table="widgets"} 1`,
+		`oms_db_query_duration_seconds_count{operation="query",table="widgets"} 1`,
+		`oms_db_query_errors_total{operation="raw",table="unknown"} 1`,
+		`go_sql_max_open_connections{db_name="metrics_test"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+	// A missing record is an expected outcome, not a database error
+	if strings.Contains(out, `oms_db_query_errors_total{operation="query"`) {
+		t.Error("record not found was counted as a query error")
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+)
+
+func TestRequestIDIsPropagatedAndLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(RequestID(logger), AccessLog())
+	r.GET("/users/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handled", slog.String("email", "jane@example.com"))
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("response %s = %q, want the propagated ID", RequestIDHeader, got)
+	}
+	if strings.Contains(buf.String(), "jane@example.com") {
+		t.Fatalf("log contains an unredacted email: %s", buf.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want the handler line and the access log line", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["request_id"] != "abc-123" {
+			t.Errorf("log line %q has request_id %v", line, entry["request_id"])
+		}
+	}
+}
+
+func TestRequestIDReplacesInvalidIDs(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID(slog.Default()))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	got := w.Header().Get(RequestIDHeader)
+	if got == "" || strings.ContainsAny(got, " \n") {
+		t.Fatalf("response %s = %q, want a generated ID", RequestIDHeader, got)
+	}
+}
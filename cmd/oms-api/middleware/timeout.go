@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds the request context with a deadline. Routes are looked up by
+// "METHOD /route/template" in perRoute and fall back to defaultTimeout, a zero
+// duration disables the deadline. Handlers pass the request context to GORM,
+// so queries still running at the deadline are canceled.
+func Timeout(defaultTimeout time.Duration, perRoute map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := defaultTimeout
+		if d, ok := perRoute[c.Request.Method+" "+c.FullPath()]; ok {
+			timeout = d
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQuery counts to a hundred million, which takes far longer than any timeout used here.
+// It is run with Exec because the SQLite driver interrupts statements on context
+// cancellation while Exec is running, but not while result rows are being stepped.
+const slowQuery = `WITH RECURSIVE cnt(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM cnt WHERE x < 100000000) SELECT COUNT(*) FROM cnt`
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestTimeoutCancelsSlowQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openTestDB(t)
+
+	queryErr := make(chan error, 1)
+	r := gin.New()
+	r.Use(Timeout(50*time.Millisecond, nil))
+	r.GET("/slow", func(c *gin.Context) {
+		err := db.WithContext(c.Request.Context()).Exec(slowQuery).Error
+		queryErr <- err
+		if err != nil {
+			apperrors.Render(c, apperrors.Internal(err, "Slow query failed"))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	elapsed := time.Since(start)
+
+	if err := <-queryErr; err == nil {
+		t.Fatal("expected the slow query to be interrupted")
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("query was not canceled at the deadline, request took %s", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusGatewayTimeout, w.Code, w.Body.String())
+	}
+
+	var problem apperrors.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if problem.Code != apperrors.CodeRequestTimeout {
+		t.Fatalf("expected code %q, got %q", apperrors.CodeRequestTimeout, problem.Code)
+	}
+}
+
+func TestTimeoutCanceledClientIsServiceUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openTestDB(t)
+
+	r := gin.New()
+	r.Use(Timeout(time.Minute, nil))
+	r.GET("/slow", func(c *gin.Context) {
+		if err := db.WithContext(c.Request.Context()).Exec(slowQuery).Error; err != nil {
+			apperrors.Render(c, apperrors.Internal(err, "Slow query failed"))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// Simulate a client that disconnects while the query is running
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("query was not canceled with the client, request took %s", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutPerRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deadlines := map[string]time.Duration{}
+	r := gin.New()
+	r.Use(Timeout(time.Second, map[string]time.Duration{
+		"GET /orders/:id": 5 * time.Second,
+		"GET /unbounded":  0,
+	}))
+	handler := func(c *gin.Context) {
+		if deadline, ok := c.Request.Context().Deadline(); ok {
+			deadlines[c.FullPath()] = time.Until(deadline).Round(time.Second)
+		}
+		c.Status(http.StatusNoContent)
+	}
+	r.GET("/orders/:id", handler)
+	r.GET("/items", handler)
+	r.GET("/unbounded", handler)
+
+	for _, path := range []string{"/orders/7", "/items", "/unbounded"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := deadlines["/orders/:id"]; got != 5*time.Second {
+		t.Errorf("expected the route timeout of 5s, got %s", got)
+	}
+	if got := deadlines["/items"]; got != time.Second {
+		t.Errorf("expected the default timeout of 1s, got %s", got)
+	}
+	if _, ok := deadlines["/unbounded"]; ok {
+		t.Error("expected no deadline for a route with a zero timeout")
+	}
+}
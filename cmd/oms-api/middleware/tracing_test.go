@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingContinuesTraceparentAndNestsQueries(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	db := openTestDB(t)
+	if err := db.Use(tracing.NewGormPlugin()); err != nil {
+		t.Fatalf("use plugin: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(Tracing())
+	r.GET("/orders/:id", func(c *gin.Context) {
+		var n int
+		db.WithContext(c.Request.Context()).Raw("SELECT 1").Scan(&n)
+		c.Status(http.StatusNoContent)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the query span and the server span", len(spans))
+	}
+	query, server := spans[0], spans[1]
+
+	if server.Name != "GET /orders/:id" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span = %q (%v), want \"GET /orders/:id\" (server)", server.Name, server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("server span trace ID = %s, want the one from traceparent %s", got, traceID)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the caller span", got)
+	}
+
+	if query.Name != "gorm.row" {
+		t.Errorf("query span = %q, want gorm.row", query.Name)
+	}
+	if query.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("query span is not a child of the server span")
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostalAddress is where a parcel or a bill goes. Orders and invoices keep a copy of it,
+// so changing or deleting an address of the address book does not rewrite them.
+type PostalAddress struct {
+	Name       string `json:"name"` // Recipient
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`       // State, province or county, when the country uses them
+	PostalCode string `json:"postal_code"` // Empty in the countries without postal codes
+	Country    string `json:"country"`     // ISO 3166-1 alpha-2 code, such as US or GB
+}
+
+// IsZero reports whether the address has not been given
+func (a PostalAddress) IsZero() bool {
+	return a == PostalAddress{}
+}
+
+// Address is an entry of the address book of a user. A user has at most one default
+// shipping and one default billing address, the first address is both.
+type Address struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id" gorm:"index"`
+
+	PostalAddress `gorm:"embedded"` // Flattened into the address in JSON
+
+	DefaultShipping bool           `json:"default_shipping" gorm:"not null;default:false"`
+	DefaultBilling  bool           `json:"default_billing" gorm:"not null;default:false"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Effects of coupons
+const (
+	CouponPercentage   = "percentage"    // Value is a rate of the eligible lines, 0.1 for 10%
+	CouponFixed        = "fixed"         // Value is an amount taken off the eligible lines
+	CouponFreeShipping = "free_shipping" // The shipping charge is waived
+)
+
+// Coupon is a code customers enter on an order for a discount on top of the automatic
+// seasonal, volume and loyalty discounts
+type Coupon struct {
+	ID                    int            `json:"id"`
+	Code                  string         `json:"code" gorm:"uniqueIndex"` // Upper case, deleted coupons keep their code
+	Effect                string         `json:"effect"`
+	Value                 float64        `json:"value"`                                    // Unused by free shipping coupons
+	StartsAt              *time.Time     `json:"starts_at"`                                // Valid from its creation when nil
+	EndsAt                *time.Time     `json:"ends_at"`                                  // Valid until deleted when nil
+	MinimumSpend          float64        `json:"minimum_spend"`                            // Items of the order after the automatic discounts
+	EligibleItemIDs       []int          `json:"eligible_item_ids" gorm:"serializer:json"` // Every item when empty
+	MaxRedemptions        int            `json:"max_redemptions"`                          // Unlimited when 0
+	MaxRedemptionsPerUser int            `json:"max_redemptions_per_user"`                 // Unlimited when 0
+	Redemptions           int            `json:"redemptions" gorm:"not null;default:0"`    // Orders using the coupon
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `json:"deleted_at"`
+}
+
+// Eligible reports whether the coupon discounts the item
+func (c Coupon) Eligible(itemID int) bool {
+	if len(c.EligibleItemIDs) == 0 {
+		return true
+	}
+	for _, id := range c.EligibleItemIDs {
+		if id == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// CouponRedemption is the use of a coupon by an order. It keeps the terms the coupon had
+// when it was redeemed, the order is priced with those even when the coupon is changed or
+// deleted afterwards. It is removed when the order is cancelled or drops the coupon, which
+// gives the use back.
+type CouponRedemption struct {
+	ID              int       `json:"id"`
+	CouponID        int       `json:"coupon_id" gorm:"index"`
+	OrderID         int       `json:"order_id" gorm:"uniqueIndex"` // An order uses one coupon at a time
+	UserID          int       `json:"user_id" gorm:"index"`
+	Code            string    `json:"code"`
+	Effect          string    `json:"effect"`
+	Value           float64   `json:"value"`
+	MinimumSpend    float64   `json:"minimum_spend"`
+	EligibleItemIDs []int     `json:"eligible_item_ids" gorm:"serializer:json"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Terms returns the coupon with the terms it had when it was redeemed
+func (r CouponRedemption) Terms() Coupon {
+	return Coupon{ID: r.CouponID, Code: r.Code, Effect: r.Effect, Value: r.Value, MinimumSpend: r.MinimumSpend, EligibleItemIDs: r.EligibleItemIDs}
+}
@@ -4,6 +4,8 @@ type Discounts struct {
 	SeasonalDiscount    float64 `json:"seasonal_discount"`
 	VolumeBasedDiscount float64 `json:"volume_based_discount"`
 	LoyaltyDiscount     float64 `json:"loyalty_discount"`
+	LoyaltyTier         string  `json:"loyalty_tier"`    // Tier the loyalty discount belongs to, empty without one
+	CouponDiscount      float64 `json:"coupon_discount"` // Amount taken off by the coupon of the order, the waived shipping charge for free shipping coupons
 	TotalDiscountAmount float64 `json:"total_discount_amount"`
 }
 
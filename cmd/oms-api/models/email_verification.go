@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// EmailVerification is a single-use token proving that a user controls an email address.
+// Only the SHA-256 hash of the token is stored.
+type EmailVerification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id" gorm:"index"`
+	Email     string     `json:"email"` // The address the token was issued for
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
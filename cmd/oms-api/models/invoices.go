@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Invoice is the bill of a confirmed order. It copies the customer, lines, discounts and
+// totals of the order when it is issued and never changes afterwards, so later changes
+// to the order, its items or the customer do not rewrite it.
+type Invoice struct {
+	ID               int           `json:"id"`
+	Number           string        `json:"number" gorm:"uniqueIndex"` // INV-<year>-<sequence>, such as INV-2024-000042
+	Year             int           `json:"year" gorm:"uniqueIndex:idx_invoices_year_sequence"`
+	Sequence         int           `json:"sequence" gorm:"uniqueIndex:idx_invoices_year_sequence"` // Gap-free within the year, starting at 1
+	OrderID          int           `json:"order_id" gorm:"uniqueIndex"`
+	Order            *Order        `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	UserID           int           `json:"user_id"`
+	CustomerName     string        `json:"customer_name"`
+	CustomerEmail    string        `json:"customer_email"`
+	BillingAddress   PostalAddress `json:"billing_address" gorm:"embedded;embeddedPrefix:billing_address_"` // Billing address of the order
+	Subtotal         float64       `json:"subtotal"`                                                        // Sum of the lines before discounts
+	Discount         float64       `json:"discount"`                                                        // Seasonal, loyalty and volume discounts of the order
+	Shipping         float64       `json:"shipping" gorm:"not null;default:0"`
+	Tax              float64       `json:"tax" gorm:"not null;default:0"`
+	PricesIncludeTax bool          `json:"prices_include_tax" gorm:"not null;default:false"` // The tax is part of the subtotal and shipping rather than added to them
+	Total            float64       `json:"total"`                                            // Grand total of the order
+	IssuedAt         time.Time     `json:"issued_at"`
+	Lines            []InvoiceLine `json:"lines" gorm:"foreignKey:InvoiceID"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// InvoiceLine is an order line as it was invoiced
+type InvoiceLine struct {
+	ID          int     `json:"id"`
+	InvoiceID   int     `json:"invoice_id" gorm:"index"`
+	ItemID      int     `json:"item_id"`
+	Description string  `json:"description"` // Name of the item when the invoice was issued
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Discount    float64 `json:"discount"` // Share of the order discounts of the line
+	Amount      float64 `json:"amount"`   // Quantity*UnitPrice-Discount
+	TaxRate     float64 `json:"tax_rate" gorm:"not null;default:0"`
+	Tax         float64 `json:"tax" gorm:"not null;default:0"` // Included in Amount when the prices include tax
+}
+
+// InvoiceSequence holds the last invoice number issued in a year. Numbers are taken from
+// it in the transaction that stores the invoice, so a failed invoice gives its number back.
+type InvoiceSequence struct {
+	Year       int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null;default:0"`
+}
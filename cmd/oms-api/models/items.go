@@ -12,8 +12,10 @@ type Item struct {
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
 	Price       float64        `json:"price"`
-	CreatedAt   time.Time      `json:"created_at"` // Change to time.Time
-	UpdatedAt   time.Time      `json:"updated_at"` // Change to time.Time
+	Weight      float64        `json:"weight" gorm:"not null;default:0"`                // Kilograms, shipping rates may be banded by weight
+	TaxCategory string         `json:"tax_category" gorm:"not null;default:'standard'"` // standard, reduced, zero or exempt
+	CreatedAt   time.Time      `json:"created_at"`                                      // Change to time.Time
+	UpdatedAt   time.Time      `json:"updated_at"`                                      // Change to time.Time
 	DeletedAt   gorm.DeletedAt `json:"deleted_at"`
 }
 
@@ -1,22 +1,51 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Order statuses, an order starts as pending and is either confirmed or cancelled.
+// A confirmed order is paid once its payment has been captured, refunds move a paid
+// order to partially refunded and, once everything was given back, to refunded.
+// Shipments move a confirmed or paid order to partially shipped, shipped once every
+// unit has been sent, and delivered once every shipment has arrived.
+const (
+	OrderStatusPending           = "Pending"
+	OrderStatusConfirmed         = "Confirm"
+	OrderStatusCancelled         = "Cancelled"
+	OrderStatusPaid              = "Paid"
+	OrderStatusPartiallyRefunded = "PartiallyRefunded"
+	OrderStatusRefunded          = "Refunded"
+	OrderStatusPartiallyShipped  = "PartiallyShipped"
+	OrderStatusShipped           = "Shipped"
+	OrderStatusDelivered         = "Delivered"
+)
+
 // Order represents an order in the OMS system
 type Order struct {
-	ID         int            `json:"id"`
-	UserID     int            `json:"user_id"`
-	TotalPrice float64        `json:"total_price"`
-	Status     string         `json:"status"`
-	FinalPrice float64        `json:"final_price"` // Total price after applying discounts
-	Items      []OrderItem    `json:"items"`       // List of items in the order
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"deleted_at"`
+	ID         int          `json:"id"`
+	UserID     int          `json:"user_id"`
+	TotalPrice float64      `json:"total_price"`
+	Status     string       `json:"status"`
+	FinalPrice float64      `json:"final_price"`                                       // Grand total: the discounted items with shipping, and tax unless prices include it
+	Shipping   ShippingLine `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"` // Charged on top of the discounted items
+	Tax        float64      `json:"tax" gorm:"not null;default:0"`                     // Tax of the lines and shipping
+	// Whether the prices of the order include its tax, as the catalog prices did when it was priced
+	PricesIncludeTax bool `json:"prices_include_tax" gorm:"not null;default:false"`
+	// Code of the coupon the order uses and the amount it took off, the waived shipping
+	// charge for free shipping coupons. Empty without a coupon.
+	CouponCode     string  `json:"coupon_code" gorm:"index"`
+	CouponDiscount float64 `json:"coupon_discount" gorm:"not null;default:0"`
+	// Copies of the addresses the order ships and is billed to, taken when it is created
+	ShippingAddress PostalAddress  `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_address_"`
+	BillingAddress  PostalAddress  `json:"billing_address" gorm:"embedded;embeddedPrefix:billing_address_"`
+	Items           []OrderItem    `json:"items"` // List of items in the order
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
 }
 
 // OrderItem represents an item in an order
@@ -26,21 +55,59 @@ type OrderItem struct {
 	ItemID    int            `json:"item_id"`
 	Quantity  int            `json:"quantity"`
 	Price     float64        `json:"price"`
+	Discount  float64        `json:"discount" gorm:"not null;default:0"` // Share of the order discounts of the whole line, the line costs Price*Quantity-Discount
+	TaxRate   float64        `json:"tax_rate" gorm:"not null;default:0"` // Rate of the tax category of the item at the shipping address
+	Tax       float64        `json:"tax" gorm:"not null;default:0"`      // Tax of the discounted line, added to its cost unless prices include tax
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at"`
 }
 
+// ShippingLine is the delivery charge of an order. It is not discounted, but free shipping
+// coupons waive it.
+type ShippingLine struct {
+	Method string  `json:"method"` // standard, express or pickup, empty on orders placed before shipping was charged
+	Zone   string  `json:"zone"`   // Zone of the rate table the destination is in
+	Price  float64 `json:"price" gorm:"not null;default:0"`
+	Tax    float64 `json:"tax" gorm:"not null;default:0"` // Shipping is taxed in its own category
+}
+
+// OrderTotals breaks the grand total of an order down
+type OrderTotals struct {
+	Subtotal   float64 `json:"subtotal"` // Lines at their catalog prices
+	Discount   float64 `json:"discount"`
+	Tax        float64 `json:"tax"` // Already part of the subtotal and shipping when prices include tax
+	Shipping   float64 `json:"shipping"`
+	GrandTotal float64 `json:"grand_total"`
+}
+
+// Totals returns the totals of the order
+func (o Order) Totals() OrderTotals {
+	totals := OrderTotals{Subtotal: o.TotalPrice, Tax: o.Tax, Shipping: o.Shipping.Price, GrandTotal: o.FinalPrice}
+	totals.Discount = o.TotalPrice + o.Shipping.Price - o.FinalPrice
+	if !o.PricesIncludeTax {
+		totals.Discount += o.Tax
+	}
+	totals.Discount = math.Round(totals.Discount*100) / 100
+	return totals
+}
+
 type OrderResposnse struct {
-	ID         int                 `json:"id"`
-	UserID     int                 `json:"user_id"`
-	TotalPrice float64             `json:"total_price"`
-	Status     string              `json:"status"`
-	FinalPrice float64             `json:"final_price"` // Total price after applying discounts
-	Items      []ResponseOrderItem `json:"items"`       // List of items in the order
-	CreatedAt  time.Time           `json:"created_at"`
-	UpdatedAt  time.Time           `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt      `json:"deleted_at"`
+	ID              int                 `json:"id"`
+	UserID          int                 `json:"user_id"`
+	TotalPrice      float64             `json:"total_price"`
+	Status          string              `json:"status"`
+	FinalPrice      float64             `json:"final_price"` // Total price after applying discounts, with shipping
+	Shipping        ShippingLine        `json:"shipping"`
+	Totals          OrderTotals         `json:"totals"`
+	CouponCode      string              `json:"coupon_code"`
+	CouponDiscount  float64             `json:"coupon_discount"`
+	ShippingAddress PostalAddress       `json:"shipping_address"`
+	BillingAddress  PostalAddress       `json:"billing_address"`
+	Items           []ResponseOrderItem `json:"items"` // List of items in the order
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt      `json:"deleted_at"`
 }
 
 type ResponseOrderItem struct {
@@ -48,18 +115,24 @@ type ResponseOrderItem struct {
 	// ItemName string `json:"item_name"`
 	Quantity int     `json:"quantity"`
 	Price    float64 `json:"price"`
+	TaxRate  float64 `json:"tax_rate"`
+	Tax      float64 `json:"tax"`
 }
 
 type OrderResposnseGet struct {
-	ID         int                    `json:"id"`
-	UserID     int                    `json:"user_id"`
-	TotalPrice float64                `json:"total_price"`
-	Status     string                 `json:"status"`
-	FinalPrice float64                `json:"final_price"` // Total price after applying discounts
-	Items      []ResponseOrderItemGet `json:"items"`       // List of items in the order
-	CreatedAt  time.Time              `json:"created_at"`
-	UpdatedAt  time.Time              `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt         `json:"deleted_at"`
+	ID              int                    `json:"id"`
+	UserID          int                    `json:"user_id"`
+	TotalPrice      float64                `json:"total_price"`
+	Status          string                 `json:"status"`
+	FinalPrice      float64                `json:"final_price"` // Total price after applying discounts, with shipping
+	Shipping        ShippingLine           `json:"shipping"`
+	Totals          OrderTotals            `json:"totals"`
+	ShippingAddress PostalAddress          `json:"shipping_address"`
+	BillingAddress  PostalAddress          `json:"billing_address"`
+	Items           []ResponseOrderItemGet `json:"items"` // List of items in the order
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt         `json:"deleted_at"`
 }
 
 type ResponseOrderItemGet struct {
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Payment statuses. A payment is created as pending, the provider either authorizes it
+// or it fails. An authorized payment is captured once the order is confirmed, it is
+// capturing while the provider is asked for it, or voided when the order is cancelled. A
+// captured payment is refunded once all of it was given back.
+const (
+	PaymentStatusPending    = "Pending"
+	PaymentStatusAuthorized = "Authorized"
+	PaymentStatusCapturing  = "Capturing"
+	PaymentStatusCaptured   = "Captured"
+	PaymentStatusVoided     = "Voided"
+	PaymentStatusFailed     = "Failed"
+	PaymentStatusRefunded   = "Refunded"
+)
+
+// Operations of the payment provider, recorded on every PaymentAttempt
+const (
+	PaymentOperationAuthorize = "authorize"
+	PaymentOperationCapture   = "capture"
+	PaymentOperationVoid      = "void"
+	PaymentOperationRefund    = "refund"
+)
+
+// Payment is the payment of an order through a payment provider
+type Payment struct {
+	ID                int              `json:"id"`
+	OrderID           int              `json:"order_id" gorm:"index"`
+	Order             *Order           `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	Provider          string           `json:"provider"`
+	ProviderReference string           `json:"provider_reference" gorm:"index"` // Authorization ID at the provider
+	Amount            float64          `json:"amount"`                          // Final price of the order when the payment was started
+	CapturedAmount    float64          `json:"captured_amount"`
+	CapturedAt        *time.Time       `json:"captured_at"`     // When the provider captured it, nil before
+	RefundedAmount    float64          `json:"refunded_amount"` // Includes refunds pending at the provider
+	Status            string           `json:"status"`
+	Attempts          []PaymentAttempt `json:"attempts" gorm:"foreignKey:PaymentID"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+// PaymentAttempt records a single call to the payment provider, successful or not
+type PaymentAttempt struct {
+	ID                int       `json:"id"`
+	PaymentID         int       `json:"payment_id" gorm:"index"`
+	Operation         string    `json:"operation"`
+	Amount            float64   `json:"amount"`
+	Succeeded         bool      `json:"succeeded"`
+	ProviderReference string    `json:"provider_reference,omitempty"`
+	ErrorCode         string    `json:"error_code,omitempty"`
+	ErrorMessage      string    `json:"error_message,omitempty"`
+	EventID           string    `json:"event_id,omitempty"` // Webhook event that reported the outcome, empty for calls of the API
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// WebhookEvent records a webhook event that has been applied, so that an event the
+// provider delivers again is recognized by its ID and ignored
+type WebhookEvent struct {
+	ID        int       `json:"id"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_webhook_events_provider_event"`
+	EventID   string    `json:"event_id" gorm:"uniqueIndex:idx_webhook_events_provider_event"`
+	Type      string    `json:"type"`
+	PaymentID int       `json:"payment_id" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
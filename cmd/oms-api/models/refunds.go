@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Refund statuses. A refund made through the API is pending while the provider is asked
+// for it, its amount and lines are held meanwhile so concurrent refunds cannot give them
+// back again.
+const (
+	RefundStatusPending   = "Pending"
+	RefundStatusSucceeded = "Succeeded"
+)
+
+// Refund gives back money of a paid order through its payment. Refunds made through
+// the API list the order lines they give back, refunds reported by the provider's
+// webhooks have no lines.
+type Refund struct {
+	ID                int          `json:"id"`
+	OrderID           int          `json:"order_id" gorm:"index"`
+	PaymentID         int          `json:"payment_id" gorm:"index"`
+	Payment           *Payment     `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	Amount            float64      `json:"amount"`
+	Shipping          float64      `json:"shipping" gorm:"not null;default:0"` // Part of the amount giving back the shipping charge, with its tax
+	Reason            string       `json:"reason"`
+	Status            string       `json:"status" gorm:"not null;default:'Succeeded'"`
+	ProviderReference string       `json:"provider_reference" gorm:"index"` // Refund ID at the provider
+	Lines             []RefundLine `json:"lines" gorm:"foreignKey:RefundID"`
+	CreatedAt         time.Time    `json:"created_at"`
+}
+
+// RefundLine is the part of a refund that gives back units of an order line
+type RefundLine struct {
+	ID          int     `json:"id"`
+	RefundID    int     `json:"refund_id" gorm:"index"`
+	OrderItemID int     `json:"order_item_id" gorm:"index"`
+	ItemID      int     `json:"item_id"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`                         // Unit price of the order line
+	Discount    float64 `json:"discount"`                      // Share of the line discount of the refunded units
+	Tax         float64 `json:"tax" gorm:"not null;default:0"` // Share of the line tax of the refunded units
+	Amount      float64 `json:"amount"`                        // Quantity*Price-Discount, plus Tax unless prices include it
+}
@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// Request DTOs are bound from the request body and validated before they are mapped
+// onto the GORM models. The same DTO is used on create, update and merge patch so
+// that every write path enforces the same rules.
+
+// UserRequest is the body of the create and update user endpoints
+type UserRequest struct {
+	Name   string `json:"name" binding:"required,notblank,max=100"`
+	Email  string `json:"email" binding:"required,email,max=254"`
+	Region string `json:"region" binding:"max=50"` // One of the regions of the campaign calendar, or empty
+}
+
+// ItemRequest is the body of the create and update item endpoints
+type ItemRequest struct {
+	Name        string  `json:"name" binding:"required,notblank,max=200"`
+	Description string  `json:"description" binding:"required,notblank,max=1000"`
+	Price       float64 `json:"price" binding:"required,gt=0"`
+	Weight      float64 `json:"weight" binding:"gte=0,max=100000"`                                   // Kilograms
+	TaxCategory string  `json:"tax_category" binding:"omitempty,oneof=standard reduced zero exempt"` // standard when empty
+}
+
+// Category returns the tax category of the item, standard when none is given
+func (r ItemRequest) Category() string {
+	if r.TaxCategory == "" {
+		return "standard"
+	}
+	return r.TaxCategory
+}
+
+// CreateOrderRequest is the body of the create order endpoint
+type CreateOrderRequest struct {
+	UserID         int                `json:"user_id" binding:"required,gt=0"`
+	Status         string             `json:"status" binding:"omitempty,oneof=Pending"`
+	ShippingMethod string             `json:"shipping_method" binding:"omitempty,oneof=standard express pickup"` // standard when empty
+	Items          []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	// The order ships to an address of the address book of the user or to one given inline,
+	// the default shipping address of the user when neither is given. It is billed the same
+	// way, to the default billing address and then to the shipping address.
+	ShippingAddressID int             `json:"shipping_address_id" binding:"omitempty,gt=0"`
+	ShippingAddress   *AddressRequest `json:"shipping_address"`
+	BillingAddressID  int             `json:"billing_address_id" binding:"omitempty,gt=0"`
+	BillingAddress    *AddressRequest `json:"billing_address"`
+	CouponCode        string          `json:"coupon_code" binding:"max=32"` // Matched in any case, no coupon when empty
+}
+
+// UpdateOrderRequest is the body of the update order endpoint
+type UpdateOrderRequest struct {
+	Status         string             `json:"status" binding:"omitempty,oneof=Pending Confirm Cancelled"`
+	ShippingMethod string             `json:"shipping_method" binding:"omitempty,oneof=standard express pickup"` // Unchanged when empty
+	Items          []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	CouponCode     *string            `json:"coupon_code" binding:"omitempty,max=32"` // Unchanged when missing, removed when empty
+}
+
+// OrderPatch lists the order fields that can be changed with a merge patch.
+// Items is an array, so a patch replaces the whole list (RFC 7396). An empty or null
+// coupon code removes the coupon of the order.
+type OrderPatch struct {
+	Items      []OrderItemRequest `json:"items" binding:"omitempty,min=1,dive"`
+	CouponCode *string            `json:"coupon_code" binding:"omitempty,max=32"`
+}
+
+// CouponRequest is the body of the create and update coupon endpoints. Value is a rate
+// for percentage coupons, an amount for fixed ones and ignored for free shipping. The
+// code cannot be changed on update.
+type CouponRequest struct {
+	Code                  string     `json:"code" binding:"required,alphanum,max=32"`
+	Effect                string     `json:"effect" binding:"required,oneof=percentage fixed free_shipping"`
+	Value                 float64    `json:"value" binding:"gte=0"`
+	StartsAt              *time.Time `json:"starts_at"` // Valid right away when missing
+	EndsAt                *time.Time `json:"ends_at"`   // Valid until deleted when missing
+	MinimumSpend          float64    `json:"minimum_spend" binding:"gte=0"`
+	EligibleItemIDs       []int      `json:"eligible_item_ids" binding:"omitempty,dive,gt=0"` // All items when empty
+	MaxRedemptions        int        `json:"max_redemptions" binding:"gte=0"`                 // Unlimited when 0
+	MaxRedemptionsPerUser int        `json:"max_redemptions_per_user" binding:"gte=0"`        // Unlimited when 0
+}
+
+// AddressRequest is the body of the create and update address endpoints and an address
+// given inline on an order. The postal code must have the format of the country.
+type AddressRequest struct {
+	Name            string `json:"name" binding:"required,notblank,max=100"`
+	Line1           string `json:"line1" binding:"required,notblank,max=200"`
+	Line2           string `json:"line2" binding:"max=200"`
+	City            string `json:"city" binding:"required,notblank,max=100"`
+	State           string `json:"state" binding:"max=100"`
+	PostalCode      string `json:"postal_code" binding:"max=20,postal_code"`
+	Country         string `json:"country" binding:"required,country"`
+	DefaultShipping bool   `json:"default_shipping"` // Ignored on the addresses of an order
+	DefaultBilling  bool   `json:"default_billing"`  // Ignored on the addresses of an order
+}
+
+// PostalAddress returns the address without the default flags
+func (r AddressRequest) PostalAddress() PostalAddress {
+	return PostalAddress{Name: r.Name, Line1: r.Line1, Line2: r.Line2, City: r.City, State: r.State, PostalCode: r.PostalCode, Country: r.Country}
+}
+
+// StartPaymentRequest is the body of the start payment endpoint
+type StartPaymentRequest struct {
+	PaymentMethod string `json:"payment_method" binding:"required,notblank,max=100"` // Token of the card or account at the provider
+	Provider      string `json:"provider" binding:"max=50"`                          // The default provider when empty
+}
+
+// RefundRequest is the body of the refund endpoint. Without items everything that has
+// not been refunded yet is refunded.
+type RefundRequest struct {
+	Items  []OrderItemRequest `json:"items" binding:"omitempty,dive"`
+	Reason string             `json:"reason" binding:"max=500"`
+}
+
+// CreateShipmentRequest is the body of the create shipment endpoint. Without items
+// everything that has not been shipped yet is shipped.
+type CreateShipmentRequest struct {
+	Carrier        string             `json:"carrier" binding:"required,notblank,max=50"`
+	TrackingNumber string             `json:"tracking_number" binding:"required,notblank,max=100"`
+	Items          []OrderItemRequest `json:"items" binding:"omitempty,dive"`
+}
+
+// OrderItemRequest is a single line of an order request
+type OrderItemRequest struct {
+	ItemID   int `json:"item_id" binding:"required,gt=0"`
+	Quantity int `json:"quantity" binding:"required,gt=0,max=10000"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Shipment statuses. A shipment is in transit once it has been handed to the carrier,
+// until it is marked as delivered.
+const (
+	ShipmentStatusInTransit = "InTransit"
+	ShipmentStatusDelivered = "Delivered"
+)
+
+// Shipment is a parcel sent for an order. An order may ship in several parcels, each
+// with some units of its lines.
+type Shipment struct {
+	ID             int            `json:"id"`
+	OrderID        int            `json:"order_id" gorm:"index"`
+	Order          *Order         `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	Carrier        string         `json:"carrier" gorm:"uniqueIndex:idx_shipments_carrier_tracking"`
+	TrackingNumber string         `json:"tracking_number" gorm:"uniqueIndex:idx_shipments_carrier_tracking"`
+	Status         string         `json:"status"`
+	ShippedAt      time.Time      `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+	Lines          []ShipmentLine `json:"lines" gorm:"foreignKey:ShipmentID"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ShipmentLine is the part of a shipment that carries units of an order line
+type ShipmentLine struct {
+	ID          int `json:"id"`
+	ShipmentID  int `json:"shipment_id" gorm:"index"`
+	OrderItemID int `json:"order_item_id" gorm:"index"`
+	ItemID      int `json:"item_id"`
+	Quantity    int `json:"quantity"`
+}
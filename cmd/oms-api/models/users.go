@@ -8,13 +8,15 @@ import (
 
 // User represents a user in the OMS system
 type User struct {
-	ID        int            `json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at"`
-	Orders    []Order        `gorm:"foreignKey:UserID"` // Ensure the foreign key is correctly set
+	ID              int            `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`                             // Stored normalized, unique among non-deleted users
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`                 // Set once the current email address is verified
+	Region          string         `json:"region" gorm:"not null;default:''"` // Campaign region, the business timezone applies when empty
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
+	Orders          []Order        `gorm:"foreignKey:UserID"` // Ensure the foreign key is correctly set
 
 }
 
@@ -23,19 +25,17 @@ type UserOrder struct {
 	OrderID int `json:"order_id"`
 }
 
-
-
 type UserResponse struct {
-	ID        int            `json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+	ID              int            `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	Region          string         `json:"region"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
 }
 
-
-
 type UserOrderResponse struct {
 	ID            int             `json:"id"`
 	Name          string          `json:"name"`
@@ -50,8 +50,10 @@ type OrderResponse struct {
 	ID         int            `json:"id"`
 	TotalPrice float64        `json:"total_price"`
 	Status     string         `json:"status"`
-	FinalPrice float64        `json:"final_price"` // Total price after applying discounts
-	Items      []ItemResponse `json:"items"`       // List of items in the order
+	FinalPrice float64        `json:"final_price"` // Total price after applying discounts, with shipping
+	Shipping   ShippingLine   `json:"shipping"`
+	Totals     OrderTotals    `json:"totals"`
+	Items      []ItemResponse `json:"items"` // List of items in the order
 }
 
 // OrderItem represents an item in an order
@@ -0,0 +1,140 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Payment method tokens that make the mock provider decline. Every other token is accepted.
+const (
+	MockMethodDeclined          = "tok_declined"           // Authorization is declined
+	MockMethodInsufficientFunds = "tok_insufficient_funds" // Authorization is declined
+	MockMethodCaptureDeclined   = "tok_capture_declined"   // Authorization succeeds, capture is declined
+)
+
+// Mock is an in-process provider for tests and local development. It is deterministic:
+// the outcome depends only on the payment method token and the operations before, and
+// references are numbered in the order they are issued.
+type Mock struct {
+	mu             sync.Mutex
+	seq            int
+	authorizations map[string]*mockAuthorization
+	payments       map[int]string // Reference of the authorization of every payment ID
+}
+
+type mockAuthorization struct {
+	method   string
+	amount   float64
+	captured float64
+	refunded float64
+	voided   bool
+}
+
+// NewMock creates a mock provider without authorizations
+func NewMock() *Mock {
+	return &Mock{authorizations: map[string]*mockAuthorization{}, payments: map[int]string{}}
+}
+
+func (m *Mock) Name() string { return "mock" }
+
+func (m *Mock) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	switch req.Method {
+	case MockMethodDeclined:
+		return "", &DeclinedError{Code: "card_declined", Message: "The card was declined"}
+	case MockMethodInsufficientFunds:
+		return "", &DeclinedError{Code: "insufficient_funds", Message: "The account has insufficient funds"}
+	}
+	if req.Amount <= 0 {
+		return "", &DeclinedError{Code: "invalid_amount", Message: "The amount must be positive"}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reference, ok := m.payments[req.PaymentID]; ok {
+		return reference, nil
+	}
+	reference := m.next("auth")
+	m.authorizations[reference] = &mockAuthorization{method: req.Method, amount: req.Amount}
+	m.payments[req.PaymentID] = reference
+	return reference, nil
+}
+
+func (m *Mock) Capture(ctx context.Context, reference string, amount float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	auth, err := m.authorization(reference)
+	if err != nil {
+		return err
+	}
+	switch {
+	case auth.method == MockMethodCaptureDeclined:
+		return &DeclinedError{Code: "capture_declined", Message: "The capture was declined"}
+	case auth.voided:
+		return &DeclinedError{Code: "authorization_voided", Message: "The authorization was voided"}
+	case auth.captured > auth.amount-0.005 && amount > auth.amount-0.005:
+		return nil // Captured in full before
+	case auth.captured+amount > auth.amount+0.005:
+		return &DeclinedError{Code: "amount_too_large", Message: fmt.Sprintf("Only %.2f of the authorization can be captured", auth.amount-auth.captured)}
+	}
+	auth.captured += amount
+	return nil
+}
+
+func (m *Mock) Void(ctx context.Context, reference string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	auth, err := m.authorization(reference)
+	if err != nil {
+		return err
+	}
+	if auth.captured > 0 {
+		return &DeclinedError{Code: "already_captured", Message: "A captured authorization cannot be voided"}
+	}
+	auth.voided = true
+	return nil
+}
+
+func (m *Mock) Refund(ctx context.Context, reference string, amount float64) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	auth, err := m.authorization(reference)
+	if err != nil {
+		return "", err
+	}
+	if amount <= 0 || auth.refunded+amount > auth.captured+0.005 {
+		return "", &DeclinedError{Code: "amount_too_large", Message: fmt.Sprintf("Only %.2f of the capture can be refunded", auth.captured-auth.refunded)}
+	}
+	auth.refunded += amount
+	return m.next("refund"), nil
+}
+
+// next returns a new reference, m.mu must be held
+func (m *Mock) next(kind string) string {
+	m.seq++
+	return fmt.Sprintf("mock_%s_%d", kind, m.seq)
+}
+
+// authorization returns the authorization with reference, m.mu must be held
+func (m *Mock) authorization(reference string) (*mockAuthorization, error) {
+	auth, ok := m.authorizations[reference]
+	if !ok {
+		return nil, &DeclinedError{Code: "unknown_reference", Message: fmt.Sprintf("No authorization %s", reference)}
+	}
+	return auth, nil
+}
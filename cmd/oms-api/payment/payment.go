@@ -0,0 +1,45 @@
+// Package payment talks to payment providers. Providers hold money in two steps: an
+// authorization reserves the amount, a capture collects it. Authorizations that are not
+// captured are voided, captured money is given back with refunds.
+package payment
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider is a payment service provider
+type Provider interface {
+	// Name identifies the provider in payments and webhook routes
+	Name() string
+	// Authorize reserves the amount and returns the reference of the authorization. It is
+	// idempotent on the payment ID: asking again for a payment returns the authorization
+	// made for it before instead of reserving the amount twice.
+	Authorize(ctx context.Context, req AuthorizeRequest) (string, error)
+	// Capture collects amount of an authorization. Capturing an authorization that has
+	// been captured in full before succeeds without collecting it again.
+	Capture(ctx context.Context, reference string, amount float64) error
+	// Void releases an authorization that has not been captured
+	Void(ctx context.Context, reference string) error
+	// Refund gives back amount of a captured authorization and returns the reference of the refund
+	Refund(ctx context.Context, reference string, amount float64) (string, error)
+}
+
+// AuthorizeRequest asks a provider to reserve money for a payment
+type AuthorizeRequest struct {
+	PaymentID int
+	OrderID   int
+	Amount    float64
+	Method    string // Token of the card or account, issued by the provider
+}
+
+// DeclinedError is returned when the provider refused an operation. Any other error
+// means the provider could not be reached or failed, the outcome is unknown then.
+type DeclinedError struct {
+	Code    string
+	Message string
+}
+
+func (e *DeclinedError) Error() string {
+	return fmt.Sprintf("declined: %s: %s", e.Code, e.Message)
+}
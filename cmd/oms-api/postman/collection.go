@@ -0,0 +1,175 @@
+// Package postman replays a Postman collection against an http.Handler. It reads the
+// v2.0 and v2.1 collection formats, substitutes {{variables}} and checks every response
+// against the status code and the assertions in the test script of its request.
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Collection is a Postman collection
+type Collection struct {
+	Info      Info       `json:"info"`
+	Item      []Item     `json:"item"`
+	Variables []Variable `json:"variable"`
+}
+
+// Info describes the collection
+type Info struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// Variable is a collection variable
+type Variable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Item is either a folder, which has items of its own, or a request
+type Item struct {
+	Name    string   `json:"name"`
+	Item    []Item   `json:"item"`
+	Request *Request `json:"request"`
+	Event   []Event  `json:"event"`
+}
+
+// Request is the request of an item
+type Request struct {
+	Method string   `json:"method"`
+	Header []Header `json:"header"`
+	Body   *Body    `json:"body"`
+	URL    URL      `json:"url"`
+}
+
+// Header is a request header, disabled headers are not sent
+type Header struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Body is a request body, only the raw mode is supported
+type Body struct {
+	Mode    string `json:"mode"`
+	Raw     string `json:"raw"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+// URL is the raw URL of a request. Collections store it either as a string (v2.0)
+// or as an object with a raw member (v2.1).
+type URL string
+
+func (u *URL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = URL(raw)
+		return nil
+	}
+	var object struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("url must be a string or an object with a raw member: %w", err)
+	}
+	*u = URL(object.Raw)
+	return nil
+}
+
+// Event is a script attached to a request, the runner only looks at "test" scripts
+type Event struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec []string `json:"exec"`
+	} `json:"script"`
+}
+
+// Step is a request of the collection in the order it is sent, with the parsed test script
+type Step struct {
+	Name       string // Folder names and the request name, joined by " / "
+	Request    Request
+	Assertions []Assertion
+}
+
+// Load reads a collection from a file
+func Load(path string) (*Collection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse collection %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Steps flattens the folders of the collection into the list of requests to send and
+// parses their test scripts. Items without a request or with an unsupported script are
+// an error, so a broken collection is reported before anything is sent.
+func (c *Collection) Steps() ([]Step, error) {
+	var steps []Step
+	var walk func(prefix string, items []Item) error
+	walk = func(prefix string, items []Item) error {
+		for _, item := range items {
+			name := item.Name
+			if prefix != "" {
+				name = prefix + " / " + item.Name
+			}
+			if item.Request == nil {
+				if len(item.Item) == 0 {
+					return fmt.Errorf("%s: neither a folder nor a request", name)
+				}
+				if err := walk(name, item.Item); err != nil {
+					return err
+				}
+				continue
+			}
+			if item.Request.Method == "" || item.Request.URL == "" {
+				return fmt.Errorf("%s: request needs a method and a URL", name)
+			}
+			step := Step{Name: name, Request: *item.Request}
+			for _, event := range item.Event {
+				if event.Listen != "test" {
+					continue
+				}
+				assertions, err := ParseScript(event.Script.Exec)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				step.Assertions = append(step.Assertions, assertions...)
+			}
+			steps = append(steps, step)
+		}
+		return nil
+	}
+	if err := walk("", c.Item); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+var variablePattern = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// substitute replaces the {{variables}} in s, the names of undefined variables are returned
+func substitute(s string, vars map[string]string) (string, []string) {
+	var missing []string
+	out := variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := strings.TrimSpace(match[2 : len(match)-2])
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+	return out, missing
+}
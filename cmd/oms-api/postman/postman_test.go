@@ -0,0 +1,132 @@
+package postman
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const collectionJSON = `{
+	"info": {"name": "test"},
+	"variable": [{"key": "baseUrl", "value": "http://localhost:8080"}],
+	"item": [
+		{"name": "folder", "item": [
+			{
+				"name": "create",
+				"event": [{"listen": "test", "script": {"exec": [
+					"pm.test(\"created\", function () {",
+					"    pm.response.to.have.status(201);",
+					"    pm.expect(pm.response.json().thing.name).to.eql(\"box\");",
+					"});",
+					"pm.collectionVariables.set(\"thingId\", pm.response.json().thing.id);"
+				]}}],
+				"request": {
+					"method": "POST",
+					"header": [{"key": "X-Trace", "value": "{{trace}}"}, {"key": "X-Off", "value": "x", "disabled": true}],
+					"body": {"mode": "raw", "raw": "{\"name\": \"box\"}", "options": {"raw": {"language": "json"}}},
+					"url": "{{baseUrl}}/things"
+				}
+			}
+		]},
+		{"name": "get", "request": {"method": "GET", "url": {"raw": "{{baseUrl}}/things/{{thingId}}?full=1"}}},
+		{"name": "missing", "request": {"method": "GET", "url": "{{baseUrl}}/things/{{otherId}}"}},
+		{
+			"name": "wrong",
+			"event": [{"listen": "test", "script": {"exec": ["pm.expect(pm.response.json().things[0].name).to.eql(\"crate\");"]}}],
+			"request": {"method": "GET", "url": "{{baseUrl}}/things"}
+		}
+	]
+}`
+
+// echo records the requests it gets and answers like a tiny REST API
+type echo struct {
+	requests []*http.Request
+	bodies   []string
+}
+
+func (e *echo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, string(body))
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"thing": {"id": 7, "name": "box"}}`))
+	case r.URL.Path == "/things":
+		w.Write([]byte(`{"things": [{"id": 7, "name": "box"}]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code": "not_found"}`))
+	}
+}
+
+func TestRun(t *testing.T) {
+	var c Collection
+	if err := json.Unmarshal([]byte(collectionJSON), &c); err != nil {
+		t.Fatal(err)
+	}
+	handler := &echo{}
+	runner := &Runner{Handler: handler, Variables: map[string]string{"trace": "abc"}}
+	report, err := runner.Run(context.Background(), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != 4 || report.Failed() != 3 {
+		t.Fatalf("results %+v, want 4 results and 3 failures", report.Results)
+	}
+	create, get, missing, wrong := report.Results[0], report.Results[1], report.Results[2], report.Results[3]
+
+	if create.Name != "folder / create" || !create.Passed() {
+		t.Errorf("create %+v, want a passing request named after its folder", create)
+	}
+	post := handler.requests[0]
+	if post.Header.Get("Content-Type") != "application/json" || post.Header.Get("X-Trace") != "abc" || post.Header.Get("X-Off") != "" {
+		t.Errorf("create headers %v", post.Header)
+	}
+	if handler.bodies[0] != `{"name": "box"}` {
+		t.Errorf("create body %q", handler.bodies[0])
+	}
+
+	// The variable set by create is used, the default status check wants 2xx
+	if get.URL != "/things/7?full=1" || get.Status != http.StatusNotFound || len(get.Failures) != 1 || get.Body == "" {
+		t.Errorf("get %+v, want a 404 failure for /things/7?full=1", get)
+	}
+	if missing.Status != 0 || len(missing.Failures) != 1 || !strings.Contains(missing.Failures[0], "otherId") {
+		t.Errorf("missing %+v, want an undefined variable that is not sent", missing)
+	}
+	if len(wrong.Failures) != 1 || wrong.Failures[0] != `response.things[0].name is "box", want "crate"` {
+		t.Errorf("wrong failures %q", wrong.Failures)
+	}
+
+	var text strings.Builder
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "4 requests, 1 passed, 3 failed") {
+		t.Errorf("report:\n%s", text.String())
+	}
+}
+
+func TestParseScriptRejectsUnsupportedStatements(t *testing.T) {
+	for _, line := range []string{
+		`pm.expect(pm.response.code).to.be.oneOf([200, 201]);`,
+		`pm.expect(pm.response.json().id).to.eql(undefined);`,
+		`console.log(pm.response.json());`,
+	} {
+		if _, err := ParseScript([]string{line}); err == nil {
+			t.Errorf("%s parsed, want an error", line)
+		}
+	}
+}
+
+func TestStepsRejectsRequestsWithoutURL(t *testing.T) {
+	c := Collection{Item: []Item{{Name: "broken", Request: &Request{Method: http.MethodGet}}}}
+	if _, err := c.Steps(); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("Steps() error %v, want one naming the request", err)
+	}
+}
@@ -0,0 +1,72 @@
+package postman
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the outcome of a collection run
+type Report struct {
+	Collection string   `json:"collection"`
+	Results    []Result `json:"results"`
+}
+
+// Result is the outcome of a single request
+type Result struct {
+	Name     string        `json:"name"`
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Status   int           `json:"status"` // Zero when the request was not sent
+	Duration time.Duration `json:"duration_ns"`
+	Failures []string      `json:"failures,omitempty"`
+	Body     string        `json:"body,omitempty"` // Response body of failed requests
+}
+
+// Passed reports whether the request met all its assertions
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Failed returns the number of failed requests
+func (r *Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteText writes a table with a line per request, followed by the failures and a summary
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Collection %s\n\n", r.Collection)
+	for _, result := range r.Results {
+		outcome := "PASS"
+		if !result.Passed() {
+			outcome = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", outcome, result.Name, result.Method, result.Status, result.URL)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range r.Results {
+		if result.Passed() {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", result.Name)
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "  - %s\n", failure)
+		}
+		if result.Body != "" {
+			fmt.Fprintf(w, "  response: %s\n", result.Body)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d requests, %d passed, %d failed\n", len(r.Results), len(r.Results)-r.Failed(), r.Failed())
+	return err
+}
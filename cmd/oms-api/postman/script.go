@@ -0,0 +1,140 @@
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The runner does not execute JavaScript. Test scripts are limited to the statements
+// below, which Postman runs as they are, so QA can still use the collection in the app:
+//
+//	pm.test("name", function () {
+//	    pm.response.to.have.status(201);
+//	    pm.expect(pm.response.json().user.email).to.eql("ada@example.com");
+//	});
+//	pm.collectionVariables.set("userId", pm.response.json().user.id);
+//
+// Blank lines, // comments and the pm.test wrapper lines are ignored.
+var (
+	statusStatement = regexp.MustCompile(`^pm\.response\.to\.have\.status\((\d{3})\);?$`)
+	eqlStatement    = regexp.MustCompile(`^pm\.expect\(pm\.response\.json\(\)((?:\.[A-Za-z_$][\w$]*|\[\d+\])*)\)\.to\.eql\((.+)\);?$`)
+	setStatement    = regexp.MustCompile(`^pm\.collectionVariables\.set\("([^"]+)",\s*pm\.response\.json\(\)((?:\.[A-Za-z_$][\w$]*|\[\d+\])*)\);?$`)
+	testOpen        = regexp.MustCompile(`^pm\.test\(.*function\s*\(\)\s*{$`)
+	pathSegment     = regexp.MustCompile(`\.([A-Za-z_$][\w$]*)|\[(\d+)\]`)
+)
+
+// Assertion is a statement of a test script
+type Assertion struct {
+	Kind     AssertionKind
+	Status   int         // Expected status of a status assertion
+	Path     string      // JSON path into the response body, like .user.id or .orders[0].id
+	Expected interface{} // Expected value of an equality assertion, decoded as JSON
+	Variable string      // Collection variable set by a set assertion
+}
+
+// AssertionKind tells the statements of a test script apart
+type AssertionKind int
+
+const (
+	AssertStatus AssertionKind = iota // pm.response.to.have.status
+	AssertEqual                       // pm.expect(pm.response.json()...).to.eql
+	SetVariable                       // pm.collectionVariables.set
+)
+
+// ParseScript parses the lines of a test script
+func ParseScript(lines []string) ([]Assertion, error) {
+	var assertions []Assertion
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "//"), testOpen.MatchString(line), line == "});":
+			continue
+		}
+
+		if m := statusStatement.FindStringSubmatch(line); m != nil {
+			status, _ := strconv.Atoi(m[1])
+			assertions = append(assertions, Assertion{Kind: AssertStatus, Status: status})
+			continue
+		}
+		if m := eqlStatement.FindStringSubmatch(line); m != nil {
+			var expected interface{}
+			if err := json.Unmarshal([]byte(m[2]), &expected); err != nil {
+				return nil, fmt.Errorf("script line %d: expected value %s is not JSON", i+1, m[2])
+			}
+			assertions = append(assertions, Assertion{Kind: AssertEqual, Path: m[1], Expected: expected})
+			continue
+		}
+		if m := setStatement.FindStringSubmatch(line); m != nil {
+			assertions = append(assertions, Assertion{Kind: SetVariable, Variable: m[1], Path: m[2]})
+			continue
+		}
+		return nil, fmt.Errorf("script line %d: unsupported statement %q", i+1, line)
+	}
+	return assertions, nil
+}
+
+// check applies the assertion to a response. Set assertions store the value in vars.
+func (a Assertion) check(status int, body interface{}, vars map[string]string) error {
+	switch a.Kind {
+	case AssertStatus:
+		if status != a.Status {
+			return fmt.Errorf("status %d, want %d", status, a.Status)
+		}
+		return nil
+	}
+
+	value, err := lookup(body, a.Path)
+	if err != nil {
+		return err
+	}
+	if a.Kind == SetVariable {
+		vars[a.Variable] = variableValue(value)
+		return nil
+	}
+	if !reflect.DeepEqual(value, a.Expected) {
+		got, _ := json.Marshal(value)
+		want, _ := json.Marshal(a.Expected)
+		return fmt.Errorf("response%s is %s, want %s", a.Path, got, want)
+	}
+	return nil
+}
+
+// lookup follows path through a decoded JSON document
+func lookup(body interface{}, path string) (interface{}, error) {
+	value := body
+	for _, m := range pathSegment.FindAllStringSubmatch(path, -1) {
+		if m[1] != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("response%s: %s is not a member of an object", path, m[1])
+			}
+			if value, ok = object[m[1]]; !ok {
+				return nil, fmt.Errorf("response%s: no member %s", path, m[1])
+			}
+			continue
+		}
+		index, _ := strconv.Atoi(m[2])
+		array, ok := value.([]interface{})
+		if !ok || index >= len(array) {
+			return nil, fmt.Errorf("response%s: no element %d", path, index)
+		}
+		value = array[index]
+	}
+	return value, nil
+}
+
+// variableValue formats a JSON value the way Postman stores it in a variable
+func variableValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
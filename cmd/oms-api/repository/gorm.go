@@ -0,0 +1,590 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore is the Store backed by the database. The connection has to be opened with
+// TranslateError so that unique violations are reported as gorm.ErrDuplicatedKey.
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore creates a store on db
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Users() UserRepository { return gormUsers{s.db} }
+
+func (s *GormStore) Items() ItemRepository { return gormItems{s.db} }
+
+func (s *GormStore) Orders() OrderRepository { return gormOrders{s.db} }
+
+func (s *GormStore) EmailVerifications() EmailVerificationRepository {
+	return gormEmailVerifications{s.db}
+}
+
+func (s *GormStore) Payments() PaymentRepository { return gormPayments{s.db} }
+
+func (s *GormStore) WebhookEvents() WebhookEventRepository { return gormWebhookEvents{s.db} }
+
+func (s *GormStore) Refunds() RefundRepository { return gormRefunds{s.db} }
+
+func (s *GormStore) Invoices() InvoiceRepository { return gormInvoices{s.db} }
+
+func (s *GormStore) Shipments() ShipmentRepository { return gormShipments{s.db} }
+
+func (s *GormStore) Addresses() AddressRepository { return gormAddresses{s.db} }
+
+func (s *GormStore) Coupons() CouponRepository { return gormCoupons{s.db} }
+
+// Transaction runs fn in a database transaction, nested calls use savepoints
+func (s *GormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormStore{db: tx})
+	})
+}
+
+// notFound maps gorm.ErrRecordNotFound to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// softDelete deletes the record with id, telling a missing record from one that is already deleted
+func softDelete(db *gorm.DB, model interface{}, id int) error {
+	result := db.Delete(model, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Unscoped().Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadyDeleted
+	}
+	return ErrNotFound
+}
+
+// update writes columns of the record and reports ErrNotFound when no active record was changed
+func update(db *gorm.DB, model interface{}, columns []string) error {
+	selected := append(append([]string{}, columns...), "updated_at")
+	result := db.Model(model).Select(selected).Updates(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	err := r.db.WithContext(ctx).Omit(clause.Associations).Create(user).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func (r gormUsers) List(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) Get(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) GetWithOrders(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Orders", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Orders.Items").First(&user, id).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) Update(ctx context.Context, user *models.User, columns ...string) error {
+	user.UpdatedAt = time.Now()
+	err := update(r.db.WithContext(ctx).Omit(clause.Associations), user, columnsOr(columns, userColumns))
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func (r gormUsers) Delete(ctx context.Context, id int) error {
+	return softDelete(r.db.WithContext(ctx), &models.User{}, id)
+}
+
+type gormItems struct{ db *gorm.DB }
+
+func (r gormItems) Create(ctx context.Context, item *models.Item) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r gormItems) List(ctx context.Context) ([]models.Item, error) {
+	var items []models.Item
+	err := r.db.WithContext(ctx).Order("id").Find(&items).Error
+	return items, err
+}
+
+func (r gormItems) Get(ctx context.Context, id int) (models.Item, error) {
+	var item models.Item
+	err := r.db.WithContext(ctx).First(&item, id).Error
+	return item, notFound(err)
+}
+
+func (r gormItems) Update(ctx context.Context, item *models.Item, columns ...string) error {
+	item.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx), item, columnsOr(columns, itemColumns))
+}
+
+func (r gormItems) Delete(ctx context.Context, id int) error {
+	return softDelete(r.db.WithContext(ctx), &models.Item{}, id)
+}
+
+type gormOrders struct{ db *gorm.DB }
+
+func (r gormOrders) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+			return err
+		}
+		for i := range order.Items {
+			order.Items[i].OrderID = order.ID
+		}
+		if len(order.Items) > 0 {
+			if err := tx.Create(&order.Items).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.UserOrder{UserID: order.UserID, OrderID: order.ID}).Error
+	})
+}
+
+func (r gormOrders) List(ctx context.Context) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.WithContext(ctx).Preload("Items").Order("id").Find(&orders).Error
+	return orders, err
+}
+
+func (r gormOrders) Get(ctx context.Context, id int) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("Items").First(&order, id).Error
+	return order, notFound(err)
+}
+
+func (r gormOrders) Lock(ctx context.Context, id int) (models.Order, error) {
+	// The update locks the row of the order until the transaction ends
+	result := r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", id).UpdateColumn("status", gorm.Expr("status"))
+	if result.Error != nil {
+		return models.Order{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Order{}, ErrNotFound
+	}
+	return r.Get(ctx, id)
+}
+
+func (r gormOrders) ReplaceItems(ctx context.Context, orderID int, items []models.OrderItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", orderID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].ID = 0
+			items[i].OrderID = orderID
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+}
+
+func (r gormOrders) Update(ctx context.Context, order *models.Order, columns ...string) error {
+	order.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx).Omit(clause.Associations), order, columnsOr(columns, orderColumns))
+}
+
+func (r gormOrders) Delete(ctx context.Context, id int) error {
+	return softDelete(r.db.WithContext(ctx), &models.Order{}, id)
+}
+
+type gormEmailVerifications struct{ db *gorm.DB }
+
+func (r gormEmailVerifications) Create(ctx context.Context, verification *models.EmailVerification) error {
+	return r.db.WithContext(ctx).Create(verification).Error
+}
+
+func (r gormEmailVerifications) FindUnused(ctx context.Context, tokenHash string) (models.EmailVerification, error) {
+	var verification models.EmailVerification
+	err := r.db.WithContext(ctx).Where("token_hash = ? AND used_at IS NULL", tokenHash).First(&verification).Error
+	return verification, notFound(err)
+}
+
+func (r gormEmailVerifications) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	// The used_at condition makes concurrent redemptions of the same token fail
+	result := r.db.WithContext(ctx).Model(&models.EmailVerification{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormPayments struct{ db *gorm.DB }
+
+// withAttempts preloads the attempts of payments in the order they were made
+func withAttempts(db *gorm.DB) *gorm.DB {
+	return db.Preload("Attempts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+}
+
+func (r gormPayments) Create(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(payment).Error
+}
+
+func (r gormPayments) Get(ctx context.Context, id int) (models.Payment, error) {
+	var payment models.Payment
+	err := withAttempts(r.db.WithContext(ctx)).First(&payment, id).Error
+	return payment, notFound(err)
+}
+
+func (r gormPayments) GetByReference(ctx context.Context, provider, reference string) (models.Payment, error) {
+	var payment models.Payment
+	err := withAttempts(r.db.WithContext(ctx)).Where("provider = ? AND provider_reference = ?", provider, reference).First(&payment).Error
+	return payment, notFound(err)
+}
+
+func (r gormPayments) ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := withAttempts(r.db.WithContext(ctx)).Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	return payments, err
+}
+
+func (r gormPayments) Lock(ctx context.Context, id int) (models.Payment, error) {
+	// The update locks the row of the payment until the transaction ends
+	result := r.db.WithContext(ctx).Model(&models.Payment{}).Where("id = ?", id).UpdateColumn("refunded_amount", gorm.Expr("refunded_amount"))
+	if result.Error != nil {
+		return models.Payment{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Payment{}, ErrNotFound
+	}
+	return r.Get(ctx, id)
+}
+
+func (r gormPayments) Update(ctx context.Context, payment *models.Payment, columns ...string) error {
+	payment.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx).Omit(clause.Associations), payment, columnsOr(columns, paymentColumns))
+}
+
+func (r gormPayments) AddAttempt(ctx context.Context, attempt *models.PaymentAttempt) error {
+	return r.db.WithContext(ctx).Create(attempt).Error
+}
+
+func (r gormPayments) SpendByUser(ctx context.Context, userID int, since time.Time) (float64, error) {
+	var spend float64
+	err := r.db.WithContext(ctx).Model(&models.Payment{}).
+		Joins("JOIN orders ON orders.id = payments.order_id").
+		Where("orders.user_id = ? AND orders.deleted_at IS NULL AND orders.status <> ?", userID, models.OrderStatusCancelled).
+		Where("payments.status IN ? AND payments.captured_at >= ?", []string{models.PaymentStatusCaptured, models.PaymentStatusRefunded}, since).
+		Select("COALESCE(SUM(payments.captured_amount - payments.refunded_amount), 0)").
+		Scan(&spend).Error
+	return spend, err
+}
+
+type gormWebhookEvents struct{ db *gorm.DB }
+
+func (r gormWebhookEvents) Create(ctx context.Context, event *models.WebhookEvent) error {
+	err := r.db.WithContext(ctx).Create(event).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateEvent
+	}
+	return err
+}
+
+type gormRefunds struct{ db *gorm.DB }
+
+func (r gormRefunds) Create(ctx context.Context, refund *models.Refund) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(refund).Error; err != nil {
+			return err
+		}
+		for i := range refund.Lines {
+			refund.Lines[i].RefundID = refund.ID
+		}
+		if len(refund.Lines) == 0 {
+			return nil
+		}
+		return tx.Create(&refund.Lines).Error
+	})
+}
+
+func (r gormRefunds) ListByOrder(ctx context.Context, orderID int) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.WithContext(ctx).Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("order_id = ?", orderID).Order("id").Find(&refunds).Error
+	return refunds, err
+}
+
+func (r gormRefunds) ExistsByReference(ctx context.Context, paymentID int, reference string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Refund{}).Where("payment_id = ? AND provider_reference = ?", paymentID, reference).Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormRefunds) Update(ctx context.Context, refund *models.Refund, columns ...string) error {
+	// Refunds have no updated_at, which update writes
+	result := r.db.WithContext(ctx).Omit(clause.Associations).Model(refund).Select(columnsOr(columns, refundColumns)).Updates(refund)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r gormRefunds) Delete(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("refund_id = ?", id).Delete(&models.RefundLine{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Refund{}, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return result.Error
+	})
+}
+
+type gormInvoices struct{ db *gorm.DB }
+
+func (r gormInvoices) NextSequence(ctx context.Context, year int) (int, error) {
+	db := r.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceSequence{Year: year}).Error; err != nil {
+		return 0, err
+	}
+	// The update locks the row of the year until the transaction ends, so concurrent
+	// invoices wait for each other and a rolled back invoice leaves no gap
+	err := db.Model(&models.InvoiceSequence{}).Where("year = ?", year).UpdateColumn("last_number", gorm.Expr("last_number + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+	var sequence models.InvoiceSequence
+	if err := db.Where("year = ?", year).Take(&sequence).Error; err != nil {
+		return 0, err
+	}
+	return sequence.LastNumber, nil
+}
+
+func (r gormInvoices) Create(ctx context.Context, invoice *models.Invoice) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(invoice).Error; err != nil {
+			return err
+		}
+		for i := range invoice.Lines {
+			invoice.Lines[i].InvoiceID = invoice.ID
+		}
+		if len(invoice.Lines) == 0 {
+			return nil
+		}
+		return tx.Create(&invoice.Lines).Error
+	})
+}
+
+func (r gormInvoices) GetByOrder(ctx context.Context, orderID int) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.WithContext(ctx).Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("order_id = ?", orderID).Take(&invoice).Error
+	return invoice, notFound(err)
+}
+
+type gormShipments struct{ db *gorm.DB }
+
+func withShipmentLines(db *gorm.DB) *gorm.DB {
+	return db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+}
+
+func (r gormShipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(shipment).Error; err != nil {
+			return err
+		}
+		for i := range shipment.Lines {
+			shipment.Lines[i].ShipmentID = shipment.ID
+		}
+		if len(shipment.Lines) == 0 {
+			return nil
+		}
+		return tx.Create(&shipment.Lines).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateTrackingNumber
+	}
+	return err
+}
+
+func (r gormShipments) Get(ctx context.Context, id int) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := withShipmentLines(r.db.WithContext(ctx)).First(&shipment, id).Error
+	return shipment, notFound(err)
+}
+
+func (r gormShipments) ListByOrder(ctx context.Context, orderID int) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	err := withShipmentLines(r.db.WithContext(ctx)).Where("order_id = ?", orderID).Order("id").Find(&shipments).Error
+	return shipments, err
+}
+
+func (r gormShipments) Update(ctx context.Context, shipment *models.Shipment, columns ...string) error {
+	shipment.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx).Omit(clause.Associations), shipment, columnsOr(columns, shipmentColumns))
+}
+
+type gormAddresses struct{ db *gorm.DB }
+
+func (r gormAddresses) Create(ctx context.Context, address *models.Address) error {
+	return r.db.WithContext(ctx).Create(address).Error
+}
+
+func (r gormAddresses) Get(ctx context.Context, id int) (models.Address, error) {
+	var address models.Address
+	err := r.db.WithContext(ctx).First(&address, id).Error
+	return address, notFound(err)
+}
+
+func (r gormAddresses) ListByUser(ctx context.Context, userID int) ([]models.Address, error) {
+	var addresses []models.Address
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&addresses).Error
+	return addresses, err
+}
+
+func (r gormAddresses) Update(ctx context.Context, address *models.Address, columns ...string) error {
+	address.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx), address, columnsOr(columns, addressColumns))
+}
+
+func (r gormAddresses) Delete(ctx context.Context, id int) error {
+	return softDelete(r.db.WithContext(ctx), &models.Address{}, id)
+}
+
+type gormCoupons struct{ db *gorm.DB }
+
+func (r gormCoupons) Create(ctx context.Context, coupon *models.Coupon) error {
+	err := r.db.WithContext(ctx).Create(coupon).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateCouponCode
+	}
+	return err
+}
+
+func (r gormCoupons) Get(ctx context.Context, id int) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := r.db.WithContext(ctx).First(&coupon, id).Error
+	return coupon, notFound(err)
+}
+
+func (r gormCoupons) GetByCode(ctx context.Context, code string) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := r.db.WithContext(ctx).Where("code = ?", code).Take(&coupon).Error
+	return coupon, notFound(err)
+}
+
+func (r gormCoupons) List(ctx context.Context) ([]models.Coupon, error) {
+	var coupons []models.Coupon
+	err := r.db.WithContext(ctx).Order("id").Find(&coupons).Error
+	return coupons, err
+}
+
+func (r gormCoupons) Update(ctx context.Context, coupon *models.Coupon, columns ...string) error {
+	coupon.UpdatedAt = time.Now()
+	return update(r.db.WithContext(ctx), coupon, columnsOr(columns, couponColumns))
+}
+
+func (r gormCoupons) Delete(ctx context.Context, id int) error {
+	return softDelete(r.db.WithContext(ctx), &models.Coupon{}, id)
+}
+
+func (r gormCoupons) CountRedemptions(ctx context.Context, couponID, userID int) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", couponID, userID).Count(&count).Error
+	return count, err
+}
+
+func (r gormCoupons) Redeem(ctx context.Context, redemption *models.CouponRedemption) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The update locks the coupon until the transaction ends, so concurrent redemptions
+		// wait for each other and count the redemptions committed before them
+		result := tx.Model(&models.Coupon{}).
+			Where("id = ? AND (max_redemptions = 0 OR redemptions < max_redemptions)", redemption.CouponID).
+			UpdateColumn("redemptions", gorm.Expr("redemptions + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		var coupon models.Coupon
+		if err := tx.First(&coupon, redemption.CouponID).Error; err != nil {
+			return notFound(err)
+		}
+		if result.RowsAffected == 0 {
+			return ErrCouponExhausted
+		}
+		if coupon.MaxRedemptionsPerUser > 0 {
+			used, err := gormCoupons{tx}.CountRedemptions(ctx, coupon.ID, redemption.UserID)
+			if err != nil {
+				return err
+			}
+			if used >= int64(coupon.MaxRedemptionsPerUser) {
+				return ErrCouponUserLimit
+			}
+		}
+		keepTerms(redemption, coupon)
+		return tx.Create(redemption).Error
+	})
+}
+
+func (r gormCoupons) GetRedemption(ctx context.Context, orderID int) (models.CouponRedemption, error) {
+	var redemption models.CouponRedemption
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Take(&redemption).Error
+	return redemption, notFound(err)
+}
+
+func (r gormCoupons) Release(ctx context.Context, orderID int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var redemption models.CouponRedemption
+		if err := tx.Where("order_id = ?", orderID).Take(&redemption).Error; err != nil {
+			return notFound(err)
+		}
+		if err := tx.Delete(&redemption).Error; err != nil {
+			return err
+		}
+		// Deleted coupons still give the redemption back
+		return tx.Unscoped().Model(&models.Coupon{}).Where("id = ? AND redemptions > 0", redemption.CouponID).
+			UpdateColumn("redemptions", gorm.Expr("redemptions - 1")).Error
+	})
+}
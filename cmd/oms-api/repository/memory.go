@@ -0,0 +1,1438 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"gorm.io/gorm"
+)
+
+// MemoryStore is a Store that keeps everything in memory. It follows the soft delete
+// and unique email rules of the database and is meant for tests. Transactions are
+// serialized and their changes are thrown away when they fail.
+type MemoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	inTx bool // The store passed to a transaction already holds mu
+}
+
+type memoryData struct {
+	lastID        map[string]int
+	users         map[int]models.User
+	items         map[int]models.Item
+	orders        map[int]models.Order // Without their items, those are kept in orderItems
+	orderItems    map[int]models.OrderItem
+	userOrders    []models.UserOrder
+	verifications map[int]models.EmailVerification
+	payments      map[int]models.Payment // Without their attempts, those are kept in attempts
+	attempts      map[int]models.PaymentAttempt
+	webhookEvents map[int]models.WebhookEvent
+	refunds       map[int]models.Refund // Without their lines, those are kept in refundLines
+	refundLines   map[int]models.RefundLine
+	invoices      map[int]models.Invoice // Without their lines, those are kept in invoiceLines
+	invoiceLines  map[int]models.InvoiceLine
+	sequences     map[int]int             // Last invoice number by year
+	shipments     map[int]models.Shipment // Without their lines, those are kept in shipmentLines
+	shipmentLines map[int]models.ShipmentLine
+	addresses     map[int]models.Address
+	coupons       map[int]models.Coupon
+	redemptions   map[int]models.CouponRedemption
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			lastID:        map[string]int{},
+			users:         map[int]models.User{},
+			items:         map[int]models.Item{},
+			orders:        map[int]models.Order{},
+			orderItems:    map[int]models.OrderItem{},
+			verifications: map[int]models.EmailVerification{},
+			payments:      map[int]models.Payment{},
+			attempts:      map[int]models.PaymentAttempt{},
+			webhookEvents: map[int]models.WebhookEvent{},
+			refunds:       map[int]models.Refund{},
+			refundLines:   map[int]models.RefundLine{},
+			invoices:      map[int]models.Invoice{},
+			invoiceLines:  map[int]models.InvoiceLine{},
+			sequences:     map[int]int{},
+			shipments:     map[int]models.Shipment{},
+			shipmentLines: map[int]models.ShipmentLine{},
+			addresses:     map[int]models.Address{},
+			coupons:       map[int]models.Coupon{},
+			redemptions:   map[int]models.CouponRedemption{},
+		},
+	}
+}
+
+func (s *MemoryStore) Users() UserRepository { return memoryUsers{s} }
+
+func (s *MemoryStore) Items() ItemRepository { return memoryItems{s} }
+
+func (s *MemoryStore) Orders() OrderRepository { return memoryOrders{s} }
+
+func (s *MemoryStore) EmailVerifications() EmailVerificationRepository {
+	return memoryEmailVerifications{s}
+}
+
+func (s *MemoryStore) Payments() PaymentRepository { return memoryPayments{s} }
+
+func (s *MemoryStore) WebhookEvents() WebhookEventRepository { return memoryWebhookEvents{s} }
+
+func (s *MemoryStore) Refunds() RefundRepository { return memoryRefunds{s} }
+
+func (s *MemoryStore) Invoices() InvoiceRepository { return memoryInvoices{s} }
+
+func (s *MemoryStore) Shipments() ShipmentRepository { return memoryShipments{s} }
+
+func (s *MemoryStore) Addresses() AddressRepository { return memoryAddresses{s} }
+
+func (s *MemoryStore) Coupons() CouponRepository { return memoryCoupons{s} }
+
+// Transaction runs fn while holding the store, the data is restored when fn fails
+func (s *MemoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.lock()
+	defer s.unlock()
+
+	snapshot := s.data.clone()
+	if err := fn(&MemoryStore{mu: s.mu, data: s.data, inTx: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// begin checks the context and locks the store, the returned function unlocks it
+func (s *MemoryStore) begin(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.lock()
+	return s.unlock, nil
+}
+
+func (s *MemoryStore) lock() {
+	if !s.inTx {
+		s.mu.Lock()
+	}
+}
+
+func (s *MemoryStore) unlock() {
+	if !s.inTx {
+		s.mu.Unlock()
+	}
+}
+
+func (d *memoryData) nextID(table string) int {
+	d.lastID[table]++
+	return d.lastID[table]
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		lastID:        make(map[string]int, len(d.lastID)),
+		users:         make(map[int]models.User, len(d.users)),
+		items:         make(map[int]models.Item, len(d.items)),
+		orders:        make(map[int]models.Order, len(d.orders)),
+		orderItems:    make(map[int]models.OrderItem, len(d.orderItems)),
+		userOrders:    append([]models.UserOrder(nil), d.userOrders...),
+		verifications: make(map[int]models.EmailVerification, len(d.verifications)),
+		payments:      make(map[int]models.Payment, len(d.payments)),
+		attempts:      make(map[int]models.PaymentAttempt, len(d.attempts)),
+		webhookEvents: make(map[int]models.WebhookEvent, len(d.webhookEvents)),
+		refunds:       make(map[int]models.Refund, len(d.refunds)),
+		refundLines:   make(map[int]models.RefundLine, len(d.refundLines)),
+		invoices:      make(map[int]models.Invoice, len(d.invoices)),
+		invoiceLines:  make(map[int]models.InvoiceLine, len(d.invoiceLines)),
+		sequences:     make(map[int]int, len(d.sequences)),
+		shipments:     make(map[int]models.Shipment, len(d.shipments)),
+		shipmentLines: make(map[int]models.ShipmentLine, len(d.shipmentLines)),
+		addresses:     make(map[int]models.Address, len(d.addresses)),
+		coupons:       make(map[int]models.Coupon, len(d.coupons)),
+		redemptions:   make(map[int]models.CouponRedemption, len(d.redemptions)),
+	}
+	for k, v := range d.lastID {
+		c.lastID[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.items {
+		c.items[k] = v
+	}
+	for k, v := range d.orders {
+		c.orders[k] = v
+	}
+	for k, v := range d.orderItems {
+		c.orderItems[k] = v
+	}
+	for k, v := range d.verifications {
+		c.verifications[k] = v
+	}
+	for k, v := range d.payments {
+		c.payments[k] = v
+	}
+	for k, v := range d.attempts {
+		c.attempts[k] = v
+	}
+	for k, v := range d.webhookEvents {
+		c.webhookEvents[k] = v
+	}
+	for k, v := range d.refunds {
+		c.refunds[k] = v
+	}
+	for k, v := range d.refundLines {
+		c.refundLines[k] = v
+	}
+	for k, v := range d.invoices {
+		c.invoices[k] = v
+	}
+	for k, v := range d.invoiceLines {
+		c.invoiceLines[k] = v
+	}
+	for k, v := range d.sequences {
+		c.sequences[k] = v
+	}
+	for k, v := range d.shipments {
+		c.shipments[k] = v
+	}
+	for k, v := range d.shipmentLines {
+		c.shipmentLines[k] = v
+	}
+	for k, v := range d.addresses {
+		c.addresses[k] = v
+	}
+	for k, v := range d.coupons {
+		c.coupons[k] = v
+	}
+	for k, v := range d.redemptions {
+		c.redemptions[k] = v
+	}
+	return c
+}
+
+// activeItems returns the items of an order that are not soft deleted, ordered by ID
+func (d *memoryData) activeItems(orderID int) []models.OrderItem {
+	var items []models.OrderItem
+	for _, item := range d.orderItems {
+		if item.OrderID == orderID && !item.DeletedAt.Valid {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// paymentAttempts returns the attempts of a payment, ordered by ID
+func (d *memoryData) paymentAttempts(paymentID int) []models.PaymentAttempt {
+	var attempts []models.PaymentAttempt
+	for _, attempt := range d.attempts {
+		if attempt.PaymentID == paymentID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].ID < attempts[j].ID })
+	return attempts
+}
+
+// deletedAt returns the soft delete marker for now
+func deletedAt(now time.Time) gorm.DeletedAt {
+	return gorm.DeletedAt{Time: now, Valid: true}
+}
+
+func unknownColumn(column string) error {
+	return fmt.Errorf("unknown column %q", column)
+}
+
+type memoryUsers struct{ s *MemoryStore }
+
+// emailTaken reports whether an active user other than id uses email
+func (r memoryUsers) emailTaken(email string, id int) bool {
+	for _, u := range r.s.data.users {
+		if u.ID != id && !u.DeletedAt.Valid && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if r.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+	now := time.Now()
+	user.ID = r.s.data.nextID("users")
+	user.CreatedAt, user.UpdatedAt = now, now
+	stored := *user
+	stored.Orders = nil
+	r.s.data.users[user.ID] = stored
+	return nil
+}
+
+func (r memoryUsers) List(ctx context.Context) ([]models.User, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var users []models.User
+	for _, u := range r.s.data.users {
+		if !u.DeletedAt.Valid {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, id int) (models.User, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer unlock()
+
+	user, ok := r.s.data.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r memoryUsers) GetWithOrders(ctx context.Context, id int) (models.User, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer unlock()
+
+	user, ok := r.s.data.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.User{}, ErrNotFound
+	}
+	for _, order := range r.s.data.orders {
+		if order.UserID == id && !order.DeletedAt.Valid {
+			order.Items = r.s.data.activeItems(order.ID)
+			user.Orders = append(user.Orders, order)
+		}
+	}
+	sort.Slice(user.Orders, func(i, j int) bool { return user.Orders[i].ID < user.Orders[j].ID })
+	return user, nil
+}
+
+func (r memoryUsers) Update(ctx context.Context, user *models.User, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.users[user.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, userColumns) {
+		switch column {
+		case "name":
+			stored.Name = user.Name
+		case "email":
+			if r.emailTaken(user.Email, user.ID) {
+				return ErrDuplicateEmail
+			}
+			stored.Email = user.Email
+		case "email_verified_at":
+			stored.EmailVerifiedAt = user.EmailVerifiedAt
+		case "region":
+			stored.Region = user.Region
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	user.UpdatedAt = stored.UpdatedAt
+	r.s.data.users[user.ID] = stored
+	return nil
+}
+
+func (r memoryUsers) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.DeletedAt.Valid {
+		return ErrAlreadyDeleted
+	}
+	user.DeletedAt = deletedAt(time.Now())
+	r.s.data.users[id] = user
+	return nil
+}
+
+type memoryItems struct{ s *MemoryStore }
+
+func (r memoryItems) Create(ctx context.Context, item *models.Item) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	item.ID = r.s.data.nextID("items")
+	item.CreatedAt, item.UpdatedAt = now, now
+	r.s.data.items[item.ID] = *item
+	return nil
+}
+
+func (r memoryItems) List(ctx context.Context) ([]models.Item, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var items []models.Item
+	for _, item := range r.s.data.items {
+		if !item.DeletedAt.Valid {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+func (r memoryItems) Get(ctx context.Context, id int) (models.Item, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Item{}, err
+	}
+	defer unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || item.DeletedAt.Valid {
+		return models.Item{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (r memoryItems) Update(ctx context.Context, item *models.Item, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.items[item.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, itemColumns) {
+		switch column {
+		case "name":
+			stored.Name = item.Name
+		case "description":
+			stored.Description = item.Description
+		case "price":
+			stored.Price = item.Price
+		case "weight":
+			stored.Weight = item.Weight
+		case "tax_category":
+			stored.TaxCategory = item.TaxCategory
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	item.UpdatedAt = stored.UpdatedAt
+	r.s.data.items[item.ID] = stored
+	return nil
+}
+
+func (r memoryItems) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if item.DeletedAt.Valid {
+		return ErrAlreadyDeleted
+	}
+	item.DeletedAt = deletedAt(time.Now())
+	r.s.data.items[id] = item
+	return nil
+}
+
+type memoryOrders struct{ s *MemoryStore }
+
+// insertItems stores new items of an order
+func (r memoryOrders) insertItems(orderID int, items []models.OrderItem, now time.Time) {
+	for i := range items {
+		items[i].ID = r.s.data.nextID("order_items")
+		items[i].OrderID = orderID
+		items[i].CreatedAt, items[i].UpdatedAt = now, now
+		r.s.data.orderItems[items[i].ID] = items[i]
+	}
+}
+
+func (r memoryOrders) Create(ctx context.Context, order *models.Order) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	order.ID = r.s.data.nextID("orders")
+	order.CreatedAt, order.UpdatedAt = now, now
+	stored := *order
+	stored.Items = nil
+	r.s.data.orders[order.ID] = stored
+	r.insertItems(order.ID, order.Items, now)
+	r.s.data.userOrders = append(r.s.data.userOrders, models.UserOrder{UserID: order.UserID, OrderID: order.ID})
+	return nil
+}
+
+func (r memoryOrders) List(ctx context.Context) ([]models.Order, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var orders []models.Order
+	for _, order := range r.s.data.orders {
+		if !order.DeletedAt.Valid {
+			order.Items = r.s.data.activeItems(order.ID)
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+func (r memoryOrders) Get(ctx context.Context, id int) (models.Order, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Order{}, err
+	}
+	defer unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok || order.DeletedAt.Valid {
+		return models.Order{}, ErrNotFound
+	}
+	order.Items = r.s.data.activeItems(id)
+	return order, nil
+}
+
+// Lock only reads the order, transactions of the memory store hold all of it
+func (r memoryOrders) Lock(ctx context.Context, id int) (models.Order, error) {
+	return r.Get(ctx, id)
+}
+
+func (r memoryOrders) ReplaceItems(ctx context.Context, orderID int, items []models.OrderItem) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	for _, item := range r.s.data.activeItems(orderID) {
+		item.DeletedAt = deletedAt(now)
+		r.s.data.orderItems[item.ID] = item
+	}
+	r.insertItems(orderID, items, now)
+	return nil
+}
+
+func (r memoryOrders) Update(ctx context.Context, order *models.Order, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.orders[order.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, orderColumns) {
+		switch column {
+		case "status":
+			stored.Status = order.Status
+		case "total_price":
+			stored.TotalPrice = order.TotalPrice
+		case "final_price":
+			stored.FinalPrice = order.FinalPrice
+		case "shipping_method":
+			stored.Shipping.Method = order.Shipping.Method
+		case "shipping_zone":
+			stored.Shipping.Zone = order.Shipping.Zone
+		case "shipping_price":
+			stored.Shipping.Price = order.Shipping.Price
+		case "shipping_tax":
+			stored.Shipping.Tax = order.Shipping.Tax
+		case "tax":
+			stored.Tax = order.Tax
+		case "prices_include_tax":
+			stored.PricesIncludeTax = order.PricesIncludeTax
+		case "coupon_code":
+			stored.CouponCode = order.CouponCode
+		case "coupon_discount":
+			stored.CouponDiscount = order.CouponDiscount
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	order.UpdatedAt = stored.UpdatedAt
+	r.s.data.orders[order.ID] = stored
+	return nil
+}
+
+func (r memoryOrders) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if order.DeletedAt.Valid {
+		return ErrAlreadyDeleted
+	}
+	order.DeletedAt = deletedAt(time.Now())
+	r.s.data.orders[id] = order
+	return nil
+}
+
+type memoryEmailVerifications struct{ s *MemoryStore }
+
+func (r memoryEmailVerifications) Create(ctx context.Context, verification *models.EmailVerification) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	verification.ID = r.s.data.nextID("email_verifications")
+	verification.CreatedAt = time.Now()
+	r.s.data.verifications[verification.ID] = *verification
+	return nil
+}
+
+func (r memoryEmailVerifications) FindUnused(ctx context.Context, tokenHash string) (models.EmailVerification, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.EmailVerification{}, err
+	}
+	defer unlock()
+
+	for _, v := range r.s.data.verifications {
+		if v.TokenHash == tokenHash && v.UsedAt == nil {
+			return v, nil
+		}
+	}
+	return models.EmailVerification{}, ErrNotFound
+}
+
+func (r memoryEmailVerifications) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, ok := r.s.data.verifications[id]
+	if !ok || v.UsedAt != nil {
+		return ErrNotFound
+	}
+	v.UsedAt = &at
+	r.s.data.verifications[id] = v
+	return nil
+}
+
+type memoryPayments struct{ s *MemoryStore }
+
+func (r memoryPayments) Create(ctx context.Context, payment *models.Payment) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.s.data.orders[payment.OrderID]; !ok {
+		return fmt.Errorf("order %d does not exist", payment.OrderID)
+	}
+	now := time.Now()
+	payment.ID = r.s.data.nextID("payments")
+	payment.CreatedAt, payment.UpdatedAt = now, now
+	stored := *payment
+	stored.Order, stored.Attempts = nil, nil
+	r.s.data.payments[payment.ID] = stored
+	return nil
+}
+
+func (r memoryPayments) Get(ctx context.Context, id int) (models.Payment, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	defer unlock()
+
+	payment, ok := r.s.data.payments[id]
+	if !ok {
+		return models.Payment{}, ErrNotFound
+	}
+	payment.Attempts = r.s.data.paymentAttempts(id)
+	return payment, nil
+}
+
+func (r memoryPayments) GetByReference(ctx context.Context, provider, reference string) (models.Payment, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	defer unlock()
+
+	for _, payment := range r.s.data.payments {
+		if payment.Provider == provider && payment.ProviderReference == reference && reference != "" {
+			payment.Attempts = r.s.data.paymentAttempts(payment.ID)
+			return payment, nil
+		}
+	}
+	return models.Payment{}, ErrNotFound
+}
+
+func (r memoryPayments) ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var payments []models.Payment
+	for _, payment := range r.s.data.payments {
+		if payment.OrderID == orderID {
+			payment.Attempts = r.s.data.paymentAttempts(payment.ID)
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+// Lock only reads the payment, transactions of the memory store hold all of it
+func (r memoryPayments) Lock(ctx context.Context, id int) (models.Payment, error) {
+	return r.Get(ctx, id)
+}
+
+func (r memoryPayments) Update(ctx context.Context, payment *models.Payment, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.payments[payment.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, paymentColumns) {
+		switch column {
+		case "status":
+			stored.Status = payment.Status
+		case "provider_reference":
+			stored.ProviderReference = payment.ProviderReference
+		case "captured_amount":
+			stored.CapturedAmount = payment.CapturedAmount
+		case "captured_at":
+			stored.CapturedAt = payment.CapturedAt
+		case "refunded_amount":
+			stored.RefundedAmount = payment.RefundedAmount
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	payment.UpdatedAt = stored.UpdatedAt
+	r.s.data.payments[payment.ID] = stored
+	return nil
+}
+
+func (r memoryPayments) AddAttempt(ctx context.Context, attempt *models.PaymentAttempt) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.s.data.payments[attempt.PaymentID]; !ok {
+		return fmt.Errorf("payment %d does not exist", attempt.PaymentID)
+	}
+	attempt.ID = r.s.data.nextID("payment_attempts")
+	attempt.CreatedAt = time.Now()
+	r.s.data.attempts[attempt.ID] = *attempt
+	return nil
+}
+
+func (r memoryPayments) SpendByUser(ctx context.Context, userID int, since time.Time) (float64, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var spend float64
+	for _, payment := range r.s.data.payments {
+		if payment.Status != models.PaymentStatusCaptured && payment.Status != models.PaymentStatusRefunded {
+			continue
+		}
+		if payment.CapturedAt == nil || payment.CapturedAt.Before(since) {
+			continue
+		}
+		order, ok := r.s.data.orders[payment.OrderID]
+		if ok && order.UserID == userID && !order.DeletedAt.Valid && order.Status != models.OrderStatusCancelled {
+			spend += payment.CapturedAmount - payment.RefundedAmount
+		}
+	}
+	return spend, nil
+}
+
+type memoryWebhookEvents struct{ s *MemoryStore }
+
+func (r memoryWebhookEvents) Create(ctx context.Context, event *models.WebhookEvent) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, e := range r.s.data.webhookEvents {
+		if e.Provider == event.Provider && e.EventID == event.EventID {
+			return ErrDuplicateEvent
+		}
+	}
+	event.ID = r.s.data.nextID("webhook_events")
+	event.CreatedAt = time.Now()
+	r.s.data.webhookEvents[event.ID] = *event
+	return nil
+}
+
+type memoryRefunds struct{ s *MemoryStore }
+
+func (r memoryRefunds) Create(ctx context.Context, refund *models.Refund) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.s.data.payments[refund.PaymentID]; !ok {
+		return fmt.Errorf("payment %d does not exist", refund.PaymentID)
+	}
+	refund.ID = r.s.data.nextID("refunds")
+	refund.CreatedAt = time.Now()
+	if refund.Status == "" {
+		refund.Status = models.RefundStatusSucceeded // The default of the column
+	}
+	for i := range refund.Lines {
+		refund.Lines[i].ID = r.s.data.nextID("refund_lines")
+		refund.Lines[i].RefundID = refund.ID
+		r.s.data.refundLines[refund.Lines[i].ID] = refund.Lines[i]
+	}
+	stored := *refund
+	stored.Payment, stored.Lines = nil, nil
+	r.s.data.refunds[refund.ID] = stored
+	return nil
+}
+
+func (r memoryRefunds) ListByOrder(ctx context.Context, orderID int) ([]models.Refund, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var refunds []models.Refund
+	for _, refund := range r.s.data.refunds {
+		if refund.OrderID != orderID {
+			continue
+		}
+		for _, line := range r.s.data.refundLines {
+			if line.RefundID == refund.ID {
+				refund.Lines = append(refund.Lines, line)
+			}
+		}
+		sort.Slice(refund.Lines, func(i, j int) bool { return refund.Lines[i].ID < refund.Lines[j].ID })
+		refunds = append(refunds, refund)
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+func (r memoryRefunds) ExistsByReference(ctx context.Context, paymentID int, reference string) (bool, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	for _, refund := range r.s.data.refunds {
+		if refund.PaymentID == paymentID && refund.ProviderReference == reference {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryRefunds) Update(ctx context.Context, refund *models.Refund, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.refunds[refund.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, refundColumns) {
+		switch column {
+		case "status":
+			stored.Status = refund.Status
+		case "provider_reference":
+			stored.ProviderReference = refund.ProviderReference
+		default:
+			return unknownColumn(column)
+		}
+	}
+	r.s.data.refunds[refund.ID] = stored
+	return nil
+}
+
+func (r memoryRefunds) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.s.data.refunds[id]; !ok {
+		return ErrNotFound
+	}
+	for lineID, line := range r.s.data.refundLines {
+		if line.RefundID == id {
+			delete(r.s.data.refundLines, lineID)
+		}
+	}
+	delete(r.s.data.refunds, id)
+	return nil
+}
+
+type memoryInvoices struct{ s *MemoryStore }
+
+func (r memoryInvoices) NextSequence(ctx context.Context, year int) (int, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	r.s.data.sequences[year]++
+	return r.s.data.sequences[year], nil
+}
+
+func (r memoryInvoices) Create(ctx context.Context, invoice *models.Invoice) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, existing := range r.s.data.invoices {
+		if existing.OrderID == invoice.OrderID || existing.Number == invoice.Number {
+			return fmt.Errorf("invoice %s of order %d already exists", existing.Number, existing.OrderID)
+		}
+	}
+	invoice.ID = r.s.data.nextID("invoices")
+	invoice.CreatedAt = time.Now()
+	for i := range invoice.Lines {
+		invoice.Lines[i].ID = r.s.data.nextID("invoice_lines")
+		invoice.Lines[i].InvoiceID = invoice.ID
+		r.s.data.invoiceLines[invoice.Lines[i].ID] = invoice.Lines[i]
+	}
+	stored := *invoice
+	stored.Order, stored.Lines = nil, nil
+	r.s.data.invoices[invoice.ID] = stored
+	return nil
+}
+
+func (r memoryInvoices) GetByOrder(ctx context.Context, orderID int) (models.Invoice, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	defer unlock()
+
+	for _, invoice := range r.s.data.invoices {
+		if invoice.OrderID != orderID {
+			continue
+		}
+		for _, line := range r.s.data.invoiceLines {
+			if line.InvoiceID == invoice.ID {
+				invoice.Lines = append(invoice.Lines, line)
+			}
+		}
+		sort.Slice(invoice.Lines, func(i, j int) bool { return invoice.Lines[i].ID < invoice.Lines[j].ID })
+		return invoice, nil
+	}
+	return models.Invoice{}, ErrNotFound
+}
+
+type memoryShipments struct{ s *MemoryStore }
+
+// withLines returns shipment with its lines, r.s.mu must be held
+func (r memoryShipments) withLines(shipment models.Shipment) models.Shipment {
+	for _, line := range r.s.data.shipmentLines {
+		if line.ShipmentID == shipment.ID {
+			shipment.Lines = append(shipment.Lines, line)
+		}
+	}
+	sort.Slice(shipment.Lines, func(i, j int) bool { return shipment.Lines[i].ID < shipment.Lines[j].ID })
+	return shipment
+}
+
+func (r memoryShipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, existing := range r.s.data.shipments {
+		if existing.Carrier == shipment.Carrier && existing.TrackingNumber == shipment.TrackingNumber {
+			return ErrDuplicateTrackingNumber
+		}
+	}
+	shipment.ID = r.s.data.nextID("shipments")
+	shipment.CreatedAt = time.Now()
+	shipment.UpdatedAt = shipment.CreatedAt
+	for i := range shipment.Lines {
+		shipment.Lines[i].ID = r.s.data.nextID("shipment_lines")
+		shipment.Lines[i].ShipmentID = shipment.ID
+		r.s.data.shipmentLines[shipment.Lines[i].ID] = shipment.Lines[i]
+	}
+	stored := *shipment
+	stored.Order, stored.Lines = nil, nil
+	r.s.data.shipments[shipment.ID] = stored
+	return nil
+}
+
+func (r memoryShipments) Get(ctx context.Context, id int) (models.Shipment, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Shipment{}, err
+	}
+	defer unlock()
+
+	shipment, ok := r.s.data.shipments[id]
+	if !ok {
+		return models.Shipment{}, ErrNotFound
+	}
+	return r.withLines(shipment), nil
+}
+
+func (r memoryShipments) ListByOrder(ctx context.Context, orderID int) ([]models.Shipment, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var shipments []models.Shipment
+	for _, shipment := range r.s.data.shipments {
+		if shipment.OrderID == orderID {
+			shipments = append(shipments, r.withLines(shipment))
+		}
+	}
+	sort.Slice(shipments, func(i, j int) bool { return shipments[i].ID < shipments[j].ID })
+	return shipments, nil
+}
+
+func (r memoryShipments) Update(ctx context.Context, shipment *models.Shipment, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.shipments[shipment.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, shipmentColumns) {
+		switch column {
+		case "status":
+			stored.Status = shipment.Status
+		case "delivered_at":
+			stored.DeliveredAt = shipment.DeliveredAt
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	shipment.UpdatedAt = stored.UpdatedAt
+	r.s.data.shipments[shipment.ID] = stored
+	return nil
+}
+
+type memoryAddresses struct{ s *MemoryStore }
+
+func (r memoryAddresses) Create(ctx context.Context, address *models.Address) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	address.ID = r.s.data.nextID("addresses")
+	address.CreatedAt, address.UpdatedAt = now, now
+	r.s.data.addresses[address.ID] = *address
+	return nil
+}
+
+func (r memoryAddresses) Get(ctx context.Context, id int) (models.Address, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Address{}, err
+	}
+	defer unlock()
+
+	address, ok := r.s.data.addresses[id]
+	if !ok || address.DeletedAt.Valid {
+		return models.Address{}, ErrNotFound
+	}
+	return address, nil
+}
+
+func (r memoryAddresses) ListByUser(ctx context.Context, userID int) ([]models.Address, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var addresses []models.Address
+	for _, address := range r.s.data.addresses {
+		if address.UserID == userID && !address.DeletedAt.Valid {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].ID < addresses[j].ID })
+	return addresses, nil
+}
+
+func (r memoryAddresses) Update(ctx context.Context, address *models.Address, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.addresses[address.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, addressColumns) {
+		switch column {
+		case "name":
+			stored.Name = address.Name
+		case "line1":
+			stored.Line1 = address.Line1
+		case "line2":
+			stored.Line2 = address.Line2
+		case "city":
+			stored.City = address.City
+		case "state":
+			stored.State = address.State
+		case "postal_code":
+			stored.PostalCode = address.PostalCode
+		case "country":
+			stored.Country = address.Country
+		case "default_shipping":
+			stored.DefaultShipping = address.DefaultShipping
+		case "default_billing":
+			stored.DefaultBilling = address.DefaultBilling
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	address.UpdatedAt = stored.UpdatedAt
+	r.s.data.addresses[address.ID] = stored
+	return nil
+}
+
+func (r memoryAddresses) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	address, ok := r.s.data.addresses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if address.DeletedAt.Valid {
+		return ErrAlreadyDeleted
+	}
+	address.DeletedAt = deletedAt(time.Now())
+	r.s.data.addresses[id] = address
+	return nil
+}
+
+type memoryCoupons struct{ s *MemoryStore }
+
+func (r memoryCoupons) Create(ctx context.Context, coupon *models.Coupon) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, other := range r.s.data.coupons {
+		if other.Code == coupon.Code {
+			return ErrDuplicateCouponCode
+		}
+	}
+	now := time.Now()
+	coupon.ID = r.s.data.nextID("coupons")
+	coupon.CreatedAt, coupon.UpdatedAt = now, now
+	r.s.data.coupons[coupon.ID] = *coupon
+	return nil
+}
+
+func (r memoryCoupons) Get(ctx context.Context, id int) (models.Coupon, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Coupon{}, err
+	}
+	defer unlock()
+
+	coupon, ok := r.s.data.coupons[id]
+	if !ok || coupon.DeletedAt.Valid {
+		return models.Coupon{}, ErrNotFound
+	}
+	return coupon, nil
+}
+
+func (r memoryCoupons) GetByCode(ctx context.Context, code string) (models.Coupon, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.Coupon{}, err
+	}
+	defer unlock()
+
+	for _, coupon := range r.s.data.coupons {
+		if coupon.Code == code && !coupon.DeletedAt.Valid {
+			return coupon, nil
+		}
+	}
+	return models.Coupon{}, ErrNotFound
+}
+
+func (r memoryCoupons) List(ctx context.Context) ([]models.Coupon, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var coupons []models.Coupon
+	for _, coupon := range r.s.data.coupons {
+		if !coupon.DeletedAt.Valid {
+			coupons = append(coupons, coupon)
+		}
+	}
+	sort.Slice(coupons, func(i, j int) bool { return coupons[i].ID < coupons[j].ID })
+	return coupons, nil
+}
+
+func (r memoryCoupons) Update(ctx context.Context, coupon *models.Coupon, columns ...string) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, ok := r.s.data.coupons[coupon.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	for _, column := range columnsOr(columns, couponColumns) {
+		switch column {
+		case "effect":
+			stored.Effect = coupon.Effect
+		case "value":
+			stored.Value = coupon.Value
+		case "starts_at":
+			stored.StartsAt = coupon.StartsAt
+		case "ends_at":
+			stored.EndsAt = coupon.EndsAt
+		case "minimum_spend":
+			stored.MinimumSpend = coupon.MinimumSpend
+		case "eligible_item_ids":
+			stored.EligibleItemIDs = append([]int(nil), coupon.EligibleItemIDs...)
+		case "max_redemptions":
+			stored.MaxRedemptions = coupon.MaxRedemptions
+		case "max_redemptions_per_user":
+			stored.MaxRedemptionsPerUser = coupon.MaxRedemptionsPerUser
+		default:
+			return unknownColumn(column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	coupon.UpdatedAt = stored.UpdatedAt
+	r.s.data.coupons[coupon.ID] = stored
+	return nil
+}
+
+func (r memoryCoupons) Delete(ctx context.Context, id int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	coupon, ok := r.s.data.coupons[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if coupon.DeletedAt.Valid {
+		return ErrAlreadyDeleted
+	}
+	coupon.DeletedAt = deletedAt(time.Now())
+	r.s.data.coupons[id] = coupon
+	return nil
+}
+
+func (r memoryCoupons) CountRedemptions(ctx context.Context, couponID, userID int) (int64, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return r.s.data.countRedemptions(couponID, userID), nil
+}
+
+func (r memoryCoupons) Redeem(ctx context.Context, redemption *models.CouponRedemption) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	coupon, ok := r.s.data.coupons[redemption.CouponID]
+	switch {
+	case !ok || coupon.DeletedAt.Valid:
+		return ErrNotFound
+	case coupon.MaxRedemptions > 0 && coupon.Redemptions >= coupon.MaxRedemptions:
+		return ErrCouponExhausted
+	case coupon.MaxRedemptionsPerUser > 0 && r.s.data.countRedemptions(coupon.ID, redemption.UserID) >= int64(coupon.MaxRedemptionsPerUser):
+		return ErrCouponUserLimit
+	}
+	for _, other := range r.s.data.redemptions {
+		if other.OrderID == redemption.OrderID {
+			return fmt.Errorf("order %d already redeemed a coupon", redemption.OrderID)
+		}
+	}
+	coupon.Redemptions++
+	r.s.data.coupons[coupon.ID] = coupon
+	redemption.ID = r.s.data.nextID("coupon_redemptions")
+	redemption.CreatedAt = time.Now()
+	keepTerms(redemption, coupon)
+	r.s.data.redemptions[redemption.ID] = *redemption
+	return nil
+}
+
+func (r memoryCoupons) GetRedemption(ctx context.Context, orderID int) (models.CouponRedemption, error) {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return models.CouponRedemption{}, err
+	}
+	defer unlock()
+
+	for _, redemption := range r.s.data.redemptions {
+		if redemption.OrderID == orderID {
+			return redemption, nil
+		}
+	}
+	return models.CouponRedemption{}, ErrNotFound
+}
+
+func (r memoryCoupons) Release(ctx context.Context, orderID int) error {
+	unlock, err := r.s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, redemption := range r.s.data.redemptions {
+		if redemption.OrderID != orderID {
+			continue
+		}
+		delete(r.s.data.redemptions, id)
+		if coupon, ok := r.s.data.coupons[redemption.CouponID]; ok && coupon.Redemptions > 0 {
+			coupon.Redemptions--
+			r.s.data.coupons[coupon.ID] = coupon
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// countRedemptions counts the redemptions of a coupon by a user
+func (d *memoryData) countRedemptions(couponID, userID int) int64 {
+	var count int64
+	for _, redemption := range d.redemptions {
+		if redemption.CouponID == couponID && redemption.UserID == userID {
+			count++
+		}
+	}
+	return count
+}
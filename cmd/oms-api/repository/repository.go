@@ -0,0 +1,226 @@
+// Package repository hides how users, items and orders are stored. Handlers depend on
+// the interfaces in this file, the GORM implementation is used in production and the
+// in-memory implementation lets handlers be tested without a database.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist or has been soft deleted
+	ErrNotFound = errors.New("record not found")
+	// ErrAlreadyDeleted is returned when deleting a record that has been soft deleted before
+	ErrAlreadyDeleted = errors.New("record already deleted")
+	// ErrDuplicateEmail is returned when another active user already uses the email address
+	ErrDuplicateEmail = errors.New("email address already in use")
+	// ErrDuplicateEvent is returned when a webhook event of the same provider and ID has been recorded before
+	ErrDuplicateEvent = errors.New("webhook event already recorded")
+	// ErrDuplicateTrackingNumber is returned when another shipment of the carrier has the tracking number
+	ErrDuplicateTrackingNumber = errors.New("tracking number already in use")
+	// ErrDuplicateCouponCode is returned when another coupon, even a deleted one, has the code
+	ErrDuplicateCouponCode = errors.New("coupon code already in use")
+	// ErrCouponExhausted is returned when redeeming a coupon that has been redeemed as often as it may be
+	ErrCouponExhausted = errors.New("coupon has no redemptions left")
+	// ErrCouponUserLimit is returned when redeeming a coupon the user has redeemed as often as they may
+	ErrCouponUserLimit = errors.New("coupon has no redemptions left for the user")
+)
+
+// Store gives access to all repositories. Repositories returned by the Store passed
+// to fn in Transaction take part in that transaction.
+type Store interface {
+	Users() UserRepository
+	Items() ItemRepository
+	Orders() OrderRepository
+	EmailVerifications() EmailVerificationRepository
+	Payments() PaymentRepository
+	WebhookEvents() WebhookEventRepository
+	Refunds() RefundRepository
+	Invoices() InvoiceRepository
+	Shipments() ShipmentRepository
+	Addresses() AddressRepository
+	Coupons() CouponRepository
+
+	// Transaction runs fn in a transaction that is committed when fn returns nil and rolled back otherwise
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+// UserRepository stores users. Get, List and Update only see users that are not soft deleted.
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	List(ctx context.Context) ([]models.User, error)
+	Get(ctx context.Context, id int) (models.User, error)
+	// GetWithOrders returns the user with its orders and their items
+	GetWithOrders(ctx context.Context, id int) (models.User, error)
+	// Update writes the given columns of user, all of name, email, email_verified_at and region when none are given
+	Update(ctx context.Context, user *models.User, columns ...string) error
+	Delete(ctx context.Context, id int) error
+}
+
+// ItemRepository stores the items that can be ordered
+type ItemRepository interface {
+	Create(ctx context.Context, item *models.Item) error
+	List(ctx context.Context) ([]models.Item, error)
+	Get(ctx context.Context, id int) (models.Item, error)
+	// Update writes the given columns of item, all of name, description, price, weight and tax_category when none are given
+	Update(ctx context.Context, item *models.Item, columns ...string) error
+	Delete(ctx context.Context, id int) error
+}
+
+// OrderRepository stores orders together with their items
+type OrderRepository interface {
+	// Create stores the order, its items and the link to its user
+	Create(ctx context.Context, order *models.Order) error
+	List(ctx context.Context) ([]models.Order, error)
+	Get(ctx context.Context, id int) (models.Order, error)
+	// Lock returns the order with its items and holds it until the transaction ends, so
+	// transactions that lock the same order wait for each other
+	Lock(ctx context.Context, id int) (models.Order, error)
+	// ReplaceItems soft deletes the current items of the order and stores items instead
+	ReplaceItems(ctx context.Context, orderID int, items []models.OrderItem) error
+	// Update writes the given columns of order, the status, the prices, the shipping line, the tax and the coupon when none are given
+	Update(ctx context.Context, order *models.Order, columns ...string) error
+	Delete(ctx context.Context, id int) error
+}
+
+// EmailVerificationRepository stores email verification tokens by their hash
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, verification *models.EmailVerification) error
+	// FindUnused returns the verification with the token hash unless it has been used
+	FindUnused(ctx context.Context, tokenHash string) (models.EmailVerification, error)
+	// MarkUsed redeems the verification, it returns ErrNotFound when it has been used already
+	MarkUsed(ctx context.Context, id int, at time.Time) error
+}
+
+// PaymentRepository stores payments and the attempts made at their provider
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	// Get returns the payment with its attempts
+	Get(ctx context.Context, id int) (models.Payment, error)
+	// GetByReference returns the payment of provider with the authorization reference, with its attempts
+	GetByReference(ctx context.Context, provider, reference string) (models.Payment, error)
+	// ListByOrder returns the payments of an order with their attempts, oldest first
+	ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error)
+	// Lock returns the payment with its attempts and holds it until the transaction ends,
+	// so transactions that lock the same payment wait for each other and read what the
+	// ones before them stored
+	Lock(ctx context.Context, id int) (models.Payment, error)
+	// Update writes the given columns of payment, all of status, provider_reference, captured_amount, captured_at and refunded_amount when none are given
+	Update(ctx context.Context, payment *models.Payment, columns ...string) error
+	// AddAttempt records a call to the provider for the payment attempt.PaymentID
+	AddAttempt(ctx context.Context, attempt *models.PaymentAttempt) error
+	// SpendByUser sums what the payments of the orders of a user captured since since and
+	// did not refund. Cancelled and soft deleted orders are left out.
+	SpendByUser(ctx context.Context, userID int, since time.Time) (float64, error)
+}
+
+// RefundRepository stores refunds together with their lines
+type RefundRepository interface {
+	// Create stores the refund and its lines
+	Create(ctx context.Context, refund *models.Refund) error
+	// ListByOrder returns the refunds of an order with their lines, oldest first
+	ListByOrder(ctx context.Context, orderID int) ([]models.Refund, error)
+	// ExistsByReference reports whether a refund of the payment has the provider reference
+	ExistsByReference(ctx context.Context, paymentID int, reference string) (bool, error)
+	// Update writes the given columns of refund, status and provider_reference when none are given
+	Update(ctx context.Context, refund *models.Refund, columns ...string) error
+	// Delete removes the refund and its lines
+	Delete(ctx context.Context, id int) error
+}
+
+// InvoiceRepository stores invoices and hands out their numbers
+type InvoiceRepository interface {
+	// NextSequence takes the next invoice number of year. It must be called in the
+	// transaction that creates the invoice, which holds the number until it ends.
+	NextSequence(ctx context.Context, year int) (int, error)
+	// Create stores the invoice and its lines
+	Create(ctx context.Context, invoice *models.Invoice) error
+	// GetByOrder returns the invoice of an order with its lines, ErrNotFound if it has none
+	GetByOrder(ctx context.Context, orderID int) (models.Invoice, error)
+}
+
+// ShipmentRepository stores the shipments of orders together with their lines
+type ShipmentRepository interface {
+	// Create stores the shipment and its lines, it returns ErrDuplicateTrackingNumber when
+	// the carrier's tracking number is used by another shipment
+	Create(ctx context.Context, shipment *models.Shipment) error
+	Get(ctx context.Context, id int) (models.Shipment, error)
+	// ListByOrder returns the shipments of an order with their lines, oldest first
+	ListByOrder(ctx context.Context, orderID int) ([]models.Shipment, error)
+	// Update writes the given columns, status and delivered_at by default
+	Update(ctx context.Context, shipment *models.Shipment, columns ...string) error
+}
+
+// AddressRepository stores the address books of users. Get, ListByUser and Update only
+// see addresses that are not soft deleted.
+type AddressRepository interface {
+	Create(ctx context.Context, address *models.Address) error
+	Get(ctx context.Context, id int) (models.Address, error)
+	// ListByUser returns the addresses of a user, oldest first
+	ListByUser(ctx context.Context, userID int) ([]models.Address, error)
+	// Update writes the given columns of address, all of its fields and default flags when none are given
+	Update(ctx context.Context, address *models.Address, columns ...string) error
+	Delete(ctx context.Context, id int) error
+}
+
+// CouponRepository stores coupons and their redemptions. Get, GetByCode, List and Update
+// only see coupons that are not soft deleted.
+type CouponRepository interface {
+	// Create stores the coupon, it returns ErrDuplicateCouponCode when the code is taken
+	Create(ctx context.Context, coupon *models.Coupon) error
+	Get(ctx context.Context, id int) (models.Coupon, error)
+	GetByCode(ctx context.Context, code string) (models.Coupon, error)
+	// List returns the coupons, oldest first
+	List(ctx context.Context) ([]models.Coupon, error)
+	// Update writes the given columns of coupon, all of its terms when none are given. The code cannot be changed.
+	Update(ctx context.Context, coupon *models.Coupon, columns ...string) error
+	Delete(ctx context.Context, id int) error
+	// CountRedemptions counts the redemptions of a coupon by a user
+	CountRedemptions(ctx context.Context, couponID, userID int) (int64, error)
+	// Redeem records redemption with the current terms of its coupon and counts it on the
+	// coupon. The count is taken with a conditional update that holds the coupon until the
+	// transaction ends, so concurrent orders never redeem a coupon more often than its
+	// limits allow. It returns ErrCouponExhausted or ErrCouponUserLimit when a limit has
+	// been reached, and ErrNotFound when the coupon has been deleted.
+	Redeem(ctx context.Context, redemption *models.CouponRedemption) error
+	// GetRedemption returns the redemption of an order, ErrNotFound when the order has none
+	GetRedemption(ctx context.Context, orderID int) (models.CouponRedemption, error)
+	// Release removes the redemption of an order and gives it back to its coupon, it
+	// returns ErrNotFound when the order has none
+	Release(ctx context.Context, orderID int) error
+}
+
+// WebhookEventRepository records the webhook events that have been applied
+type WebhookEventRepository interface {
+	// Create records event, it returns ErrDuplicateEvent when the provider sent the event ID before
+	Create(ctx context.Context, event *models.WebhookEvent) error
+}
+
+var (
+	userColumns     = []string{"name", "email", "email_verified_at", "region"}
+	itemColumns     = []string{"name", "description", "price", "weight", "tax_category"}
+	orderColumns    = []string{"status", "total_price", "final_price", "shipping_method", "shipping_zone", "shipping_price", "shipping_tax", "tax", "prices_include_tax", "coupon_code", "coupon_discount"}
+	paymentColumns  = []string{"status", "provider_reference", "captured_amount", "captured_at", "refunded_amount"}
+	refundColumns   = []string{"status", "provider_reference"}
+	shipmentColumns = []string{"status", "delivered_at"}
+	addressColumns  = []string{"name", "line1", "line2", "city", "state", "postal_code", "country", "default_shipping", "default_billing"}
+	couponColumns   = []string{"effect", "value", "starts_at", "ends_at", "minimum_spend", "eligible_item_ids", "max_redemptions", "max_redemptions_per_user"}
+)
+
+// columnsOr returns columns, or defaults when no columns are given
+func columnsOr(columns, defaults []string) []string {
+	if len(columns) == 0 {
+		return defaults
+	}
+	return columns
+}
+
+// keepTerms copies the terms of coupon onto its redemption
+func keepTerms(redemption *models.CouponRedemption, coupon models.Coupon) {
+	redemption.Code, redemption.Effect, redemption.Value = coupon.Code, coupon.Effect, coupon.Value
+	redemption.MinimumSpend, redemption.EligibleItemIDs = coupon.MinimumSpend, coupon.EligibleItemIDs
+}
@@ -0,0 +1,794 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // Every connection would get its own in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// forEachStore runs test against both implementations, they have to behave the same
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("gorm", func(t *testing.T) { test(t, NewGormStore(openTestDB(t))) })
+	t.Run("memory", func(t *testing.T) { test(t, NewMemoryStore()) })
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		users := store.Users()
+
+		ada := models.User{Name: "Ada", Email: "ada@example.com"}
+		if err := users.Create(ctx, &ada); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.Create(ctx, &models.User{Name: "Imposter", Email: "ada@example.com"}); !errors.Is(err, ErrDuplicateEmail) {
+			t.Fatalf("creating a user with a taken email returned %v, want ErrDuplicateEmail", err)
+		}
+		bob := models.User{Name: "Bob", Email: "bob@example.com"}
+		if err := users.Create(ctx, &bob); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the given columns are written
+		ada.Name = "Ada Lovelace"
+		ada.Email = "ignored@example.com"
+		if err := users.Update(ctx, &ada, "name"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := users.Get(ctx, ada.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Ada Lovelace" || got.Email != "ada@example.com" {
+			t.Fatalf("user after updating the name = %q %q", got.Name, got.Email)
+		}
+		bob.Email = "ada@example.com"
+		if err := users.Update(ctx, &bob); !errors.Is(err, ErrDuplicateEmail) {
+			t.Fatalf("updating to a taken email returned %v, want ErrDuplicateEmail", err)
+		}
+
+		if err := users.Delete(ctx, ada.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.Delete(ctx, ada.ID); !errors.Is(err, ErrAlreadyDeleted) {
+			t.Fatalf("deleting twice returned %v, want ErrAlreadyDeleted", err)
+		}
+		if err := users.Delete(ctx, 999); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("deleting a missing user returned %v, want ErrNotFound", err)
+		}
+		if _, err := users.Get(ctx, ada.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a deleted user returned %v, want ErrNotFound", err)
+		}
+		if err := users.Update(ctx, &ada); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("updating a deleted user returned %v, want ErrNotFound", err)
+		}
+		list, err := users.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].ID != bob.ID {
+			t.Fatalf("listed %+v, want only the active user", list)
+		}
+
+		// The email of a deleted user can be used again
+		if err := users.Create(ctx, &models.User{Name: "Ada", Email: "ada@example.com"}); err != nil {
+			t.Fatalf("reusing the email of a deleted user: %v", err)
+		}
+	})
+}
+
+func TestItems(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		items := store.Items()
+
+		shirt := models.Item{Name: "Shirt", Description: "Cotton", Price: 10}
+		if err := items.Create(ctx, &shirt); err != nil {
+			t.Fatal(err)
+		}
+		shirt.Price = 12
+		if err := items.Update(ctx, &shirt, "price"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := items.Get(ctx, shirt.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Price != 12 || got.Name != "Shirt" {
+			t.Fatalf("item after update = %+v", got)
+		}
+
+		if err := items.Delete(ctx, shirt.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := items.Delete(ctx, shirt.ID); !errors.Is(err, ErrAlreadyDeleted) {
+			t.Fatalf("deleting twice returned %v, want ErrAlreadyDeleted", err)
+		}
+		if _, err := items.Get(ctx, shirt.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a deleted item returned %v, want ErrNotFound", err)
+		}
+		if list, err := items.List(ctx); err != nil || len(list) != 0 {
+			t.Fatalf("listed %v, %v, want no items", list, err)
+		}
+	})
+}
+
+func TestOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		orders := store.Orders()
+
+		order := models.Order{UserID: 1, Status: "Pending", TotalPrice: 30, FinalPrice: 30, Items: []models.OrderItem{
+			{ItemID: 1, Quantity: 1, Price: 10},
+			{ItemID: 2, Quantity: 2, Price: 10},
+		}}
+		if err := orders.Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		if order.ID == 0 || order.Items[0].OrderID != order.ID {
+			t.Fatalf("created order %+v is not linked to its items", order)
+		}
+
+		if err := orders.ReplaceItems(ctx, order.ID, []models.OrderItem{{ItemID: 3, Quantity: 5, Price: 2}}); err != nil {
+			t.Fatal(err)
+		}
+		order.Status = "Confirm"
+		order.TotalPrice = 10
+		order.Shipping = models.ShippingLine{Method: "express", Zone: "domestic", Price: 5}
+		if err := orders.Update(ctx, &order, "status", "total_price", "shipping_method", "shipping_price"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := orders.Get(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "Confirm" || got.TotalPrice != 10 || got.FinalPrice != 30 || got.Shipping != (models.ShippingLine{Method: "express", Price: 5}) {
+			t.Fatalf("order after update = %+v", got)
+		}
+		if len(got.Items) != 1 || got.Items[0].ItemID != 3 {
+			t.Fatalf("order items after replacing = %+v", got.Items)
+		}
+
+		if err := orders.Delete(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := orders.Delete(ctx, order.ID); !errors.Is(err, ErrAlreadyDeleted) {
+			t.Fatalf("deleting twice returned %v, want ErrAlreadyDeleted", err)
+		}
+		if list, err := orders.List(ctx); err != nil || len(list) != 0 {
+			t.Fatalf("listed %v, %v, want no orders", list, err)
+		}
+	})
+}
+
+func TestUserWithOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		user := models.User{Name: "Ada", Email: "ada@example.com"}
+		if err := store.Users().Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			order := models.Order{UserID: user.ID, Status: "Pending", Items: []models.OrderItem{{ItemID: 1, Quantity: i + 1, Price: 10}}}
+			if err := store.Orders().Create(ctx, &order); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.Orders().Delete(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := store.Users().GetWithOrders(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Orders) != 1 || got.Orders[0].ID != 2 || len(got.Orders[0].Items) != 1 || got.Orders[0].Items[0].Quantity != 2 {
+			t.Fatalf("user orders = %+v, want the active order with its item", got.Orders)
+		}
+	})
+}
+
+func TestEmailVerifications(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		verifications := store.EmailVerifications()
+
+		v := models.EmailVerification{UserID: 1, Email: "ada@example.com", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := verifications.Create(ctx, &v); err != nil {
+			t.Fatal(err)
+		}
+		found, err := verifications.FindUnused(ctx, "hash")
+		if err != nil || found.ID != v.ID {
+			t.Fatalf("FindUnused = %+v, %v", found, err)
+		}
+		if err := verifications.MarkUsed(ctx, v.ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifications.MarkUsed(ctx, v.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("redeeming twice returned %v, want ErrNotFound", err)
+		}
+		if _, err := verifications.FindUnused(ctx, "hash"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("FindUnused after use returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestPayments(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		order := models.Order{UserID: 1, Status: "Confirm", TotalPrice: 30, FinalPrice: 30}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		payments := store.Payments()
+
+		first := models.Payment{OrderID: order.ID, Provider: "mock", Amount: 30, Status: models.PaymentStatusPending}
+		if err := payments.Create(ctx, &first); err != nil {
+			t.Fatal(err)
+		}
+		second := models.Payment{OrderID: order.ID, Provider: "mock", Amount: 30, Status: models.PaymentStatusPending}
+		if err := payments.Create(ctx, &second); err != nil {
+			t.Fatal(err)
+		}
+		for _, attempt := range []models.PaymentAttempt{
+			{PaymentID: second.ID, Operation: models.PaymentOperationAuthorize, Amount: 30, Succeeded: true, ProviderReference: "auth_1"},
+			{PaymentID: second.ID, Operation: models.PaymentOperationCapture, Amount: 30, ErrorCode: "capture_declined"},
+		} {
+			if err := payments.AddAttempt(ctx, &attempt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Only the given columns are written
+		second.Status = models.PaymentStatusAuthorized
+		second.ProviderReference = "auth_1"
+		second.CapturedAmount = 99
+		if err := payments.Update(ctx, &second, "status", "provider_reference"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := payments.Get(ctx, second.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != models.PaymentStatusAuthorized || got.ProviderReference != "auth_1" || got.CapturedAmount != 0 {
+			t.Fatalf("payment after update = %+v", got)
+		}
+		if len(got.Attempts) != 2 || got.Attempts[0].Operation != models.PaymentOperationAuthorize || got.Attempts[1].ErrorCode != "capture_declined" {
+			t.Fatalf("payment attempts = %+v, want both in order", got.Attempts)
+		}
+
+		list, err := payments.ListByOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != first.ID || len(list[0].Attempts) != 0 || len(list[1].Attempts) != 2 {
+			t.Fatalf("payments of the order = %+v", list)
+		}
+		byReference, err := payments.GetByReference(ctx, "mock", "auth_1")
+		if err != nil || byReference.ID != second.ID || len(byReference.Attempts) != 2 {
+			t.Fatalf("GetByReference = %+v, %v, want the second payment", byReference, err)
+		}
+		if _, err := payments.GetByReference(ctx, "other", "auth_1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a reference of another provider returned %v, want ErrNotFound", err)
+		}
+		if _, err := payments.Get(ctx, 999); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a missing payment returned %v, want ErrNotFound", err)
+		}
+		if err := payments.Update(ctx, &models.Payment{ID: 999, Status: models.PaymentStatusFailed}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("updating a missing payment returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestLocks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		order := models.Order{UserID: 1, Status: "Confirm", TotalPrice: 30, FinalPrice: 30, Items: []models.OrderItem{{ItemID: 1, Quantity: 3, Price: 10}}}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		p := models.Payment{OrderID: order.ID, Provider: "mock", Amount: 30, CapturedAmount: 30, Status: models.PaymentStatusCaptured}
+		if err := store.Payments().Create(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+
+		// Locking changes nothing
+		err := store.Transaction(ctx, func(tx Store) error {
+			locked, err := tx.Orders().Lock(ctx, order.ID)
+			if err != nil || locked.Status != "Confirm" || len(locked.Items) != 1 {
+				t.Errorf("locked order = %+v, %v", locked, err)
+			}
+			payment, err := tx.Payments().Lock(ctx, p.ID)
+			if err != nil || payment.CapturedAmount != 30 || payment.RefundedAmount != 0 {
+				t.Errorf("locked payment = %+v, %v", payment, err)
+			}
+			if _, err := tx.Orders().Lock(ctx, 999); !errors.Is(err, ErrNotFound) {
+				t.Errorf("locking a missing order returned %v, want ErrNotFound", err)
+			}
+			if _, err := tx.Payments().Lock(ctx, 999); !errors.Is(err, ErrNotFound) {
+				t.Errorf("locking a missing payment returned %v, want ErrNotFound", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := store.Payments().Get(ctx, p.ID); got.CapturedAmount != 30 || got.Status != models.PaymentStatusCaptured {
+			t.Fatalf("payment after the lock = %+v", got)
+		}
+	})
+}
+
+func TestSpendByUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now()
+		pay := func(userID int, status, paymentStatus string, captured, refunded float64) models.Order {
+			t.Helper()
+			order := models.Order{UserID: userID, Status: status, TotalPrice: captured, FinalPrice: captured}
+			if err := store.Orders().Create(ctx, &order); err != nil {
+				t.Fatal(err)
+			}
+			payment := models.Payment{OrderID: order.ID, Provider: "mock", Amount: captured, CapturedAmount: captured, RefundedAmount: refunded, Status: paymentStatus}
+			if captured > 0 {
+				capturedAt := now
+				payment.CapturedAt = &capturedAt
+			}
+			if err := store.Payments().Create(ctx, &payment); err != nil {
+				t.Fatal(err)
+			}
+			return order
+		}
+		pay(1, models.OrderStatusPaid, models.PaymentStatusCaptured, 100, 0)
+		pay(1, models.OrderStatusPartiallyRefunded, models.PaymentStatusCaptured, 50, 20)
+		pay(1, models.OrderStatusRefunded, models.PaymentStatusRefunded, 40, 40)
+		pay(1, models.OrderStatusConfirmed, models.PaymentStatusAuthorized, 0, 0)
+		pay(1, models.OrderStatusCancelled, models.PaymentStatusCaptured, 70, 0)
+		deleted := pay(1, models.OrderStatusPaid, models.PaymentStatusCaptured, 60, 0)
+		if err := store.Orders().Delete(ctx, deleted.ID); err != nil {
+			t.Fatal(err)
+		}
+		pay(2, models.OrderStatusPaid, models.PaymentStatusCaptured, 500, 0)
+		// Placed now but captured before the window
+		now = now.AddDate(0, 0, -2)
+		pay(1, models.OrderStatusPaid, models.PaymentStatusCaptured, 80, 0)
+
+		if spend, err := store.Payments().SpendByUser(ctx, 1, time.Now().Add(-time.Hour)); err != nil || spend != 130 {
+			t.Fatalf("SpendByUser = %v, %v, want 130 captured and not refunded", spend, err)
+		}
+		if spend, err := store.Payments().SpendByUser(ctx, 1, time.Now().AddDate(0, 0, -3)); err != nil || spend != 210 {
+			t.Fatalf("SpendByUser of a window with the earlier capture = %v, %v, want 210", spend, err)
+		}
+		if spend, err := store.Payments().SpendByUser(ctx, 1, time.Now().Add(time.Hour)); err != nil || spend != 0 {
+			t.Fatalf("SpendByUser of a window after the orders = %v, %v, want 0", spend, err)
+		}
+		if spend, err := store.Payments().SpendByUser(ctx, 3, time.Time{}); err != nil || spend != 0 {
+			t.Fatalf("SpendByUser of a user without orders = %v, %v, want 0", spend, err)
+		}
+	})
+}
+
+func TestRefunds(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		order := models.Order{UserID: 1, Status: "Paid", TotalPrice: 30, FinalPrice: 30}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		payment := models.Payment{OrderID: order.ID, Provider: "mock", Amount: 30, CapturedAmount: 30, Status: models.PaymentStatusCaptured}
+		if err := store.Payments().Create(ctx, &payment); err != nil {
+			t.Fatal(err)
+		}
+		refunds := store.Refunds()
+
+		partial := models.Refund{OrderID: order.ID, PaymentID: payment.ID, Amount: 15, ProviderReference: "re_1", Lines: []models.RefundLine{
+			{OrderItemID: 1, ItemID: 1, Quantity: 1, Price: 10, Amount: 10},
+			{OrderItemID: 2, ItemID: 2, Quantity: 1, Price: 5, Amount: 5},
+		}}
+		if err := refunds.Create(ctx, &partial); err != nil {
+			t.Fatal(err)
+		}
+		if err := refunds.Create(ctx, &models.Refund{OrderID: order.ID, PaymentID: payment.ID, Amount: 15, ProviderReference: "re_2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := refunds.ListByOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != partial.ID || len(list[0].Lines) != 2 || list[0].Lines[1].ItemID != 2 || len(list[1].Lines) != 0 {
+			t.Fatalf("refunds of the order = %+v", list)
+		}
+		if ok, err := refunds.ExistsByReference(ctx, payment.ID, "re_2"); err != nil || !ok {
+			t.Fatalf("ExistsByReference(re_2) = %v, %v, want true", ok, err)
+		}
+		if ok, err := refunds.ExistsByReference(ctx, payment.ID, "re_3"); err != nil || ok {
+			t.Fatalf("ExistsByReference(re_3) = %v, %v, want false", ok, err)
+		}
+
+		// A pending refund gets its reference once the provider made it, or is removed
+		pending := models.Refund{OrderID: order.ID, PaymentID: payment.ID, Status: models.RefundStatusPending, Amount: 5, Lines: []models.RefundLine{
+			{OrderItemID: 1, ItemID: 1, Quantity: 1, Price: 5, Amount: 5},
+		}}
+		if err := refunds.Create(ctx, &pending); err != nil {
+			t.Fatal(err)
+		}
+		pending.Status, pending.ProviderReference = models.RefundStatusSucceeded, "re_3"
+		if err := refunds.Update(ctx, &pending); err != nil {
+			t.Fatal(err)
+		}
+		if list, _ = refunds.ListByOrder(ctx, order.ID); len(list) != 3 || list[2].Status != models.RefundStatusSucceeded || list[2].ProviderReference != "re_3" {
+			t.Fatalf("refunds after the update = %+v", list)
+		}
+		if list[0].Status != models.RefundStatusSucceeded {
+			t.Fatalf("refund stored without a status = %+v, want it succeeded", list[0])
+		}
+		if err := refunds.Delete(ctx, pending.ID); err != nil {
+			t.Fatal(err)
+		}
+		if list, _ = refunds.ListByOrder(ctx, order.ID); len(list) != 2 {
+			t.Fatalf("%d refunds after the delete, want 2", len(list))
+		}
+		if err := refunds.Delete(ctx, pending.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("deleting a deleted refund returned %v, want ErrNotFound", err)
+		}
+		if err := refunds.Update(ctx, &pending); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("updating a deleted refund returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestInvoices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		order := models.Order{UserID: 1, Status: "Confirm", TotalPrice: 30, FinalPrice: 30}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		invoices := store.Invoices()
+
+		if n, err := invoices.NextSequence(ctx, 2024); err != nil || n != 1 {
+			t.Fatalf("first number of 2024 = %d, %v, want 1", n, err)
+		}
+		if n, err := invoices.NextSequence(ctx, 2025); err != nil || n != 1 {
+			t.Fatalf("first number of 2025 = %d, %v, want 1", n, err)
+		}
+		// A number taken in a transaction that is rolled back is handed out again
+		rollback := errors.New("rollback")
+		err := store.Transaction(ctx, func(tx Store) error {
+			if n, err := tx.Invoices().NextSequence(ctx, 2024); err != nil || n != 2 {
+				t.Errorf("second number of 2024 = %d, %v, want 2", n, err)
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatal(err)
+		}
+		if n, err := invoices.NextSequence(ctx, 2024); err != nil || n != 2 {
+			t.Fatalf("number of 2024 after a rollback = %d, %v, want 2 again", n, err)
+		}
+
+		invoice := models.Invoice{Number: "INV-2024-000002", Year: 2024, Sequence: 2, OrderID: order.ID, Subtotal: 30, Total: 30, Lines: []models.InvoiceLine{
+			{ItemID: 1, Description: "Shirt", Quantity: 1, UnitPrice: 10, Amount: 10},
+			{ItemID: 2, Description: "Hat", Quantity: 2, UnitPrice: 10, Amount: 20},
+		}}
+		if err := invoices.Create(ctx, &invoice); err != nil {
+			t.Fatal(err)
+		}
+		if err := invoices.Create(ctx, &models.Invoice{Number: "INV-2024-000003", Year: 2024, Sequence: 3, OrderID: order.ID}); err == nil {
+			t.Fatal("created a second invoice for the order")
+		}
+
+		got, err := invoices.GetByOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != invoice.ID || got.Number != "INV-2024-000002" || len(got.Lines) != 2 || got.Lines[1].Description != "Hat" {
+			t.Fatalf("invoice of the order = %+v", got)
+		}
+		if _, err := invoices.GetByOrder(ctx, order.ID+1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetByOrder without an invoice returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestShipments(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		order := models.Order{UserID: 1, Status: "Confirm", TotalPrice: 30, FinalPrice: 30}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		shipments := store.Shipments()
+
+		first := models.Shipment{OrderID: order.ID, Carrier: "DHL", TrackingNumber: "JD0001", Status: models.ShipmentStatusInTransit, ShippedAt: time.Now(), Lines: []models.ShipmentLine{
+			{OrderItemID: 1, ItemID: 1, Quantity: 1},
+			{OrderItemID: 2, ItemID: 2, Quantity: 2},
+		}}
+		if err := shipments.Create(ctx, &first); err != nil {
+			t.Fatal(err)
+		}
+		if err := shipments.Create(ctx, &models.Shipment{OrderID: order.ID, Carrier: "DHL", TrackingNumber: "JD0001"}); !errors.Is(err, ErrDuplicateTrackingNumber) {
+			t.Fatalf("reusing a tracking number returned %v, want ErrDuplicateTrackingNumber", err)
+		}
+		second := models.Shipment{OrderID: order.ID, Carrier: "UPS", TrackingNumber: "JD0001", Status: models.ShipmentStatusInTransit, ShippedAt: time.Now()}
+		if err := shipments.Create(ctx, &second); err != nil {
+			t.Fatalf("another carrier's tracking number was rejected: %v", err)
+		}
+
+		delivered := time.Now()
+		first.Status, first.DeliveredAt, first.Carrier = models.ShipmentStatusDelivered, &delivered, "ignored"
+		if err := shipments.Update(ctx, &first); err != nil {
+			t.Fatal(err)
+		}
+		got, err := shipments.Get(ctx, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != models.ShipmentStatusDelivered || got.DeliveredAt == nil || got.Carrier != "DHL" || len(got.Lines) != 2 || got.Lines[1].Quantity != 2 {
+			t.Fatalf("delivered shipment = %+v", got)
+		}
+		if _, err := shipments.Get(ctx, 999); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get of a missing shipment returned %v, want ErrNotFound", err)
+		}
+		if err := shipments.Update(ctx, &models.Shipment{ID: 999}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Update of a missing shipment returned %v, want ErrNotFound", err)
+		}
+
+		list, err := shipments.ListByOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != first.ID || len(list[0].Lines) != 2 || len(list[1].Lines) != 0 {
+			t.Fatalf("shipments of the order = %+v", list)
+		}
+	})
+}
+
+func TestAddresses(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		addresses := store.Addresses()
+
+		home := models.Address{UserID: 1, PostalAddress: models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "Springfield", PostalCode: "94105", Country: "US"}, DefaultShipping: true, DefaultBilling: true}
+		work := models.Address{UserID: 1, PostalAddress: models.PostalAddress{Name: "Ada", Line1: "2 Market St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"}}
+		other := models.Address{UserID: 2, PostalAddress: models.PostalAddress{Name: "Bob", Line1: "3 Elm St", City: "Dublin", PostalCode: "D02 X285", Country: "IE"}}
+		for _, address := range []*models.Address{&home, &work, &other} {
+			if err := addresses.Create(ctx, address); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		home.DefaultShipping, home.City = false, "ignored"
+		if err := addresses.Update(ctx, &home, "default_shipping"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := addresses.Get(ctx, home.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.DefaultShipping || !got.DefaultBilling || got.City != "Springfield" || got.Country != "US" {
+			t.Fatalf("address after update = %+v", got)
+		}
+
+		if err := addresses.Delete(ctx, work.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := addresses.Delete(ctx, work.ID); !errors.Is(err, ErrAlreadyDeleted) {
+			t.Fatalf("deleting twice returned %v, want ErrAlreadyDeleted", err)
+		}
+		if _, err := addresses.Get(ctx, work.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a deleted address returned %v, want ErrNotFound", err)
+		}
+		if err := addresses.Update(ctx, &work); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("updating a deleted address returned %v, want ErrNotFound", err)
+		}
+		list, err := addresses.ListByUser(ctx, 1)
+		if err != nil || len(list) != 1 || list[0].ID != home.ID {
+			t.Fatalf("addresses of the user = %+v, %v, want the home address", list, err)
+		}
+
+		order := models.Order{UserID: 1, Status: "Pending", ShippingAddress: home.PostalAddress, BillingAddress: work.PostalAddress}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := store.Orders().Get(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.ShippingAddress != home.PostalAddress || stored.BillingAddress != work.PostalAddress {
+			t.Fatalf("order addresses = %+v and %+v", stored.ShippingAddress, stored.BillingAddress)
+		}
+	})
+}
+
+func TestCoupons(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		coupons := store.Coupons()
+
+		save := models.Coupon{Code: "SAVE10", Effect: models.CouponPercentage, Value: 0.1, EligibleItemIDs: []int{1, 2}, MaxRedemptions: 3, MaxRedemptionsPerUser: 1}
+		if err := coupons.Create(ctx, &save); err != nil {
+			t.Fatal(err)
+		}
+		if err := coupons.Create(ctx, &models.Coupon{Code: "SAVE10", Effect: models.CouponFixed, Value: 5}); !errors.Is(err, ErrDuplicateCouponCode) {
+			t.Fatalf("creating a coupon with a taken code returned %v, want ErrDuplicateCouponCode", err)
+		}
+		save.Value, save.MinimumSpend, save.Code = 0.15, 20, "ignored"
+		if err := coupons.Update(ctx, &save, "value", "minimum_spend"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := coupons.GetByCode(ctx, "SAVE10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != 0.15 || got.MinimumSpend != 20 || len(got.EligibleItemIDs) != 2 || got.EligibleItemIDs[1] != 2 {
+			t.Fatalf("coupon after update = %+v", got)
+		}
+
+		// Concurrent orders of different users redeem the coupon no more often than it may be
+		var wg sync.WaitGroup
+		results := make([]error, 6)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = store.Transaction(ctx, func(tx Store) error {
+					return tx.Coupons().Redeem(ctx, &models.CouponRedemption{CouponID: save.ID, OrderID: i + 1, UserID: i + 1})
+				})
+			}(i)
+		}
+		wg.Wait()
+		redeemed := 0
+		for _, err := range results {
+			switch {
+			case err == nil:
+				redeemed++
+			case !errors.Is(err, ErrCouponExhausted):
+				t.Fatalf("redeeming returned %v, want ErrCouponExhausted", err)
+			}
+		}
+		if got, _ := coupons.Get(ctx, save.ID); redeemed != 3 || got.Redemptions != 3 {
+			t.Fatalf("%d redemptions, coupon counts %d, want 3", redeemed, got.Redemptions)
+		}
+
+		// Releasing gives a redemption back, users are held to their own limit
+		if err := coupons.Release(ctx, 100); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("releasing the coupon of an order without one returned %v, want ErrNotFound", err)
+		}
+		var holder models.CouponRedemption
+		for i, err := range results {
+			if err == nil {
+				holder = models.CouponRedemption{CouponID: save.ID, OrderID: i + 1, UserID: i + 1}
+				break
+			}
+		}
+		if err := coupons.Release(ctx, holder.OrderID); err != nil {
+			t.Fatal(err)
+		}
+		if err := coupons.Redeem(ctx, &models.CouponRedemption{CouponID: save.ID, OrderID: 10, UserID: 7}); err != nil {
+			t.Fatal(err)
+		}
+		if used, err := coupons.CountRedemptions(ctx, save.ID, 7); err != nil || used != 1 {
+			t.Fatalf("redemptions of the user = %d, %v, want 1", used, err)
+		}
+		save.MaxRedemptions = 0
+		if err := coupons.Update(ctx, &save, "max_redemptions"); err != nil {
+			t.Fatal(err)
+		}
+		if err := coupons.Redeem(ctx, &models.CouponRedemption{CouponID: save.ID, OrderID: 11, UserID: 7}); !errors.Is(err, ErrCouponUserLimit) {
+			t.Fatalf("redeeming twice for a user returned %v, want ErrCouponUserLimit", err)
+		}
+
+		if err := coupons.Delete(ctx, save.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := coupons.GetByCode(ctx, "SAVE10"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting a deleted coupon returned %v, want ErrNotFound", err)
+		}
+		if err := coupons.Redeem(ctx, &models.CouponRedemption{CouponID: save.ID, OrderID: 12, UserID: 8}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("redeeming a deleted coupon returned %v, want ErrNotFound", err)
+		}
+		// The redemption keeps the terms the coupon had
+		redemption, err := coupons.GetRedemption(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if terms := redemption.Terms(); terms.ID != save.ID || terms.Code != "SAVE10" || terms.Value != 0.15 || terms.MinimumSpend != 20 || len(terms.EligibleItemIDs) != 2 {
+			t.Fatalf("terms of the redemption = %+v", terms)
+		}
+		if _, err := coupons.GetRedemption(ctx, 12); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("getting the redemption of an order without one returned %v, want ErrNotFound", err)
+		}
+		if err := coupons.Create(ctx, &models.Coupon{Code: "SAVE10", Effect: models.CouponFixed, Value: 5}); !errors.Is(err, ErrDuplicateCouponCode) {
+			t.Fatalf("reusing the code of a deleted coupon returned %v, want ErrDuplicateCouponCode", err)
+		}
+		if list, err := coupons.List(ctx); err != nil || len(list) != 0 {
+			t.Fatalf("coupons = %+v, %v, want none", list, err)
+		}
+	})
+}
+
+func TestWebhookEvents(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		events := store.WebhookEvents()
+
+		if err := events.Create(ctx, &models.WebhookEvent{Provider: "mock", EventID: "evt_1", Type: "payment.captured", PaymentID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := events.Create(ctx, &models.WebhookEvent{Provider: "mock", EventID: "evt_1", Type: "payment.captured", PaymentID: 1}); !errors.Is(err, ErrDuplicateEvent) {
+			t.Fatalf("recording an event twice returned %v, want ErrDuplicateEvent", err)
+		}
+		// Event IDs are only unique per provider
+		if err := events.Create(ctx, &models.WebhookEvent{Provider: "other", EventID: "evt_1", Type: "payment.captured", PaymentID: 2}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		failure := errors.New("failure")
+
+		err := store.Transaction(ctx, func(tx Store) error {
+			if err := tx.Items().Create(ctx, &models.Item{Name: "Shirt", Description: "Cotton", Price: 10}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Transaction returned %v, want the error of fn", err)
+		}
+		if list, err := store.Items().List(ctx); err != nil || len(list) != 0 {
+			t.Fatalf("listed %v, %v after rollback, want no items", list, err)
+		}
+
+		err = store.Transaction(ctx, func(tx Store) error {
+			return tx.Items().Create(ctx, &models.Item{Name: "Shirt", Description: "Cotton", Price: 10})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list, err := store.Items().List(ctx); err != nil || len(list) != 1 {
+			t.Fatalf("listed %v, %v after commit, want one item", list, err)
+		}
+	})
+}
+
+func TestCanceledContext(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := store.Users().List(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("List returned %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+const (
+	homeAddress = `{"name":"Ada Lovelace","line1":"1 Main St","city":"Springfield","state":"CA","postal_code":"94105","country":"US"}`
+	workAddress = `{"name":"Ada Lovelace","line1":"2 Market St","city":"London","postal_code":"sw1a 1aa","country":"gb","default_billing":true}`
+)
+
+func TestAddressBookAndOrderSnapshots(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+
+	var home, work struct{ Address models.Address }
+	api.send(http.MethodPost, "/api/createAddressByUserId/"+itoa(ada), homeAddress).expect(http.StatusOK).decode(&home)
+	api.send(http.MethodPost, "/api/createAddressByUserId/"+itoa(ada), workAddress).expect(http.StatusOK).decode(&work)
+	if !home.Address.DefaultShipping || !home.Address.DefaultBilling || work.Address.PostalCode != "SW1A 1AA" || work.Address.Country != "GB" {
+		t.Fatalf("addresses %+v and %+v, want home as the first default and work normalized", home.Address, work.Address)
+	}
+	var list struct{ Addresses []models.Address }
+	api.get("/api/getAddressesByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&list)
+	if len(list.Addresses) != 2 || list.Addresses[0].DefaultBilling || !list.Addresses[1].DefaultBilling {
+		t.Fatalf("addresses %+v, want work to be the default billing address", list.Addresses)
+	}
+
+	// The order ships to the default shipping address and is billed to the default billing address
+	order := itoa(api.createOrder(ada, line(shirt, 1)).ID)
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.ShippingAddress.City != "Springfield" || got.BillingAddress.City != "London" {
+		t.Fatalf("order addresses %+v and %+v, want home and work", got.ShippingAddress, got.BillingAddress)
+	}
+
+	// Changing the address book leaves the order alone
+	api.send(http.MethodPut, "/api/updateAddressByAddressId/"+itoa(home.Address.ID), strings.Replace(homeAddress, "1 Main St", "9 Oak Ave", 1)).expect(http.StatusOK)
+	api.delete("/api/deleteAddressByAddressId/" + itoa(work.Address.ID)).expect(http.StatusOK)
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.ShippingAddress.Line1 != "1 Main St" || got.BillingAddress.City != "London" {
+		t.Fatalf("order addresses after changing the address book %+v and %+v", got.ShippingAddress, got.BillingAddress)
+	}
+
+	var created struct{ Order models.Order }
+	api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}],"shipping_address":`+workAddress+`,"billing_address_id":`+itoa(home.Address.ID)+`}`).
+		expect(http.StatusOK).decode(&created)
+	if created.Order.ShippingAddress.PostalCode != "SW1A 1AA" || created.Order.BillingAddress.Line1 != "9 Oak Ave" {
+		t.Fatalf("order addresses %+v and %+v, want the inline address and home", created.Order.ShippingAddress, created.Order.BillingAddress)
+	}
+}
+
+func TestAddressErrors(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	bob := api.createUser("Bob", "bob@example.com")
+	shirt := api.addItem("Shirt", 10)
+
+	problem := api.send(http.MethodPost, "/api/createAddressByUserId/"+itoa(ada), `{"name":"Ada","line1":"1 Main St","city":"Paris","postal_code":"7500","country":"FR"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "postal_code" {
+		t.Errorf("field errors %+v, want postal_code", problem.Errors)
+	}
+	problem = api.send(http.MethodPost, "/api/createAddressByUserId/"+itoa(ada), `{"name":"Ada","line1":"1 Main St","city":"Atlantis","country":"XX"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "country" {
+		t.Errorf("field errors %+v, want country", problem.Errors)
+	}
+	api.send(http.MethodPost, "/api/createAddressByUserId/999", homeAddress).expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+	api.get("/api/getAddressesByUserId/999").expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+	api.send(http.MethodPut, "/api/updateAddressByAddressId/999", homeAddress).expectProblem(http.StatusNotFound, apperrors.CodeAddressNotFound)
+	api.delete("/api/deleteAddressByAddressId/999").expectProblem(http.StatusNotFound, apperrors.CodeAddressNotFound)
+
+	var bobs struct{ Address models.Address }
+	api.send(http.MethodPost, "/api/createAddressByUserId/"+itoa(bob), homeAddress).expect(http.StatusOK).decode(&bobs)
+	items := `"items":[{"item_id":` + itoa(shirt) + `,"quantity":1}]`
+	problem = api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"shipping_address_id":`+itoa(bobs.Address.ID)+`}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "shipping_address_id" {
+		t.Errorf("field errors %+v, want shipping_address_id", problem.Errors)
+	}
+	problem = api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"billing_address":{"name":"Ada","line1":"1 Main St","city":"Berlin","country":"DE"}}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "billing_address.postal_code" {
+		t.Errorf("field errors %+v, want billing_address.postal_code", problem.Errors)
+	}
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/handlers"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+// TestHandlersUseRequestContext sends every database backed route a request whose
+// context is already canceled. A handler that passes the request context to GORM
+// fails before touching the database and answers 503, one that doesn't would succeed.
+func TestHandlersUseRequestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := openTestDB(t)
+
+	r := gin.New()
+	store := repository.NewGormStore(db)
+	SetupRoutes(r, &handlers.Application{
+		Store:             store,
+		Orders:            service.NewOrderService(store),
+		Payments:          service.NewPaymentService(store, payment.NewMock()),
+		Shipments:         service.NewShipmentService(store),
+		Addresses:         service.NewAddressService(store),
+		Coupons:           service.NewCouponService(store),
+		EmailVerification: &handlers.EmailVerification{Mailer: mailer.LogSender{}, PublicURL: "http://localhost", TokenTTL: time.Hour},
+		Readiness:         health.NewReadiness(db),
+	})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	requests := []struct {
+		method, path, contentType, body string
+	}{
+		{http.MethodPost, "/api/createUser", "application/json", `{"name":"Ada","email":"ada@example.com"}`},
+		{http.MethodGet, "/api/FetchAllUser", "", ""},
+		{http.MethodGet, "/api/GetUserDetailByUserId/1", "", ""},
+		{http.MethodGet, "/api/GetUserDetailsWithOrdersByUserId/1", "", ""},
+		{http.MethodPut, "/api/UpdateUserDetails/1", "application/json", `{"name":"Ada","email":"ada@example.com"}`},
+		{http.MethodPatch, "/api/UpdateUserDetails/1", "application/merge-patch+json", `{"name":"Ada"}`},
+		{http.MethodDelete, "/api/DeleteUserByUserId/1", "", ""},
+		{http.MethodPost, "/api/SendVerificationEmail/1", "", ""},
+		{http.MethodGet, "/api/VerifyEmail?token=abc", "", ""},
+		{http.MethodPost, "/api/AddItem", "application/json", `{"name":"Shirt","description":"Cotton","price":10}`},
+		{http.MethodGet, "/api/GetItems", "", ""},
+		{http.MethodGet, "/api/GetItemByItemId/1", "", ""},
+		{http.MethodPut, "/api/UpdateItemByItemId/1", "application/json", `{"name":"Shirt","description":"Cotton","price":10}`},
+		{http.MethodPatch, "/api/UpdateItemByItemId/1", "application/merge-patch+json", `{"price":12}`},
+		{http.MethodDelete, "/api/DeleteItemByItemId/1", "", ""},
+		{http.MethodPost, "/api/createOrder", "application/json", `{"user_id":1,"items":[{"item_id":1,"quantity":1}]}`},
+		{http.MethodGet, "/api/getOrders", "", ""},
+		{http.MethodGet, "/api/getOrderByOrderId/1", "", ""},
+		{http.MethodPut, "/api/updateOrderByOrderId/1", "application/json", `{"items":[{"item_id":1,"quantity":1}]}`},
+		{http.MethodPatch, "/api/updateOrderByOrderId/1", "application/merge-patch+json", `{"items":[{"item_id":1,"quantity":1}]}`},
+		{http.MethodPut, "/api/updateOrderStatusByOrderId/1", "", ""},
+		{http.MethodDelete, "/api/deleteOrderByOderId/1", "", ""},
+		{http.MethodPost, "/api/startPaymentByOrderId/1", "application/json", `{"payment_method":"tok_visa"}`},
+		{http.MethodPost, "/api/capturePaymentByOrderId/1", "", ""},
+		{http.MethodGet, "/api/getPaymentsByOrderId/1", "", ""},
+		{http.MethodPost, "/api/refundOrderByOrderId/1", "application/json", `{}`},
+		{http.MethodGet, "/api/getRefundsByOrderId/1", "", ""},
+		{http.MethodGet, "/orders/1/invoice", "", ""},
+		{http.MethodPost, "/api/quoteOrder", "application/json", `{"user_id":1,"items":[{"item_id":1,"quantity":1}]}`},
+		{http.MethodPost, "/api/createShipmentByOrderId/1", "application/json", `{"carrier":"DHL","tracking_number":"JD0001"}`},
+		{http.MethodPut, "/api/deliverShipmentByShipmentId/1", "", ""},
+		{http.MethodGet, "/api/getShipmentsByOrderId/1", "", ""},
+		{http.MethodPost, "/api/createAddressByUserId/1", "application/json", `{"name":"Ada","line1":"1 Main St","city":"Springfield","postal_code":"94105","country":"US"}`},
+		{http.MethodGet, "/api/getAddressesByUserId/1", "", ""},
+		{http.MethodPut, "/api/updateAddressByAddressId/1", "application/json", `{"name":"Ada","line1":"1 Main St","city":"Springfield","postal_code":"94105","country":"US"}`},
+		{http.MethodDelete, "/api/deleteAddressByAddressId/1", "", ""},
+		{http.MethodPost, "/api/createCoupon", "application/json", `{"code":"SAVE10","effect":"percentage","value":0.1}`},
+		{http.MethodGet, "/api/getCoupons", "", ""},
+		{http.MethodPut, "/api/updateCouponByCouponId/1", "application/json", `{"code":"SAVE10","effect":"percentage","value":0.1}`},
+		{http.MethodDelete, "/api/deleteCouponByCouponId/1", "", ""},
+	}
+
+	for _, tc := range requests {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)).WithContext(canceled)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+			}
+		})
+	}
+}
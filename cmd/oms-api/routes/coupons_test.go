@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+func TestCouponsDiscountOrders(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	shoes := api.addItem("Shoes", 50)
+
+	var created struct{ Coupon models.Coupon }
+	api.send(http.MethodPost, "/api/createCoupon", `{"code":"save10","effect":"percentage","value":0.1,"max_redemptions":1}`).
+		expect(http.StatusOK).decode(&created)
+	if created.Coupon.Code != "SAVE10" {
+		t.Fatalf("created coupon %+v, want its code in upper case", created.Coupon)
+	}
+	api.send(http.MethodPost, "/api/createCoupon", `{"code":"SAVE10","effect":"fixed","value":5}`).
+		expectProblem(http.StatusConflict, apperrors.CodeCouponCodeTaken)
+	problem := api.send(http.MethodPost, "/api/createCoupon", `{"code":"HALF","effect":"percentage","value":50}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "value" {
+		t.Errorf("field errors %+v, want value", problem.Errors)
+	}
+	api.send(http.MethodPost, "/api/createCoupon", `{"code":"HALF OFF","effect":"half"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+
+	// The coupon comes off the order and is shown on it
+	items := `"items":[{"item_id":` + itoa(shirt) + `,"quantity":2},{"item_id":` + itoa(shoes) + `,"quantity":1}]`
+	var quoted struct{ Quote service.Quote }
+	api.send(http.MethodPost, "/api/quoteOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"coupon_code":"save10"}`).expect(http.StatusOK).decode(&quoted)
+	if quoted.Quote.CouponCode != "SAVE10" || quoted.Quote.Discounts.CouponDiscount != 7 || quoted.Quote.FinalPrice != 63 {
+		t.Fatalf("quote %+v, want 7 off", quoted.Quote)
+	}
+	var order struct{ Order models.Order }
+	api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"coupon_code":"save10"}`).expect(http.StatusOK).decode(&order)
+	id := itoa(order.Order.ID)
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + id).expect(http.StatusOK).decode(&got)
+	if got.CouponCode != "SAVE10" || got.CouponDiscount != 7 || got.Totals.Discount != 7 || got.FinalPrice != 63 {
+		t.Fatalf("order %+v, want 7 off", got)
+	}
+
+	// The coupon has been used up by the order
+	problem = api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"coupon_code":"SAVE10"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeCouponNotApplicable)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "coupon_code" || problem.Errors[0].Rule != service.CouponExhausted {
+		t.Errorf("field errors %+v, want coupon_code to be exhausted", problem.Errors)
+	}
+
+	// Removing the coupon from the order, with null like with an empty code, gives it back
+	var patched struct{ Order models.Order }
+	api.patch("/api/updateOrderByOrderId/"+id, `{"coupon_code":null}`).expect(http.StatusOK).decode(&patched)
+	if patched.Order.CouponCode != "" || patched.Order.FinalPrice != 70 {
+		t.Fatalf("order without its coupon %+v", patched.Order)
+	}
+	var listed struct{ Coupons []models.Coupon }
+	api.get("/api/getCoupons").expect(http.StatusOK).decode(&listed)
+	if len(listed.Coupons) != 1 || listed.Coupons[0].Redemptions != 0 {
+		t.Fatalf("coupons %+v, want the redemption given back", listed.Coupons)
+	}
+
+	// Updated terms apply to the orders that redeem the coupon afterwards
+	coupon := itoa(created.Coupon.ID)
+	api.send(http.MethodPut, "/api/updateCouponByCouponId/"+coupon, `{"code":"SAVE10","effect":"fixed","value":15,"eligible_item_ids":[`+itoa(shoes)+`]}`).expect(http.StatusOK)
+	api.patch("/api/updateOrderByOrderId/"+id, `{"coupon_code":"Save10"}`).expect(http.StatusOK).decode(&patched)
+	if patched.Order.CouponDiscount != 15 || patched.Order.FinalPrice != 55 {
+		t.Fatalf("order with 15 off the shoes %+v", patched.Order)
+	}
+	problem = api.patch("/api/updateOrderByOrderId/"+id, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeCouponNotApplicable)
+	if len(problem.Errors) != 1 || problem.Errors[0].Rule != service.CouponNoEligibleItems {
+		t.Errorf("field errors %+v, want no eligible items", problem.Errors)
+	}
+
+	api.delete("/api/deleteCouponByCouponId/" + coupon).expect(http.StatusOK)
+	api.delete("/api/deleteCouponByCouponId/"+coupon).expectProblem(http.StatusNotFound, apperrors.CodeCouponNotFound)
+	// The order keeps the terms it redeemed after the coupon has been deleted
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+id, `{"items":[{"item_id":`+itoa(shoes)+`,"quantity":2}]}`).expect(http.StatusOK)
+	api.get("/api/getOrderByOrderId/" + id).expect(http.StatusOK).decode(&got)
+	if got.CouponCode != "SAVE10" || got.CouponDiscount != 15 || got.FinalPrice != 85 {
+		t.Fatalf("order changed after its coupon was deleted %+v, want 15 off", got)
+	}
+	api.send(http.MethodPut, "/api/updateCouponByCouponId/"+coupon, `{"code":"SAVE10","effect":"fixed","value":5}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeCouponNotFound)
+	api.send(http.MethodPost, "/api/quoteOrder", `{"user_id":`+itoa(ada)+`,`+items+`,"coupon_code":"SAVE10"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeCouponNotApplicable)
+}
@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/loyalty"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+func TestSeasonalDiscount(t *testing.T) {
+	tests := []struct {
+		name  string
+		now   time.Time
+		final float64
+	}{
+		{"before the season", time.Date(2024, time.December, 2, 23, 59, 0, 0, time.UTC), 100},
+		{"first day", time.Date(2024, time.December, 3, 0, 0, 0, 0, time.UTC), 85},
+		{"last minute", time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC), 85},
+		{"new year", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			ada := api.createUser("Ada", "ada@example.com")
+			shirt := api.addItem("Shirt", 50)
+			api.clock.Set(tt.now)
+
+			order := api.createOrder(ada, line(shirt, 2))
+			if order.TotalPrice != 100 || order.FinalPrice != tt.final {
+				t.Fatalf("order priced %v/%v, want 100/%v", order.TotalPrice, order.FinalPrice, tt.final)
+			}
+		})
+	}
+}
+
+func TestVolumeDiscount(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	hat := api.addItem("Hat", 5)
+
+	// Nine units of an item are not enough, ten take 10% off that line only
+	order := api.createOrder(ada, line(shirt, 9), line(hat, 10))
+	if order.TotalPrice != 140 || order.FinalPrice != 135 {
+		t.Fatalf("order priced %v/%v, want 140/135", order.TotalPrice, order.FinalPrice)
+	}
+}
+
+// pay pays an order and confirms it
+func (api *testAPI) pay(order int) {
+	api.t.Helper()
+	id := itoa(order)
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+id, `{"payment_method":"tok_visa"}`).expect(http.StatusOK)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+id, "").expect(http.StatusOK)
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+id, "").expect(http.StatusOK)
+}
+
+func TestLoyaltyDiscount(t *testing.T) {
+	api := newTestAPI(t)
+	program, err := loyalty.New(365, []loyalty.Tier{
+		{Name: "Silver", MinimumSpend: 100, DiscountRate: 0.05},
+		{Name: "Gold", MinimumSpend: 300, DiscountRate: 0.1, FreeShipping: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.app.Orders.Loyalty = program
+	ada := api.createUser("Ada", "ada@example.com")
+	grace := api.createUser("Grace", "grace@example.com")
+	shirt := api.addItem("Shirt", 20)
+	watch := api.addItem("Watch", 120)
+	standing := func(userID int) loyalty.Status {
+		t.Helper()
+		var body struct{ Loyalty loyalty.Status }
+		api.get("/api/GetUserDetailByUserId/" + itoa(userID)).expect(http.StatusOK).decode(&body)
+		return body.Loyalty
+	}
+
+	// Orders that have not been paid do not count, however many there are
+	for i := 0; i < 6; i++ {
+		if order := api.createOrder(ada, line(shirt, 1)); order.FinalPrice != 20 {
+			t.Fatalf("order %d priced %v, want no discount before any spend", i+1, order.FinalPrice)
+		}
+	}
+	if got := standing(ada); got != (loyalty.Status{WindowDays: 365, NextTier: "Silver", SpendToNextTier: 100}) {
+		t.Fatalf("loyalty without spend %+v", got)
+	}
+
+	paid := api.createOrder(ada, line(watch, 1))
+	api.pay(paid.ID)
+	var quoted struct{ Quote service.Quote }
+	api.send(http.MethodPost, "/api/quoteOrder", `{"user_id":`+itoa(ada)+`,"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).expect(http.StatusOK).decode(&quoted)
+	if quoted.Quote.FinalPrice != 19 || quoted.Quote.Discounts.LoyaltyTier != "Silver" {
+		t.Fatalf("quote after spending 120 %+v, want 5%% off as Silver", quoted.Quote)
+	}
+	want := loyalty.Status{Tier: "Silver", Spend: 120, WindowDays: 365, DiscountRate: 0.05, NextTier: "Gold", SpendToNextTier: 180, Progress: 0.1}
+	if got := standing(ada); got != want {
+		t.Fatalf("loyalty after spending 120 %+v, want %+v", got, want)
+	}
+	var withOrders struct{ Loyalty loyalty.Status }
+	api.get("/api/GetUserDetailsWithOrdersByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&withOrders)
+	if withOrders.Loyalty != want {
+		t.Fatalf("loyalty with orders %+v, want %+v", withOrders.Loyalty, want)
+	}
+	if order := api.createOrder(grace, line(shirt, 1)); order.FinalPrice != 20 {
+		t.Fatalf("another user's order priced %v, want no discount", order.FinalPrice)
+	}
+
+	// Refunded spend no longer counts
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+itoa(paid.ID), `{}`).expect(http.StatusOK)
+	if order := api.createOrder(ada, line(shirt, 1)); order.FinalPrice != 20 {
+		t.Fatalf("order after a refund priced %v, want no discount", order.FinalPrice)
+	}
+	if got := standing(ada); got.Tier != "" || got.Spend != 0 {
+		t.Fatalf("loyalty after a refund %+v, want no tier", got)
+	}
+}
+
+func TestDiscountsCombine(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	api.clock.Set(time.Date(2024, time.December, 24, 10, 0, 0, 0, time.UTC))
+
+	// 15% seasonal on 100 plus 10% of the volume line
+	order := api.createOrder(ada, line(shirt, 10))
+	if order.TotalPrice != 100 || order.FinalPrice != 75 {
+		t.Fatalf("order priced %v/%v, want 100/75", order.TotalPrice, order.FinalPrice)
+	}
+}
+
+func TestSeasonalDiscountFollowsTheCustomerRegion(t *testing.T) {
+	api := newTestAPI(t)
+	shirt := api.addItem("Shirt", 50)
+	customer := func(email, region string) int {
+		var body userBody
+		api.send(http.MethodPost, "/api/createUser", `{"name":"Customer","email":"`+email+`","region":"`+region+`"}`).
+			expect(http.StatusOK).decode(&body)
+		return body.User.ID
+	}
+	utc := customer("utc@example.com", "")
+	india := customer("india@example.com", "in")
+	newYork := customer("ny@example.com", "us-east")
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		userID int
+		final  float64
+	}{
+		{"already December 3rd in India", time.Date(2024, time.December, 2, 19, 0, 0, 0, time.UTC), india, 85},
+		{"still December 2nd in UTC", time.Date(2024, time.December, 2, 19, 0, 0, 0, time.UTC), utc, 100},
+		{"December 3rd in UTC", time.Date(2024, time.December, 3, 2, 0, 0, 0, time.UTC), utc, 85},
+		{"still December 2nd in New York", time.Date(2024, time.December, 3, 2, 0, 0, 0, time.UTC), newYork, 100},
+		{"still December 31st in New York", time.Date(2025, time.January, 1, 2, 0, 0, 0, time.UTC), newYork, 85},
+		{"already January 1st in UTC", time.Date(2025, time.January, 1, 2, 0, 0, 0, time.UTC), utc, 100},
+	}
+	for _, tt := range tests {
+		api.clock.Set(tt.now)
+		if order := api.createOrder(tt.userID, line(shirt, 2)); order.FinalPrice != tt.final {
+			t.Errorf("%s: order priced %v, want %v", tt.name, order.FinalPrice, tt.final)
+		}
+	}
+}
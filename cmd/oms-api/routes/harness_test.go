@@ -0,0 +1,282 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/campaign"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/handlers"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/mailer"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/middleware"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/utils"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testAPI serves the routes of SetupRoutes on a database of its own. The clock of the
+// order service and the outgoing mail are under the control of the test.
+type testAPI struct {
+	t      *testing.T
+	router *gin.Engine
+	db     *gorm.DB
+	app    *handlers.Application
+	mail   *mailbox
+	clock  *clock.Manual
+}
+
+// openTestDB opens a migrated in-memory database that is closed when the test ends
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // Every connection would get its own in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// testWebhookSecret signs the webhooks of the mock provider
+const testWebhookSecret = "whsec_test"
+
+// testRegions are the campaign regions of the test API, the business timezone is UTC
+var testRegions = map[string]string{"in": "Asia/Kolkata", "us-east": "America/New_York"}
+
+// newTestAPI starts an API on an empty database, its clock stands at noon on June 1st 2024
+// and it runs the default campaigns in every region
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	api := &testAPI{
+		t:     t,
+		db:    openTestDB(t),
+		mail:  &mailbox{},
+		clock: clock.NewManual(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)),
+	}
+
+	regions := map[string]*time.Location{}
+	for name, tz := range testRegions {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		regions[name] = loc
+	}
+	calendar, err := campaign.New(time.UTC, regions, campaign.DefaultCampaigns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := repository.NewGormStore(api.db)
+	orders := service.NewOrderService(store)
+	orders.Clock = api.clock
+	orders.Calendar = calendar
+	orders.Payments = service.NewPaymentService(store, payment.NewMock())
+	orders.Payments.Clock = api.clock
+	shipments := service.NewShipmentService(store)
+	shipments.Clock = api.clock
+	api.app = &handlers.Application{
+		Store:             store,
+		Orders:            orders,
+		Payments:          orders.Payments,
+		Shipments:         shipments,
+		Addresses:         service.NewAddressService(store),
+		Coupons:           service.NewCouponService(store),
+		EmailVerification: &handlers.EmailVerification{Mailer: api.mail, PublicURL: "http://oms.test", TokenTTL: time.Hour},
+		Webhooks:          &handlers.Webhooks{Secrets: map[string]string{"mock": testWebhookSecret}, Tolerance: webhook.DefaultTolerance, Clock: api.clock},
+		Readiness:         health.NewReadiness(api.db),
+		SchemaVersion: func(ctx context.Context) (int, error) {
+			return database.CurrentVersion(api.db.WithContext(ctx))
+		},
+	}
+
+	quiet, err := logging.New(io.Discard, "error", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.router = gin.New()
+	api.router.Use(middleware.RequestID(quiet), gin.Recovery())
+	SetupRoutes(api.router, api.app)
+	return api
+}
+
+// response is a recorded response
+type response struct {
+	*httptest.ResponseRecorder
+	t *testing.T
+}
+
+// do sends a request with a body of the given content type
+func (api *testAPI) do(method, path, contentType, body string) response {
+	api.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	return response{ResponseRecorder: w, t: api.t}
+}
+
+func (api *testAPI) get(path string) response {
+	api.t.Helper()
+	return api.do(http.MethodGet, path, "", "")
+}
+
+func (api *testAPI) delete(path string) response {
+	api.t.Helper()
+	return api.do(http.MethodDelete, path, "", "")
+}
+
+// send sends a JSON body
+func (api *testAPI) send(method, path, body string) response {
+	api.t.Helper()
+	return api.do(method, path, "application/json", body)
+}
+
+// patch sends a JSON merge patch
+func (api *testAPI) patch(path, body string) response {
+	api.t.Helper()
+	return api.do(http.MethodPatch, path, utils.MergePatchContentType, body)
+}
+
+// expect fails the test unless the response has status
+func (r response) expect(status int) response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("status %d, want %d: %s", r.Code, status, r.Body.String())
+	}
+	return r
+}
+
+// expectProblem fails the test unless the response is a problem with status and code
+func (r response) expectProblem(status int, code apperrors.Code) apperrors.Problem {
+	r.t.Helper()
+	r.expect(status)
+	if ct := r.Header().Get("Content-Type"); !strings.HasPrefix(ct, apperrors.ProblemContentType) {
+		r.t.Fatalf("content type %q, want %s", ct, apperrors.ProblemContentType)
+	}
+	var problem apperrors.Problem
+	r.decode(&problem)
+	if problem.Code != code {
+		r.t.Fatalf("problem code %q, want %q: %s", problem.Code, code, r.Body.String())
+	}
+	return problem
+}
+
+// decode unmarshals the JSON body into v
+func (r response) decode(v interface{}) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("decode %s: %v", r.Body.String(), err)
+	}
+}
+
+// createUser creates a user through the API and returns its ID
+func (api *testAPI) createUser(name, email string) int {
+	api.t.Helper()
+	var body struct{ User models.UserResponse }
+	api.send(http.MethodPost, "/api/createUser", `{"name":"`+name+`","email":"`+email+`"}`).expect(http.StatusOK).decode(&body)
+	return body.User.ID
+}
+
+// addItem adds an item through the API and returns its ID
+func (api *testAPI) addItem(name string, price float64) int {
+	api.t.Helper()
+	var body struct{ Item models.Item }
+	payload, _ := json.Marshal(models.ItemRequest{Name: name, Description: name + " description", Price: price})
+	api.send(http.MethodPost, "/api/AddItem", string(payload)).expect(http.StatusOK).decode(&body)
+	return body.Item.ID
+}
+
+// createOrder creates an order through the API
+func (api *testAPI) createOrder(userID int, items ...models.OrderItemRequest) models.Order {
+	api.t.Helper()
+	var body struct{ Order models.Order }
+	payload, _ := json.Marshal(models.CreateOrderRequest{UserID: userID, Items: items})
+	api.send(http.MethodPost, "/api/createOrder", string(payload)).expect(http.StatusOK).decode(&body)
+	return body.Order
+}
+
+// itoa formats an ID for a path
+func itoa(id int) string {
+	return strconv.Itoa(id)
+}
+
+// line is a shorthand for an order request line
+func line(itemID, quantity int) models.OrderItemRequest {
+	return models.OrderItemRequest{ItemID: itemID, Quantity: quantity}
+}
+
+// mailbox records the messages sent by the API
+type mailbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *mailbox) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *mailbox) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+var verificationLink = regexp.MustCompile(`/api/VerifyEmail\?token=(\S+)`)
+
+// lastToken returns the verification token of the last message sent to address
+func (m *mailbox) lastToken(t *testing.T, address string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != address {
+			continue
+		}
+		match := verificationLink.FindStringSubmatch(m.messages[i].Body)
+		if match == nil {
+			t.Fatalf("no verification link in %q", m.messages[i].Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	t.Fatalf("no message sent to %s", address)
+	return ""
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+// getAs sends a GET request accepting the accept media types
+func (api *testAPI) getAs(path, accept string) response {
+	api.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	return response{ResponseRecorder: w, t: api.t}
+}
+
+func TestConfirmedOrdersAreInvoiced(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	first := itoa(api.createOrder(ada, line(shirt, 10)).ID)
+	second := itoa(api.createOrder(ada, line(shirt, 1)).ID)
+
+	api.get("/orders/"+first+"/invoice").expectProblem(http.StatusConflict, apperrors.CodeOrderNotConfirmed)
+	api.get("/orders/999/invoice").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+first, "").expect(http.StatusOK)
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+second, `{"status":"Confirm","items":[{"item_id":`+itoa(shirt)+`,"quantity":2}]}`).expect(http.StatusOK)
+
+	// Renaming the item does not change the invoices issued before
+	api.send(http.MethodPut, "/api/UpdateItemByItemId/"+itoa(shirt), `{"name":"T-shirt","description":"Cotton","price":12}`).expect(http.StatusOK)
+
+	var got struct{ Invoice models.Invoice }
+	api.get("/orders/" + first + "/invoice").expect(http.StatusOK).decode(&got)
+	if got.Invoice.Number != "INV-2024-000001" || got.Invoice.CustomerName != "Ada" || got.Invoice.Subtotal != 100 || got.Invoice.Discount != 10 || got.Invoice.Total != 90 {
+		t.Fatalf("invoice of the first order %+v", got.Invoice)
+	}
+	if len(got.Invoice.Lines) != 1 || got.Invoice.Lines[0].Description != "Shirt" || got.Invoice.Lines[0].Amount != 90 {
+		t.Fatalf("invoice lines %+v, want the shirts as ordered", got.Invoice.Lines)
+	}
+	api.get("/orders/" + second + "/invoice").expect(http.StatusOK).decode(&got)
+	if got.Invoice.Number != "INV-2024-000002" || got.Invoice.Total != 20 {
+		t.Fatalf("invoice of the second order %+v", got.Invoice)
+	}
+}
+
+func TestInvoiceFormats(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	order := itoa(api.createOrder(ada, line(api.addItem("Shirt", 10), 1)).ID)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+	path := "/orders/" + order + "/invoice"
+
+	html := api.getAs(path, "text/html,application/xhtml+xml;q=0.9").expect(http.StatusOK)
+	if ct := html.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" || !strings.Contains(html.Body.String(), "Invoice INV-2024-000001") {
+		t.Fatalf("HTML invoice of type %q:\n%s", ct, html.Body)
+	}
+	pdf := api.getAs(path, "application/pdf").expect(http.StatusOK)
+	if ct := pdf.Header().Get("Content-Type"); ct != "application/pdf" || !bytes.HasPrefix(pdf.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("PDF invoice of type %q: %.100q", ct, pdf.Body.Bytes())
+	}
+	if disposition := pdf.Header().Get("Content-Disposition"); disposition != `inline; filename="INV-2024-000001.pdf"` {
+		t.Errorf("Content-Disposition %q", disposition)
+	}
+	if vary := api.getAs(path, "*/*").expect(http.StatusOK).Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("Vary %q, want Accept", vary)
+	}
+	api.getAs(path, "image/png").expectProblem(http.StatusNotAcceptable, apperrors.CodeNotAcceptable)
+}
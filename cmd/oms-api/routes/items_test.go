@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestItemCRUD(t *testing.T) {
+	api := newTestAPI(t)
+
+	var items []models.Item
+	api.get("/api/GetItems").expect(http.StatusOK).decode(&items)
+	if items == nil || len(items) != 0 {
+		t.Fatalf("items %v, want an empty list", items)
+	}
+
+	shirt := api.addItem("Shirt", 19.99)
+	path := itoa(shirt)
+
+	var got models.Item
+	api.get("/api/GetItemByItemId/" + path).expect(http.StatusOK).decode(&got)
+	if got.Name != "Shirt" || got.Price != 19.99 {
+		t.Fatalf("got item %+v", got)
+	}
+
+	api.send(http.MethodPut, "/api/UpdateItemByItemId/"+path, `{"name":"Blue shirt","description":"Cotton","price":24.5}`).expect(http.StatusOK)
+	api.get("/api/GetItemByItemId/" + path).expect(http.StatusOK).decode(&got)
+	if got.Name != "Blue shirt" || got.Description != "Cotton" || got.Price != 24.5 {
+		t.Fatalf("item after update %+v", got)
+	}
+
+	var patched struct{ Item models.Item }
+	api.patch("/api/UpdateItemByItemId/"+path, `{"price":20}`).expect(http.StatusOK).decode(&patched)
+	if patched.Item.Name != "Blue shirt" || patched.Item.Price != 20 {
+		t.Fatalf("patched item %+v, want only the price changed", patched.Item)
+	}
+
+	api.get("/api/GetItems").expect(http.StatusOK).decode(&items)
+	if len(items) != 1 || items[0].ID != shirt {
+		t.Fatalf("items %+v", items)
+	}
+}
+
+func TestItemErrors(t *testing.T) {
+	api := newTestAPI(t)
+	shirt := itoa(api.addItem("Shirt", 10))
+
+	problem := api.send(http.MethodPost, "/api/AddItem", `{"name":"Free","description":"Nothing","price":0}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "price" {
+		t.Errorf("field errors %+v, want price", problem.Errors)
+	}
+	api.send(http.MethodPut, "/api/UpdateItemByItemId/"+shirt, `{"name":"Shirt"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.patch("/api/UpdateItemByItemId/"+shirt, `{"price":-1}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.patch("/api/UpdateItemByItemId/"+shirt, `{"sku":"X"}`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidPatch)
+
+	api.get("/api/GetItemByItemId/999").expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+	api.get("/api/GetItemByItemId/shirt").expectProblem(http.StatusBadRequest, apperrors.CodeInvalidID)
+	api.send(http.MethodPut, "/api/UpdateItemByItemId/999", `{"name":"Shirt","description":"Cotton","price":1}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+	api.patch("/api/UpdateItemByItemId/999", `{"price":1}`).expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+}
+
+func TestDeleteItemIsSoft(t *testing.T) {
+	api := newTestAPI(t)
+	shirt := api.addItem("Shirt", 10)
+	hat := api.addItem("Hat", 5)
+
+	api.delete("/api/DeleteItemByItemId/" + itoa(shirt)).expect(http.StatusOK)
+	api.delete("/api/DeleteItemByItemId/"+itoa(shirt)).expectProblem(http.StatusConflict, apperrors.CodeItemAlreadyDeleted)
+	api.delete("/api/DeleteItemByItemId/999").expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+
+	api.get("/api/GetItemByItemId/"+itoa(shirt)).expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+	api.patch("/api/UpdateItemByItemId/"+itoa(shirt), `{"price":1}`).expectProblem(http.StatusNotFound, apperrors.CodeItemNotFound)
+
+	var items []models.Item
+	api.get("/api/GetItems").expect(http.StatusOK).decode(&items)
+	if len(items) != 1 || items[0].ID != hat {
+		t.Fatalf("items %+v, want only the remaining item", items)
+	}
+
+	// Deleted items can no longer be ordered
+	ada := api.createUser("Ada", "ada@example.com")
+	api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidItem)
+}
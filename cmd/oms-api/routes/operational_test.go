@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/database"
+)
+
+func TestOperationalRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	api.get("/healthz").expect(http.StatusOK)
+
+	var ready struct{ Status string }
+	api.get("/readyz").expect(http.StatusOK).decode(&ready)
+	if ready.Status != "ready" {
+		t.Errorf("readyz status %q, want ready", ready.Status)
+	}
+
+	var version struct {
+		ExpectedSchemaVersion int `json:"expected_schema_version"`
+		SchemaVersion         int `json:"schema_version"`
+	}
+	api.get("/version").expect(http.StatusOK).decode(&version)
+	if version.ExpectedSchemaVersion != database.SchemaVersion || version.SchemaVersion != database.SchemaVersion {
+		t.Errorf("version reports schema %d of %d, want %d", version.SchemaVersion, version.ExpectedSchemaVersion, database.SchemaVersion)
+	}
+
+	metrics := api.get("/metrics").expect(http.StatusOK)
+	if !strings.Contains(metrics.Body.String(), "oms_orders_created_total") {
+		t.Error("metrics do not include the order counters")
+	}
+
+	api.app.Readiness.SetShuttingDown()
+	api.get("/readyz").expect(http.StatusServiceUnavailable)
+}
+
+func TestUnknownRoutesAreProblems(t *testing.T) {
+	api := newTestAPI(t)
+
+	api.get("/api/nope").expectProblem(http.StatusNotFound, apperrors.CodeRouteNotFound)
+	api.delete("/api/GetItems").expectProblem(http.StatusMethodNotAllowed, apperrors.CodeMethodNotAllowed)
+}
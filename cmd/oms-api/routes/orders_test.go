@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/service"
+)
+
+func TestCreateOrder(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	hat := api.addItem("Hat", 2.5)
+
+	order := api.createOrder(ada, line(shirt, 2), line(hat, 4))
+	if order.ID == 0 || order.UserID != ada || order.Status != models.OrderStatusPending {
+		t.Fatalf("created order %+v", order)
+	}
+	if order.TotalPrice != 30 || order.FinalPrice != 30 {
+		t.Fatalf("order priced %v/%v, want 30 without discounts", order.TotalPrice, order.FinalPrice)
+	}
+	if len(order.Items) != 2 || order.Items[0].Price != 10 || order.Items[1].Price != 2.5 {
+		t.Fatalf("order items %+v, want the catalog prices", order.Items)
+	}
+
+	body := func(items string) string { return `{"user_id":` + itoa(ada) + `,"items":` + items + `}` }
+	api.send(http.MethodPost, "/api/createOrder", body(`[{"item_id":999,"quantity":1}]`)).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidItem)
+	api.send(http.MethodPost, "/api/createOrder", body(`[{"item_id":`+itoa(shirt)+`,"quantity":0}]`)).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.send(http.MethodPost, "/api/createOrder", body(`[]`)).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,"status":"Confirm","items":[{"item_id":1,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.send(http.MethodPost, "/api/createOrder", `{"items":`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidInput)
+}
+
+func TestGetOrders(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	first := api.createOrder(ada, line(shirt, 1))
+	api.createOrder(ada, line(shirt, 3))
+
+	var list struct{ Orders []models.OrderResponse }
+	api.get("/api/getOrders").expect(http.StatusOK).decode(&list)
+	if len(list.Orders) != 2 {
+		t.Fatalf("listed %d orders, want 2", len(list.Orders))
+	}
+
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + itoa(first.ID)).expect(http.StatusOK).decode(&got)
+	if got.UserID != ada || got.FinalPrice != 10 || len(got.Items) != 1 || got.Items[0].ItemID != shirt {
+		t.Fatalf("got order %+v", got)
+	}
+	api.get("/api/getOrderByOrderId/999").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.get("/api/getOrderByOrderId/first").expectProblem(http.StatusBadRequest, apperrors.CodeInvalidID)
+}
+
+func TestUpdateOrder(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := api.createOrder(ada, line(shirt, 1))
+	path := "/api/updateOrderByOrderId/" + itoa(order.ID)
+
+	api.send(http.MethodPut, path, `{"status":"Confirm","items":[{"item_id":`+itoa(shirt)+`,"quantity":3}]}`).expect(http.StatusOK)
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + itoa(order.ID)).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusConfirmed || got.TotalPrice != 30 || got.FinalPrice != 30 || got.Items[0].Quantity != 3 {
+		t.Fatalf("order after update %+v", got)
+	}
+
+	api.send(http.MethodPut, path, `{"status":"Shipped","items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	// The confirmed order has been invoiced, its invoice would no longer match it
+	api.send(http.MethodPut, path, `{"status":"Pending","items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusConflict, apperrors.CodeOrderInvoiced)
+	pending := api.createOrder(ada, line(shirt, 1))
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+itoa(pending.ID), `{"items":[{"item_id":999,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidItem)
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/999", `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+}
+
+func TestPatchOrder(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	hat := api.addItem("Hat", 5)
+	order := api.createOrder(ada, line(shirt, 1))
+	path := "/api/updateOrderByOrderId/" + itoa(order.ID)
+
+	var patched struct{ Order models.Order }
+	api.patch(path, `{"items":[{"item_id":`+itoa(hat)+`,"quantity":2}]}`).expect(http.StatusOK).decode(&patched)
+	if len(patched.Order.Items) != 1 || patched.Order.Items[0].ItemID != hat || patched.Order.FinalPrice != 10 {
+		t.Fatalf("patched order %+v", patched.Order)
+	}
+
+	// Without items there is nothing to change
+	api.patch(path, `{}`).expect(http.StatusOK).decode(&patched)
+	if len(patched.Order.Items) != 1 || patched.Order.Items[0].ItemID != hat {
+		t.Fatalf("order after empty patch %+v", patched.Order)
+	}
+
+	api.patch(path, `{"items":[]}`).expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.patch(path, `{"status":"Confirm"}`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidPatch)
+	api.patch("/api/updateOrderByOrderId/999", `{}`).expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+itoa(order.ID), "").expect(http.StatusOK)
+	api.patch(path, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusConflict, apperrors.CodeOrderNotPending)
+}
+
+func TestConfirmOrder(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := api.createOrder(ada, line(shirt, 1))
+	path := "/api/updateOrderStatusByOrderId/" + itoa(order.ID)
+
+	api.send(http.MethodPut, path, "").expect(http.StatusOK)
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + itoa(order.ID)).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusConfirmed {
+		t.Fatalf("status %q after confirming", got.Status)
+	}
+
+	api.send(http.MethodPut, path, "").expectProblem(http.StatusConflict, apperrors.CodeOrderNotPending)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/999", "").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+}
+
+func TestDeleteOrderCancelsIt(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := api.createOrder(ada, line(shirt, 1))
+	kept := api.createOrder(ada, line(shirt, 2))
+	id := itoa(order.ID)
+
+	api.delete("/api/deleteOrderByOderId/" + id).expect(http.StatusOK)
+	api.delete("/api/deleteOrderByOderId/"+id).expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.delete("/api/deleteOrderByOderId/999").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+
+	api.get("/api/getOrderByOrderId/"+id).expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+id, "").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.patch("/api/updateOrderByOrderId/"+id, `{}`).expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+
+	var list struct{ Orders []models.OrderResponse }
+	api.get("/api/getOrders").expect(http.StatusOK).decode(&list)
+	if len(list.Orders) != 1 || list.Orders[0].ID != kept.ID {
+		t.Fatalf("listed %+v, want only the remaining order", list.Orders)
+	}
+
+	// The row is kept with the cancelled status
+	var cancelled models.Order
+	if err := api.db.Unscoped().First(&cancelled, order.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != models.OrderStatusCancelled || !cancelled.DeletedAt.Valid {
+		t.Fatalf("deleted order %+v, want a cancelled soft-deleted row", cancelled)
+	}
+}
+
+func TestQuoteOrderIncludesShipping(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	body := func(method string) string {
+		return `{"user_id":` + itoa(ada) + `,"shipping_method":"` + method + `","items":[{"item_id":` + itoa(shirt) + `,"quantity":2}]}`
+	}
+
+	var quoted struct{ Quote service.Quote }
+	api.send(http.MethodPost, "/api/quoteOrder", body("express")).expect(http.StatusOK).decode(&quoted)
+	if quoted.Quote.Shipping.Method != "express" || quoted.Quote.Shipping.Price != 9.99 || quoted.Quote.FinalPrice != 29.99 {
+		t.Fatalf("quote %+v, want 9.99 for express shipping on top of 20", quoted.Quote)
+	}
+	var list struct{ Orders []models.OrderResponse }
+	api.get("/api/getOrders").expect(http.StatusOK).decode(&list)
+	if len(list.Orders) != 0 {
+		t.Fatalf("quoting stored %d orders", len(list.Orders))
+	}
+
+	var created struct{ Order models.Order }
+	api.send(http.MethodPost, "/api/createOrder", body("express")).expect(http.StatusOK).decode(&created)
+	if created.Order.Shipping != quoted.Quote.Shipping || created.Order.FinalPrice != 29.99 {
+		t.Fatalf("created order %+v, want the quoted shipping", created.Order)
+	}
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + itoa(created.Order.ID)).expect(http.StatusOK).decode(&got)
+	if got.Shipping.Price != 9.99 {
+		t.Fatalf("got order %+v, want its shipping line", got)
+	}
+
+	// Standard shipping is free with the built-in rates
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+itoa(created.Order.ID), `{"shipping_method":"standard","items":[{"item_id":`+itoa(shirt)+`,"quantity":2}]}`).
+		expect(http.StatusOK)
+	api.get("/api/getOrderByOrderId/" + itoa(created.Order.ID)).expect(http.StatusOK).decode(&got)
+	if got.Shipping.Method != "standard" || got.FinalPrice != 20 {
+		t.Fatalf("order after switching to standard shipping %+v", got)
+	}
+
+	problem := api.send(http.MethodPost, "/api/quoteOrder", body("drone")).expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "shipping_method" {
+		t.Errorf("field errors %+v, want shipping_method", problem.Errors)
+	}
+	api.send(http.MethodPost, "/api/quoteOrder", `{"user_id":`+itoa(ada)+`,"items":[{"item_id":999,"quantity":1}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidItem)
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestOrderIsPaidAfterCapture(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 19.99)
+	order := itoa(api.createOrder(ada, line(shirt, 2)).ID)
+
+	var started struct{ Payment models.Payment }
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa"}`).expect(http.StatusOK).decode(&started)
+	if started.Payment.Status != models.PaymentStatusAuthorized || started.Payment.Amount != 39.98 || started.Payment.Provider != "mock" {
+		t.Fatalf("started payment %+v", started.Payment)
+	}
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa"}`).
+		expectProblem(http.StatusConflict, apperrors.CodePaymentInProgress)
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+order, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":5}]}`).
+		expectProblem(http.StatusConflict, apperrors.CodePaymentInProgress)
+
+	// The payment is captured once the order is confirmed
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+order, "").expectProblem(http.StatusConflict, apperrors.CodeOrderNotConfirmed)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+	var captured struct{ Payment models.Payment }
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+order, "").expect(http.StatusOK).decode(&captured)
+	if captured.Payment.Status != models.PaymentStatusCaptured || captured.Payment.CapturedAmount != 39.98 {
+		t.Fatalf("captured payment %+v", captured.Payment)
+	}
+
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status %s, want Paid", got.Status)
+	}
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+order, "").expectProblem(http.StatusConflict, apperrors.CodeOrderPaid)
+	api.delete("/api/deleteOrderByOderId/"+order).expectProblem(http.StatusConflict, apperrors.CodeOrderPaid)
+
+	var list struct{ Payments []models.Payment }
+	api.get("/api/getPaymentsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if len(list.Payments) != 1 || len(list.Payments[0].Attempts) != 2 {
+		t.Fatalf("payments %+v, want one payment with two attempts", list.Payments)
+	}
+}
+
+func TestPaymentErrors(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := itoa(api.createOrder(ada, line(shirt, 1)).ID)
+
+	problem := api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "payment_method" {
+		t.Errorf("field errors %+v, want payment_method", problem.Errors)
+	}
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa","provider":"stripe"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeUnknownPaymentProvider)
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/999", `{"payment_method":"tok_visa"}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.get("/api/getPaymentsByOrderId/999").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_declined"}`).
+		expectProblem(http.StatusPaymentRequired, apperrors.CodePaymentDeclined)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+order, "").expectProblem(http.StatusConflict, apperrors.CodePaymentNotAuthorized)
+
+	var list struct{ Payments []models.Payment }
+	api.get("/api/getPaymentsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if len(list.Payments) != 1 || list.Payments[0].Status != models.PaymentStatusFailed || list.Payments[0].Attempts[0].ErrorCode != "card_declined" {
+		t.Fatalf("payments %+v, want the declined payment", list.Payments)
+	}
+}
+
+func TestCancellingAnOrderVoidsItsPayment(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := api.createOrder(ada, line(shirt, 1))
+
+	var started struct{ Payment models.Payment }
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+itoa(order.ID), `{"payment_method":"tok_visa"}`).expect(http.StatusOK).decode(&started)
+	api.delete("/api/deleteOrderByOderId/" + itoa(order.ID)).expect(http.StatusOK)
+
+	payment, err := api.app.Store.Payments().Get(context.Background(), started.Payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != models.PaymentStatusVoided {
+		t.Fatalf("payment status %s after cancelling the order, want Voided", payment.Status)
+	}
+}
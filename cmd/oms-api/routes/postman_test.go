@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/postman"
+)
+
+var postmanReport = flag.String("postman.report", "", "write the report of the Postman collection replay to this file, as JSON when it ends in .json")
+
+// TestPostmanCollection replays the collection QA uses against the routes, so the
+// collection fails the build when it drifts away from the API:
+//
+//	go test ./cmd/oms-api/routes -run Postman -v -postman.report=postman-report.txt
+func TestPostmanCollection(t *testing.T) {
+	collection, err := postman.Load(filepath.Join("..", "..", "..", "documents", "OMS-GoLang.postman_collection.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newTestAPI(t)
+	runner := &postman.Runner{Handler: api.router}
+	report, err := runner.Run(context.Background(), collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + text.String())
+
+	if *postmanReport != "" {
+		data := []byte(text.String())
+		if strings.HasSuffix(*postmanReport, ".json") {
+			if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(*postmanReport, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if report.Failed() > 0 {
+		t.Fatalf("%d of %d collection requests failed", report.Failed(), len(report.Results))
+	}
+}
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+// paidOrder creates an order for ten shirts, which get a volume discount, and a pair of
+// shoes, and pays it. It returns the order ID and the item IDs.
+func (api *testAPI) paidOrder() (string, int, int) {
+	api.t.Helper()
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	shoes := api.addItem("Shoes", 50)
+	order := itoa(api.createOrder(ada, line(shirt, 10), line(shoes, 1)).ID)
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa"}`).expect(http.StatusOK)
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+	api.send(http.MethodPost, "/api/capturePaymentByOrderId/"+order, "").expect(http.StatusOK)
+	return order, shirt, shoes
+}
+
+func TestRefundingAnOrder(t *testing.T) {
+	api := newTestAPI(t)
+	order, shirt, shoes := api.paidOrder()
+
+	var partial struct{ Refund models.Refund }
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":2}],"reason":"Too small"}`).
+		expect(http.StatusOK).decode(&partial)
+	if partial.Refund.Amount != 18 || partial.Refund.Reason != "Too small" || len(partial.Refund.Lines) != 1 || partial.Refund.Lines[0].Discount != 2 {
+		t.Fatalf("refund of two shirts %+v, want 18 after their share of the volume discount", partial.Refund)
+	}
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusPartiallyRefunded {
+		t.Fatalf("order status %s, want PartiallyRefunded", got.Status)
+	}
+
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{"items":[{"item_id":`+itoa(shoes)+`,"quantity":2}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidRefundQuantity)
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{}`).expect(http.StatusOK)
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusRefunded {
+		t.Fatalf("order status %s, want Refunded", got.Status)
+	}
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{}`).expectProblem(http.StatusConflict, apperrors.CodeOrderNotPaid)
+
+	var list struct{ Refunds []models.Refund }
+	api.get("/api/getRefundsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if len(list.Refunds) != 2 || list.Refunds[0].Amount+list.Refunds[1].Amount != 140 {
+		t.Fatalf("refunds %+v, want two refunds of the 140 paid", list.Refunds)
+	}
+}
+
+func TestRefundErrors(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := itoa(api.createOrder(ada, line(shirt, 1)).ID)
+
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{}`).expectProblem(http.StatusConflict, apperrors.CodeOrderNotPaid)
+	api.send(http.MethodPost, "/api/refundOrderByOrderId/999", `{}`).expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.get("/api/getRefundsByOrderId/999").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	problem := api.send(http.MethodPost, "/api/refundOrderByOrderId/"+order, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":0}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "items[0].quantity" {
+		t.Errorf("field errors %+v, want items[0].quantity", problem.Errors)
+	}
+
+	var list struct{ Refunds []models.Refund }
+	api.get("/api/getRefundsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if list.Refunds == nil || len(list.Refunds) != 0 {
+		t.Fatalf("refunds %+v, want an empty list", list.Refunds)
+	}
+}
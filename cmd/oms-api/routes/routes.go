@@ -2,33 +2,86 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
 	"github.com/keyurKalariya/OMS/cmd/oms-api/handlers" // Import the handlers package
-	"gorm.io/gorm"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/metrics"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/utils"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB) {
+// SetupRoutes registers the handlers of app on r
+func SetupRoutes(r *gin.Engine, app *handlers.Application) {
+
+	// Unknown routes and methods are reported as problem details as well
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(apperrors.NoRoute)
+	r.NoMethod(apperrors.NoMethod)
+
+	// Request DTOs are validated with the JSON field names
+	utils.SetupValidator()
+
+	// Operational routes
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", app.Readyz)
+	r.GET("/version", app.Version)
 
 	// Users API routes
-	r.POST("/api/createUser", func(c *gin.Context) { handlers.AddUser(c, db) })
-	r.GET("/api/FetchAllUser", func(c *gin.Context) { handlers.FetchUsers(c, db) })
-	r.GET("/api/GetUserDetailByUserId/:id", func(c *gin.Context) { handlers.GetUserDetailByUserId(c, db) })
-	r.GET("/api/GetUserDetailsWithOrdersByUserId/:id", func(c *gin.Context) { handlers.GetUserDetailsWithOrdersByUserId(c, db) })
-	r.PUT("/api/UpdateUserDetails/:id", func(c *gin.Context) { handlers.UpdateUserDetails(c, db) })
-	r.DELETE("/api/DeleteUserByUserId/:id", func(c *gin.Context) { handlers.DeleteUserByUserId(c, db) })
+	r.POST("/api/createUser", app.AddUser)
+	r.GET("/api/FetchAllUser", app.FetchUsers)
+	r.GET("/api/GetUserDetailByUserId/:id", app.GetUserDetailByUserId)
+	r.GET("/api/GetUserDetailsWithOrdersByUserId/:id", app.GetUserDetailsWithOrdersByUserId)
+	r.PUT("/api/UpdateUserDetails/:id", app.UpdateUserDetails)
+	r.PATCH("/api/UpdateUserDetails/:id", app.PatchUserDetails)
+	r.DELETE("/api/DeleteUserByUserId/:id", app.DeleteUserByUserId)
+	r.POST("/api/SendVerificationEmail/:id", app.SendVerificationEmail)
+	r.GET("/api/VerifyEmail", app.VerifyEmail)
+
+	//address book API routes
+	r.POST("/api/createAddressByUserId/:id", app.CreateAddressByUserId)
+	r.GET("/api/getAddressesByUserId/:id", app.GetAddressesByUserId)
+	r.PUT("/api/updateAddressByAddressId/:id", app.UpdateAddressByAddressId)
+	r.DELETE("/api/deleteAddressByAddressId/:id", app.DeleteAddressByAddressId)
 
 	//Items API routes
-	r.POST("/api/AddItem", func(c *gin.Context) { handlers.AddItem(c, db) })
-	r.GET("/api/GetItems", func(c *gin.Context) { handlers.GetItems(c, db) })
-	r.GET("/api/GetItemByItemId/:id", func(c *gin.Context) { handlers.GetItemByItemId(c, db) })
-	r.PUT("/api/UpdateItemByItemId/:id", func(c *gin.Context) { handlers.UpdateItemByItemId(c, db) })
-	r.DELETE("/api/DeleteItemByItemId/:id", func(c *gin.Context) { handlers.DeleteItemByItemId(c, db) })
+	r.POST("/api/AddItem", app.AddItem)
+	r.GET("/api/GetItems", app.GetItems)
+	r.GET("/api/GetItemByItemId/:id", app.GetItemByItemId)
+	r.PUT("/api/UpdateItemByItemId/:id", app.UpdateItemByItemId)
+	r.PATCH("/api/UpdateItemByItemId/:id", app.PatchItemByItemId)
+	r.DELETE("/api/DeleteItemByItemId/:id", app.DeleteItemByItemId)
 
 	//orders API routes
-	r.POST("/api/createOrder", func(c *gin.Context) { handlers.CreateOrder(c, db) })
-	r.GET("/api/getOrders", func(c *gin.Context) { handlers.GetOrders(c, db) })
-	r.GET("/api/getOrderByOrderId/:id", func(c *gin.Context) { handlers.GetOrderByOrderId(c, db) })
-	r.PUT("/api/updateOrderByOrderId/:id", func(c *gin.Context) { handlers.UpdateOrderByOrderId(c, db) })
-	r.PUT("/api/updateOrderStatusByOrderId/:id", func(c *gin.Context) { handlers.UpdateOrderStatusByOrderId(c, db) })
-	r.DELETE("/api/deleteOrderByOderId/:id", func(c *gin.Context) { handlers.DeleteOrderByOrderId(c, db) })
+	r.POST("/api/createOrder", app.CreateOrder)
+	r.POST("/api/quoteOrder", app.QuoteOrder)
+	r.GET("/api/getOrders", app.GetOrders)
+	r.GET("/api/getOrderByOrderId/:id", app.GetOrderByOrderId)
+	r.PUT("/api/updateOrderByOrderId/:id", app.UpdateOrderByOrderId)
+	r.PATCH("/api/updateOrderByOrderId/:id", app.PatchOrderByOrderId)
+	r.PUT("/api/updateOrderStatusByOrderId/:id", app.UpdateOrderStatusByOrderId)
+	r.DELETE("/api/deleteOrderByOderId/:id", app.DeleteOrderByOrderId)
+
+	//coupons API routes
+	r.POST("/api/createCoupon", app.CreateCoupon)
+	r.GET("/api/getCoupons", app.GetCoupons)
+	r.PUT("/api/updateCouponByCouponId/:id", app.UpdateCouponByCouponId)
+	r.DELETE("/api/deleteCouponByCouponId/:id", app.DeleteCouponByCouponId)
+
+	//payments API routes
+	r.POST("/api/startPaymentByOrderId/:id", app.StartPaymentByOrderId)
+	r.POST("/api/capturePaymentByOrderId/:id", app.CapturePaymentByOrderId)
+	r.GET("/api/getPaymentsByOrderId/:id", app.GetPaymentsByOrderId)
+	r.POST("/api/refundOrderByOrderId/:id", app.RefundOrderByOrderId)
+	r.GET("/api/getRefundsByOrderId/:id", app.GetRefundsByOrderId)
+
+	//shipments API routes
+	r.POST("/api/createShipmentByOrderId/:id", app.CreateShipmentByOrderId)
+	r.PUT("/api/deliverShipmentByShipmentId/:id", app.DeliverShipmentByShipmentId)
+	r.GET("/api/getShipmentsByOrderId/:id", app.GetShipmentsByOrderId)
+
+	// Invoices of confirmed orders as JSON, HTML or PDF, following the Accept header
+	r.GET("/orders/:id/invoice", app.GetOrderInvoice)
+
+	// Webhooks sent by payment providers, authenticated by their signature
+	r.POST("/webhooks/payments/:provider", app.ReceivePaymentWebhook)
 
 }
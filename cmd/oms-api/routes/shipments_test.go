@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestShippingAnOrder(t *testing.T) {
+	api := newTestAPI(t)
+	order, shirt, _ := api.paidOrder()
+
+	var first struct{ Shipment models.Shipment }
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0001","items":[{"item_id":`+itoa(shirt)+`,"quantity":4}]}`).
+		expect(http.StatusCreated).decode(&first)
+	if first.Shipment.Status != models.ShipmentStatusInTransit || len(first.Shipment.Lines) != 1 || first.Shipment.Lines[0].Quantity != 4 {
+		t.Fatalf("first shipment %+v, want four shirts in transit", first.Shipment)
+	}
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusPartiallyShipped {
+		t.Fatalf("order status %s, want PartiallyShipped", got.Status)
+	}
+	api.send(http.MethodPut, "/api/updateOrderByOrderId/"+order, `{"items":[{"item_id":`+itoa(shirt)+`,"quantity":1}]}`).
+		expectProblem(http.StatusConflict, apperrors.CodeOrderShipped)
+	api.delete("/api/deleteOrderByOderId/"+order).expectProblem(http.StatusConflict, apperrors.CodeOrderShipped)
+
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0001"}`).
+		expectProblem(http.StatusConflict, apperrors.CodeTrackingNumberTaken)
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0002","items":[{"item_id":`+itoa(shirt)+`,"quantity":7}]}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidShipmentQuantity)
+	var second struct{ Shipment models.Shipment }
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0002"}`).
+		expect(http.StatusCreated).decode(&second)
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0003"}`).
+		expectProblem(http.StatusConflict, apperrors.CodeOrderNotShippable)
+
+	api.send(http.MethodPut, "/api/deliverShipmentByShipmentId/"+itoa(first.Shipment.ID), "").expect(http.StatusOK)
+	api.send(http.MethodPut, "/api/deliverShipmentByShipmentId/"+itoa(first.Shipment.ID), "").
+		expectProblem(http.StatusConflict, apperrors.CodeShipmentDelivered)
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusShipped {
+		t.Fatalf("order status %s, want Shipped", got.Status)
+	}
+	api.send(http.MethodPut, "/api/deliverShipmentByShipmentId/"+itoa(second.Shipment.ID), "").expect(http.StatusOK)
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusDelivered {
+		t.Fatalf("order status %s, want Delivered", got.Status)
+	}
+
+	var list struct{ Shipments []models.Shipment }
+	api.get("/api/getShipmentsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if len(list.Shipments) != 2 || list.Shipments[1].DeliveredAt == nil || !list.Shipments[1].DeliveredAt.Equal(api.clock.Now()) {
+		t.Fatalf("shipments %+v, want two delivered shipments", list.Shipments)
+	}
+}
+
+func TestShipmentErrors(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := itoa(api.createOrder(ada, line(shirt, 1)).ID)
+
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":"DHL","tracking_number":"JD0001"}`).
+		expectProblem(http.StatusConflict, apperrors.CodeOrderNotShippable)
+	api.send(http.MethodPost, "/api/createShipmentByOrderId/999", `{"carrier":"DHL","tracking_number":"JD0001"}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	api.send(http.MethodPut, "/api/deliverShipmentByShipmentId/999", "").expectProblem(http.StatusNotFound, apperrors.CodeShipmentNotFound)
+	api.get("/api/getShipmentsByOrderId/999").expectProblem(http.StatusNotFound, apperrors.CodeOrderNotFound)
+	problem := api.send(http.MethodPost, "/api/createShipmentByOrderId/"+order, `{"carrier":" "}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "carrier" || problem.Errors[1].Field != "tracking_number" {
+		t.Errorf("field errors %+v, want carrier and tracking_number", problem.Errors)
+	}
+
+	var list struct{ Shipments []models.Shipment }
+	api.get("/api/getShipmentsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if list.Shipments == nil || len(list.Shipments) != 0 {
+		t.Fatalf("shipments %+v, want an empty list", list.Shipments)
+	}
+}
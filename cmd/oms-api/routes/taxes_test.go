@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+)
+
+func TestOrdersAreTaxedByShippingAddress(t *testing.T) {
+	api := newTestAPI(t)
+	taxes, err := tax.New(false, []tax.Jurisdiction{{Country: "GB", Rates: map[string]float64{tax.CategoryStandard: 0.2, tax.CategoryReduced: 0.05}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.app.Orders.Tax = taxes
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	shoes := api.addItem("Shoes", 50)
+
+	var patched struct{ Item models.Item }
+	api.patch("/api/UpdateItemByItemId/"+itoa(shirt), `{"tax_category":"reduced"}`).expect(http.StatusOK).decode(&patched)
+	if patched.Item.TaxCategory != tax.CategoryReduced {
+		t.Fatalf("patched item %+v, want the reduced category", patched.Item)
+	}
+	problem := api.patch("/api/UpdateItemByItemId/"+itoa(shirt), `{"tax_category":"luxury"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "tax_category" {
+		t.Errorf("field errors %+v, want tax_category", problem.Errors)
+	}
+
+	var created struct{ Order models.Order }
+	api.send(http.MethodPost, "/api/createOrder", `{"user_id":`+itoa(ada)+`,"items":[{"item_id":`+itoa(shirt)+`,"quantity":2},{"item_id":`+itoa(shoes)+`,"quantity":1}],"shipping_address":`+workAddress+`}`).
+		expect(http.StatusOK).decode(&created)
+	order := itoa(created.Order.ID)
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Totals != (models.OrderTotals{Subtotal: 70, Tax: 11, GrandTotal: 81}) || got.Items[0].Tax != 1 || got.Items[1].TaxRate != 0.2 {
+		t.Fatalf("order %+v, want 11 of tax on top of 70", got)
+	}
+
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+	var invoiced struct{ Invoice models.Invoice }
+	api.get("/orders/" + order + "/invoice").expect(http.StatusOK).decode(&invoiced)
+	if invoiced.Invoice.Tax != 11 || invoiced.Invoice.Total != 81 || invoiced.Invoice.Lines[0].TaxRate != 0.05 {
+		t.Fatalf("invoice %+v, want the tax of the order", invoiced.Invoice)
+	}
+
+	// Orders without an address are not taxed
+	if untaxed := api.createOrder(ada, line(shoes, 1)); untaxed.Tax != 0 || untaxed.FinalPrice != 50 {
+		t.Fatalf("order without an address %+v, want no tax", untaxed)
+	}
+}
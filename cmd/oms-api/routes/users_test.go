@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+type userBody struct {
+	User models.UserResponse
+}
+
+func TestCreateUser(t *testing.T) {
+	api := newTestAPI(t)
+
+	var created userBody
+	api.send(http.MethodPost, "/api/createUser", `{"name":"Ada","email":"Ada@Example.com"}`).expect(http.StatusOK).decode(&created)
+	if created.User.ID == 0 || created.User.Email != "ada@example.com" || created.User.EmailVerifiedAt != nil {
+		t.Fatalf("created user %+v, want a normalized unverified email", created.User)
+	}
+	if api.mail.count() != 1 {
+		t.Fatalf("%d messages sent, want the verification email", api.mail.count())
+	}
+
+	api.send(http.MethodPost, "/api/createUser", `{"name":"Imposter","email":"ADA@example.com"}`).
+		expectProblem(http.StatusConflict, apperrors.CodeEmailTaken)
+
+	problem := api.send(http.MethodPost, "/api/createUser", `{"name":" ","email":"not-an-email"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 2 {
+		t.Errorf("field errors %+v, want name and email", problem.Errors)
+	}
+	api.send(http.MethodPost, "/api/createUser", `{"name":`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidInput)
+	api.send(http.MethodPost, "/api/createUser", `{"name":1,"email":"x@example.com"}`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidInput)
+}
+
+func TestFetchAndGetUser(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	api.createUser("Grace", "grace@example.com")
+
+	var list struct{ Users []models.UserResponse }
+	api.get("/api/FetchAllUser").expect(http.StatusOK).decode(&list)
+	if len(list.Users) != 2 {
+		t.Fatalf("listed %d users, want 2", len(list.Users))
+	}
+
+	var got userBody
+	api.get("/api/GetUserDetailByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&got)
+	if got.User.Name != "Ada" {
+		t.Errorf("got user %+v", got.User)
+	}
+	api.get("/api/GetUserDetailByUserId/999").expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+	api.get("/api/GetUserDetailByUserId/ada").expectProblem(http.StatusBadRequest, apperrors.CodeInvalidID)
+}
+
+func TestUpdateUser(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	api.createUser("Grace", "grace@example.com")
+	path := "/api/UpdateUserDetails/" + itoa(ada)
+
+	// Keeping the email does not send another verification email
+	api.send(http.MethodPut, path, `{"name":"Ada Lovelace","email":"ada@example.com"}`).expect(http.StatusOK)
+	if api.mail.count() != 2 {
+		t.Fatalf("%d messages sent, want only the two from creating the users", api.mail.count())
+	}
+	api.send(http.MethodPut, path, `{"name":"Ada","email":"lovelace@example.com"}`).expect(http.StatusOK)
+	if api.mail.count() != 3 {
+		t.Fatalf("%d messages sent, want a verification email for the new address", api.mail.count())
+	}
+
+	api.send(http.MethodPut, path, `{"name":"Ada","email":"grace@example.com"}`).expectProblem(http.StatusConflict, apperrors.CodeEmailTaken)
+	api.send(http.MethodPut, path, `{"name":"Ada"}`).expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.send(http.MethodPut, "/api/UpdateUserDetails/999", `{"name":"Ada","email":"ada@example.com"}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+
+	var got userBody
+	api.get("/api/GetUserDetailByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&got)
+	if got.User.Name != "Ada" || got.User.Email != "lovelace@example.com" {
+		t.Errorf("user after updates %+v", got.User)
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	path := "/api/UpdateUserDetails/" + itoa(ada)
+
+	var patched userBody
+	api.patch(path, `{"name":"Ada Lovelace"}`).expect(http.StatusOK).decode(&patched)
+	if patched.User.Name != "Ada Lovelace" || patched.User.Email != "ada@example.com" {
+		t.Fatalf("patched user %+v, want only the name changed", patched.User)
+	}
+
+	api.send(http.MethodPatch, path, `{"name":"Ada"}`).expectProblem(http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType)
+	api.patch(path, `{"id":5}`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidPatch)
+	api.patch(path, `{"name":null}`).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidPatch)
+	api.patch(path, `{"email":"nope"}`).expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	api.patch("/api/UpdateUserDetails/999", `{"name":"Ada"}`).expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+}
+
+func TestDeleteUserIsSoft(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	grace := api.createUser("Grace", "grace@example.com")
+
+	api.delete("/api/DeleteUserByUserId/" + itoa(ada)).expect(http.StatusOK)
+	api.delete("/api/DeleteUserByUserId/"+itoa(ada)).expectProblem(http.StatusConflict, apperrors.CodeUserAlreadyDeleted)
+	api.delete("/api/DeleteUserByUserId/999").expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+
+	api.get("/api/GetUserDetailByUserId/"+itoa(ada)).expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+	api.get("/api/GetUserDetailsWithOrdersByUserId/"+itoa(ada)).expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+	api.send(http.MethodPut, "/api/UpdateUserDetails/"+itoa(ada), `{"name":"Ada","email":"ada@example.com"}`).
+		expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+
+	var list struct{ Users []models.UserResponse }
+	api.get("/api/FetchAllUser").expect(http.StatusOK).decode(&list)
+	if len(list.Users) != 1 || list.Users[0].ID != grace {
+		t.Fatalf("listed %+v, want only the remaining user", list.Users)
+	}
+
+	// The row is kept, its email address can be used by a new user
+	var count int64
+	if err := api.db.Unscoped().Model(&models.User{}).Where("id = ?", ada).Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("deleted user row count %d, %v, want the row kept", count, err)
+	}
+	api.createUser("Ada", "ada@example.com")
+}
+
+func TestUserWithOrders(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	kept := api.createOrder(ada, line(shirt, 2))
+	cancelled := api.createOrder(ada, line(shirt, 1))
+	api.delete("/api/deleteOrderByOderId/" + itoa(cancelled.ID)).expect(http.StatusOK)
+
+	var body struct{ User models.UserOrderResponse }
+	api.get("/api/GetUserDetailsWithOrdersByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&body)
+	orders := body.User.OrderResponse
+	if len(orders) != 1 || orders[0].ID != kept.ID {
+		t.Fatalf("user orders %+v, want only the order that was not cancelled", orders)
+	}
+	if len(orders[0].Items) != 1 || orders[0].Items[0].ItemID != shirt || orders[0].Items[0].Quantity != 2 {
+		t.Fatalf("order items %+v", orders[0].Items)
+	}
+	api.get("/api/GetUserDetailsWithOrdersByUserId/999").expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+}
+
+func TestEmailVerification(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+	token := api.mail.lastToken(t, "ada@example.com")
+
+	api.get("/api/VerifyEmail").expectProblem(http.StatusBadRequest, apperrors.CodeInvalidVerificationToken)
+	api.get("/api/VerifyEmail?token=forged").expectProblem(http.StatusBadRequest, apperrors.CodeInvalidVerificationToken)
+
+	api.get("/api/VerifyEmail?token=" + token).expect(http.StatusOK)
+	api.get("/api/VerifyEmail?token="+token).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidVerificationToken)
+
+	var got userBody
+	api.get("/api/GetUserDetailByUserId/" + itoa(ada)).expect(http.StatusOK).decode(&got)
+	if got.User.EmailVerifiedAt == nil {
+		t.Fatal("email not verified after redeeming the token")
+	}
+	api.send(http.MethodPost, "/api/SendVerificationEmail/"+itoa(ada), "").
+		expectProblem(http.StatusConflict, apperrors.CodeEmailAlreadyVerified)
+	api.send(http.MethodPost, "/api/SendVerificationEmail/999", "").expectProblem(http.StatusNotFound, apperrors.CodeUserNotFound)
+}
+
+func TestVerificationTokenRules(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.createUser("Ada", "ada@example.com")
+
+	// A token issued for a previous address does not verify the new one
+	oldToken := api.mail.lastToken(t, "ada@example.com")
+	api.patch("/api/UpdateUserDetails/"+itoa(ada), `{"email":"lovelace@example.com"}`).expect(http.StatusOK)
+	api.get("/api/VerifyEmail?token="+oldToken).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidVerificationToken)
+
+	// Tokens that outlived their TTL are rejected
+	api.app.EmailVerification.TokenTTL = -time.Minute
+	api.send(http.MethodPost, "/api/SendVerificationEmail/"+itoa(ada), "").expect(http.StatusAccepted)
+	expired := api.mail.lastToken(t, "lovelace@example.com")
+	api.get("/api/VerifyEmail?token="+expired).expectProblem(http.StatusGone, apperrors.CodeVerificationTokenExpired)
+}
+
+func TestUserRegion(t *testing.T) {
+	api := newTestAPI(t)
+
+	var created userBody
+	api.send(http.MethodPost, "/api/createUser", `{"name":"Ada","email":"ada@example.com","region":"in"}`).expect(http.StatusOK).decode(&created)
+	if created.User.Region != "in" {
+		t.Fatalf("created user %+v, want region in", created.User)
+	}
+	path := "/api/UpdateUserDetails/" + itoa(created.User.ID)
+
+	problem := api.send(http.MethodPost, "/api/createUser", `{"name":"Grace","email":"grace@example.com","region":"mars"}`).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "region" || problem.Errors[0].Message != "must be one of: in, us-east" {
+		t.Errorf("field errors %+v, want the known regions", problem.Errors)
+	}
+	api.patch(path, `{"region":"mars"}`).expectProblem(http.StatusUnprocessableEntity, apperrors.CodeValidationFailed)
+
+	var patched userBody
+	api.patch(path, `{"region":"us-east"}`).expect(http.StatusOK).decode(&patched)
+	if patched.User.Region != "us-east" || patched.User.Name != "Ada" {
+		t.Fatalf("patched user %+v, want only the region changed", patched.User)
+	}
+
+	// PUT replaces the user, without a region the business timezone applies
+	api.send(http.MethodPut, path, `{"name":"Ada","email":"ada@example.com"}`).expect(http.StatusOK)
+	var got userBody
+	api.get("/api/GetUserDetailByUserId/" + itoa(created.User.ID)).expect(http.StatusOK).decode(&got)
+	if got.User.Region != "" {
+		t.Fatalf("region after PUT without one %q, want empty", got.User.Region)
+	}
+}
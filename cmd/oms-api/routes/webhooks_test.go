@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+// deliver posts a webhook body with a signature header to the mock provider's endpoint
+func (api *testAPI) deliver(body []byte, signature string) response {
+	api.t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/payments/mock", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, signature)
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	return response{ResponseRecorder: w, t: api.t}
+}
+
+// deliverEvent signs event with the test secret at the time of the API clock and posts it
+func (api *testAPI) deliverEvent(event webhook.Event) response {
+	api.t.Helper()
+	body, signature, err := webhook.SignEvent(testWebhookSecret, event, api.clock.Now())
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	return api.deliver(body, signature)
+}
+
+// authorizedOrder creates an order and authorizes its payment, it returns the order ID and the provider reference
+func (api *testAPI) authorizedOrder() (string, string) {
+	api.t.Helper()
+	ada := api.createUser("Ada", "ada@example.com")
+	shirt := api.addItem("Shirt", 10)
+	order := itoa(api.createOrder(ada, line(shirt, 3)).ID)
+	var started struct{ Payment models.Payment }
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa"}`).expect(http.StatusOK).decode(&started)
+	return order, started.Payment.ProviderReference
+}
+
+func TestCapturedWebhookPaysTheOrder(t *testing.T) {
+	api := newTestAPI(t)
+	order, reference := api.authorizedOrder()
+	api.send(http.MethodPut, "/api/updateOrderStatusByOrderId/"+order, "").expect(http.StatusOK)
+
+	event := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: reference}}
+	var applied struct {
+		Payment   models.Payment
+		Duplicate bool
+	}
+	api.deliverEvent(event).expect(http.StatusOK).decode(&applied)
+	if applied.Duplicate || applied.Payment.Status != models.PaymentStatusCaptured || applied.Payment.CapturedAmount != 30 {
+		t.Fatalf("applied event %+v", applied)
+	}
+	var got models.OrderResposnse
+	api.get("/api/getOrderByOrderId/" + order).expect(http.StatusOK).decode(&got)
+	if got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status %s, want Paid", got.Status)
+	}
+
+	// The provider delivers the event again
+	api.clock.Advance(time.Minute)
+	api.deliverEvent(event).expect(http.StatusOK).decode(&applied)
+	if !applied.Duplicate {
+		t.Fatal("a redelivered event was not reported as duplicate")
+	}
+
+	refund := webhook.Event{ID: "evt_2", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: reference, Amount: 30, RefundReference: "re_1"}}
+	api.deliverEvent(refund).expect(http.StatusOK).decode(&applied)
+	if applied.Payment.Status != models.PaymentStatusRefunded || applied.Payment.RefundedAmount != 30 {
+		t.Fatalf("payment after the refund %+v", applied.Payment)
+	}
+	failed := webhook.Event{ID: "evt_3", Type: webhook.EventPaymentFailed, Data: webhook.EventData{ProviderReference: reference}}
+	api.deliverEvent(failed).expectProblem(http.StatusConflict, apperrors.CodeInvalidPaymentTransition)
+
+	var list struct{ Payments []models.Payment }
+	api.get("/api/getPaymentsByOrderId/" + order).expect(http.StatusOK).decode(&list)
+	if attempts := list.Payments[0].Attempts; len(attempts) != 3 || attempts[1].EventID != "evt_1" || attempts[2].ProviderReference != "re_1" {
+		t.Fatalf("attempts %+v, want the authorization, the capture and the refund", attempts)
+	}
+}
+
+func TestFailedWebhookFailsThePayment(t *testing.T) {
+	api := newTestAPI(t)
+	order, reference := api.authorizedOrder()
+
+	event := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentFailed, Data: webhook.EventData{ProviderReference: reference, ErrorCode: "expired_card", ErrorMessage: "The card has expired"}}
+	var applied struct{ Payment models.Payment }
+	api.deliverEvent(event).expect(http.StatusOK).decode(&applied)
+	if applied.Payment.Status != models.PaymentStatusFailed {
+		t.Fatalf("payment after the failed event %+v", applied.Payment)
+	}
+
+	// The order can be paid again
+	api.send(http.MethodPost, "/api/startPaymentByOrderId/"+order, `{"payment_method":"tok_visa"}`).expect(http.StatusOK)
+}
+
+func TestWebhooksAreAuthenticated(t *testing.T) {
+	api := newTestAPI(t)
+	_, reference := api.authorizedOrder()
+	event := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: reference}}
+	body, signature, err := webhook.SignEvent(testWebhookSecret, event, api.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.deliver(body, "").expectProblem(http.StatusUnauthorized, apperrors.CodeInvalidWebhookSignature)
+	_, forged, _ := webhook.SignEvent("whsec_guess", event, api.clock.Now())
+	api.deliver(body, forged).expectProblem(http.StatusUnauthorized, apperrors.CodeInvalidWebhookSignature)
+	tampered := strings.Replace(string(body), "evt_1", "evt_9", 1)
+	api.deliver([]byte(tampered), signature).expectProblem(http.StatusUnauthorized, apperrors.CodeInvalidWebhookSignature)
+
+	// A captured request replayed after the tolerance window is rejected
+	api.clock.Advance(webhook.DefaultTolerance + time.Second)
+	api.deliver(body, signature).expectProblem(http.StatusUnauthorized, apperrors.CodeInvalidWebhookSignature)
+
+	api.do(http.MethodPost, "/webhooks/payments/acme", "application/json", string(body)).
+		expectProblem(http.StatusNotFound, apperrors.CodeUnknownPaymentProvider)
+
+	var list struct{ Payments []models.Payment }
+	api.get("/api/getPaymentsByOrderId/1").expect(http.StatusOK).decode(&list)
+	if list.Payments[0].Status != models.PaymentStatusAuthorized {
+		t.Fatalf("payment status %s after rejected webhooks, want Authorized", list.Payments[0].Status)
+	}
+}
+
+func TestInvalidWebhookEvents(t *testing.T) {
+	api := newTestAPI(t)
+	_, reference := api.authorizedOrder()
+
+	api.deliverEvent(webhook.Event{ID: "evt_1", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: "mock_auth_999"}}).
+		expectProblem(http.StatusNotFound, apperrors.CodePaymentNotFound)
+	api.deliverEvent(webhook.Event{ID: "evt_2", Type: "charge.disputed", Data: webhook.EventData{ProviderReference: reference}}).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidWebhookEvent)
+	api.deliverEvent(webhook.Event{Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: reference}}).
+		expectProblem(http.StatusUnprocessableEntity, apperrors.CodeInvalidWebhookEvent)
+
+	body := []byte(`{"id":`)
+	api.deliver(body, webhook.Sign(testWebhookSecret, api.clock.Now(), body)).expectProblem(http.StatusBadRequest, apperrors.CodeInvalidInput)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/config"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+)
+
+// Server runs the HTTP server until SIGINT or SIGTERM and then shuts down gracefully:
+// readiness turns false, in-flight requests are drained up to ShutdownTimeout and the
+// registered shutdown hooks run, e.g. to stop background workers and close the database.
+type Server struct {
+	HTTP            *http.Server
+	Readiness       *health.Readiness
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration
+
+	hooks      []hook
+	cancelBase context.CancelFunc
+}
+
+type hook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// New creates a server for handler configured from cfg
+func New(handler http.Handler, cfg config.ServerConfig, readiness *health.Readiness) *Server {
+	// Requests derive their context from baseCtx, canceling it aborts the requests
+	// that are still running when the drain deadline has passed
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		},
+		Readiness:       readiness,
+		DrainDelay:      cfg.DrainDelay,
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		cancelBase:      cancelBase,
+	}
+}
+
+// OnShutdown registers fn to run after the HTTP server stopped. Hooks run in the
+// order they were registered, so dependencies of earlier hooks should be registered last.
+func (s *Server) OnShutdown(name string, fn func(context.Context) error) {
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// Run listens on the configured address and serves until shutdown
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return errors.Join(err, s.runHooks())
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done or the process receives SIGINT or SIGTERM,
+// then shuts down gracefully. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.HTTP.Serve(ln) }()
+	slog.Info("server is running", slog.String("addr", ln.Addr().String()))
+
+	select {
+	case err := <-serveErr:
+		s.cancelBase()
+		return errors.Join(err, s.runHooks())
+	case <-ctx.Done():
+	}
+	// A second signal terminates the process right away
+	stop()
+
+	slog.Info("shutting down", slog.Duration("drain_delay", s.DrainDelay), slog.Duration("timeout", s.ShutdownTimeout))
+	if s.Readiness != nil {
+		s.Readiness.SetShuttingDown()
+	}
+	time.Sleep(s.DrainDelay)
+
+	err := s.shutdownHTTP()
+	<-serveErr // http.ErrServerClosed once Serve has returned
+	return errors.Join(err, s.runHooks())
+}
+
+// shutdownHTTP stops accepting connections and waits for in-flight requests. Requests
+// still running at the deadline get their context canceled so their transactions roll back.
+func (s *Server) shutdownHTTP() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	err := s.HTTP.Shutdown(ctx)
+	s.cancelBase()
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("in-flight requests did not finish before the shutdown timeout and were canceled")
+		return errors.Join(err, s.HTTP.Close())
+	}
+	return err
+}
+
+func (s *Server) runHooks() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, h := range s.hooks {
+		if err := h.fn(ctx); err != nil {
+			slog.Error("shutdown hook failed", slog.String("hook", h.name), slog.Any("error", err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
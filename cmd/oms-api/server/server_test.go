@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/config"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/handlers"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/health"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQuery counts to a hundred million, which takes far longer than any timeout used here.
+// It is run with Exec because the SQLite driver interrupts statements on context
+// cancellation while Exec is running, but not while result rows are being stepped.
+const slowQuery = `WITH RECURSIVE cnt(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM cnt WHERE x < 100000000) SELECT COUNT(*) FROM cnt`
+
+type record struct {
+	ID   int
+	Name string
+}
+
+// openTestDB opens a file database so that it can be inspected after the server closed its pool
+func openTestDB(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+type testServer struct {
+	srv     *Server
+	url     string
+	started chan struct{}
+	done    chan error
+	hooks   []string
+	mu      sync.Mutex
+}
+
+// startServer serves a router whose POST /slow runs work inside a transaction, it
+// signals started once the transaction holds an uncommitted row
+func startServer(t *testing.T, db *gorm.DB, cfg config.ServerConfig, work func(tx *gorm.DB) error) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ts := &testServer{started: make(chan struct{}), done: make(chan error, 1)}
+
+	readiness := health.NewReadiness(db)
+	r := gin.New()
+	app := &handlers.Application{Readiness: readiness}
+	r.GET("/readyz", app.Readyz)
+	r.POST("/slow", func(c *gin.Context) {
+		err := db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&record{Name: "in-flight"}).Error; err != nil {
+				return err
+			}
+			close(ts.started)
+			return work(tx)
+		})
+		if err != nil {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	ts.srv = New(r, cfg, readiness)
+	for _, name := range []string{"workers", "database"} {
+		name := name
+		ts.srv.OnShutdown(name, func(context.Context) error {
+			ts.mu.Lock()
+			defer ts.mu.Unlock()
+			ts.hooks = append(ts.hooks, name)
+			if name == "database" {
+				sqlDB, err := db.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.Close()
+			}
+			return nil
+		})
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.url = "http://" + ln.Addr().String()
+	go func() { ts.done <- ts.srv.Serve(context.Background(), ln) }()
+	return ts
+}
+
+func countRecords(t *testing.T, path string) int64 {
+	t.Helper()
+	var n int64
+	if err := openTestDB(t, path).Model(&record{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSIGTERMDrainsInFlightRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drain.db")
+	db := openTestDB(t, path)
+	if err := db.AutoMigrate(&record{}); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	ts := startServer(t, db, config.ServerConfig{DrainDelay: 200 * time.Millisecond, ShutdownTimeout: 5 * time.Second}, func(tx *gorm.DB) error {
+		<-release
+		return nil
+	})
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(ts.url+"/slow", "application/json", nil)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-ts.started
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	// During the drain delay the listener is still open and readiness reports the shutdown
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(ts.url + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusServiceUnavailable {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("/readyz did not report the shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-ts.done:
+		t.Fatalf("server stopped before the in-flight request finished: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	close(release)
+
+	if got := <-status; got != http.StatusCreated {
+		t.Fatalf("in-flight request got status %d, want %d", got, http.StatusCreated)
+	}
+	if err := <-ts.done; err != nil {
+		t.Fatalf("Serve returned %v after a clean shutdown", err)
+	}
+	if _, err := http.Get(ts.url + "/readyz"); err == nil {
+		t.Fatal("server still accepts connections after shutdown")
+	}
+	if len(ts.hooks) != 2 || ts.hooks[0] != "workers" || ts.hooks[1] != "database" {
+		t.Fatalf("shutdown hooks ran as %v, want [workers database]", ts.hooks)
+	}
+	if n := countRecords(t, path); n != 1 {
+		t.Fatalf("%d records committed, want the one of the drained request", n)
+	}
+}
+
+func TestShutdownTimeoutRollsBackSlowRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeout.db")
+	db := openTestDB(t, path)
+	if err := db.AutoMigrate(&record{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := startServer(t, db, config.ServerConfig{ShutdownTimeout: 100 * time.Millisecond}, func(tx *gorm.DB) error {
+		return tx.Exec(slowQuery).Error
+	})
+	go func() {
+		if resp, err := http.Post(ts.url+"/slow", "application/json", nil); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-ts.started
+	start := time.Now()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	err := <-ts.done
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Serve returned %v, want the shutdown deadline to be reported", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("shutdown took %s, the slow request was not canceled", elapsed)
+	}
+	if n := countRecords(t, path); n != 0 {
+		t.Fatalf("%d records committed, the canceled transaction should have rolled back", n)
+	}
+}
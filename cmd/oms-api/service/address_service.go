@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/address"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// AddressInput is an entry of an address book
+type AddressInput struct {
+	Address         models.PostalAddress
+	DefaultShipping bool // Makes it the default shipping address of the user
+	DefaultBilling  bool // Makes it the default billing address of the user
+}
+
+// AddressChoice picks the address of an order, from the address book of the user by ID
+// or given inline. The default address of the user is used when neither is set.
+type AddressChoice struct {
+	ID      int
+	Address *models.PostalAddress
+}
+
+// AddressService keeps the address books of users. Addresses are normalized and their
+// postal codes must have the format of their country. A user has at most one default
+// shipping and one default billing address, the first address is both.
+type AddressService struct {
+	store repository.Store
+}
+
+// NewAddressService creates an address service on store
+func NewAddressService(store repository.Store) *AddressService {
+	return &AddressService{store: store}
+}
+
+// List returns the addresses of a user, oldest first
+func (s *AddressService) List(ctx context.Context, userID int) ([]models.Address, error) {
+	if err := checkUser(ctx, s.store, userID); err != nil {
+		return nil, err
+	}
+	return s.store.Addresses().ListByUser(ctx, userID)
+}
+
+// Create adds an address to the address book of a user
+func (s *AddressService) Create(ctx context.Context, userID int, in AddressInput) (models.Address, error) {
+	postal, err := checkAddress("", in.Address)
+	if err != nil {
+		return models.Address{}, err
+	}
+	stored := models.Address{UserID: userID, PostalAddress: postal, DefaultShipping: in.DefaultShipping, DefaultBilling: in.DefaultBilling}
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := checkUser(ctx, tx, userID); err != nil {
+			return err
+		}
+		existing, err := tx.Addresses().ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			stored.DefaultShipping, stored.DefaultBilling = true, true
+		}
+		if err := tx.Addresses().Create(ctx, &stored); err != nil {
+			return err
+		}
+		return clearOtherDefaults(ctx, tx, existing, stored)
+	})
+	if err != nil {
+		return models.Address{}, err
+	}
+	return stored, nil
+}
+
+// Update replaces an address and its default flags
+func (s *AddressService) Update(ctx context.Context, id int, in AddressInput) (models.Address, error) {
+	postal, err := checkAddress("", in.Address)
+	if err != nil {
+		return models.Address{}, err
+	}
+	var stored models.Address
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		stored, err = getAddress(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		stored.PostalAddress, stored.DefaultShipping, stored.DefaultBilling = postal, in.DefaultShipping, in.DefaultBilling
+		if err := tx.Addresses().Update(ctx, &stored); err != nil {
+			return err
+		}
+		existing, err := tx.Addresses().ListByUser(ctx, stored.UserID)
+		if err != nil {
+			return err
+		}
+		return clearOtherDefaults(ctx, tx, existing, stored)
+	})
+	if err != nil {
+		return models.Address{}, err
+	}
+	return stored, nil
+}
+
+// Delete removes an address from its address book. Orders keep their copy of it.
+func (s *AddressService) Delete(ctx context.Context, id int) error {
+	err := s.store.Addresses().Delete(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrAlreadyDeleted) {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+// clearOtherDefaults takes the default flags set on address off the other addresses of its user
+func clearOtherDefaults(ctx context.Context, tx repository.Store, addresses []models.Address, address models.Address) error {
+	for _, other := range addresses {
+		if other.ID == address.ID {
+			continue
+		}
+		var columns []string
+		if address.DefaultShipping && other.DefaultShipping {
+			other.DefaultShipping = false
+			columns = append(columns, "default_shipping")
+		}
+		if address.DefaultBilling && other.DefaultBilling {
+			other.DefaultBilling = false
+			columns = append(columns, "default_billing")
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		if err := tx.Addresses().Update(ctx, &other, columns...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderAddresses returns the addresses an order of the user ships and is billed to. The
+// shipping address falls back to the default shipping address of the user, the billing
+// address to the default billing address and then to the shipping address. Both are
+// empty when the user has no addresses.
+func orderAddresses(ctx context.Context, store repository.Store, userID int, shipTo, billTo AddressChoice) (shipping, billing models.PostalAddress, err error) {
+	var book []models.Address
+	if shipTo.ID == 0 && shipTo.Address == nil || billTo.ID == 0 && billTo.Address == nil {
+		if book, err = store.Addresses().ListByUser(ctx, userID); err != nil {
+			return shipping, billing, err
+		}
+	}
+
+	shipping, ok, err := chooseAddress(ctx, store, userID, "shipping_address", shipTo)
+	if err != nil {
+		return shipping, billing, err
+	}
+	if !ok {
+		for _, a := range book {
+			if a.DefaultShipping {
+				shipping = a.PostalAddress
+			}
+		}
+	}
+
+	billing, ok, err = chooseAddress(ctx, store, userID, "billing_address", billTo)
+	if err != nil {
+		return shipping, billing, err
+	}
+	if !ok {
+		billing = shipping
+		for _, a := range book {
+			if a.DefaultBilling {
+				billing = a.PostalAddress
+			}
+		}
+	}
+	return shipping, billing, nil
+}
+
+// chooseAddress returns the address choice picked, ok is false when it picked none. field
+// is the JSON name of the inline address, the ID is field followed by _id.
+func chooseAddress(ctx context.Context, store repository.Store, userID int, field string, choice AddressChoice) (models.PostalAddress, bool, error) {
+	switch {
+	case choice.ID != 0 && choice.Address != nil:
+		return models.PostalAddress{}, false, &InvalidAddressError{Field: field + "_id", Rule: "excluded_with", Message: "must not be given together with " + field}
+	case choice.Address != nil:
+		postal, err := checkAddress(field+".", *choice.Address)
+		return postal, err == nil, err
+	case choice.ID != 0:
+		stored, err := store.Addresses().Get(ctx, choice.ID)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && stored.UserID != userID {
+			return models.PostalAddress{}, false, &InvalidAddressError{Field: field + "_id", Rule: "address", Message: "must be an address of the user"}
+		}
+		return stored.PostalAddress, err == nil, err
+	}
+	return models.PostalAddress{}, false, nil
+}
+
+// checkAddress normalizes a and checks the fields every address needs and the postal code
+// format of its country. prefix is put in front of the field names of the errors.
+func checkAddress(prefix string, a models.PostalAddress) (models.PostalAddress, error) {
+	a = address.Normalize(a)
+	required := []struct {
+		field, value string
+	}{
+		{"name", a.Name},
+		{"line1", a.Line1},
+		{"city", a.City},
+		{"country", a.Country},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return a, &InvalidAddressError{Field: prefix + r.field, Rule: "required", Message: "is required"}
+		}
+	}
+	if !address.Supported(a.Country) {
+		return a, &InvalidAddressError{Field: prefix + "country", Rule: "country", Message: "must be a supported ISO 3166-1 alpha-2 country code"}
+	}
+	if !address.ValidPostalCode(a.Country, a.PostalCode) {
+		return a, &InvalidAddressError{Field: prefix + "postal_code", Rule: "postal_code", Message: "must be a valid postal code of the country"}
+	}
+	return a, nil
+}
+
+func getAddress(ctx context.Context, store repository.Store, id int) (models.Address, error) {
+	stored, err := store.Addresses().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Address{}, ErrAddressNotFound
+	}
+	return stored, err
+}
+
+// checkUser returns ErrUserNotFound unless the user exists
+func checkUser(ctx context.Context, store repository.Store, userID int) error {
+	_, err := store.Users().Get(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
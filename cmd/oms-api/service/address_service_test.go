@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+)
+
+var (
+	home = models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "Springfield", PostalCode: "94105", Country: "US"}
+	work = models.PostalAddress{Name: "Ada", Line1: "2 Market St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"}
+)
+
+func TestAddressBookKeepsOneDefaultOfEachKind(t *testing.T) {
+	orders, store := newTestService(t)
+	ctx := context.Background()
+	user := models.User{Name: "Ada", Email: "ada@example.com"}
+	if err := store.Users().Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	addresses := NewAddressService(store)
+
+	first, err := addresses.Create(ctx, user.ID, AddressInput{Address: models.PostalAddress{Name: " Ada ", Line1: "1 Main St", City: "Springfield", PostalCode: " 94105", Country: "us"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PostalAddress != home || !first.DefaultShipping || !first.DefaultBilling {
+		t.Fatalf("first address = %+v, want the normalized default address", first)
+	}
+	second, err := addresses.Create(ctx, user.ID, AddressInput{Address: work, DefaultBilling: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := addresses.List(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].DefaultShipping || list[0].DefaultBilling || !list[1].DefaultBilling || list[1].DefaultShipping {
+		t.Fatalf("addresses = %+v, want home for shipping and work for billing", list)
+	}
+
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: user.ID, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ShippingAddress != home || order.BillingAddress != work {
+		t.Fatalf("order addresses = %+v and %+v, want the defaults", order.ShippingAddress, order.BillingAddress)
+	}
+
+	// Orders keep their copy when the address book changes
+	moved := work
+	moved.Line1 = "3 Strand"
+	if _, err := addresses.Update(ctx, second.ID, AddressInput{Address: moved, DefaultShipping: true, DefaultBilling: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addresses.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.ShippingAddress != home || got.BillingAddress != work {
+		t.Fatalf("order addresses after changing the address book = %+v and %+v", got.ShippingAddress, got.BillingAddress)
+	}
+	if list, _ := addresses.List(ctx, user.ID); len(list) != 1 || list[0].Line1 != "3 Strand" || !list[0].DefaultShipping {
+		t.Fatalf("addresses after the update = %+v", list)
+	}
+
+	if err := addresses.Delete(ctx, first.ID); !errors.Is(err, ErrAddressNotFound) {
+		t.Fatalf("deleting an address twice returned %v, want ErrAddressNotFound", err)
+	}
+	if _, err := addresses.List(ctx, 999); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("listing the addresses of a missing user returned %v, want ErrUserNotFound", err)
+	}
+	var invalid *InvalidAddressError
+	if _, err := addresses.Create(ctx, user.ID, AddressInput{Address: models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "Paris", PostalCode: "7500", Country: "FR"}}); !errors.As(err, &invalid) || invalid.Field != "postal_code" {
+		t.Fatalf("creating an address with a bad postal code returned %v, want InvalidAddressError", err)
+	}
+}
+
+func TestOrderAddressesAreChosenAndShippedByCountry(t *testing.T) {
+	orders, store := newTestService(t)
+	ctx := context.Background()
+	rates, err := shipping.New(0, []shipping.Zone{
+		{Name: "uk", Countries: []string{"GB"}, Rates: []shipping.Rate{{Method: shipping.MethodStandard, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 4}}}}},
+		{Name: "rest", Rates: []shipping.Rate{{Method: shipping.MethodStandard, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 15}}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders.Shipping = rates
+	ada := models.User{Name: "Ada", Email: "ada@example.com"}
+	bob := models.User{Name: "Bob", Email: "bob@example.com"}
+	for _, user := range []*models.User{&ada, &bob} {
+		if err := store.Users().Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addresses := NewAddressService(store)
+	bobs, err := addresses.Create(ctx, bob.ID, AddressInput{Address: work})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []OrderLine{{ItemID: 1, Quantity: 1}}
+
+	// An inline address is shipped to and billed when no billing address is given
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: ada.ID, Lines: lines, ShippingAddress: AddressChoice{Address: &work}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ShippingAddress != work || order.BillingAddress != work || order.Shipping.Zone != "uk" || order.FinalPrice != 14 {
+		t.Fatalf("order to London = %+v, want the uk rate", order)
+	}
+	quote, err := orders.Quote(ctx, CreateOrderInput{UserID: ada.ID, Lines: lines, ShippingAddress: AddressChoice{Address: &home}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Shipping.Zone != "rest" || quote.FinalPrice != 25 {
+		t.Fatalf("quote to Springfield = %+v, want the rate of the rest of the world", quote)
+	}
+
+	var invalid *InvalidAddressError
+	if _, err := orders.Create(ctx, CreateOrderInput{UserID: ada.ID, Lines: lines, ShippingAddress: AddressChoice{ID: bobs.ID}}); !errors.As(err, &invalid) || invalid.Field != "shipping_address_id" {
+		t.Fatalf("shipping to the address of another user returned %v, want InvalidAddressError", err)
+	}
+	bad := models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "Springfield", PostalCode: "SW1A 1AA", Country: "US"}
+	if _, err := orders.Create(ctx, CreateOrderInput{UserID: ada.ID, Lines: lines, BillingAddress: AddressChoice{Address: &bad}}); !errors.As(err, &invalid) || invalid.Field != "billing_address.postal_code" {
+		t.Fatalf("billing an invalid address returned %v, want InvalidAddressError", err)
+	}
+	if _, err := orders.Create(ctx, CreateOrderInput{UserID: bob.ID, Lines: lines, ShippingAddress: AddressChoice{ID: bobs.ID, Address: &home}}); !errors.As(err, &invalid) || invalid.Rule != "excluded_with" {
+		t.Fatalf("giving an address ID and an address returned %v, want InvalidAddressError", err)
+	}
+
+	// Without addresses the order has none and ships by the region of the customer
+	order, err = orders.Create(ctx, CreateOrderInput{UserID: ada.ID, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.ShippingAddress.IsZero() || !order.BillingAddress.IsZero() || order.Shipping.Zone != "rest" {
+		t.Fatalf("order without addresses = %+v", order)
+	}
+}
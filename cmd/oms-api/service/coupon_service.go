@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// CouponInput holds the terms of a coupon
+type CouponInput struct {
+	Code                  string // Ignored by Update, codes cannot be changed
+	Effect                string
+	Value                 float64
+	StartsAt              *time.Time
+	EndsAt                *time.Time
+	MinimumSpend          float64
+	EligibleItemIDs       []int
+	MaxRedemptions        int
+	MaxRedemptionsPerUser int
+}
+
+// CouponService manages the coupons customers can enter on their orders. Codes are
+// matched in any case and stored in upper case.
+type CouponService struct {
+	store repository.Store
+}
+
+// NewCouponService creates a coupon service on store
+func NewCouponService(store repository.Store) *CouponService {
+	return &CouponService{store: store}
+}
+
+// List returns the coupons, oldest first
+func (s *CouponService) List(ctx context.Context) ([]models.Coupon, error) {
+	return s.store.Coupons().List(ctx)
+}
+
+// Create stores a coupon
+func (s *CouponService) Create(ctx context.Context, in CouponInput) (models.Coupon, error) {
+	coupon := models.Coupon{Code: couponCode(in.Code)}
+	if err := setCouponTerms(ctx, s.store, &coupon, in); err != nil {
+		return models.Coupon{}, err
+	}
+	if err := s.store.Coupons().Create(ctx, &coupon); err != nil {
+		if errors.Is(err, repository.ErrDuplicateCouponCode) {
+			return models.Coupon{}, ErrCouponCodeTaken
+		}
+		return models.Coupon{}, err
+	}
+	return coupon, nil
+}
+
+// Update replaces the terms of a coupon for the orders that redeem it afterwards. Orders
+// that redeemed it before keep the terms they redeemed, also when they change.
+func (s *CouponService) Update(ctx context.Context, id int, in CouponInput) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		if coupon, err = getCoupon(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := setCouponTerms(ctx, tx, &coupon, in); err != nil {
+			return err
+		}
+		return tx.Coupons().Update(ctx, &coupon)
+	})
+	if err != nil {
+		return models.Coupon{}, err
+	}
+	return coupon, nil
+}
+
+// Delete ends a coupon, its code cannot be given to another coupon
+func (s *CouponService) Delete(ctx context.Context, id int) error {
+	err := s.store.Coupons().Delete(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrAlreadyDeleted) {
+		return ErrCouponNotFound
+	}
+	return err
+}
+
+// setCouponTerms checks the terms of in against the items of store and copies them to coupon
+func setCouponTerms(ctx context.Context, store repository.Store, coupon *models.Coupon, in CouponInput) error {
+	switch {
+	case in.Effect == models.CouponPercentage && (in.Value <= 0 || in.Value > 1):
+		return &InvalidCouponError{Field: "value", Rule: "lte", Message: "must be a rate above 0 and at most 1"}
+	case in.Effect == models.CouponFixed && in.Value <= 0:
+		return &InvalidCouponError{Field: "value", Rule: "gt", Message: "must be greater than 0"}
+	case in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt):
+		return &InvalidCouponError{Field: "ends_at", Rule: "gtfield", Message: "must be after starts_at"}
+	}
+	for _, itemID := range in.EligibleItemIDs {
+		if _, err := store.Items().Get(ctx, itemID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return &InvalidCouponError{Field: "eligible_item_ids", Rule: "exists", Message: "must only list existing items"}
+			}
+			return err
+		}
+	}
+
+	coupon.Effect = in.Effect
+	coupon.Value = in.Value
+	if in.Effect == models.CouponFreeShipping {
+		coupon.Value = 0
+	}
+	coupon.StartsAt, coupon.EndsAt = in.StartsAt, in.EndsAt
+	coupon.MinimumSpend = in.MinimumSpend
+	coupon.EligibleItemIDs = in.EligibleItemIDs
+	coupon.MaxRedemptions, coupon.MaxRedemptionsPerUser = in.MaxRedemptions, in.MaxRedemptionsPerUser
+	return nil
+}
+
+// couponCode normalizes a code entered by a customer
+func couponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func getCoupon(ctx context.Context, store repository.Store, id int) (models.Coupon, error) {
+	coupon, err := store.Coupons().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Coupon{}, ErrCouponNotFound
+	}
+	return coupon, err
+}
+
+// findCoupon returns the coupon with code, nil without a code. It must be valid at now
+// and have redemptions left for the user.
+func findCoupon(ctx context.Context, store repository.Store, code string, userID int, now time.Time) (*models.Coupon, error) {
+	if code == "" {
+		return nil, nil
+	}
+	coupon, err := store.Coupons().GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, &CouponNotApplicableError{Code: code, Reason: CouponUnknown}
+		}
+		return nil, err
+	}
+
+	switch {
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return nil, &CouponNotApplicableError{Code: code, Reason: CouponNotStarted}
+	case coupon.EndsAt != nil && !now.Before(*coupon.EndsAt):
+		return nil, &CouponNotApplicableError{Code: code, Reason: CouponExpired}
+	case coupon.MaxRedemptions > 0 && coupon.Redemptions >= coupon.MaxRedemptions:
+		return nil, &CouponNotApplicableError{Code: code, Reason: CouponExhausted}
+	}
+	if coupon.MaxRedemptionsPerUser > 0 {
+		used, err := store.Coupons().CountRedemptions(ctx, coupon.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(coupon.MaxRedemptionsPerUser) {
+			return nil, &CouponNotApplicableError{Code: code, Reason: CouponUserLimit}
+		}
+	}
+	return &coupon, nil
+}
+
+// redeemedCoupon returns the coupon an order has redeemed with the terms it had then, nil
+// when the order has none. It keeps applying to the order when it has expired, been used
+// up, changed or deleted since.
+func redeemedCoupon(ctx context.Context, store repository.Store, orderID int) (*models.Coupon, error) {
+	redemption, err := store.Coupons().GetRedemption(ctx, orderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	coupon := redemption.Terms()
+	return &coupon, nil
+}
+
+// redeemCoupon counts the coupon of an order, which must not have redeemed one yet
+func redeemCoupon(ctx context.Context, store repository.Store, coupon *models.Coupon, order models.Order) error {
+	err := store.Coupons().Redeem(ctx, &models.CouponRedemption{CouponID: coupon.ID, OrderID: order.ID, UserID: order.UserID})
+	switch {
+	case errors.Is(err, repository.ErrCouponExhausted):
+		return &CouponNotApplicableError{Code: coupon.Code, Reason: CouponExhausted}
+	case errors.Is(err, repository.ErrCouponUserLimit):
+		return &CouponNotApplicableError{Code: coupon.Code, Reason: CouponUserLimit}
+	case errors.Is(err, repository.ErrNotFound):
+		return &CouponNotApplicableError{Code: coupon.Code, Reason: CouponUnknown}
+	}
+	return err
+}
+
+// releaseCoupon gives back the coupon redeemed by an order, if it has one
+func releaseCoupon(ctx context.Context, store repository.Store, orderID int) error {
+	if err := store.Coupons().Release(ctx, orderID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+)
+
+// expectCouponError fails unless err reports that the coupon cannot be used for reason
+func expectCouponError(t *testing.T, err error, reason string) {
+	t.Helper()
+	var notApplicable *CouponNotApplicableError
+	if !errors.As(err, &notApplicable) || notApplicable.Reason != reason {
+		t.Fatalf("got %v, want the coupon to be rejected as %s", err, reason)
+	}
+}
+
+func TestCouponTerms(t *testing.T) {
+	_, store := newTestService(t)
+	coupons := NewCouponService(store)
+	ctx := context.Background()
+
+	coupon, err := coupons.Create(ctx, CouponInput{Code: " save10 ", Effect: models.CouponPercentage, Value: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coupon.Code != "SAVE10" {
+		t.Fatalf("code = %q, want it in upper case", coupon.Code)
+	}
+	if _, err := coupons.Create(ctx, CouponInput{Code: "Save10", Effect: models.CouponFixed, Value: 5}); !errors.Is(err, ErrCouponCodeTaken) {
+		t.Fatalf("creating a coupon with a taken code returned %v, want ErrCouponCodeTaken", err)
+	}
+
+	june := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	may := june.AddDate(0, -1, 0)
+	for field, in := range map[string]CouponInput{
+		"value":             {Code: "HALF", Effect: models.CouponPercentage, Value: 1.5},
+		"ends_at":           {Code: "HALF", Effect: models.CouponFixed, Value: 5, StartsAt: &june, EndsAt: &may},
+		"eligible_item_ids": {Code: "HALF", Effect: models.CouponFixed, Value: 5, EligibleItemIDs: []int{2, 9}},
+	} {
+		var invalid *InvalidCouponError
+		if _, err := coupons.Create(ctx, in); !errors.As(err, &invalid) || invalid.Field != field {
+			t.Errorf("creating %+v returned %v, want an invalid %s", in, err, field)
+		}
+	}
+
+	// Free shipping coupons have no value, the code is kept on update
+	if coupon, err = coupons.Update(ctx, coupon.ID, CouponInput{Code: "OTHER", Effect: models.CouponFreeShipping, Value: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if coupon.Code != "SAVE10" || coupon.Effect != models.CouponFreeShipping || coupon.Value != 0 {
+		t.Fatalf("updated coupon = %+v", coupon)
+	}
+	if err := coupons.Delete(ctx, coupon.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coupons.Create(ctx, CouponInput{Code: "SAVE10", Effect: models.CouponFixed, Value: 5}); !errors.Is(err, ErrCouponCodeTaken) {
+		t.Fatalf("reusing the code of a deleted coupon returned %v, want ErrCouponCodeTaken", err)
+	}
+	if _, err := coupons.Update(ctx, coupon.ID, CouponInput{Effect: models.CouponFixed, Value: 5}); !errors.Is(err, ErrCouponNotFound) {
+		t.Fatalf("updating a deleted coupon returned %v, want ErrCouponNotFound", err)
+	}
+	if err := coupons.Delete(ctx, coupon.ID); !errors.Is(err, ErrCouponNotFound) {
+		t.Fatalf("deleting a deleted coupon returned %v, want ErrCouponNotFound", err)
+	}
+}
+
+func TestCouponsComeOnTopOfTheAutomaticDiscounts(t *testing.T) {
+	svc, store := newTestService(t)
+	svc.Clock = clock.NewManual(time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC))
+	coupons := NewCouponService(store)
+	ctx := context.Background()
+	for _, in := range []CouponInput{
+		{Code: "SAVE10", Effect: models.CouponPercentage, Value: 0.1},
+		{Code: "SHOES10", Effect: models.CouponFixed, Value: 10, MinimumSpend: 60, EligibleItemIDs: []int{2}},
+		{Code: "SHIPFREE", Effect: models.CouponFreeShipping},
+	} {
+		if _, err := coupons.Create(ctx, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := []OrderLine{{ItemID: 1, Quantity: 2}, {ItemID: 2, Quantity: 1}}
+
+	// Ten percent of 70 is spread over both lines by their price
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines, CouponCode: "save10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.CouponCode != "SAVE10" || quote.Discounts.CouponDiscount != 7 || quote.Items[0].Discount != 2 || quote.Items[1].Discount != 5 || quote.FinalPrice != 63 {
+		t.Fatalf("quote with 10%% off = %+v", quote)
+	}
+
+	// Ten shirts get the volume discount first, the coupon takes ten percent of the rest
+	quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 10}}, CouponCode: "SAVE10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Discounts.CouponDiscount != 9 || quote.FinalPrice != 81 {
+		t.Fatalf("quote of ten shirts with 10%% off = %+v, want 9 off the 90 left after the volume discount", quote)
+	}
+
+	// A fixed coupon only discounts the eligible items and needs the minimum spend
+	if quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines, CouponCode: "SHOES10"}); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Items[0].Discount != 0 || quote.Items[1].Discount != 10 || quote.FinalPrice != 60 {
+		t.Fatalf("quote with 10 off the shoes = %+v", quote)
+	}
+	_, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 2}}, CouponCode: "SHOES10"})
+	expectCouponError(t, err, CouponNoEligibleItems)
+	_, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 2, Quantity: 1}}, CouponCode: "SHOES10"})
+	expectCouponError(t, err, CouponMinimumSpend)
+
+	// Free shipping waives the shipping charge
+	if quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines, ShippingMethod: shipping.MethodExpress, CouponCode: "SHIPFREE"}); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Shipping.Price != 0 || quote.Discounts.CouponDiscount != 9.99 || quote.FinalPrice != 70 {
+		t.Fatalf("quote with free express shipping = %+v", quote)
+	}
+
+	_, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines, CouponCode: "NOPE"})
+	expectCouponError(t, err, CouponUnknown)
+}
+
+func TestCouponRedemptionLimitsAndValidity(t *testing.T) {
+	svc, store := newTestService(t)
+	now := clock.NewManual(time.Date(2024, time.May, 20, 12, 0, 0, 0, time.UTC))
+	svc.Clock = now
+	coupons := NewCouponService(store)
+	ctx := context.Background()
+	starts := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	ends := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	coupon, err := coupons.Create(ctx, CouponInput{Code: "ONCE", Effect: models.CouponFixed, Value: 5, StartsAt: &starts, EndsAt: &ends, MaxRedemptions: 2, MaxRedemptionsPerUser: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []OrderLine{{ItemID: 1, Quantity: 2}}
+	create := func(userID int) (models.Order, error) {
+		return svc.Create(ctx, CreateOrderInput{UserID: userID, Lines: lines, CouponCode: "once"})
+	}
+	redemptions := func() int {
+		t.Helper()
+		got, err := store.Coupons().Get(ctx, coupon.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Redemptions
+	}
+
+	_, err = create(1)
+	expectCouponError(t, err, CouponNotStarted)
+
+	now.Set(starts.AddDate(0, 0, 9))
+	first, err := create(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CouponCode != "ONCE" || first.CouponDiscount != 5 || first.FinalPrice != 15 {
+		t.Fatalf("order with the coupon = %+v, want 5 off", first)
+	}
+	_, err = create(1)
+	expectCouponError(t, err, CouponUserLimit)
+	second, err := create(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = create(3)
+	expectCouponError(t, err, CouponExhausted)
+	if got := redemptions(); got != 2 {
+		t.Fatalf("redemptions = %d, want 2", got)
+	}
+
+	// Cancelling an order gives its coupon back
+	if err := svc.Cancel(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	third, err := create(3)
+	if err != nil {
+		t.Fatalf("coupon given back by a cancelled order: %v", err)
+	}
+
+	// Orders keep their coupon when edited after it expired, and may remove it
+	now.Set(ends)
+	_, err = create(4)
+	expectCouponError(t, err, CouponExpired)
+	if second, err = svc.ChangeItems(ctx, second.ID, []OrderLine{{ItemID: 2, Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if second.CouponCode != "ONCE" || second.FinalPrice != 45 {
+		t.Fatalf("order edited after the coupon expired = %+v, want it to keep 5 off", second)
+	}
+	none := ""
+	if third, err = svc.Change(ctx, third.ID, ChangeOrderInput{CouponCode: &none}); err != nil {
+		t.Fatal(err)
+	}
+	if third.CouponCode != "" || third.CouponDiscount != 0 || third.FinalPrice != 20 || redemptions() != 1 {
+		t.Fatalf("order without its coupon = %+v, redemptions = %d", third, redemptions())
+	}
+
+	// An expired coupon cannot be added back
+	code := "ONCE"
+	_, err = svc.Update(ctx, third.ID, UpdateOrderInput{Lines: lines, CouponCode: &code})
+	expectCouponError(t, err, CouponExpired)
+
+	// Orders keep the terms they redeemed when the coupon is changed or deleted
+	if _, err := coupons.Update(ctx, coupon.ID, CouponInput{Effect: models.CouponFixed, Value: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if second, err = svc.ChangeItems(ctx, second.ID, []OrderLine{{ItemID: 1, Quantity: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if second.CouponDiscount != 5 || second.FinalPrice != 25 {
+		t.Fatalf("order edited after the coupon changed = %+v, want it to keep 5 off", second)
+	}
+	if err := coupons.Delete(ctx, coupon.ID); err != nil {
+		t.Fatal(err)
+	}
+	if second, err = svc.ChangeItems(ctx, second.ID, []OrderLine{{ItemID: 1, Quantity: 1}}); err != nil {
+		t.Fatalf("editing an order after its coupon was deleted: %v", err)
+	}
+	if second.CouponCode != "ONCE" || second.FinalPrice != 5 {
+		t.Fatalf("order edited after the coupon was deleted = %+v, want it to keep 5 off", second)
+	}
+	_, err = svc.Update(ctx, third.ID, UpdateOrderInput{Lines: lines, CouponCode: &code})
+	expectCouponError(t, err, CouponUnknown)
+}
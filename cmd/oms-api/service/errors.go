@@ -0,0 +1,275 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrOrderNotFound is returned when an order does not exist or has been deleted
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderAlreadyDeleted is returned when cancelling an order that was deleted concurrently
+	ErrOrderAlreadyDeleted = errors.New("order already deleted")
+	// ErrEmptyOrder is returned when an order would have no items
+	ErrEmptyOrder = errors.New("order has no items")
+	// ErrOrderPaid is returned when changing or cancelling an order whose payment has been captured
+	ErrOrderPaid = errors.New("order has been paid")
+	// ErrPaymentInProgress is returned when an order has a payment that is pending, authorized or being captured
+	ErrPaymentInProgress = errors.New("order has a payment in progress")
+	// ErrNoAuthorizedPayment is returned when capturing an order without an authorized payment
+	ErrNoAuthorizedPayment = errors.New("order has no authorized payment")
+	// ErrPaymentNotFound is returned when a webhook event is about a payment that does not exist
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrDuplicateEvent is returned when a webhook event has been applied before
+	ErrDuplicateEvent = errors.New("webhook event already applied")
+	// ErrNothingToRefund is returned when everything that was paid for an order has been refunded
+	ErrNothingToRefund = errors.New("nothing left to refund")
+	// ErrOrderInvoiced is returned when updating an order that has been invoiced, its
+	// invoice cannot change anymore
+	ErrOrderInvoiced = errors.New("order has been invoiced")
+	// ErrOrderShipped is returned when changing or cancelling an order that has been shipped
+	ErrOrderShipped = errors.New("order has been shipped")
+	// ErrNothingToShip is returned when every unit of an order has been shipped
+	ErrNothingToShip = errors.New("nothing left to ship")
+	// ErrShipmentNotFound is returned when a shipment does not exist
+	ErrShipmentNotFound = errors.New("shipment not found")
+	// ErrShipmentDelivered is returned when marking a shipment as delivered twice
+	ErrShipmentDelivered = errors.New("shipment already delivered")
+	// ErrTrackingNumberTaken is returned when another shipment of the carrier has the tracking number
+	ErrTrackingNumberTaken = errors.New("tracking number already in use")
+	// ErrUserNotFound is returned when a user does not exist or has been deleted
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAddressNotFound is returned when an address does not exist or has been deleted
+	ErrAddressNotFound = errors.New("address not found")
+	// ErrCouponNotFound is returned when a coupon does not exist or has been deleted
+	ErrCouponNotFound = errors.New("coupon not found")
+	// ErrCouponCodeTaken is returned when another coupon, even a deleted one, has the code
+	ErrCouponCodeTaken = errors.New("coupon code already in use")
+)
+
+// InvalidItemError reports an order line for an item that does not exist or has been deleted
+type InvalidItemError struct {
+	ItemID int
+}
+
+func (e *InvalidItemError) Error() string {
+	return fmt.Sprintf("invalid item ID: %d", e.ItemID)
+}
+
+// InvalidQuantityError reports an order line with a quantity that is not positive
+type InvalidQuantityError struct {
+	ItemID   int
+	Quantity int
+}
+
+func (e *InvalidQuantityError) Error() string {
+	return fmt.Sprintf("invalid quantity %d for item %d", e.Quantity, e.ItemID)
+}
+
+// ShippingUnavailableError reports a shipping method the zone of the destination does not
+// offer, or an order beyond the last band of its rate
+type ShippingUnavailableError struct {
+	Method  string
+	Country string // Country of the shipping address, empty when the order has none
+	Region  string
+}
+
+func (e *ShippingUnavailableError) Error() string {
+	switch {
+	case e.Country != "":
+		return fmt.Sprintf("%s shipping is not available for this order to %s", e.Method, e.Country)
+	case e.Region != "":
+		return fmt.Sprintf("%s shipping is not available for this order to region %s", e.Method, e.Region)
+	}
+	return fmt.Sprintf("%s shipping is not available for this order", e.Method)
+}
+
+// InvalidAddressError reports an address that cannot be used, Field is the JSON path of
+// the offending field and Rule the check it failed
+type InvalidAddressError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *InvalidAddressError) Error() string {
+	return fmt.Sprintf("invalid address: %s %s", e.Field, e.Message)
+}
+
+// InvalidCouponError reports coupon terms that cannot be used, Field is the JSON name of
+// the offending field and Rule the check it failed
+type InvalidCouponError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *InvalidCouponError) Error() string {
+	return fmt.Sprintf("invalid coupon: %s %s", e.Field, e.Message)
+}
+
+// Reasons a coupon cannot be used on an order
+const (
+	CouponUnknown         = "unknown"
+	CouponNotStarted      = "not_started"
+	CouponExpired         = "expired"
+	CouponExhausted       = "exhausted"
+	CouponUserLimit       = "user_limit"
+	CouponMinimumSpend    = "minimum_spend"
+	CouponNoEligibleItems = "no_eligible_items"
+)
+
+// CouponNotApplicableError reports a coupon code that cannot be used on an order
+type CouponNotApplicableError struct {
+	Code         string
+	Reason       string  // One of the Coupon reasons
+	MinimumSpend float64 // Set when the order does not reach it
+}
+
+func (e *CouponNotApplicableError) Error() string {
+	switch e.Reason {
+	case CouponUnknown:
+		return fmt.Sprintf("coupon %s does not exist", e.Code)
+	case CouponNotStarted:
+		return fmt.Sprintf("coupon %s is not valid yet", e.Code)
+	case CouponExpired:
+		return fmt.Sprintf("coupon %s has expired", e.Code)
+	case CouponExhausted:
+		return fmt.Sprintf("coupon %s has been used up", e.Code)
+	case CouponUserLimit:
+		return fmt.Sprintf("coupon %s has been used as often as the customer may use it", e.Code)
+	case CouponMinimumSpend:
+		return fmt.Sprintf("coupon %s needs a minimum spend of %.2f", e.Code, e.MinimumSpend)
+	case CouponNoEligibleItems:
+		return fmt.Sprintf("coupon %s does not apply to any item of the order", e.Code)
+	}
+	return fmt.Sprintf("coupon %s cannot be used: %s", e.Code, e.Reason)
+}
+
+// NotPendingError reports a change to an order that is no longer pending
+type NotPendingError struct {
+	OrderID int
+	Status  string
+}
+
+func (e *NotPendingError) Error() string {
+	return fmt.Sprintf("order %d is not pending (current status: %s)", e.OrderID, e.Status)
+}
+
+// InvalidStatusError reports an order status that does not exist
+type InvalidStatusError struct {
+	Status string
+}
+
+func (e *InvalidStatusError) Error() string {
+	return fmt.Sprintf("invalid order status %q", e.Status)
+}
+
+// NotConfirmedError reports a capture for an order that has not been confirmed
+type NotConfirmedError struct {
+	OrderID int
+	Status  string
+}
+
+func (e *NotConfirmedError) Error() string {
+	return fmt.Sprintf("order %d is not confirmed (current status: %s)", e.OrderID, e.Status)
+}
+
+// NotPaidError reports a refund for an order that has not been paid
+type NotPaidError struct {
+	OrderID int
+	Status  string
+}
+
+func (e *NotPaidError) Error() string {
+	return fmt.Sprintf("order %d is not paid (current status: %s)", e.OrderID, e.Status)
+}
+
+// RefundQuantityError reports a refund of more units of an item than are left to refund
+type RefundQuantityError struct {
+	ItemID     int
+	Quantity   int
+	Refundable int // Units of the item that can still be refunded
+}
+
+func (e *RefundQuantityError) Error() string {
+	return fmt.Sprintf("cannot refund %d of item %d, %d can be refunded", e.Quantity, e.ItemID, e.Refundable)
+}
+
+// NotShippableError reports a shipment for an order that is not confirmed or paid
+type NotShippableError struct {
+	OrderID int
+	Status  string
+}
+
+func (e *NotShippableError) Error() string {
+	return fmt.Sprintf("order %d cannot be shipped (current status: %s)", e.OrderID, e.Status)
+}
+
+// ShipmentQuantityError reports a shipment of more units of an item than are left to ship
+type ShipmentQuantityError struct {
+	ItemID    int
+	Quantity  int
+	Shippable int // Units of the item that have not been shipped yet
+}
+
+func (e *ShipmentQuantityError) Error() string {
+	return fmt.Sprintf("cannot ship %d of item %d, %d are left to ship", e.Quantity, e.ItemID, e.Shippable)
+}
+
+// UnknownProviderError reports a payment provider that is not configured
+type UnknownProviderError struct {
+	Provider string
+}
+
+func (e *UnknownProviderError) Error() string {
+	return fmt.Sprintf("unknown payment provider %q", e.Provider)
+}
+
+// PaymentDeclinedError reports an operation the payment provider refused
+type PaymentDeclinedError struct {
+	PaymentID int
+	Operation string
+	Code      string // Decline code of the provider
+	Message   string
+}
+
+func (e *PaymentDeclinedError) Error() string {
+	return fmt.Sprintf("payment %d: %s declined: %s", e.PaymentID, e.Operation, e.Message)
+}
+
+// ProviderError reports a payment provider that could not be reached or failed
+type ProviderError struct {
+	Provider  string
+	Operation string
+	Err       error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("payment provider %s: %s: %v", e.Provider, e.Operation, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidEventError reports a webhook event that cannot be applied as sent
+type InvalidEventError struct {
+	EventID string
+	Reason  string
+}
+
+func (e *InvalidEventError) Error() string {
+	return fmt.Sprintf("invalid webhook event %q: %s", e.EventID, e.Reason)
+}
+
+// PaymentTransitionError reports an event that does not apply to the current status of a payment
+type PaymentTransitionError struct {
+	PaymentID int
+	Status    string
+	EventType string
+}
+
+func (e *PaymentTransitionError) Error() string {
+	return fmt.Sprintf("payment %d is %s, %s does not apply", e.PaymentID, e.Status, e.EventType)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// Invoice returns the invoice of a confirmed order. Orders confirmed before invoices
+// were issued get theirs on first request.
+func (s *OrderService) Invoice(ctx context.Context, orderID int) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := getOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusConfirmed && !isPaid(order.Status) && !isShipped(order.Status) {
+			return &NotConfirmedError{OrderID: orderID, Status: order.Status}
+		}
+		invoice, err = s.issueInvoice(ctx, tx, order)
+		return err
+	})
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	return invoice, nil
+}
+
+// issueInvoice copies order into the next invoice of the current year in the business
+// timezone. An order has a single invoice: when it is confirmed again after going back
+// to pending, it keeps the invoice it was first given.
+func (s *OrderService) issueInvoice(ctx context.Context, tx repository.Store, order models.Order) (models.Invoice, error) {
+	invoice, err := tx.Invoices().GetByOrder(ctx, order.ID)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return invoice, err
+	}
+
+	now := s.Clock.Now()
+	year := now.In(s.Calendar.Location("")).Year()
+	sequence, err := tx.Invoices().NextSequence(ctx, year)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	totals := order.Totals()
+	invoice = models.Invoice{
+		Number:           invoiceNumber(year, sequence),
+		Year:             year,
+		Sequence:         sequence,
+		OrderID:          order.ID,
+		UserID:           order.UserID,
+		Subtotal:         totals.Subtotal,
+		Discount:         totals.Discount,
+		Shipping:         totals.Shipping,
+		Tax:              totals.Tax,
+		PricesIncludeTax: order.PricesIncludeTax,
+		Total:            totals.GrandTotal,
+		IssuedAt:         now,
+		BillingAddress:   order.BillingAddress,
+	}
+
+	user, err := tx.Users().Get(ctx, order.UserID)
+	switch {
+	case err == nil:
+		invoice.CustomerName, invoice.CustomerEmail = user.Name, user.Email
+	case !errors.Is(err, repository.ErrNotFound):
+		return models.Invoice{}, err
+	}
+	for _, item := range order.Items {
+		line := models.InvoiceLine{
+			ItemID:      item.ItemID,
+			Description: fmt.Sprintf("Item %d", item.ItemID), // Items deleted since the order was placed have no name
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			Discount:    item.Discount,
+			Amount:      roundCents(item.Price*float64(item.Quantity) - item.Discount),
+			TaxRate:     item.TaxRate,
+			Tax:         item.Tax,
+		}
+		catalogItem, err := tx.Items().Get(ctx, item.ItemID)
+		switch {
+		case err == nil:
+			line.Description = catalogItem.Name
+		case !errors.Is(err, repository.ErrNotFound):
+			return models.Invoice{}, err
+		}
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	if err := tx.Invoices().Create(ctx, &invoice); err != nil {
+		return models.Invoice{}, err
+	}
+	return invoice, nil
+}
+
+// invoiceNumber formats the sequence-th invoice number of year
+func invoiceNumber(year, sequence int) string {
+	return fmt.Sprintf("INV-%d-%06d", year, sequence)
+}
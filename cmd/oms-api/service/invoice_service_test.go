@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestInvoiceNumbersStartOverEveryYear(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	now := clock.NewManual(time.Date(2024, time.December, 31, 23, 0, 0, 0, time.UTC))
+	svc.Clock = now
+
+	confirm := func() models.Invoice {
+		t.Helper()
+		order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.Confirm(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		}
+		invoice, err := svc.Invoice(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invoice
+	}
+
+	if invoice := confirm(); invoice.Number != "INV-2024-000001" {
+		t.Fatalf("first invoice number %s", invoice.Number)
+	}
+	if invoice := confirm(); invoice.Number != "INV-2024-000002" {
+		t.Fatalf("second invoice number %s", invoice.Number)
+	}
+	now.Advance(2 * time.Hour)
+	if invoice := confirm(); invoice.Number != "INV-2025-000001" || invoice.Sequence != 1 || invoice.Year != 2025 {
+		t.Fatalf("first invoice of 2025 = %+v", invoice)
+	}
+}
+
+func TestOrdersKeepTheirFirstInvoice(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notConfirmed *NotConfirmedError
+	if _, err := svc.Invoice(ctx, order.ID); !errors.As(err, &notConfirmed) {
+		t.Fatalf("invoice of a pending order returned %v, want NotConfirmedError", err)
+	}
+
+	confirmed := UpdateOrderInput{Status: models.OrderStatusConfirmed, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}}
+	if _, err := svc.Update(ctx, order.ID, confirmed); err != nil {
+		t.Fatal(err)
+	}
+	first, err := svc.Invoice(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The invoiced order can neither go back to pending nor be repriced
+	if _, err := svc.Update(ctx, order.ID, UpdateOrderInput{Status: models.OrderStatusPending, Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); !errors.Is(err, ErrOrderInvoiced) {
+		t.Fatalf("updating an invoiced order returned %v, want ErrOrderInvoiced", err)
+	}
+	if _, err := svc.Update(ctx, order.ID, UpdateOrderInput{Lines: []OrderLine{{ItemID: 1, Quantity: 4}}}); !errors.Is(err, ErrOrderInvoiced) {
+		t.Fatalf("repricing an invoiced order returned %v, want ErrOrderInvoiced", err)
+	}
+	if got, _ := svc.Get(ctx, order.ID); got.Status != models.OrderStatusConfirmed || got.FinalPrice != 10 {
+		t.Fatalf("invoiced order after the updates = %+v, want it unchanged", got)
+	}
+	again, err := svc.Invoice(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || again.Total != 10 || again.Lines[0].ItemID != 1 {
+		t.Fatalf("invoice after the updates = %+v, want the first invoice %+v", again, first)
+	}
+}
@@ -0,0 +1,587 @@
+// Package service holds the business logic of the API. Services take plain Go inputs,
+// run their own transactions and return the domain errors of this package, so they
+// can be used from the HTTP handlers as well as from command line tools and jobs.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/campaign"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/loyalty"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+)
+
+// OrderLine asks for a quantity of an item
+type OrderLine struct {
+	ItemID   int
+	Quantity int
+}
+
+// CreateOrderInput describes a new order
+type CreateOrderInput struct {
+	UserID          int
+	Lines           []OrderLine
+	ShippingMethod  string        // Standard shipping when empty
+	ShippingAddress AddressChoice // The default shipping address of the user when empty
+	BillingAddress  AddressChoice // The default billing address of the user, then the shipping address, when empty
+	CouponCode      string        // No coupon when empty
+}
+
+// UpdateOrderInput replaces the items of an order and optionally changes its status
+type UpdateOrderInput struct {
+	Status         string // Unchanged when empty
+	Lines          []OrderLine
+	ShippingMethod string  // Unchanged when empty
+	CouponCode     *string // Unchanged when nil, removed when empty
+}
+
+// ChangeOrderInput lists the changes to a pending order, nil fields are left unchanged
+type ChangeOrderInput struct {
+	Lines      []OrderLine
+	CouponCode *string // Removed when empty
+}
+
+// Quote is the price of a set of order lines for a user
+type Quote struct {
+	Items            []models.OrderItem  `json:"items"` // Priced with the current catalog prices, with their tax
+	TotalPrice       float64             `json:"total_price"`
+	Discounts        models.Discounts    `json:"discounts"`
+	CouponCode       string              `json:"coupon_code"` // Coupon applied on top of the automatic discounts, empty without one
+	Shipping         models.ShippingLine `json:"shipping"`
+	Tax              float64             `json:"tax"`
+	PricesIncludeTax bool                `json:"prices_include_tax"`
+	FinalPrice       float64             `json:"final_price"` // Grand total: the discounted items with shipping, and tax unless prices include it
+	Totals           models.OrderTotals  `json:"totals"`
+}
+
+// OrderService creates orders and moves them through their life cycle
+type OrderService struct {
+	// Clock decides whether a seasonal campaign is running and a coupon is valid
+	Clock clock.Clock
+	// Calendar holds the seasonal campaigns, they follow the region of the customer
+	Calendar *campaign.Calendar
+	// Shipping holds the shipping rates by zone and method
+	Shipping *shipping.Table
+	// Loyalty holds the loyalty tiers and the window the spend of customers is counted in
+	Loyalty *loyalty.Program
+	// Tax holds the tax rates by jurisdiction and whether catalog prices include tax
+	Tax *tax.Table
+	// Payments voids the authorized payments of cancelled orders, it may be nil when orders are not paid
+	Payments *PaymentService
+
+	store repository.Store
+}
+
+// NewOrderService creates an order service on store with the system clock, the
+// default campaigns in UTC, the default shipping rates and no tax
+func NewOrderService(store repository.Store) *OrderService {
+	calendar, err := campaign.New(time.UTC, nil, campaign.DefaultCampaigns)
+	if err != nil {
+		panic(err) // The default campaigns are valid
+	}
+	rates, err := shipping.New(0, shipping.DefaultZones)
+	if err != nil {
+		panic(err) // The default rates are valid
+	}
+	taxes, err := tax.New(false, nil)
+	if err != nil {
+		panic(err) // An empty tax table is valid
+	}
+	program, err := loyalty.New(loyalty.DefaultWindowDays, loyalty.DefaultTiers)
+	if err != nil {
+		panic(err) // The default tiers are valid
+	}
+	return &OrderService{Clock: clock.System, Calendar: calendar, Shipping: rates, Loyalty: program, Tax: taxes, store: store}
+}
+
+// Quote prices an order without storing anything, its coupon is checked but not redeemed
+func (s *OrderService) Quote(ctx context.Context, in CreateOrderInput) (Quote, error) {
+	shipTo, _, err := orderAddresses(ctx, s.store, in.UserID, in.ShippingAddress, in.BillingAddress)
+	if err != nil {
+		return Quote{}, err
+	}
+	coupon, err := findCoupon(ctx, s.store, couponCode(in.CouponCode), in.UserID, s.Clock.Now())
+	if err != nil {
+		return Quote{}, err
+	}
+	return s.quote(ctx, s.store, in.UserID, in.Lines, in.ShippingMethod, shipTo, coupon)
+}
+
+// Create prices the lines and stores a pending order with its items and a copy of its
+// shipping and billing addresses. Its coupon is redeemed in the same transaction.
+func (s *OrderService) Create(ctx context.Context, in CreateOrderInput) (models.Order, error) {
+	var order models.Order
+	var quote Quote
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		shipTo, billTo, err := orderAddresses(ctx, tx, in.UserID, in.ShippingAddress, in.BillingAddress)
+		if err != nil {
+			return err
+		}
+		coupon, err := findCoupon(ctx, tx, couponCode(in.CouponCode), in.UserID, s.Clock.Now())
+		if err != nil {
+			return err
+		}
+		quote, err = s.quote(ctx, tx, in.UserID, in.Lines, in.ShippingMethod, shipTo, coupon)
+		if err != nil {
+			return err
+		}
+		order = models.Order{
+			UserID:           in.UserID,
+			Status:           models.OrderStatusPending,
+			TotalPrice:       quote.TotalPrice,
+			FinalPrice:       quote.FinalPrice,
+			Shipping:         quote.Shipping,
+			Tax:              quote.Tax,
+			PricesIncludeTax: quote.PricesIncludeTax,
+			CouponCode:       quote.CouponCode,
+			CouponDiscount:   quote.Discounts.CouponDiscount,
+			ShippingAddress:  shipTo,
+			BillingAddress:   billTo,
+			Items:            quote.Items,
+		}
+		if err := tx.Orders().Create(ctx, &order); err != nil {
+			return err
+		}
+		if coupon == nil {
+			return nil
+		}
+		return redeemCoupon(ctx, tx, coupon, order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	recordOrderCreated(quote.TotalPrice, quote.Discounts)
+	return order, nil
+}
+
+// Get returns an order with its items
+func (s *OrderService) Get(ctx context.Context, id int) (models.Order, error) {
+	return getOrder(ctx, s.store, id)
+}
+
+// LoyaltyStatus returns the loyalty tier of a user, its benefits and their progress to the next tier
+func (s *OrderService) LoyaltyStatus(ctx context.Context, userID int) (loyalty.Status, error) {
+	return loyaltyStatus(ctx, s.store.Payments(), s.Loyalty, userID, s.Clock.Now())
+}
+
+// List returns all orders that are not deleted with their items
+func (s *OrderService) List(ctx context.Context) ([]models.Order, error) {
+	return s.store.Orders().List(ctx)
+}
+
+// Update replaces the items of an order, reprices it and changes its status, shipping
+// method and coupon when they are given.
+// Orders that are paid, shipped, invoiced or have a payment in progress cannot be
+// updated, invoiced orders can only be cancelled. Confirmed orders are invoiced.
+func (s *OrderService) Update(ctx context.Context, id int, in UpdateOrderInput) (models.Order, error) {
+	if err := validateStatus(in.Status); err != nil {
+		return models.Order{}, err
+	}
+
+	var order models.Order
+	statusChanged := false
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		order, err = getOrder(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if isShipped(order.Status) {
+			return ErrOrderShipped
+		}
+		if err := checkNoActivePayment(ctx, tx, order); err != nil {
+			return err
+		}
+		if err := checkNotInvoiced(ctx, tx, id); err != nil {
+			return err
+		}
+		columns := []string{"total_price", "final_price"}
+		statusChanged = in.Status != "" && in.Status != order.Status
+		if statusChanged {
+			order.Status = in.Status
+			columns = append(columns, "status")
+		}
+		if in.ShippingMethod != "" {
+			order.Shipping.Method = in.ShippingMethod
+		}
+		if err := s.reprice(ctx, tx, &order, in.Lines, in.CouponCode, columns...); err != nil {
+			return err
+		}
+		if statusChanged && order.Status == models.OrderStatusConfirmed {
+			_, err = s.issueInvoice(ctx, tx, order)
+		}
+		if statusChanged && order.Status == models.OrderStatusCancelled && order.CouponCode != "" {
+			err = releaseCoupon(ctx, tx, id)
+		}
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	if statusChanged {
+		recordOrderStatus(order.Status)
+	}
+	return order, nil
+}
+
+// ChangeItems replaces the items of a pending order without a payment in progress and
+// reprices it. The order is returned unchanged when lines is nil.
+func (s *OrderService) ChangeItems(ctx context.Context, id int, lines []OrderLine) (models.Order, error) {
+	return s.Change(ctx, id, ChangeOrderInput{Lines: lines})
+}
+
+// Change replaces the items or the coupon of a pending order without a payment in
+// progress and reprices it. The order is returned unchanged when nothing is given.
+func (s *OrderService) Change(ctx context.Context, id int, in ChangeOrderInput) (models.Order, error) {
+	var order models.Order
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		order, err = getPendingOrder(ctx, tx, id)
+		if err != nil || (in.Lines == nil && in.CouponCode == nil) {
+			return err
+		}
+		if err := checkNoActivePayment(ctx, tx, order); err != nil {
+			return err
+		}
+		lines := in.Lines
+		if lines == nil {
+			for _, item := range order.Items {
+				lines = append(lines, OrderLine{ItemID: item.ItemID, Quantity: item.Quantity})
+			}
+		}
+		return s.reprice(ctx, tx, &order, lines, in.CouponCode, "total_price", "final_price")
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+// Confirm moves a pending order to confirmed and invoices it. An order whose payment the
+// provider reported captured while it was pending is paid instead.
+func (s *OrderService) Confirm(ctx context.Context, id int) (models.Order, error) {
+	var order models.Order
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		order, err = getPendingOrder(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		order.Status = models.OrderStatusConfirmed
+		captured, err := captureReported(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if captured {
+			order.Status = models.OrderStatusPaid
+		}
+		if err := tx.Orders().Update(ctx, &order, "status"); err != nil {
+			return err
+		}
+		_, err = s.issueInvoice(ctx, tx, order)
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	recordOrderStatus(order.Status)
+	return order, nil
+}
+
+// Cancel voids the authorized payments of an order, sets its status to cancelled and
+// deletes it. Paid and shipped orders cannot be cancelled.
+func (s *OrderService) Cancel(ctx context.Context, id int) error {
+	// Shipped orders keep their payment
+	order, err := getOrder(ctx, s.store, id)
+	if err != nil {
+		return err
+	}
+	if isShipped(order.Status) {
+		return ErrOrderShipped
+	}
+	if s.Payments != nil {
+		if err := s.Payments.VoidAuthorized(ctx, id); err != nil {
+			return err
+		}
+	}
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := getOrder(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if isShipped(order.Status) {
+			return ErrOrderShipped
+		}
+		if err := checkNoActivePayment(ctx, tx, order); err != nil {
+			return err
+		}
+		order.Status = models.OrderStatusCancelled
+		if err := tx.Orders().Update(ctx, &order, "status"); err != nil {
+			return err
+		}
+		// Cancelled orders give their coupon back
+		if order.CouponCode != "" {
+			if err := releaseCoupon(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		if err := tx.Orders().Delete(ctx, id); err != nil {
+			if errors.Is(err, repository.ErrAlreadyDeleted) {
+				return ErrOrderAlreadyDeleted
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	recordOrderStatus(models.OrderStatusCancelled)
+	return nil
+}
+
+// quote prices lines shipped with method to destination with the items and orders of
+// store, with coupon on top of the automatic discounts when it is not nil
+func (s *OrderService) quote(ctx context.Context, store repository.Store, userID int, lines []OrderLine, method string, destination models.PostalAddress, coupon *models.Coupon) (Quote, error) {
+	if len(lines) == 0 {
+		return Quote{}, ErrEmptyOrder
+	}
+
+	var quote Quote
+	var weight float64
+	var categories []string
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return Quote{}, &InvalidQuantityError{ItemID: line.ItemID, Quantity: line.Quantity}
+		}
+		item, err := store.Items().Get(ctx, line.ItemID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return Quote{}, &InvalidItemError{ItemID: line.ItemID}
+			}
+			return Quote{}, err
+		}
+		quote.Items = append(quote.Items, models.OrderItem{ItemID: line.ItemID, Quantity: line.Quantity, Price: item.Price})
+		quote.TotalPrice += item.Price * float64(line.Quantity)
+		weight += item.Weight * float64(line.Quantity)
+		categories = append(categories, item.TaxCategory)
+	}
+
+	// The seasonal campaigns follow the region of the customer, unknown users get the business timezone
+	region := ""
+	user, err := store.Users().Get(ctx, userID)
+	switch {
+	case err == nil:
+		region = user.Region
+	case !errors.Is(err, repository.ErrNotFound):
+		return Quote{}, err
+	}
+
+	// Calculate discounts based on predefined conditions and the final price after applying them
+	season := seasonAt{calendar: s.Calendar, region: region, now: s.Clock.Now()}
+	var standing loyalty.Status
+	quote.Discounts, standing = calculateDiscounts(ctx, store.Payments(), s.Loyalty, season, userID, quote.Items)
+	itemsPrice := calculateTotalPrice(ctx, quote.Items, quote.Discounts)
+	allocateDiscounts(quote.Items, quote.Discounts, itemsPrice)
+	if coupon != nil {
+		amount, err := applyCoupon(coupon, quote.Items, itemsPrice)
+		if err != nil {
+			return Quote{}, err
+		}
+		quote.CouponCode = coupon.Code
+		quote.Discounts.CouponDiscount = amount
+		itemsPrice -= amount
+	}
+
+	// Shipping goes to the country of the shipping address, or the region of the customer,
+	// and is priced on the discounted items
+	if method == "" {
+		method = shipping.MethodStandard
+	}
+	charge, ok := s.Shipping.Price(method, shipping.Parcel{Country: destination.Country, Region: region, Weight: weight, Value: roundCents(itemsPrice)})
+	if !ok {
+		return Quote{}, &ShippingUnavailableError{Method: method, Country: destination.Country, Region: region}
+	}
+	if standing.FreeShipping && charge.Method == shipping.MethodStandard {
+		charge.Price = 0
+	}
+	if coupon != nil && coupon.Effect == models.CouponFreeShipping {
+		quote.Discounts.CouponDiscount = charge.Price
+		charge.Price = 0
+	}
+	quote.Shipping = models.ShippingLine{Method: charge.Method, Zone: charge.Zone, Price: charge.Price}
+
+	// Tax follows the shipping address, it is charged on the discounted lines and on shipping
+	quote.Tax = applyTax(s.Tax, destination, quote.Items, categories, &quote.Shipping)
+	quote.PricesIncludeTax = s.Tax.PricesIncludeTax()
+	quote.FinalPrice = roundCents(itemsPrice + charge.Price + addedTax(quote.Tax, quote.PricesIncludeTax))
+	quote.Totals = models.Order{
+		TotalPrice:       quote.TotalPrice,
+		FinalPrice:       quote.FinalPrice,
+		Shipping:         quote.Shipping,
+		Tax:              quote.Tax,
+		PricesIncludeTax: quote.PricesIncludeTax,
+	}.Totals()
+	return quote, nil
+}
+
+// reprice replaces the items of order with lines, shipped with the shipping method of the
+// order to its shipping address, and writes the new prices, the shipping line, the tax,
+// the coupon and the given columns. The order keeps the coupon it redeemed unless code
+// is given, which redeems another coupon or, when empty, none.
+func (s *OrderService) reprice(ctx context.Context, tx repository.Store, order *models.Order, lines []OrderLine, code *string, columns ...string) error {
+	current := order.CouponCode
+	if code != nil {
+		current = couponCode(*code)
+	}
+	changed := current != order.CouponCode
+	var coupon *models.Coupon
+	var err error
+	if changed {
+		coupon, err = findCoupon(ctx, tx, current, order.UserID, s.Clock.Now())
+	} else {
+		coupon, err = redeemedCoupon(ctx, tx, order.ID)
+	}
+	if err != nil {
+		return err
+	}
+	quote, err := s.quote(ctx, tx, order.UserID, lines, order.Shipping.Method, order.ShippingAddress, coupon)
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := releaseCoupon(ctx, tx, order.ID); err != nil {
+			return err
+		}
+		if coupon != nil {
+			if err := redeemCoupon(ctx, tx, coupon, *order); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Orders().ReplaceItems(ctx, order.ID, quote.Items); err != nil {
+		return err
+	}
+	order.Items = quote.Items
+	order.TotalPrice = quote.TotalPrice
+	order.FinalPrice = quote.FinalPrice
+	order.Shipping = quote.Shipping
+	order.Tax = quote.Tax
+	order.PricesIncludeTax = quote.PricesIncludeTax
+	order.CouponCode = quote.CouponCode
+	order.CouponDiscount = quote.Discounts.CouponDiscount
+	return tx.Orders().Update(ctx, order, append(columns, "shipping_method", "shipping_zone", "shipping_price", "shipping_tax", "tax", "prices_include_tax", "coupon_code", "coupon_discount")...)
+}
+
+func getOrder(ctx context.Context, store repository.Store, id int) (models.Order, error) {
+	order, err := store.Orders().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// checkNotInvoiced returns ErrOrderInvoiced when the order has an invoice, whose lines
+// and totals have to keep matching the order
+func checkNotInvoiced(ctx context.Context, store repository.Store, orderID int) error {
+	_, err := store.Invoices().GetByOrder(ctx, orderID)
+	switch {
+	case err == nil:
+		return ErrOrderInvoiced
+	case errors.Is(err, repository.ErrNotFound):
+		return nil
+	}
+	return err
+}
+
+// lockOrder returns the order and holds it until the transaction of store ends
+func lockOrder(ctx context.Context, store repository.Store, id int) (models.Order, error) {
+	order, err := store.Orders().Lock(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// getPendingOrder returns the order when it can still be changed
+func getPendingOrder(ctx context.Context, store repository.Store, id int) (models.Order, error) {
+	order, err := getOrder(ctx, store, id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if order.Status != models.OrderStatusPending {
+		return models.Order{}, &NotPendingError{OrderID: id, Status: order.Status}
+	}
+	return order, nil
+}
+
+func validateStatus(status string) error {
+	switch status {
+	case "", models.OrderStatusPending, models.OrderStatusConfirmed, models.OrderStatusCancelled:
+		return nil
+	}
+	return &InvalidStatusError{Status: status}
+}
+
+// lineUnits is a number of units of an order line
+type lineUnits struct {
+	item     models.OrderItem
+	quantity int
+}
+
+// shortage reports an item requested with more units than are left of it
+type shortage struct {
+	itemID   int
+	quantity int // Units requested
+	left     int // Units left on the order lines of the item
+}
+
+// takeUnits spreads the requested quantities of every item over the order lines with
+// that item, leaving out the units already used of each line, by order item ID. An
+// order may have an item on several lines, and a request may ask for it on several.
+// Without requested lines it takes every unit that is left.
+func takeUnits(items []models.OrderItem, used map[int]int, requested []OrderLine) ([]lineUnits, *shortage, error) {
+	var taken []lineUnits
+	if len(requested) == 0 {
+		for _, item := range items {
+			if left := item.Quantity - used[item.ID]; left > 0 {
+				taken = append(taken, lineUnits{item: item, quantity: left})
+			}
+		}
+		return taken, nil, nil
+	}
+
+	wanted := map[int]int{}
+	var itemIDs []int
+	for _, line := range requested {
+		if line.Quantity <= 0 {
+			return nil, nil, &InvalidQuantityError{ItemID: line.ItemID, Quantity: line.Quantity}
+		}
+		if _, ok := wanted[line.ItemID]; !ok {
+			itemIDs = append(itemIDs, line.ItemID)
+		}
+		wanted[line.ItemID] += line.Quantity
+	}
+	for _, itemID := range itemIDs {
+		want := wanted[itemID]
+		for _, item := range items {
+			left := item.Quantity - used[item.ID]
+			if item.ItemID != itemID || left <= 0 || want == 0 {
+				continue
+			}
+			quantity := min(left, want)
+			taken = append(taken, lineUnits{item: item, quantity: quantity})
+			want -= quantity
+		}
+		if want > 0 {
+			return nil, &shortage{itemID: itemID, quantity: wanted[itemID], left: wanted[itemID] - want}, nil
+		}
+	}
+	return taken, nil, nil
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/campaign"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/loyalty"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+)
+
+// newTestService returns a service on a memory store holding a shirt for 10 and shoes for 50
+func newTestService(t *testing.T) (*OrderService, repository.Store) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	for _, item := range []models.Item{
+		{Name: "Shirt", Description: "Cotton", Price: 10},
+		{Name: "Shoes", Description: "Leather", Price: 50},
+	} {
+		if err := store.Items().Create(context.Background(), &item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOrderService(store), store
+}
+
+func TestQuoteDoesNotStore(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 2}, {ItemID: 2, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.TotalPrice != 70 || len(quote.Items) != 2 || quote.Items[1].Price != 50 {
+		t.Fatalf("quote = %+v", quote)
+	}
+	if orders, _ := store.Orders().List(ctx); len(orders) != 0 {
+		t.Fatalf("quoting stored %d orders", len(orders))
+	}
+}
+
+func TestCreateRejectsInvalidLines(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+
+	var invalidItem *InvalidItemError
+	if _, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}, {ItemID: 9, Quantity: 1}}}); !errors.As(err, &invalidItem) || invalidItem.ItemID != 9 {
+		t.Fatalf("Create with a missing item returned %v, want InvalidItemError for item 9", err)
+	}
+	var invalidQuantity *InvalidQuantityError
+	if _, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 0}}}); !errors.As(err, &invalidQuantity) {
+		t.Fatalf("Create with a zero quantity returned %v, want InvalidQuantityError", err)
+	}
+	if _, err := svc.Create(ctx, CreateOrderInput{UserID: 1}); !errors.Is(err, ErrEmptyOrder) {
+		t.Fatalf("Create without lines returned %v, want ErrEmptyOrder", err)
+	}
+	if orders, _ := store.Orders().List(ctx); len(orders) != 0 {
+		t.Fatalf("%d orders stored for rejected input", len(orders))
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+
+	order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderStatusPending || order.TotalPrice != 10 {
+		t.Fatalf("created order = %+v", order)
+	}
+
+	order, err = svc.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 2, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TotalPrice != 100 {
+		t.Fatalf("total price after changing items = %v, want 100", order.TotalPrice)
+	}
+	unchanged, err := svc.ChangeItems(ctx, order.ID, nil)
+	if err != nil || unchanged.TotalPrice != 100 || len(unchanged.Items) != 1 {
+		t.Fatalf("ChangeItems without lines = %+v, %v, want the order unchanged", unchanged, err)
+	}
+
+	if _, err := svc.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	var notPending *NotPendingError
+	if _, err := svc.Confirm(ctx, order.ID); !errors.As(err, &notPending) || notPending.Status != models.OrderStatusConfirmed {
+		t.Fatalf("confirming twice returned %v, want NotPendingError", err)
+	}
+	if _, err := svc.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 1, Quantity: 1}}); !errors.As(err, &notPending) {
+		t.Fatalf("changing a confirmed order returned %v, want NotPendingError", err)
+	}
+
+	if err := svc.Cancel(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(ctx, order.ID); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("Get after cancelling returned %v, want ErrOrderNotFound", err)
+	}
+	if err := svc.Cancel(ctx, order.ID); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("cancelling twice returned %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestUpdateRepricesAndChangesStatus(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var invalidStatus *InvalidStatusError
+	if _, err := svc.Update(ctx, order.ID, UpdateOrderInput{Status: "Shipped", Lines: []OrderLine{{ItemID: 1, Quantity: 1}}}); !errors.As(err, &invalidStatus) {
+		t.Fatalf("Update with an unknown status returned %v, want InvalidStatusError", err)
+	}
+
+	updated, err := svc.Update(ctx, order.ID, UpdateOrderInput{Status: models.OrderStatusConfirmed, Lines: []OrderLine{{ItemID: 2, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Get(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.OrderStatusConfirmed || got.TotalPrice != 50 || got.FinalPrice != updated.FinalPrice || len(got.Items) != 1 {
+		t.Fatalf("order after update = %+v", got)
+	}
+
+	if _, err := svc.Update(ctx, 99, UpdateOrderInput{Lines: []OrderLine{{ItemID: 1, Quantity: 1}}}); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("updating a missing order returned %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestQuoteUsesClockAndCustomerRegion(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Calendar, err = campaign.New(time.UTC, map[string]*time.Location{"in": kolkata}, campaign.DefaultCampaigns); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Ada", Email: "ada@example.com", Region: "in"}
+	if err := store.Users().Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20:00 UTC on December 2nd is already December 3rd in India
+	svc.Clock = clock.NewManual(time.Date(2024, time.December, 2, 20, 0, 0, 0, time.UTC))
+	lines := []OrderLine{{ItemID: 1, Quantity: 1}}
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: user.ID, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Discounts.SeasonalDiscount != 0.15 {
+		t.Errorf("seasonal discount for a customer in India = %v, want 0.15", quote.Discounts.SeasonalDiscount)
+	}
+	if quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 99, Lines: lines}); err != nil || quote.Discounts.SeasonalDiscount != 0 {
+		t.Errorf("seasonal discount for an unknown customer = %v, %v, want none in UTC", quote.Discounts.SeasonalDiscount, err)
+	}
+}
+
+func TestShippingIsChargedOnTheDiscountedItems(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+	rates, err := shipping.New(100, []shipping.Zone{{Name: "domestic", Rates: []shipping.Rate{
+		{Method: shipping.MethodStandard, Basis: shipping.BasisWeight, Bands: []shipping.Band{{UpTo: 1, Price: 3}, {UpTo: 20, Price: 6}}},
+		{Method: shipping.MethodExpress, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 12}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Shipping = rates
+	for id, weight := range map[int]float64{1: 0.4, 2: 2} {
+		if err := store.Items().Update(ctx, &models.Item{ID: id, Weight: weight}, "weight"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Shipping != (models.ShippingLine{Method: shipping.MethodStandard, Zone: "domestic", Price: 3}) || quote.TotalPrice != 20 || quote.FinalPrice != 23 {
+		t.Fatalf("quote of two shirts = %+v, want 3 for standard shipping on top of 20", quote)
+	}
+
+	// Ten shirts and shoes are worth 140 after the volume discount, standard shipping is free
+	lines := []OrderLine{{ItemID: 1, Quantity: 10}, {ItemID: 2, Quantity: 1}}
+	order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Shipping.Price != 0 || order.FinalPrice != 140 {
+		t.Fatalf("order above the free shipping threshold = %+v, want free shipping", order)
+	}
+	if order, err = svc.Update(ctx, order.ID, UpdateOrderInput{Lines: lines, ShippingMethod: shipping.MethodExpress}); err != nil {
+		t.Fatal(err)
+	}
+	if order.Shipping.Method != shipping.MethodExpress || order.FinalPrice != 152 {
+		t.Fatalf("order after choosing express shipping = %+v, want 12 for shipping", order)
+	}
+	if order, err = svc.Update(ctx, order.ID, UpdateOrderInput{Lines: []OrderLine{{ItemID: 1, Quantity: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := svc.Get(ctx, order.ID); got.Shipping.Method != shipping.MethodExpress || got.FinalPrice != 22 {
+		t.Fatalf("order after changing its items = %+v, want it to keep express shipping", got)
+	}
+
+	var unavailable *ShippingUnavailableError
+	if _, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 2, Quantity: 11}}}); !errors.As(err, &unavailable) || unavailable.Method != shipping.MethodStandard {
+		t.Fatalf("quote of a parcel heavier than the last band returned %v, want ShippingUnavailableError", err)
+	}
+	if _, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: lines, ShippingMethod: shipping.MethodPickup}); !errors.As(err, &unavailable) {
+		t.Fatalf("creating an order with a method the zone does not offer returned %v, want ShippingUnavailableError", err)
+	}
+}
+
+func TestTaxIsChargedPerLineAtTheRatesOfTheShippingAddress(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+	jurisdictions := []tax.Jurisdiction{
+		{Country: "GB", Rates: map[string]float64{tax.CategoryStandard: 0.2, tax.CategoryReduced: 0.05}},
+		{Country: "US", Region: "CA", Rates: map[string]float64{tax.CategoryStandard: 0.0725}},
+	}
+	exclusive, err := tax.New(false, jurisdictions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Tax = exclusive
+	rates, err := shipping.New(0, []shipping.Zone{{Name: "everywhere", Rates: []shipping.Rate{
+		{Method: shipping.MethodStandard, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 12}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Shipping = rates
+	if err := store.Items().Update(ctx, &models.Item{ID: 1, TaxCategory: tax.CategoryReduced}, "tax_category"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shirts are taxed at the reduced rate, shoes and shipping at the standard rate, on top of the prices
+	london := models.PostalAddress{Name: "Ada", Line1: "2 Market St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"}
+	order, err := svc.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 2}, {ItemID: 2, Quantity: 1}}, ShippingAddress: AddressChoice{Address: &london}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Items[0].TaxRate != 0.05 || order.Items[0].Tax != 1 || order.Items[1].Tax != 10 || order.Shipping.Tax != 2.4 {
+		t.Fatalf("order lines %+v and shipping %+v, want 1 and 10 of tax on the lines and 2.40 on shipping", order.Items, order.Shipping)
+	}
+	if order.Tax != 13.4 || order.FinalPrice != 95.4 || order.Totals() != (models.OrderTotals{Subtotal: 70, Shipping: 12, Tax: 13.4, GrandTotal: 95.4}) {
+		t.Fatalf("order = %+v, want 13.40 of tax on top of 82", order)
+	}
+
+	// A state with its own rates is taxed at them
+	california := models.PostalAddress{Name: "Ada", Line1: "1 Main St", City: "Springfield", State: "CA", PostalCode: "94105", Country: "US"}
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 2, Quantity: 2}}, ShippingAddress: AddressChoice{Address: &california}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Tax != 8.12 || quote.FinalPrice != 120.12 || quote.Totals.GrandTotal != 120.12 {
+		t.Fatalf("quote to California = %+v, want 8.12 of tax", quote)
+	}
+
+	// When prices include tax the tax is the part of them it makes up
+	inclusive, err := tax.New(true, jurisdictions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Tax = inclusive
+	quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 2, Quantity: 1}}, ShippingAddress: AddressChoice{Address: &london}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !quote.PricesIncludeTax || quote.Items[0].Tax != 8.33 || quote.Shipping.Tax != 2 || quote.Tax != 10.33 || quote.FinalPrice != 62 {
+		t.Fatalf("quote with prices including tax = %+v, want 10.33 of the 62 to be tax", quote)
+	}
+
+	// Editing the order taxes it again
+	if order, err = svc.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 2, Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := svc.Get(ctx, order.ID); !got.PricesIncludeTax || got.Tax != 10.33 || got.FinalPrice != 62 {
+		t.Fatalf("order after changing its items = %+v", got)
+	}
+}
+
+func TestLoyaltyTiersFollowTheSpendInTheWindow(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+	program, err := loyalty.New(30, []loyalty.Tier{
+		{Name: "Silver", MinimumSpend: 100, DiscountRate: 0.05},
+		{Name: "Gold", MinimumSpend: 300, DiscountRate: 0.1, FreeShipping: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Loyalty = program
+	rates, err := shipping.New(0, []shipping.Zone{{Name: "everywhere", Rates: []shipping.Rate{
+		{Method: shipping.MethodStandard, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 5}}},
+		{Method: shipping.MethodExpress, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 12}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Shipping = rates
+	spent := func(status string, captured float64) {
+		t.Helper()
+		order := models.Order{UserID: 1, Status: status, TotalPrice: captured, FinalPrice: captured}
+		if err := store.Orders().Create(ctx, &order); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		payment := models.Payment{OrderID: order.ID, Provider: "mock", Amount: captured, CapturedAmount: captured, CapturedAt: &now, Status: models.PaymentStatusCaptured}
+		if err := store.Payments().Create(ctx, &payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spent(models.OrderStatusDelivered, 320)
+	spent(models.OrderStatusCancelled, 1000)
+
+	// Gold takes 10% off and ships standard for free, express is still charged
+	lines := []OrderLine{{ItemID: 2, Quantity: 1}}
+	quote, err := svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Discounts.LoyaltyTier != "Gold" || quote.Discounts.LoyaltyDiscount != 0.1 || quote.Shipping.Price != 0 || quote.FinalPrice != 45 {
+		t.Fatalf("quote for a Gold customer = %+v", quote)
+	}
+	if quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines, ShippingMethod: shipping.MethodExpress}); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Shipping.Price != 12 || quote.FinalPrice != 57 {
+		t.Fatalf("express quote for a Gold customer = %+v, want express charged", quote)
+	}
+	standing, err := svc.LoyaltyStatus(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if standing.Tier != "Gold" || standing.Spend != 320 || standing.NextTier != "" || standing.Progress != 1 {
+		t.Fatalf("standing = %+v, want Gold from the spend of the order that was not cancelled", standing)
+	}
+
+	// Spend older than the window does not count
+	svc.Clock = clock.NewManual(time.Now().AddDate(0, 0, 31))
+	if quote, err = svc.Quote(ctx, CreateOrderInput{UserID: 1, Lines: lines}); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Discounts.LoyaltyTier != "" || quote.FinalPrice != 55 {
+		t.Fatalf("quote once the spend left the window = %+v, want no loyalty benefits", quote)
+	}
+}
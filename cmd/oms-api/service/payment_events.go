@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+// ApplyEvent applies a verified webhook event of provider to the payment it is about.
+// The event is recorded, the payment and its order are changed in one transaction, so an
+// event is applied exactly once: a delivery that has been applied before returns
+// ErrDuplicateEvent and changes nothing. Events that repeat the current status of the
+// payment, such as the capture of a payment captured through the API or a refund made
+// through the API, are recorded without changing it. Captures move the order to paid
+// only when it is confirmed, like captures through the API, other orders keep their
+// status and a pending order is paid once it is confirmed. Refunds are recorded without
+// lines and move the order to its refund status, unless they report a refund made
+// through the API whose reference has not been stored yet, because the answer of the
+// provider is still being stored or was lost: the oldest pending refund of the payment
+// with the reported amount is completed then.
+func (s *PaymentService) ApplyEvent(ctx context.Context, provider string, event webhook.Event) (models.Payment, error) {
+	if err := validateEvent(event); err != nil {
+		return models.Payment{}, err
+	}
+
+	var p models.Payment
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		p, err = tx.Payments().GetByReference(ctx, provider, event.Data.ProviderReference)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Refunds made through the API hold the payment too, so both see each other's amounts
+		if p, err = tx.Payments().Lock(ctx, p.ID); err != nil {
+			return err
+		}
+		record := models.WebhookEvent{Provider: provider, EventID: event.ID, Type: event.Type, PaymentID: p.ID}
+		if err := tx.WebhookEvents().Create(ctx, &record); err != nil {
+			if errors.Is(err, repository.ErrDuplicateEvent) {
+				return ErrDuplicateEvent
+			}
+			return err
+		}
+
+		if event.Type == webhook.EventPaymentRefunded && event.Data.RefundReference != "" {
+			// Refunds made through the API are reported again by the provider
+			exists, err := tx.Refunds().ExistsByReference(ctx, p.ID, event.Data.RefundReference)
+			if err != nil || exists {
+				return err
+			}
+		}
+		if event.Type == webhook.EventPaymentRefunded {
+			amount := roundCents(event.Data.Amount)
+			pending, err := pendingRefund(ctx, tx, p, func(r models.Refund) bool { return amount == 0 || r.Amount == amount })
+			if err != nil {
+				return err
+			}
+			if pending.ID != 0 {
+				return completePendingRefund(ctx, tx, &p, &pending, event)
+			}
+		}
+
+		attempt, changed, err := applyEvent(&p, event, s.Clock.Now())
+		if err != nil || !changed {
+			return err
+		}
+		if err := tx.Payments().AddAttempt(ctx, &attempt); err != nil {
+			return err
+		}
+		p.Attempts = append(p.Attempts, attempt)
+		if event.Type == webhook.EventPaymentRefunded {
+			refund := models.Refund{OrderID: p.OrderID, PaymentID: p.ID, Status: models.RefundStatusSucceeded, Amount: attempt.Amount, ProviderReference: event.Data.RefundReference}
+			if err := recordRefund(ctx, tx, &p, &refund); err != nil {
+				return err
+			}
+			return completeRefund(ctx, tx, &p)
+		}
+		if err := tx.Payments().Update(ctx, &p, "status", "captured_amount", "captured_at"); err != nil {
+			return err
+		}
+		if event.Type != webhook.EventPaymentCaptured {
+			return nil
+		}
+
+		order, err := getOrder(ctx, tx, p.OrderID)
+		if err != nil || order.Status != models.OrderStatusConfirmed {
+			return err
+		}
+		order.Status = models.OrderStatusPaid
+		return tx.Orders().Update(ctx, &order, "status")
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+	return p, nil
+}
+
+// completePendingRefund completes the pending refund of payment p that event reports.
+// Its amount has been added to the payment when it was stored.
+func completePendingRefund(ctx context.Context, store repository.Store, p *models.Payment, refund *models.Refund, event webhook.Event) error {
+	attempt := models.PaymentAttempt{
+		PaymentID: p.ID, Operation: models.PaymentOperationRefund, Amount: refund.Amount, Succeeded: true,
+		ProviderReference: event.Data.RefundReference, EventID: event.ID,
+	}
+	if err := store.Payments().AddAttempt(ctx, &attempt); err != nil {
+		return err
+	}
+	p.Attempts = append(p.Attempts, attempt)
+	refund.Status, refund.ProviderReference = models.RefundStatusSucceeded, event.Data.RefundReference
+	if err := store.Refunds().Update(ctx, refund); err != nil {
+		return err
+	}
+	return completeRefund(ctx, store, p)
+}
+
+// captureReported reports whether a webhook reported the capture of a payment of an
+// order. The payments are locked, so a capture reported concurrently either is seen
+// here or is applied after the transaction ends.
+func captureReported(ctx context.Context, store repository.Store, orderID int) (bool, error) {
+	payments, err := store.Payments().ListByOrder(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range payments {
+		switch p.Status {
+		case models.PaymentStatusFailed, models.PaymentStatusVoided:
+			continue
+		}
+		if p, err = store.Payments().Lock(ctx, p.ID); err != nil {
+			return false, err
+		}
+		if p.Status == models.PaymentStatusCaptured {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// applyEvent moves payment p to the state reported by event, which arrived at now, and
+// returns the attempt recording it. Nothing changes when the payment already is in that
+// state.
+func applyEvent(p *models.Payment, event webhook.Event, now time.Time) (models.PaymentAttempt, bool, error) {
+	amount := roundCents(event.Data.Amount)
+	attempt := models.PaymentAttempt{PaymentID: p.ID, Amount: amount, Succeeded: true, EventID: event.ID}
+	transition := &PaymentTransitionError{PaymentID: p.ID, Status: p.Status, EventType: event.Type}
+
+	switch event.Type {
+	case webhook.EventPaymentCaptured:
+		switch p.Status {
+		case models.PaymentStatusCaptured, models.PaymentStatusRefunded:
+			return attempt, false, nil
+		case models.PaymentStatusPending, models.PaymentStatusAuthorized, models.PaymentStatusCapturing:
+		default:
+			return attempt, false, transition
+		}
+		if amount == 0 {
+			amount = p.Amount
+		}
+		if amount > p.Amount {
+			return attempt, false, &InvalidEventError{EventID: event.ID, Reason: "captured amount exceeds the payment"}
+		}
+		attempt.Operation, attempt.Amount = models.PaymentOperationCapture, amount
+		p.Status, p.CapturedAmount, p.CapturedAt = models.PaymentStatusCaptured, amount, &now
+
+	case webhook.EventPaymentFailed:
+		switch p.Status {
+		case models.PaymentStatusFailed:
+			return attempt, false, nil
+		case models.PaymentStatusPending:
+			attempt.Operation = models.PaymentOperationAuthorize
+		case models.PaymentStatusAuthorized, models.PaymentStatusCapturing:
+			attempt.Operation = models.PaymentOperationCapture
+		default:
+			return attempt, false, transition
+		}
+		attempt.Amount, attempt.Succeeded = p.Amount, false
+		attempt.ErrorCode, attempt.ErrorMessage = event.Data.ErrorCode, event.Data.ErrorMessage
+		p.Status = models.PaymentStatusFailed
+
+	case webhook.EventPaymentRefunded:
+		if p.Status != models.PaymentStatusCaptured {
+			return attempt, false, transition
+		}
+		if amount == 0 {
+			amount = roundCents(p.CapturedAmount - p.RefundedAmount)
+		}
+		if roundCents(p.RefundedAmount+amount) > p.CapturedAmount {
+			return attempt, false, &InvalidEventError{EventID: event.ID, Reason: "refunded amount exceeds the captured amount"}
+		}
+		// The refunded amount and status are changed when the refund is recorded
+		attempt.Operation, attempt.Amount = models.PaymentOperationRefund, amount
+		attempt.ProviderReference = event.Data.RefundReference
+	}
+	return attempt, true, nil
+}
+
+func validateEvent(event webhook.Event) error {
+	switch {
+	case event.ID == "":
+		return &InvalidEventError{Reason: "id is required"}
+	case event.Data.ProviderReference == "":
+		return &InvalidEventError{EventID: event.ID, Reason: "data.provider_reference is required"}
+	case event.Data.Amount < 0:
+		return &InvalidEventError{EventID: event.ID, Reason: "data.amount must not be negative"}
+	}
+	switch event.Type {
+	case webhook.EventPaymentCaptured, webhook.EventPaymentFailed, webhook.EventPaymentRefunded:
+		return nil
+	}
+	return &InvalidEventError{EventID: event.ID, Reason: "unsupported type " + event.Type}
+}
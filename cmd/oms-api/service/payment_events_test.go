@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+func TestCapturedEventPaysTheOrderOnce(t *testing.T) {
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: p.ProviderReference}}
+	captured, err := payments.ApplyEvent(ctx, "mock", event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured.Status != models.PaymentStatusCaptured || captured.CapturedAmount != 60 || captured.CapturedAt == nil {
+		t.Fatalf("payment after the captured event = %+v", captured)
+	}
+	// Like a capture through the API, it only pays confirmed orders
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPending {
+		t.Fatalf("order status = %s, want it to stay Pending", got.Status)
+	}
+	if confirmed, err := orders.Confirm(ctx, order.ID); err != nil || confirmed.Status != models.OrderStatusPaid {
+		t.Fatalf("confirming an order captured by the provider returned %+v, %v, want it Paid", confirmed, err)
+	}
+
+	if _, err := payments.ApplyEvent(ctx, "mock", event); !errors.Is(err, ErrDuplicateEvent) {
+		t.Fatalf("applying an event twice returned %v, want ErrDuplicateEvent", err)
+	}
+	// Another delivery of the same outcome is recorded without a new attempt
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_2", Type: webhook.EventPaymentCaptured, Data: event.Data}); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := payments.List(ctx, order.ID)
+	if len(list[0].Attempts) != 2 || list[0].Attempts[1].EventID != "evt_1" {
+		t.Fatalf("attempts = %+v, want the authorization and the capture of evt_1", list[0].Attempts)
+	}
+
+	var transition *PaymentTransitionError
+	failed := webhook.Event{ID: "evt_3", Type: webhook.EventPaymentFailed, Data: event.Data}
+	if _, err := payments.ApplyEvent(ctx, "mock", failed); !errors.As(err, &transition) {
+		t.Fatalf("failing a captured payment returned %v, want PaymentTransitionError", err)
+	}
+	// The rejected event was rolled back, a corrected delivery can still be applied
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_3", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: p.ProviderReference, Amount: 10}}); err != nil {
+		t.Fatalf("applying an event ID that was rejected before: %v", err)
+	}
+}
+
+func TestCapturedEventPaysConfirmedOrders(t *testing.T) {
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	event := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: p.ProviderReference}}
+	if _, err := payments.ApplyEvent(ctx, "mock", event); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %s, want Paid", got.Status)
+	}
+}
+
+func TestRefundedEvents(t *testing.T) {
+	_, payments, order := newTestPayments(t)
+	ctx := context.Background()
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := payments.store.Payments().ListByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := p[0].ProviderReference
+
+	var transition *PaymentTransitionError
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_1", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: reference}}); !errors.As(err, &transition) {
+		t.Fatalf("refunding an authorized payment returned %v, want PaymentTransitionError", err)
+	}
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_2", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: reference}}); err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_3", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: reference, Amount: 20, RefundReference: "re_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial.Status != models.PaymentStatusCaptured || partial.RefundedAmount != 20 {
+		t.Fatalf("payment after a partial refund = %+v", partial)
+	}
+	var invalid *InvalidEventError
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_4", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: reference, Amount: 50}}); !errors.As(err, &invalid) {
+		t.Fatalf("refunding more than was captured returned %v, want InvalidEventError", err)
+	}
+	full, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_5", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{ProviderReference: reference}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Status != models.PaymentStatusRefunded || full.RefundedAmount != 60 {
+		t.Fatalf("payment after refunding the rest = %+v", full)
+	}
+
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_6", Type: webhook.EventPaymentCaptured, Data: webhook.EventData{ProviderReference: "unknown"}}); !errors.Is(err, ErrPaymentNotFound) {
+		t.Fatalf("an event for an unknown payment returned %v, want ErrPaymentNotFound", err)
+	}
+	if _, err := payments.ApplyEvent(ctx, "mock", webhook.Event{ID: "evt_7", Type: "charge.disputed", Data: webhook.EventData{ProviderReference: reference}}); !errors.As(err, &invalid) {
+		t.Fatalf("an unsupported event returned %v, want InvalidEventError", err)
+	}
+}
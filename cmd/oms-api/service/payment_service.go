@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/metrics"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// StartPaymentInput asks for the final price of an order to be authorized
+type StartPaymentInput struct {
+	Provider string // The default provider when empty
+	Method   string // Payment method token issued by the provider
+}
+
+// PaymentService pays orders through payment providers. Providers are called outside
+// of transactions: a payment is stored as pending first, and every call to the provider
+// is recorded as an attempt together with the new payment status afterwards, even when
+// the request has ended meanwhile. When the provider could not be reached the outcome
+// is unknown: the payment stays pending or capturing and the provider is asked again
+// for it the next time the order is paid or captured, or a webhook reports the outcome.
+// Authorizations and captures are idempotent at the provider, so asking again never
+// charges twice.
+type PaymentService struct {
+	// Clock stamps the time payments are captured at
+	Clock clock.Clock
+
+	store           repository.Store
+	providers       map[string]payment.Provider
+	defaultProvider string
+}
+
+// NewPaymentService creates a payment service on store, the first provider is the default one
+func NewPaymentService(store repository.Store, providers ...payment.Provider) *PaymentService {
+	s := &PaymentService{Clock: clock.System, store: store, providers: map[string]payment.Provider{}}
+	for i, provider := range providers {
+		if i == 0 {
+			s.defaultProvider = provider.Name()
+		}
+		s.providers[provider.Name()] = provider
+	}
+	return s
+}
+
+// Provider returns the configured provider with name
+func (s *PaymentService) Provider(name string) (payment.Provider, bool) {
+	provider, ok := s.providers[name]
+	return provider, ok
+}
+
+// List returns the payments of an order with their attempts
+func (s *PaymentService) List(ctx context.Context, orderID int) ([]models.Payment, error) {
+	if _, err := getOrder(ctx, s.store, orderID); err != nil {
+		return nil, err
+	}
+	return s.store.Payments().ListByOrder(ctx, orderID)
+}
+
+// Start authorizes the final price of a pending or confirmed order. The order is held
+// while its pending payment is stored, so of concurrent starts only one gets to the
+// provider and the others return ErrPaymentInProgress. A pending payment whose
+// authorization has an unknown outcome is authorized again instead of starting another
+// one. The payment is returned together with a PaymentDeclinedError when the provider
+// declined it, and with a ProviderError when the outcome is unknown.
+func (s *PaymentService) Start(ctx context.Context, orderID int, in StartPaymentInput) (models.Payment, error) {
+	name := in.Provider
+	if name == "" {
+		name = s.defaultProvider
+	}
+	provider, ok := s.providers[name]
+	if !ok {
+		return models.Payment{}, &UnknownProviderError{Provider: in.Provider}
+	}
+
+	var p models.Payment
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := lockOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if p, err = unansweredPayment(ctx, tx, orderID, models.PaymentStatusPending); err != nil {
+			return err
+		}
+		if p.ID != 0 {
+			// The provider is asked again for the payment it did not answer
+			if provider, ok = s.providers[p.Provider]; !ok {
+				return &UnknownProviderError{Provider: p.Provider}
+			}
+			return nil
+		}
+		if err := checkNoActivePayment(ctx, tx, order); err != nil {
+			return err
+		}
+		p = models.Payment{OrderID: orderID, Provider: name, Amount: roundCents(order.FinalPrice), Status: models.PaymentStatusPending}
+		return tx.Payments().Create(ctx, &p)
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	reference, err := provider.Authorize(ctx, payment.AuthorizeRequest{PaymentID: p.ID, OrderID: orderID, Amount: p.Amount, Method: in.Method})
+	attempt := newAttempt(provider, &p, models.PaymentOperationAuthorize, p.Amount, reference, err)
+	var declined *payment.DeclinedError
+	switch {
+	case err == nil:
+		p.Status = models.PaymentStatusAuthorized
+		p.ProviderReference = reference
+	case errors.As(err, &declined):
+		p.Status = models.PaymentStatusFailed
+	}
+	// The answer of the provider is kept even when the request has ended meanwhile
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		current, err := tx.Payments().Lock(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Payments().AddAttempt(ctx, &attempt); err != nil {
+			return err
+		}
+		if current.Status != models.PaymentStatusPending {
+			// A concurrent start asked the provider again and stored its answer
+			p.Status, p.ProviderReference = current.Status, current.ProviderReference
+			return nil
+		}
+		return tx.Payments().Update(ctx, &p, "status", "provider_reference")
+	}); err != nil {
+		return models.Payment{}, err
+	}
+	p.Attempts = append(p.Attempts, attempt)
+	return p, attemptError(provider, &p, attempt, err)
+}
+
+// Capture collects the authorized payment of a confirmed order and marks the order as
+// paid. Orders shipped before they were paid keep their shipping status. The payment is
+// moved from authorized to capturing under a lock before the provider is asked, so of
+// concurrent captures only one gets to the provider and the others return
+// ErrPaymentInProgress. A declined capture fails the payment, the order can then be paid
+// again. A capture with an unknown outcome leaves the payment capturing, capturing the
+// order again asks the provider again.
+func (s *PaymentService) Capture(ctx context.Context, orderID int) (models.Payment, error) {
+	var p models.Payment
+	var provider payment.Provider
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := lockOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if isPaid(order.Status) {
+			return ErrOrderPaid
+		}
+		if order.Status != models.OrderStatusConfirmed && !isShipped(order.Status) {
+			return &NotConfirmedError{OrderID: orderID, Status: order.Status}
+		}
+		if p, err = authorizedPayment(ctx, tx, orderID); err != nil {
+			return err
+		}
+		var ok bool
+		if provider, ok = s.providers[p.Provider]; !ok {
+			return &UnknownProviderError{Provider: p.Provider}
+		}
+		p.Status = models.PaymentStatusCapturing
+		return tx.Payments().Update(ctx, &p, "status")
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	err = provider.Capture(ctx, p.ProviderReference, p.Amount)
+	attempt := newAttempt(provider, &p, models.PaymentOperationCapture, p.Amount, "", err)
+	var declined *payment.DeclinedError
+	switch {
+	case err == nil:
+		now := s.Clock.Now()
+		p.Status = models.PaymentStatusCaptured
+		p.CapturedAmount, p.CapturedAt = p.Amount, &now
+	case errors.As(err, &declined):
+		p.Status = models.PaymentStatusFailed
+	}
+	// The answer of the provider is kept even when the request has ended meanwhile
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		current, err := tx.Payments().Lock(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Payments().AddAttempt(ctx, &attempt); err != nil {
+			return err
+		}
+		if current.Status != models.PaymentStatusCapturing {
+			// A webhook reported the outcome while the provider was asked
+			p.Status, p.CapturedAmount, p.CapturedAt = current.Status, current.CapturedAmount, current.CapturedAt
+			return nil
+		}
+		if err := tx.Payments().Update(ctx, &p, "status", "captured_amount", "captured_at"); err != nil {
+			return err
+		}
+		if p.Status != models.PaymentStatusCaptured {
+			return nil
+		}
+		order, err := getOrder(ctx, tx, orderID)
+		if err != nil || isShipped(order.Status) {
+			return err
+		}
+		order.Status = models.OrderStatusPaid
+		return tx.Orders().Update(ctx, &order, "status")
+	}); err != nil {
+		return models.Payment{}, err
+	}
+	p.Attempts = append(p.Attempts, attempt)
+	return p, attemptError(provider, &p, attempt, err)
+}
+
+// VoidAuthorized releases the authorized payments of an order before it is cancelled
+func (s *PaymentService) VoidAuthorized(ctx context.Context, orderID int) error {
+	payments, err := s.store.Payments().ListByOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		if p.Status != models.PaymentStatusAuthorized {
+			continue
+		}
+		provider, ok := s.providers[p.Provider]
+		if !ok {
+			return &UnknownProviderError{Provider: p.Provider}
+		}
+
+		err := provider.Void(ctx, p.ProviderReference)
+		attempt := newAttempt(provider, &p, models.PaymentOperationVoid, p.Amount, "", err)
+		if err == nil {
+			p.Status = models.PaymentStatusVoided
+		}
+		if err := s.store.Transaction(ctx, func(tx repository.Store) error {
+			if err := tx.Payments().AddAttempt(ctx, &attempt); err != nil {
+				return err
+			}
+			return tx.Payments().Update(ctx, &p, "status")
+		}); err != nil {
+			return err
+		}
+		if err := attemptError(provider, &p, attempt, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newAttempt records the outcome of a provider call for payment p and counts it
+func newAttempt(provider payment.Provider, p *models.Payment, operation string, amount float64, reference string, err error) models.PaymentAttempt {
+	attempt := models.PaymentAttempt{PaymentID: p.ID, Operation: operation, Amount: amount, ProviderReference: reference}
+	var declined *payment.DeclinedError
+	outcome := metrics.PaymentSucceeded
+	switch {
+	case err == nil:
+		attempt.Succeeded = true
+	case errors.As(err, &declined):
+		attempt.ErrorCode, attempt.ErrorMessage = declined.Code, declined.Message
+		outcome = metrics.PaymentDeclined
+	default:
+		attempt.ErrorCode, attempt.ErrorMessage = providerErrorCode, err.Error()
+		outcome = metrics.PaymentError
+	}
+	metrics.PaymentOperations.WithLabelValues(provider.Name(), operation, outcome).Inc()
+	return attempt
+}
+
+// attemptError maps the error of a provider call to the errors of this package
+func attemptError(provider payment.Provider, p *models.Payment, attempt models.PaymentAttempt, err error) error {
+	var declined *payment.DeclinedError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &declined):
+		return &PaymentDeclinedError{PaymentID: p.ID, Operation: attempt.Operation, Code: declined.Code, Message: declined.Message}
+	default:
+		return &ProviderError{Provider: provider.Name(), Operation: attempt.Operation, Err: err}
+	}
+}
+
+// checkNoActivePayment reports whether the order can still be changed and paid: it is
+// not paid and none of its payments is pending or authorized
+func checkNoActivePayment(ctx context.Context, store repository.Store, order models.Order) error {
+	if isPaid(order.Status) {
+		return ErrOrderPaid
+	}
+	payments, err := store.Payments().ListByOrder(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		switch p.Status {
+		case models.PaymentStatusCaptured:
+			return ErrOrderPaid
+		case models.PaymentStatusPending, models.PaymentStatusAuthorized, models.PaymentStatusCapturing:
+			return ErrPaymentInProgress
+		}
+	}
+	return nil
+}
+
+// isPaid reports whether an order with status has been paid, refunds included
+func isPaid(status string) bool {
+	switch status {
+	case models.OrderStatusPaid, models.OrderStatusPartiallyRefunded, models.OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// providerErrorCode marks the attempts the provider did not answer, their outcome is unknown
+const providerErrorCode = "provider_error"
+
+// unansweredPayment returns the payment of an order with status whose last call to the
+// provider has an unknown outcome, a payment without ID when there is none
+func unansweredPayment(ctx context.Context, store repository.Store, orderID int, status string) (models.Payment, error) {
+	payments, err := store.Payments().ListByOrder(ctx, orderID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	for _, p := range payments {
+		if p.Status == status && outcomeUnknown(p) {
+			return p, nil
+		}
+	}
+	return models.Payment{}, nil
+}
+
+// outcomeUnknown reports whether the provider did not answer the last call for payment p
+func outcomeUnknown(p models.Payment) bool {
+	return len(p.Attempts) > 0 && p.Attempts[len(p.Attempts)-1].ErrorCode == providerErrorCode
+}
+
+// authorizedPayment returns the authorized payment of an order, or the one being
+// captured when the provider did not answer its capture, ErrPaymentInProgress when it
+// is being captured
+func authorizedPayment(ctx context.Context, store repository.Store, orderID int) (models.Payment, error) {
+	payments, err := store.Payments().ListByOrder(ctx, orderID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	for _, p := range payments {
+		switch p.Status {
+		case models.PaymentStatusAuthorized:
+			return p, nil
+		case models.PaymentStatusCapturing:
+			if outcomeUnknown(p) {
+				return p, nil
+			}
+			return models.Payment{}, ErrPaymentInProgress
+		}
+	}
+	return models.Payment{}, ErrNoAuthorizedPayment
+}
+
+// roundCents rounds an amount to whole cents, providers only take those
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+)
+
+// newTestPayments returns an order service paying through the mock provider, outside of
+// any seasonal campaign, and a pending order for a shirt and shoes
+func newTestPayments(t *testing.T) (*OrderService, *PaymentService, models.Order) {
+	t.Helper()
+	orders, store := newTestService(t)
+	orders.Clock = clock.NewManual(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC))
+	payments := NewPaymentService(store, payment.NewMock())
+	orders.Payments = payments
+
+	order, err := orders.Create(context.Background(), CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}, {ItemID: 2, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return orders, payments, order
+}
+
+func TestPaymentIsCapturedOnConfirmedOrders(t *testing.T) {
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.PaymentStatusAuthorized || p.Provider != "mock" || p.Amount != 60 || p.ProviderReference == "" {
+		t.Fatalf("started payment = %+v", p)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("starting a second payment returned %v, want ErrPaymentInProgress", err)
+	}
+	if _, err := orders.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 1, Quantity: 3}}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("changing the items of an authorized order returned %v, want ErrPaymentInProgress", err)
+	}
+
+	var notConfirmed *NotConfirmedError
+	if _, err := payments.Capture(ctx, order.ID); !errors.As(err, &notConfirmed) || notConfirmed.Status != models.OrderStatusPending {
+		t.Fatalf("capturing a pending order returned %v, want NotConfirmedError", err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = payments.Capture(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.PaymentStatusCaptured || p.CapturedAmount != 60 {
+		t.Fatalf("captured payment = %+v", p)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status after capture = %s, want Paid", got.Status)
+	}
+
+	if _, err := payments.Capture(ctx, order.ID); !errors.Is(err, ErrOrderPaid) {
+		t.Fatalf("capturing twice returned %v, want ErrOrderPaid", err)
+	}
+	if err := orders.Cancel(ctx, order.ID); !errors.Is(err, ErrOrderPaid) {
+		t.Fatalf("cancelling a paid order returned %v, want ErrOrderPaid", err)
+	}
+	if _, err := orders.Update(ctx, order.ID, UpdateOrderInput{Status: models.OrderStatusPending, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}}); !errors.Is(err, ErrOrderPaid) {
+		t.Fatalf("updating a paid order returned %v, want ErrOrderPaid", err)
+	}
+
+	list, err := payments.List(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || len(list[0].Attempts) != 2 || list[0].Attempts[1].Operation != models.PaymentOperationCapture || !list[0].Attempts[1].Succeeded {
+		t.Fatalf("payments = %+v, want one payment with an authorization and a capture", list)
+	}
+}
+
+func TestDeclinedPaymentsCanBeRetried(t *testing.T) {
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+
+	var declined *PaymentDeclinedError
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: payment.MockMethodInsufficientFunds})
+	if !errors.As(err, &declined) || declined.Code != "insufficient_funds" || declined.Operation != models.PaymentOperationAuthorize {
+		t.Fatalf("Start with insufficient funds returned %v, want PaymentDeclinedError", err)
+	}
+	if p.Status != models.PaymentStatusFailed || len(p.Attempts) != 1 || p.Attempts[0].ErrorCode != "insufficient_funds" {
+		t.Fatalf("declined payment = %+v", p)
+	}
+
+	// A payment that authorizes but whose capture is declined leaves the order confirmed
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: payment.MockMethodCaptureDeclined}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Capture(ctx, order.ID); !errors.As(err, &declined) || declined.Operation != models.PaymentOperationCapture {
+		t.Fatalf("Capture returned %v, want a declined capture", err)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusConfirmed {
+		t.Fatalf("order status after a declined capture = %s, want Confirm", got.Status)
+	}
+	if _, err := payments.Capture(ctx, order.ID); !errors.Is(err, ErrNoAuthorizedPayment) {
+		t.Fatalf("capturing without an authorized payment returned %v, want ErrNoAuthorizedPayment", err)
+	}
+
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Capture(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := payments.List(ctx, order.ID); len(list) != 3 {
+		t.Fatalf("%d payments, want every attempt kept", len(list))
+	}
+}
+
+func TestCancelVoidsAuthorizedPayments(t *testing.T) {
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Cancel(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := payments.store.Payments().Get(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.PaymentStatusVoided || len(got.Attempts) != 2 || got.Attempts[1].Operation != models.PaymentOperationVoid {
+		t.Fatalf("payment of the cancelled order = %+v, want it voided", got)
+	}
+
+	var unknown *UnknownProviderError
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Provider: "stripe", Method: "tok_visa"}); !errors.As(err, &unknown) {
+		t.Fatalf("Start with an unknown provider returned %v, want UnknownProviderError", err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("paying a cancelled order returned %v, want ErrOrderNotFound", err)
+	}
+}
+
+// heldCaptures is a mock provider that holds every capture until released
+type heldCaptures struct {
+	*payment.Mock
+	asked   chan struct{}
+	release chan struct{}
+}
+
+func (h *heldCaptures) Capture(ctx context.Context, reference string, amount float64) error {
+	h.asked <- struct{}{}
+	<-h.release
+	return h.Mock.Capture(ctx, reference, amount)
+}
+
+func TestConcurrentCapturesReachTheProviderOnce(t *testing.T) {
+	orders, store := newTestService(t)
+	provider := &heldCaptures{Mock: payment.NewMock(), asked: make(chan struct{}, 2), release: make(chan struct{})}
+	payments := NewPaymentService(store, provider)
+	orders.Payments = payments
+	ctx := context.Background()
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	captured := make(chan error)
+	go func() {
+		_, err := payments.Capture(ctx, order.ID)
+		captured <- err
+	}()
+	<-provider.asked
+
+	if _, err := payments.Capture(ctx, order.ID); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("capturing a payment that is being captured returned %v, want ErrPaymentInProgress", err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("starting a payment while one is being captured returned %v, want ErrPaymentInProgress", err)
+	}
+	if list, _ := payments.List(ctx, order.ID); len(list) != 1 || list[0].Status != models.PaymentStatusCapturing {
+		t.Fatalf("payments while the provider is asked = %+v, want one capturing", list)
+	}
+
+	close(provider.release)
+	if err := <-captured; err != nil {
+		t.Fatal(err)
+	}
+	list, _ := payments.List(ctx, order.ID)
+	if list[0].Status != models.PaymentStatusCaptured || list[0].CapturedAmount != 10 || len(list[0].Attempts) != 2 {
+		t.Fatalf("payment = %+v, want 10 captured in a single attempt", list[0])
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %s, want Paid", got.Status)
+	}
+}
+
+// lostAnswers is a mock provider whose answers are lost on the way back while lose is set
+type lostAnswers struct {
+	*payment.Mock
+	lose bool
+}
+
+func (l *lostAnswers) Authorize(ctx context.Context, req payment.AuthorizeRequest) (string, error) {
+	reference, err := l.Mock.Authorize(ctx, req)
+	if l.lose {
+		return "", errors.New("connection reset")
+	}
+	return reference, err
+}
+
+func (l *lostAnswers) Capture(ctx context.Context, reference string, amount float64) error {
+	err := l.Mock.Capture(ctx, reference, amount)
+	if l.lose {
+		return errors.New("connection reset")
+	}
+	return err
+}
+
+func TestUnansweredPaymentsAreAskedForAgain(t *testing.T) {
+	orders, store := newTestService(t)
+	provider := &lostAnswers{Mock: payment.NewMock(), lose: true}
+	payments := NewPaymentService(store, provider)
+	orders.Payments = payments
+	ctx := context.Background()
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var providerErr *ProviderError
+	p, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"})
+	if !errors.As(err, &providerErr) || p.Status != models.PaymentStatusPending {
+		t.Fatalf("Start without an answer returned %+v, %v, want a pending payment and a ProviderError", p, err)
+	}
+	if _, err := orders.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 1, Quantity: 2}}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("changing the items of an order with an unanswered payment returned %v, want ErrPaymentInProgress", err)
+	}
+	provider.lose = false
+	if p, err = payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.PaymentStatusAuthorized || p.ProviderReference != "mock_auth_1" || len(p.Attempts) != 2 {
+		t.Fatalf("payment asked for again = %+v, want the first authorization", p)
+	}
+
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	provider.lose = true
+	if p, err = payments.Capture(ctx, order.ID); !errors.As(err, &providerErr) || p.Status != models.PaymentStatusCapturing {
+		t.Fatalf("Capture without an answer returned %+v, %v, want a capturing payment and a ProviderError", p, err)
+	}
+	provider.lose = false
+	if p, err = payments.Capture(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.PaymentStatusCaptured || p.CapturedAmount != 10 {
+		t.Fatalf("payment captured again = %+v, want 10 captured", p)
+	}
+	if list, _ := payments.List(ctx, order.ID); len(list) != 1 {
+		t.Fatalf("%d payments, want the unanswered one completed", len(list))
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %s, want Paid", got.Status)
+	}
+}
+
+// endingRequests is a mock provider that ends the request while it is asked
+type endingRequests struct {
+	*payment.Mock
+	cancel context.CancelFunc
+}
+
+func (e *endingRequests) Authorize(ctx context.Context, req payment.AuthorizeRequest) (string, error) {
+	reference, err := e.Mock.Authorize(ctx, req)
+	e.cancel()
+	return reference, err
+}
+
+func TestPaymentsAreStoredAfterTheRequestEnds(t *testing.T) {
+	orders, store := newTestService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	payments := NewPaymentService(store, &endingRequests{Mock: payment.NewMock(), cancel: cancel})
+	order, err := orders.Create(context.Background(), CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := payments.List(context.Background(), order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Status != models.PaymentStatusAuthorized || len(list[0].Attempts) != 1 {
+		t.Fatalf("payments = %+v, want the authorization stored", list)
+	}
+}
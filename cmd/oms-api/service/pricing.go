@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/campaign"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/logging"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/loyalty"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/metrics"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// seasonAt is the moment an order is priced and where its customer lives
+type seasonAt struct {
+	calendar *campaign.Calendar
+	region   string
+	now      time.Time
+}
+
+// calculateDiscounts returns the discounts of the items and the loyalty standing of the
+// customer, whose tier may bring other benefits than its discount
+func calculateDiscounts(ctx context.Context, payments repository.PaymentRepository, program *loyalty.Program, season seasonAt, userID int, items []models.OrderItem) (models.Discounts, loyalty.Status) {
+	ctx, span := tracing.Tracer().Start(ctx, "calculateDiscounts") // The spend query becomes a child of this span
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	discounts := models.Discounts{}
+
+	// Seasonal discount, campaign days start at midnight in the region of the customer
+	if active, ok := season.calendar.Active(season.region, season.now); ok {
+		discounts.SeasonalDiscount = active.Rate
+		span.SetAttributes(attribute.String("discount.campaign", active.Name))
+		logger.DebugContext(ctx, "seasonal discount applied",
+			slog.String("campaign", active.Name),
+			slog.String("region", season.region),
+			slog.Float64("rate", discounts.SeasonalDiscount),
+		)
+	}
+
+	// Volume-based discount (10 or more units of any single item)
+	for _, item := range items {
+		if volumeDiscount := lineVolumeDiscount(item); volumeDiscount > 0 {
+			discounts.VolumeBasedDiscount += volumeDiscount
+			logger.DebugContext(ctx, "volume discount applied", slog.Int("item_id", item.ItemID), slog.Float64("amount", volumeDiscount))
+		}
+	}
+
+	// Loyalty discount of the tier the customer reached with their spend in the window
+	standing, err := loyaltyStatus(ctx, payments, program, userID, season.now)
+	if err != nil {
+		logger.ErrorContext(ctx, "fetching user spend failed", slog.Int("user_id", userID), slog.Any("error", err))
+		span.RecordError(err)
+	}
+	if standing.Tier != "" {
+		discounts.LoyaltyTier = standing.Tier
+		discounts.LoyaltyDiscount = standing.DiscountRate
+		logger.DebugContext(ctx, "loyalty discount applied", slog.String("tier", standing.Tier), slog.Float64("rate", discounts.LoyaltyDiscount))
+	}
+
+	span.SetAttributes(
+		attribute.Float64("discount.seasonal_rate", discounts.SeasonalDiscount),
+		attribute.Float64("discount.volume_amount", discounts.VolumeBasedDiscount),
+		attribute.Float64("discount.loyalty_rate", discounts.LoyaltyDiscount),
+		attribute.String("user.loyalty_tier", standing.Tier),
+		attribute.Float64("user.spend", standing.Spend),
+	)
+	return discounts, standing
+}
+
+// loyaltyStatus places a customer in the tiers of program by their spend in the window
+// ending at now. When the spend cannot be read they are placed as if they spent nothing.
+func loyaltyStatus(ctx context.Context, payments repository.PaymentRepository, program *loyalty.Program, userID int, now time.Time) (loyalty.Status, error) {
+	spend, err := payments.SpendByUser(ctx, userID, program.Since(now))
+	if err != nil {
+		return program.Status(0), err
+	}
+	return program.Status(spend), nil
+}
+
+func calculateTotalPrice(ctx context.Context, items []models.OrderItem, discounts models.Discounts) float64 {
+	ctx, span := tracing.Tracer().Start(ctx, "calculateTotalPrice")
+	defer span.End()
+
+	var totalPrice float64
+	for _, item := range items {
+		totalPrice += item.Price * float64(item.Quantity)
+	}
+
+	seasonalDiscount := totalPrice * discounts.SeasonalDiscount
+	loyaltyDiscount := totalPrice * discounts.LoyaltyDiscount
+	volumeDiscount := discounts.VolumeBasedDiscount
+
+	totalDiscount := seasonalDiscount + loyaltyDiscount + volumeDiscount
+	if totalDiscount > totalPrice {
+		totalDiscount = totalPrice
+	}
+
+	finalPrice := totalPrice - totalDiscount
+	span.SetAttributes(
+		attribute.Float64("order.total_price", totalPrice),
+		attribute.Float64("order.discount", totalDiscount),
+		attribute.Float64("order.final_price", finalPrice),
+	)
+	logging.FromContext(ctx).DebugContext(ctx, "order price calculated",
+		slog.Float64("total_price", totalPrice),
+		slog.Float64("discount", totalDiscount),
+		slog.Float64("final_price", finalPrice),
+	)
+	return finalPrice
+}
+
+// lineVolumeDiscount is the volume discount of a line, 10% when it has 10 or more units
+func lineVolumeDiscount(item models.OrderItem) float64 {
+	if item.Quantity >= 10 {
+		return 0.10 * item.Price * float64(item.Quantity)
+	}
+	return 0
+}
+
+// allocateDiscounts sets the share of the order discounts of every line. The seasonal
+// and loyalty rates apply to every line, the volume discount belongs to the lines it was
+// granted for, and no line is discounted below zero. Shares are rounded to cents, the
+// difference to the order discount goes to the largest shares that can take it, so the
+// discounted lines add up to the final price rounded to cents.
+func allocateDiscounts(items []models.OrderItem, discounts models.Discounts, finalPrice float64) {
+	lineTotals := make([]float64, len(items))
+	largest := make([]int, len(items))
+	var total, allocated float64
+	for i, item := range items {
+		lineTotals[i] = roundCents(item.Price * float64(item.Quantity))
+		share := lineTotals[i]*(discounts.SeasonalDiscount+discounts.LoyaltyDiscount) + lineVolumeDiscount(item)
+		items[i].Discount = roundCents(min(share, lineTotals[i]))
+		total += lineTotals[i]
+		allocated += items[i].Discount
+		largest[i] = i
+	}
+	sort.SliceStable(largest, func(a, b int) bool { return items[largest[a]].Discount > items[largest[b]].Discount })
+
+	difference := roundCents(total - roundCents(finalPrice) - allocated)
+	for _, i := range largest {
+		if difference == 0 {
+			break
+		}
+		change := max(-items[i].Discount, min(difference, lineTotals[i]-items[i].Discount))
+		items[i].Discount = roundCents(items[i].Discount + change)
+		difference = roundCents(difference - change)
+	}
+}
+
+// applyCoupon takes a percentage or fixed coupon off the eligible lines of items, which
+// already carry their share of the automatic discounts, and returns the amount it took
+// off. itemsPrice is the price of the items after the automatic discounts, it has to
+// reach the minimum spend of the coupon. The amount is spread over the eligible lines
+// by their cost and the last cents go to the largest lines, so the discounted lines
+// still add up to the price of the items. Free shipping coupons take nothing off the
+// items.
+func applyCoupon(coupon *models.Coupon, items []models.OrderItem, itemsPrice float64) (float64, error) {
+	var eligible []int
+	costs := make([]float64, len(items))
+	var base float64
+	for i, item := range items {
+		costs[i] = roundCents(item.Price*float64(item.Quantity) - item.Discount)
+		if coupon.Eligible(item.ItemID) {
+			eligible = append(eligible, i)
+			base += costs[i]
+		}
+	}
+	if len(eligible) == 0 {
+		return 0, &CouponNotApplicableError{Code: coupon.Code, Reason: CouponNoEligibleItems}
+	}
+	if roundCents(itemsPrice) < coupon.MinimumSpend {
+		return 0, &CouponNotApplicableError{Code: coupon.Code, Reason: CouponMinimumSpend, MinimumSpend: coupon.MinimumSpend}
+	}
+
+	var amount float64
+	switch coupon.Effect {
+	case models.CouponPercentage:
+		amount = roundCents(base * coupon.Value)
+	case models.CouponFixed:
+		amount = roundCents(min(coupon.Value, base))
+	}
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	shares := make([]float64, len(items))
+	var allocated float64
+	for _, i := range eligible {
+		shares[i] = roundCents(min(amount*costs[i]/base, costs[i]))
+		allocated += shares[i]
+	}
+	sort.SliceStable(eligible, func(a, b int) bool { return costs[eligible[a]] > costs[eligible[b]] })
+	difference := roundCents(amount - allocated)
+	for _, i := range eligible {
+		if difference == 0 {
+			break
+		}
+		change := max(-shares[i], min(difference, costs[i]-shares[i]))
+		shares[i] = roundCents(shares[i] + change)
+		difference = roundCents(difference - change)
+	}
+	for _, i := range eligible {
+		items[i].Discount = roundCents(items[i].Discount + shares[i])
+	}
+	return amount, nil
+}
+
+// applyTax sets the tax rate and amount of every line, the category of items[i] being
+// categories[i], and of shipping, at the rates of destination. Lines are taxed after
+// their discounts. It returns the tax of the order.
+func applyTax(rates *tax.Table, destination models.PostalAddress, items []models.OrderItem, categories []string, shipping *models.ShippingLine) float64 {
+	var total float64
+	for i := range items {
+		category := categories[i]
+		if category == "" {
+			category = tax.CategoryStandard
+		}
+		items[i].TaxRate = rates.Rate(destination.Country, destination.State, category)
+		items[i].Tax = rates.Amount(roundCents(items[i].Price*float64(items[i].Quantity)-items[i].Discount), items[i].TaxRate)
+		total += items[i].Tax
+	}
+	shipping.Tax = rates.Amount(shipping.Price, rates.Rate(destination.Country, destination.State, tax.CategoryShipping))
+	return roundCents(total + shipping.Tax)
+}
+
+// addedTax returns the part of tax added on top of prices, none when prices include it
+func addedTax(tax float64, pricesIncludeTax bool) float64 {
+	if pricesIncludeTax {
+		return 0
+	}
+	return tax
+}
+
+// recordOrderCreated counts a new order and the discount it was granted, by discount type
+func recordOrderCreated(totalPrice float64, discounts models.Discounts) {
+	metrics.OrdersCreated.Inc()
+	metrics.DiscountAmount.WithLabelValues(metrics.DiscountSeasonal).Add(totalPrice * discounts.SeasonalDiscount)
+	metrics.DiscountAmount.WithLabelValues(metrics.DiscountVolume).Add(discounts.VolumeBasedDiscount)
+	metrics.DiscountAmount.WithLabelValues(metrics.DiscountLoyalty).Add(totalPrice * discounts.LoyaltyDiscount)
+	metrics.DiscountAmount.WithLabelValues(metrics.DiscountCoupon).Add(discounts.CouponDiscount)
+}
+
+// recordOrderStatus counts an order that was moved to status
+func recordOrderStatus(status string) {
+	switch status {
+	case models.OrderStatusConfirmed:
+		metrics.OrdersConfirmed.Inc()
+	case models.OrderStatusCancelled:
+		metrics.OrdersCancelled.Inc()
+	}
+}
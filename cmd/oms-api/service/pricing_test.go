@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestAllocateDiscounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []models.OrderItem
+		discounts models.Discounts
+		want      []float64
+	}{
+		{
+			name:      "rates are shared by every line, the volume discount by its own",
+			items:     []models.OrderItem{{ItemID: 1, Quantity: 10, Price: 10}, {ItemID: 2, Quantity: 1, Price: 50}},
+			discounts: models.Discounts{SeasonalDiscount: 0.15, LoyaltyDiscount: 0.05, VolumeBasedDiscount: 10},
+			want:      []float64{30, 10},
+		},
+		{
+			name:      "rounding differences go to the largest share",
+			items:     []models.OrderItem{{ItemID: 1, Quantity: 1, Price: 19.99}, {ItemID: 2, Quantity: 2, Price: 19.99}, {ItemID: 3, Quantity: 3, Price: 19.99}},
+			discounts: models.Discounts{SeasonalDiscount: 0.15, LoyaltyDiscount: 0.05},
+			want:      []float64{4, 8, 11.99},
+		},
+		{
+			name:      "discounts above the price are scaled down to it",
+			items:     []models.OrderItem{{ItemID: 1, Quantity: 10, Price: 1}, {ItemID: 2, Quantity: 1, Price: 10}},
+			discounts: models.Discounts{SeasonalDiscount: 0.9, LoyaltyDiscount: 0.05, VolumeBasedDiscount: 1},
+			want:      []float64{10, 10},
+		},
+		{
+			name:  "no discounts",
+			items: []models.OrderItem{{ItemID: 1, Quantity: 3, Price: 0.1}},
+			want:  []float64{0},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			finalPrice := calculateTotalPrice(context.Background(), tc.items, tc.discounts)
+			allocateDiscounts(tc.items, tc.discounts, finalPrice)
+
+			var net float64
+			for i, item := range tc.items {
+				if item.Discount != tc.want[i] {
+					t.Errorf("discount of line %d = %v, want %v", i+1, item.Discount, tc.want[i])
+				}
+				net += item.Price*float64(item.Quantity) - item.Discount
+			}
+			if math.Abs(net-roundCents(finalPrice)) > 0.001 {
+				t.Errorf("lines add up to %v after their discounts, want the final price %v", net, roundCents(finalPrice))
+			}
+		})
+	}
+}
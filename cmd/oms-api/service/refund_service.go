@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// RefundInput asks for units of the lines of a paid order to be given back
+type RefundInput struct {
+	Lines  []OrderLine // Everything that has not been refunded yet when empty
+	Reason string
+}
+
+// refundedLine sums what has been refunded of an order line so far
+type refundedLine struct {
+	quantity int
+	discount float64
+	tax      float64
+	amount   float64
+}
+
+// ListRefunds returns the refunds of an order with their lines
+func (s *PaymentService) ListRefunds(ctx context.Context, orderID int) ([]models.Refund, error) {
+	if _, err := getOrder(ctx, s.store, orderID); err != nil {
+		return nil, err
+	}
+	return s.store.Refunds().ListByOrder(ctx, orderID)
+}
+
+// Refund gives back units of the lines of a paid order, shipped or not, through its
+// captured payment.
+// Every unit is refunded at its price minus its share of the discounts of its line, and
+// the refund of the last units gives back the shipping charge too, so refunding all
+// lines gives back exactly what was captured. The order is refunded once
+// the whole payment has been given back and partially refunded before.
+// The refund is stored as pending with its amount added to the payment before the
+// provider is asked for it, under a lock on the payment, so concurrent refunds of the
+// order see what it takes and never give the same units or money back twice. Only the
+// attempt is kept when the provider declines the refund. When the provider does not
+// answer, the refund stays pending and keeps what it holds until a payment.refunded
+// webhook reports it, so it is never given back twice.
+func (s *PaymentService) Refund(ctx context.Context, orderID int, in RefundInput) (models.Refund, error) {
+	var p models.Payment
+	var refund models.Refund
+	var provider payment.Provider
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := getOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusPaid && order.Status != models.OrderStatusPartiallyRefunded && !isShipped(order.Status) {
+			return &NotPaidError{OrderID: orderID, Status: order.Status}
+		}
+		if p, err = capturedPayment(ctx, tx, order); err != nil {
+			return err
+		}
+		var ok bool
+		if provider, ok = s.providers[p.Provider]; !ok {
+			return &UnknownProviderError{Provider: p.Provider}
+		}
+		// The refunds are read once the payment is held, they include those that were
+		// made while waiting for it
+		if p, err = tx.Payments().Lock(ctx, p.ID); err != nil {
+			return err
+		}
+		if p.Status != models.PaymentStatusCaptured {
+			return &NotPaidError{OrderID: orderID, Status: order.Status}
+		}
+		refunds, err := tx.Refunds().ListByOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		lines, err := refundLines(order.Items, refunds, in.Lines, order.PricesIncludeTax)
+		if err != nil {
+			return err
+		}
+
+		refund = models.Refund{OrderID: orderID, PaymentID: p.ID, Status: models.RefundStatusPending, Reason: in.Reason, Lines: lines}
+		if refundsLastUnits(order.Items, refunds, lines) {
+			refund.Shipping = roundCents(order.Shipping.Price)
+			if !order.PricesIncludeTax {
+				refund.Shipping = roundCents(refund.Shipping + order.Shipping.Tax)
+			}
+		}
+		refund.Amount = refund.Shipping
+		for _, line := range lines {
+			refund.Amount += line.Amount
+		}
+		// Refunds reported by webhooks have no lines, they are taken from what is left
+		refund.Amount = roundCents(min(refund.Amount, p.CapturedAmount-p.RefundedAmount))
+		if refund.Amount <= 0 {
+			return ErrNothingToRefund
+		}
+		return recordRefund(ctx, tx, &p, &refund)
+	})
+	if err != nil {
+		return models.Refund{}, err
+	}
+
+	reference, err := provider.Refund(ctx, p.ProviderReference, refund.Amount)
+	attempt := newAttempt(provider, &p, models.PaymentOperationRefund, refund.Amount, reference, err)
+	var declined *payment.DeclinedError
+	wasDeclined := errors.As(err, &declined)
+	if attempt.Succeeded {
+		refund.Status, refund.ProviderReference = models.RefundStatusSucceeded, reference
+	}
+	// The answer of the provider is kept even when the request has ended meanwhile
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		p, err := tx.Payments().Lock(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Payments().AddAttempt(ctx, &attempt); err != nil {
+			return err
+		}
+		current, err := pendingRefund(ctx, tx, p, func(r models.Refund) bool { return r.ID == refund.ID })
+		if err != nil || current.ID == 0 {
+			// A webhook reported the refund while the provider was asked
+			return err
+		}
+		switch {
+		case attempt.Succeeded:
+			if err := tx.Refunds().Update(ctx, &refund); err != nil {
+				return err
+			}
+			return completeRefund(ctx, tx, &p)
+		case wasDeclined:
+			// The amount held by the refund is given back
+			if err := tx.Refunds().Delete(ctx, refund.ID); err != nil {
+				return err
+			}
+			p.RefundedAmount = roundCents(p.RefundedAmount - refund.Amount)
+			return tx.Payments().Update(ctx, &p, "refunded_amount")
+		}
+		// The outcome is unknown, the refund holds its units and amount until it is reported
+		return nil
+	}); err != nil {
+		return models.Refund{}, err
+	}
+	if err != nil {
+		return models.Refund{}, attemptError(provider, &p, attempt, err)
+	}
+	return refund, nil
+}
+
+// pendingRefund returns the oldest pending refund of payment p that match accepts, a
+// refund without ID when there is none
+func pendingRefund(ctx context.Context, store repository.Store, p models.Payment, match func(models.Refund) bool) (models.Refund, error) {
+	refunds, err := store.Refunds().ListByOrder(ctx, p.OrderID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	for _, refund := range refunds {
+		if refund.PaymentID == p.ID && refund.Status == models.RefundStatusPending && match(refund) {
+			return refund, nil
+		}
+	}
+	return models.Refund{}, nil
+}
+
+// recordRefund stores refund of payment p and adds it to the refunded amount of the
+// payment. The payment must be locked.
+func recordRefund(ctx context.Context, store repository.Store, p *models.Payment, refund *models.Refund) error {
+	if err := store.Refunds().Create(ctx, refund); err != nil {
+		return err
+	}
+	p.RefundedAmount = roundCents(p.RefundedAmount + refund.Amount)
+	return store.Payments().Update(ctx, p, "refunded_amount")
+}
+
+// completeRefund moves payment p and its order to the refund status matching the amount
+// that has been refunded. Shipped orders keep their shipping status until the whole
+// payment has been given back.
+func completeRefund(ctx context.Context, store repository.Store, p *models.Payment) error {
+	if p.RefundedAmount >= p.CapturedAmount {
+		p.Status = models.PaymentStatusRefunded
+		if err := store.Payments().Update(ctx, p, "status"); err != nil {
+			return err
+		}
+	}
+
+	order, err := getOrder(ctx, store, p.OrderID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case p.Status == models.PaymentStatusRefunded:
+		order.Status = models.OrderStatusRefunded
+	case isShipped(order.Status):
+		return nil
+	default:
+		order.Status = models.OrderStatusPartiallyRefunded
+	}
+	return store.Orders().Update(ctx, &order, "status")
+}
+
+// refundLines returns the refund lines giving back the requested units of the order
+// items, or every unit that has not been refunded yet when nothing is requested
+func refundLines(items []models.OrderItem, refunds []models.Refund, requested []OrderLine, pricesIncludeTax bool) ([]models.RefundLine, error) {
+	refunded := map[int]refundedLine{}
+	for _, refund := range refunds {
+		for _, line := range refund.Lines {
+			r := refunded[line.OrderItemID]
+			r.quantity += line.Quantity
+			r.discount += line.Discount
+			r.tax += line.Tax
+			r.amount += line.Amount
+			refunded[line.OrderItemID] = r
+		}
+	}
+
+	used := map[int]int{}
+	for id, r := range refunded {
+		used[id] = r.quantity
+	}
+	taken, short, err := takeUnits(items, used, requested)
+	if err != nil {
+		return nil, err
+	}
+	if short != nil {
+		return nil, &RefundQuantityError{ItemID: short.itemID, Quantity: short.quantity, Refundable: short.left}
+	}
+
+	var lines []models.RefundLine
+	for _, units := range taken {
+		lines = append(lines, refundLine(units.item, refunded[units.item.ID], units.quantity, pricesIncludeTax))
+	}
+	return lines, nil
+}
+
+// refundsLastUnits reports whether lines give back every unit of items that the earlier
+// refunds left
+func refundsLastUnits(items []models.OrderItem, refunds []models.Refund, lines []models.RefundLine) bool {
+	left := 0
+	for _, item := range items {
+		left += item.Quantity
+	}
+	for _, refund := range append(refunds, models.Refund{Lines: lines}) {
+		for _, line := range refund.Lines {
+			left -= line.Quantity
+		}
+	}
+	return len(lines) > 0 && left == 0
+}
+
+// refundLine refunds quantity units of item with their share of the line discount and of
+// the line tax, which is added to the amount unless prices include it. The last units of
+// a line get what is left of it, so rounding never adds up to more or less than the line
+// cost.
+func refundLine(item models.OrderItem, refunded refundedLine, quantity int, pricesIncludeTax bool) models.RefundLine {
+	line := models.RefundLine{OrderItemID: item.ID, ItemID: item.ItemID, Quantity: quantity, Price: item.Price}
+	if refunded.quantity+quantity == item.Quantity {
+		line.Discount = roundCents(item.Discount - refunded.discount)
+		line.Tax = roundCents(item.Tax - refunded.tax)
+		line.Amount = roundCents(item.Price*float64(item.Quantity) - item.Discount + addedTax(item.Tax, pricesIncludeTax) - refunded.amount)
+		return line
+	}
+	line.Discount = roundCents(item.Discount * float64(quantity) / float64(item.Quantity))
+	line.Tax = roundCents(item.Tax * float64(quantity) / float64(item.Quantity))
+	line.Amount = roundCents(item.Price*float64(quantity) - line.Discount + addedTax(line.Tax, pricesIncludeTax))
+	return line
+}
+
+// capturedPayment returns the payment of an order that has been captured
+func capturedPayment(ctx context.Context, store repository.Store, order models.Order) (models.Payment, error) {
+	payments, err := store.Payments().ListByOrder(ctx, order.ID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	for _, p := range payments {
+		if p.Status == models.PaymentStatusCaptured {
+			return p, nil
+		}
+	}
+	return models.Payment{}, &NotPaidError{OrderID: order.ID, Status: order.Status}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/payment"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/shipping"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/tax"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+// newPaidOrder returns the services of newTestPayments and a paid order for ten shirts
+// and shoes, the shirts get a volume discount of 10
+func newPaidOrder(t *testing.T) (*OrderService, *PaymentService, models.Order) {
+	t.Helper()
+	orders, payments, order := newTestPayments(t)
+	ctx := context.Background()
+	order, err := orders.ChangeItems(ctx, order.ID, []OrderLine{{ItemID: 1, Quantity: 10}, {ItemID: 2, Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Capture(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	return orders, payments, order
+}
+
+func TestRefundsShareTheLineDiscounts(t *testing.T) {
+	orders, payments, order := newPaidOrder(t)
+	ctx := context.Background()
+	if order.FinalPrice != 140 || order.Items[0].Discount != 10 || order.Items[1].Discount != 0 {
+		t.Fatalf("order = %+v, want the volume discount on the shirts", order)
+	}
+
+	refund, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 3}}, Reason: "Too small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Amount != 27 || len(refund.Lines) != 1 || refund.Lines[0].Discount != 3 || refund.ProviderReference == "" {
+		t.Fatalf("refund of three shirts = %+v, want 27 after a discount of 3", refund)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPartiallyRefunded {
+		t.Fatalf("order status after a partial refund = %s, want PartiallyRefunded", got.Status)
+	}
+
+	var quantity *RefundQuantityError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 8}}}); !errors.As(err, &quantity) || quantity.Refundable != 7 {
+		t.Fatalf("refunding more shirts than are left returned %v, want RefundQuantityError", err)
+	}
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 3, Quantity: 1}}}); !errors.As(err, &quantity) || quantity.Refundable != 0 {
+		t.Fatalf("refunding an item that is not in the order returned %v, want RefundQuantityError", err)
+	}
+
+	// Everything left is refunded without lines
+	if refund, err = payments.Refund(ctx, order.ID, RefundInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if refund.Amount != 113 || len(refund.Lines) != 2 || refund.Lines[0].Quantity != 7 || refund.Lines[0].Discount != 7 {
+		t.Fatalf("refund of the rest = %+v, want 63 for the shirts and 50 for the shoes", refund)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusRefunded {
+		t.Fatalf("order status after refunding everything = %s, want Refunded", got.Status)
+	}
+	list, err := payments.List(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[0].Status != models.PaymentStatusRefunded || list[0].RefundedAmount != 140 {
+		t.Fatalf("payment after refunding everything = %+v", list[0])
+	}
+
+	var notPaid *NotPaidError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{}); !errors.As(err, &notPaid) || notPaid.Status != models.OrderStatusRefunded {
+		t.Fatalf("refunding a refunded order returned %v, want NotPaidError", err)
+	}
+	if err := orders.Cancel(ctx, order.ID); !errors.Is(err, ErrOrderPaid) {
+		t.Fatalf("cancelling a refunded order returned %v, want ErrOrderPaid", err)
+	}
+	if refunds, _ := payments.ListRefunds(ctx, order.ID); len(refunds) != 2 {
+		t.Fatalf("%d refunds, want 2", len(refunds))
+	}
+}
+
+func TestRefundsNeedAPaidOrder(t *testing.T) {
+	_, payments, order := newTestPayments(t)
+	ctx := context.Background()
+
+	var notPaid *NotPaidError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{}); !errors.As(err, &notPaid) || notPaid.Status != models.OrderStatusPending {
+		t.Fatalf("refunding a pending order returned %v, want NotPaidError", err)
+	}
+	if _, err := payments.Refund(ctx, 999, RefundInput{}); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("refunding a missing order returned %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestRefundedEventsOfAPIRefundsAreNotCountedTwice(t *testing.T) {
+	orders, payments, order := newPaidOrder(t)
+	ctx := context.Background()
+
+	refund, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := payments.List(ctx, order.ID)
+	reported := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{
+		ProviderReference: list[0].ProviderReference, Amount: refund.Amount, RefundReference: refund.ProviderReference,
+	}}
+	p, err := payments.ApplyEvent(ctx, "mock", reported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RefundedAmount != 50 {
+		t.Fatalf("refunded amount after the event of an API refund = %v, want 50", p.RefundedAmount)
+	}
+
+	// A refund made at the provider is recorded without lines
+	other := webhook.Event{ID: "evt_2", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{
+		ProviderReference: list[0].ProviderReference, Amount: 90, RefundReference: "re_dashboard",
+	}}
+	if p, err = payments.ApplyEvent(ctx, "mock", other); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.PaymentStatusRefunded {
+		t.Fatalf("payment status after refunding the rest at the provider = %s, want Refunded", p.Status)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusRefunded {
+		t.Fatalf("order status = %s, want Refunded", got.Status)
+	}
+	refunds, _ := payments.ListRefunds(ctx, order.ID)
+	if len(refunds) != 2 || len(refunds[1].Lines) != 0 || refunds[1].ProviderReference != "re_dashboard" {
+		t.Fatalf("refunds = %+v, want the provider refund without lines", refunds)
+	}
+}
+
+func TestRefundOfTheLastUnitsGivesBackShipping(t *testing.T) {
+	orders, payments, _ := newTestPayments(t)
+	ctx := context.Background()
+	rates, err := shipping.New(0, []shipping.Zone{{Name: "everywhere", Rates: []shipping.Rate{
+		{Method: shipping.MethodExpress, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 12}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders.Shipping = rates
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 2}}, ShippingMethod: shipping.MethodExpress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	p, err := payments.Capture(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CapturedAmount != 32 {
+		t.Fatalf("captured %v, want 32 with shipping", p.CapturedAmount)
+	}
+
+	first, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Amount != 10 || first.Shipping != 0 {
+		t.Fatalf("refund of the first shirt = %+v, want 10 without shipping", first)
+	}
+	last, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Amount != 22 || last.Shipping != 12 {
+		t.Fatalf("refund of the last shirt = %+v, want 22 with shipping", last)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusRefunded {
+		t.Fatalf("order status = %s, want Refunded", got.Status)
+	}
+}
+
+func TestRefundsGiveBackTheTaxAddedToThePrices(t *testing.T) {
+	orders, payments, _ := newTestPayments(t)
+	ctx := context.Background()
+	taxes, err := tax.New(false, []tax.Jurisdiction{{Country: "GB", Rates: map[string]float64{tax.CategoryStandard: 0.2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders.Tax = taxes
+	rates, err := shipping.New(0, []shipping.Zone{{Name: "everywhere", Rates: []shipping.Rate{
+		{Method: shipping.MethodStandard, Basis: shipping.BasisValue, Bands: []shipping.Band{{Price: 12}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders.Shipping = rates
+	london := models.PostalAddress{Name: "Ada", Line1: "2 Market St", City: "London", PostalCode: "SW1A 1AA", Country: "GB"}
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 3}}, ShippingAddress: AddressChoice{Address: &london}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := payments.Capture(ctx, order.ID); err != nil || p.CapturedAmount != 50.4 {
+		t.Fatalf("captured %+v, %v, want 50.40 with tax", p, err)
+	}
+
+	first, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Amount != 12 || first.Lines[0].Tax != 2 {
+		t.Fatalf("refund of the first shirt = %+v, want 12 with its tax", first)
+	}
+	last, err := payments.Refund(ctx, order.ID, RefundInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Amount != 38.4 || last.Lines[0].Tax != 4 || last.Shipping != 14.4 {
+		t.Fatalf("refund of the last shirts = %+v, want 38.40 with the tax of the shirts and of shipping", last)
+	}
+}
+
+// paidWith returns the services of newTestService paying through provider and a paid
+// order for a shirt and shoes
+func paidWith(t *testing.T, provider payment.Provider) (*OrderService, *PaymentService, models.Order) {
+	t.Helper()
+	orders, store := newTestService(t)
+	payments := NewPaymentService(store, provider)
+	orders.Payments = payments
+	ctx := context.Background()
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}, {ItemID: 2, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(ctx, order.ID, StartPaymentInput{Method: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Confirm(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Capture(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	return orders, payments, order
+}
+
+// heldRefunds is a mock provider that holds every refund until released
+type heldRefunds struct {
+	*payment.Mock
+	asked   chan struct{}
+	release chan struct{}
+}
+
+func (h *heldRefunds) Refund(ctx context.Context, reference string, amount float64) (string, error) {
+	h.asked <- struct{}{}
+	<-h.release
+	return h.Mock.Refund(ctx, reference, amount)
+}
+
+// declinedRefunds is a mock provider that declines every refund
+type declinedRefunds struct{ *payment.Mock }
+
+func (declinedRefunds) Refund(ctx context.Context, reference string, amount float64) (string, error) {
+	return "", &payment.DeclinedError{Code: "refund_declined", Message: "The refund was declined"}
+}
+
+func TestConcurrentRefundsDoNotGiveBackTheSameUnits(t *testing.T) {
+	provider := &heldRefunds{Mock: payment.NewMock(), asked: make(chan struct{}, 2), release: make(chan struct{})}
+	_, payments, order := paidWith(t, provider)
+	ctx := context.Background()
+
+	type result struct {
+		refund models.Refund
+		err    error
+	}
+	first := make(chan result)
+	go func() {
+		refund, err := payments.Refund(ctx, order.ID, RefundInput{})
+		first <- result{refund, err}
+	}()
+	<-provider.asked
+
+	// The shoes are held by the pending refund of everything
+	var quantity *RefundQuantityError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); !errors.As(err, &quantity) || quantity.Refundable != 0 {
+		t.Fatalf("refunding the shoes while they are being refunded returned %v, want RefundQuantityError", err)
+	}
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{}); !errors.Is(err, ErrNothingToRefund) {
+		t.Fatalf("refunding everything twice at once returned %v, want ErrNothingToRefund", err)
+	}
+	refunds, _ := payments.ListRefunds(ctx, order.ID)
+	if len(refunds) != 1 || refunds[0].Status != models.RefundStatusPending {
+		t.Fatalf("refunds while the provider is asked = %+v, want one pending", refunds)
+	}
+
+	close(provider.release)
+	got := <-first
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if got.refund.Amount != 60 || got.refund.Status != models.RefundStatusSucceeded {
+		t.Fatalf("refund = %+v, want all 60 refunded", got.refund)
+	}
+	list, _ := payments.List(ctx, order.ID)
+	if len(list) != 1 || list[0].RefundedAmount != 60 || list[0].Status != models.PaymentStatusRefunded || len(list[0].Attempts) != 3 {
+		t.Fatalf("payment = %+v, want 60 refunded in a single attempt", list[0])
+	}
+}
+
+func TestDeclinedRefundsGiveBackWhatTheyHeld(t *testing.T) {
+	orders, payments, order := paidWith(t, declinedRefunds{payment.NewMock()})
+	ctx := context.Background()
+
+	var declined *PaymentDeclinedError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); !errors.As(err, &declined) {
+		t.Fatalf("declined refund returned %v, want PaymentDeclinedError", err)
+	}
+	list, _ := payments.List(ctx, order.ID)
+	if list[0].RefundedAmount != 0 || list[0].Status != models.PaymentStatusCaptured || len(list[0].Attempts) != 3 {
+		t.Fatalf("payment after a declined refund = %+v, want nothing refunded and the attempt kept", list[0])
+	}
+	if refunds, _ := payments.ListRefunds(ctx, order.ID); len(refunds) != 0 {
+		t.Fatalf("refunds after a declined refund = %+v, want none", refunds)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %s, want Paid", got.Status)
+	}
+}
+
+// lostRefunds is a mock provider that makes every refund but loses its answer
+type lostRefunds struct{ *payment.Mock }
+
+func (l lostRefunds) Refund(ctx context.Context, reference string, amount float64) (string, error) {
+	if _, err := l.Mock.Refund(ctx, reference, amount); err != nil {
+		return "", err
+	}
+	return "", errors.New("connection reset")
+}
+
+func TestUnansweredRefundsHoldWhatTheyTakeUntilReported(t *testing.T) {
+	orders, payments, order := paidWith(t, lostRefunds{payment.NewMock()})
+	ctx := context.Background()
+
+	var providerErr *ProviderError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); !errors.As(err, &providerErr) {
+		t.Fatalf("unanswered refund returned %v, want ProviderError", err)
+	}
+	var quantity *RefundQuantityError
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); !errors.As(err, &quantity) || quantity.Refundable != 0 {
+		t.Fatalf("refunding the shoes again returned %v, want RefundQuantityError", err)
+	}
+	refunds, _ := payments.ListRefunds(ctx, order.ID)
+	if len(refunds) != 1 || refunds[0].Status != models.RefundStatusPending {
+		t.Fatalf("refunds after an unanswered refund = %+v, want one pending", refunds)
+	}
+
+	list, _ := payments.List(ctx, order.ID)
+	reported := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{
+		ProviderReference: list[0].ProviderReference, Amount: 50, RefundReference: "mock_refund_2",
+	}}
+	p, err := payments.ApplyEvent(ctx, "mock", reported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RefundedAmount != 50 || p.Status != models.PaymentStatusCaptured {
+		t.Fatalf("payment after the refund was reported = %+v, want 50 refunded once", p)
+	}
+	refunds, _ = payments.ListRefunds(ctx, order.ID)
+	if len(refunds) != 1 || refunds[0].Status != models.RefundStatusSucceeded || refunds[0].ProviderReference != "mock_refund_2" || len(refunds[0].Lines) != 1 {
+		t.Fatalf("refunds = %+v, want the pending refund completed", refunds)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPartiallyRefunded {
+		t.Fatalf("order status = %s, want PartiallyRefunded", got.Status)
+	}
+}
+
+func TestRefundsReportedBeforeTheirAnswerAreNotCountedTwice(t *testing.T) {
+	provider := &heldRefunds{Mock: payment.NewMock(), asked: make(chan struct{}, 1), release: make(chan struct{})}
+	_, payments, order := paidWith(t, provider)
+	ctx := context.Background()
+
+	refunded := make(chan error)
+	go func() {
+		_, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}})
+		refunded <- err
+	}()
+	<-provider.asked
+
+	list, _ := payments.List(ctx, order.ID)
+	reported := webhook.Event{ID: "evt_1", Type: webhook.EventPaymentRefunded, Data: webhook.EventData{
+		ProviderReference: list[0].ProviderReference, Amount: 50, RefundReference: "mock_refund_2",
+	}}
+	if _, err := payments.ApplyEvent(ctx, "mock", reported); err != nil {
+		t.Fatal(err)
+	}
+	close(provider.release)
+	if err := <-refunded; err != nil {
+		t.Fatal(err)
+	}
+
+	list, _ = payments.List(ctx, order.ID)
+	if list[0].RefundedAmount != 50 {
+		t.Fatalf("refunded amount = %v, want 50", list[0].RefundedAmount)
+	}
+	refunds, _ := payments.ListRefunds(ctx, order.ID)
+	if len(refunds) != 1 || refunds[0].Status != models.RefundStatusSucceeded || refunds[0].ProviderReference != "mock_refund_2" {
+		t.Fatalf("refunds = %+v, want the API refund completed once", refunds)
+	}
+}
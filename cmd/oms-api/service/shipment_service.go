@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/repository"
+)
+
+// CreateShipmentInput describes a parcel handed to a carrier
+type CreateShipmentInput struct {
+	Carrier        string
+	TrackingNumber string
+	Lines          []OrderLine // Everything that has not been shipped yet when empty
+}
+
+// ShipmentService ships confirmed orders in one or more parcels and tracks their
+// delivery. The order status follows its shipments: partially shipped until every unit
+// has been shipped, then shipped, and delivered once every shipment has arrived.
+type ShipmentService struct {
+	// Clock stamps shipments when they are shipped and delivered
+	Clock clock.Clock
+
+	store repository.Store
+}
+
+// NewShipmentService creates a shipment service on store with the system clock
+func NewShipmentService(store repository.Store) *ShipmentService {
+	return &ShipmentService{Clock: clock.System, store: store}
+}
+
+// List returns the shipments of an order with their lines
+func (s *ShipmentService) List(ctx context.Context, orderID int) ([]models.Shipment, error) {
+	if _, err := getOrder(ctx, s.store, orderID); err != nil {
+		return nil, err
+	}
+	return s.store.Shipments().ListByOrder(ctx, orderID)
+}
+
+// Create ships units of the lines of a confirmed or paid order. No line may ship more
+// units than were ordered and not refunded, over all shipments of the order. The order
+// is held while its shipments are read, so concurrent shipments see each other's units.
+func (s *ShipmentService) Create(ctx context.Context, orderID int, in CreateShipmentInput) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		order, err := lockOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		switch order.Status {
+		case models.OrderStatusConfirmed, models.OrderStatusPaid, models.OrderStatusPartiallyRefunded, models.OrderStatusPartiallyShipped:
+		default:
+			return &NotShippableError{OrderID: orderID, Status: order.Status}
+		}
+
+		shipments, err := tx.Shipments().ListByOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		used, err := refundedUnits(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range shipments {
+			for _, line := range existing.Lines {
+				used[line.OrderItemID] += line.Quantity
+			}
+		}
+		taken, short, err := takeUnits(order.Items, used, in.Lines)
+		if err != nil {
+			return err
+		}
+		if short != nil {
+			return &ShipmentQuantityError{ItemID: short.itemID, Quantity: short.quantity, Shippable: short.left}
+		}
+		if len(taken) == 0 {
+			return ErrNothingToShip
+		}
+
+		shipment = models.Shipment{
+			OrderID:        orderID,
+			Carrier:        in.Carrier,
+			TrackingNumber: in.TrackingNumber,
+			Status:         models.ShipmentStatusInTransit,
+			ShippedAt:      s.Clock.Now(),
+		}
+		for _, units := range taken {
+			shipment.Lines = append(shipment.Lines, models.ShipmentLine{OrderItemID: units.item.ID, ItemID: units.item.ItemID, Quantity: units.quantity})
+		}
+		if err := tx.Shipments().Create(ctx, &shipment); err != nil {
+			if errors.Is(err, repository.ErrDuplicateTrackingNumber) {
+				return ErrTrackingNumberTaken
+			}
+			return err
+		}
+		return updateFulfillment(ctx, tx, order, append(shipments, shipment))
+	})
+	if err != nil {
+		return models.Shipment{}, err
+	}
+	return shipment, nil
+}
+
+// Deliver marks a shipment as delivered, its order is delivered once all of it arrived.
+// The order is held while its shipments are read, so of concurrent deliveries of its
+// last parcels the one that comes last sees all of them delivered.
+func (s *ShipmentService) Deliver(ctx context.Context, shipmentID int) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		if shipment, err = getShipment(ctx, tx, shipmentID); err != nil {
+			return err
+		}
+		order, err := lockOrder(ctx, tx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		// The shipment is read again once the order is held, it may have been delivered meanwhile
+		if shipment, err = getShipment(ctx, tx, shipmentID); err != nil {
+			return err
+		}
+		if shipment.Status == models.ShipmentStatusDelivered {
+			return ErrShipmentDelivered
+		}
+		now := s.Clock.Now()
+		shipment.Status, shipment.DeliveredAt = models.ShipmentStatusDelivered, &now
+		if err := tx.Shipments().Update(ctx, &shipment); err != nil {
+			return err
+		}
+		shipments, err := tx.Shipments().ListByOrder(ctx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		return updateFulfillment(ctx, tx, order, shipments)
+	})
+	if err != nil {
+		return models.Shipment{}, err
+	}
+	return shipment, nil
+}
+
+// getShipment returns the shipment with its lines, ErrShipmentNotFound when there is none
+func getShipment(ctx context.Context, store repository.Store, id int) (models.Shipment, error) {
+	shipment, err := store.Shipments().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Shipment{}, ErrShipmentNotFound
+	}
+	return shipment, err
+}
+
+// updateFulfillment moves order to the status matching its shipments, refunded orders
+// stay refunded. Refunded units do not have to be shipped.
+func updateFulfillment(ctx context.Context, store repository.Store, order models.Order, shipments []models.Shipment) error {
+	if order.Status == models.OrderStatusRefunded {
+		return nil
+	}
+	refunded, err := refundedUnits(ctx, store, order.ID)
+	if err != nil {
+		return err
+	}
+	ordered, shipped := 0, 0
+	for _, item := range order.Items {
+		ordered += item.Quantity - refunded[item.ID]
+	}
+	delivered := true
+	for _, shipment := range shipments {
+		for _, line := range shipment.Lines {
+			shipped += line.Quantity
+		}
+		delivered = delivered && shipment.Status == models.ShipmentStatusDelivered
+	}
+
+	status := models.OrderStatusShipped
+	switch {
+	case shipped < ordered:
+		status = models.OrderStatusPartiallyShipped
+	case delivered:
+		status = models.OrderStatusDelivered
+	}
+	if status == order.Status {
+		return nil
+	}
+	order.Status = status
+	return store.Orders().Update(ctx, &order, "status")
+}
+
+// refundedUnits counts the units of every order item of an order that have been
+// refunded or are being refunded, by order item ID
+func refundedUnits(ctx context.Context, store repository.Store, orderID int) (map[int]int, error) {
+	refunds, err := store.Refunds().ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refunded := map[int]int{}
+	for _, refund := range refunds {
+		for _, line := range refund.Lines {
+			refunded[line.OrderItemID] += line.Quantity
+		}
+	}
+	return refunded, nil
+}
+
+// isShipped reports whether an order with status has shipped some of its units
+func isShipped(status string) bool {
+	switch status {
+	case models.OrderStatusPartiallyShipped, models.OrderStatusShipped, models.OrderStatusDelivered:
+		return true
+	}
+	return false
+}
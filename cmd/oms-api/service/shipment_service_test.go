@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/clock"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/models"
+)
+
+func TestShipmentsFulfilOrdersPartially(t *testing.T) {
+	orders, payments, order := newPaidOrder(t)
+	ctx := context.Background()
+	now := clock.NewManual(time.Date(2024, time.June, 2, 9, 0, 0, 0, time.UTC))
+	shipments := NewShipmentService(orders.store)
+	shipments.Clock = now
+
+	first, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001", Lines: []OrderLine{{ItemID: 1, Quantity: 4}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != models.ShipmentStatusInTransit || !first.ShippedAt.Equal(now.Now()) || len(first.Lines) != 1 || first.Lines[0].Quantity != 4 {
+		t.Fatalf("first shipment = %+v, want four shirts in transit", first)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusPartiallyShipped {
+		t.Fatalf("order status after the first shipment = %s, want PartiallyShipped", got.Status)
+	}
+
+	var quantity *ShipmentQuantityError
+	if _, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0002", Lines: []OrderLine{{ItemID: 1, Quantity: 7}}}); !errors.As(err, &quantity) || quantity.Shippable != 6 {
+		t.Fatalf("shipping more shirts than are left returned %v, want ShipmentQuantityError", err)
+	}
+	if _, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001"}); !errors.Is(err, ErrTrackingNumberTaken) {
+		t.Fatalf("reusing a tracking number returned %v, want ErrTrackingNumberTaken", err)
+	}
+
+	// Everything left is shipped without lines
+	second, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "UPS", TrackingNumber: "JD0001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Lines) != 2 || second.Lines[0].Quantity != 6 || second.Lines[1].Quantity != 1 {
+		t.Fatalf("second shipment lines = %+v, want the six shirts and the shoes left", second.Lines)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusShipped {
+		t.Fatalf("order status after shipping everything = %s, want Shipped", got.Status)
+	}
+	if _, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "UPS", TrackingNumber: "JD0003"}); err == nil {
+		t.Fatal("shipping a shipped order succeeded")
+	}
+
+	// Shipped orders can still be refunded, but no longer changed or cancelled
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 2, Quantity: 1}}}); err != nil {
+		t.Fatalf("refunding a shipped order: %v", err)
+	}
+	if err := orders.Cancel(ctx, order.ID); !errors.Is(err, ErrOrderShipped) {
+		t.Fatalf("cancelling a shipped order returned %v, want ErrOrderShipped", err)
+	}
+
+	now.Advance(48 * time.Hour)
+	delivered, err := shipments.Deliver(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered.DeliveredAt == nil || !delivered.DeliveredAt.Equal(now.Now()) {
+		t.Fatalf("delivered shipment = %+v, want it delivered now", delivered)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusShipped {
+		t.Fatalf("order status with a shipment in transit = %s, want Shipped", got.Status)
+	}
+	if _, err := shipments.Deliver(ctx, first.ID); !errors.Is(err, ErrShipmentDelivered) {
+		t.Fatalf("delivering a shipment twice returned %v, want ErrShipmentDelivered", err)
+	}
+	if _, err := shipments.Deliver(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusDelivered {
+		t.Fatalf("order status after every shipment arrived = %s, want Delivered", got.Status)
+	}
+}
+
+func TestRefundedUnitsAreNotShipped(t *testing.T) {
+	orders, payments, order := newPaidOrder(t)
+	ctx := context.Background()
+	shipments := NewShipmentService(orders.store)
+
+	if _, err := payments.Refund(ctx, order.ID, RefundInput{Lines: []OrderLine{{ItemID: 1, Quantity: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	var quantity *ShipmentQuantityError
+	if _, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001", Lines: []OrderLine{{ItemID: 1, Quantity: 8}}}); !errors.As(err, &quantity) || quantity.Shippable != 7 {
+		t.Fatalf("shipping refunded shirts returned %v, want ShipmentQuantityError with 7 shippable", err)
+	}
+
+	// Everything left is what was not refunded, and shipping it ships the order
+	shipment, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shipment.Lines) != 2 || shipment.Lines[0].Quantity != 7 || shipment.Lines[1].Quantity != 1 {
+		t.Fatalf("shipment lines = %+v, want the seven shirts not refunded and the shoes", shipment.Lines)
+	}
+	if got, _ := orders.Get(ctx, order.ID); got.Status != models.OrderStatusShipped {
+		t.Fatalf("order status after shipping everything not refunded = %s, want Shipped", got.Status)
+	}
+}
+
+func TestShipmentsNeedAConfirmedOrder(t *testing.T) {
+	orders, store := newTestService(t)
+	ctx := context.Background()
+	shipments := NewShipmentService(store)
+	order, err := orders.Create(ctx, CreateOrderInput{UserID: 1, Lines: []OrderLine{{ItemID: 1, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notShippable *NotShippableError
+	if _, err := shipments.Create(ctx, order.ID, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001"}); !errors.As(err, &notShippable) || notShippable.Status != models.OrderStatusPending {
+		t.Fatalf("shipping a pending order returned %v, want NotShippableError", err)
+	}
+	if _, err := shipments.Create(ctx, 999, CreateShipmentInput{Carrier: "DHL", TrackingNumber: "JD0001"}); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("shipping a missing order returned %v, want ErrOrderNotFound", err)
+	}
+	if _, err := shipments.Deliver(ctx, 999); !errors.Is(err, ErrShipmentNotFound) {
+		t.Fatalf("delivering a missing shipment returned %v, want ErrShipmentNotFound", err)
+	}
+}
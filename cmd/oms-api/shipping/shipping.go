@@ -0,0 +1,259 @@
+// Package shipping prices the delivery of an order. Destinations, countries or the
+// regions of customers, are grouped into zones, and every zone has a rate per shipping method, banded by the weight of the
+// parcel or by the value of the order. Orders worth at least the free shipping
+// threshold ship for free with the standard method.
+package shipping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Shipping methods
+const (
+	MethodStandard = "standard"
+	MethodExpress  = "express"
+	MethodPickup   = "pickup"
+)
+
+// Methods lists the shipping methods in the order they are offered
+var Methods = []string{MethodStandard, MethodExpress, MethodPickup}
+
+// What the bands of a rate are measured in
+const (
+	BasisWeight = "weight" // Weight of the parcel in kilograms
+	BasisValue  = "value"  // Value of the order after discounts
+)
+
+// Band is the price of a parcel up to a weight or an order value, included
+type Band struct {
+	UpTo  float64 `json:"up_to"` // No upper limit when zero, only on the last band
+	Price float64 `json:"price"`
+}
+
+// Rate prices a shipping method in a zone, by the first band the parcel fits in
+type Rate struct {
+	Method string `json:"method"`
+	Basis  string `json:"basis"`
+	Bands  []Band `json:"bands"`
+}
+
+// Zone is a group of destinations sharing the same rates. A parcel is in the zone of its
+// country, then in the zone of its region. The zone without countries and regions has
+// every destination that is in no other zone.
+type Zone struct {
+	Name      string   `json:"name"`
+	Countries []string `json:"countries"` // ISO 3166-1 alpha-2 codes, in upper case
+	Regions   []string `json:"regions"`
+	Rates     []Rate   `json:"rates"`
+}
+
+// Parcel is what is shipped and where to
+type Parcel struct {
+	Country string  // Country of the shipping address, empty when the order has none
+	Region  string  // Region of the customer
+	Weight  float64 // Kilograms
+	Value   float64 // Value of the order after discounts
+}
+
+// Charge is the price of shipping a parcel with a method
+type Charge struct {
+	Method string
+	Zone   string
+	Price  float64
+}
+
+// Table holds the zones and their rates
+type Table struct {
+	freeThreshold float64
+	zones         []Zone
+	fallback      *Zone
+}
+
+// file is the format of a rate table file
+type file struct {
+	FreeShippingThreshold float64 `json:"free_shipping_threshold"`
+	Zones                 []Zone  `json:"zones"`
+}
+
+// DefaultZones is the rate table used when no file is configured: standard shipping
+// and pickup are free everywhere, express shipping costs 9.99
+var DefaultZones = []Zone{
+	{Name: "everywhere", Rates: []Rate{
+		{Method: MethodStandard, Basis: BasisValue, Bands: []Band{{Price: 0}}},
+		{Method: MethodExpress, Basis: BasisValue, Bands: []Band{{Price: 9.99}}},
+		{Method: MethodPickup, Basis: BasisValue, Bands: []Band{{Price: 0}}},
+	}},
+}
+
+// New creates a rate table. Orders worth freeThreshold or more ship for free with the
+// standard method, a zero threshold turns free shipping off.
+func New(freeThreshold float64, zones []Zone) (*Table, error) {
+	if freeThreshold < 0 {
+		return nil, fmt.Errorf("free shipping threshold %v is negative", freeThreshold)
+	}
+	t := &Table{freeThreshold: freeThreshold}
+	countries, regions := map[string]string{}, map[string]string{}
+	for i := range zones {
+		zone := zones[i]
+		if err := zone.check(); err != nil {
+			return nil, err
+		}
+		if len(zone.Countries) == 0 && len(zone.Regions) == 0 {
+			if t.fallback != nil {
+				return nil, fmt.Errorf("zones %q and %q both have no countries or regions", t.fallback.Name, zone.Name)
+			}
+			t.fallback = &zone
+		}
+		for _, country := range zone.Countries {
+			if other, ok := countries[country]; ok {
+				return nil, fmt.Errorf("country %q is in zones %q and %q", country, other, zone.Name)
+			}
+			countries[country] = zone.Name
+		}
+		for _, region := range zone.Regions {
+			if other, ok := regions[region]; ok {
+				return nil, fmt.Errorf("region %q is in zones %q and %q", region, other, zone.Name)
+			}
+			regions[region] = zone.Name
+		}
+		t.zones = append(t.zones, zone)
+	}
+	return t, nil
+}
+
+// Load reads a rate table file. Without a path the default zones are used.
+func Load(path string) (*Table, error) {
+	if path == "" {
+		return New(0, DefaultZones)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse shipping rates %s: %w", path, err)
+	}
+	table, err := New(f.FreeShippingThreshold, f.Zones)
+	if err != nil {
+		return nil, fmt.Errorf("shipping rates %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Price returns the charge of shipping parcel with method. ok is false when the zone of
+// the destination does not offer method, or the parcel is beyond its last band.
+func (t *Table) Price(method string, parcel Parcel) (charge Charge, ok bool) {
+	zone := t.zone(parcel)
+	if zone == nil {
+		return Charge{}, false
+	}
+	for _, rate := range zone.Rates {
+		if rate.Method != method {
+			continue
+		}
+		price, ok := rate.price(parcel)
+		if !ok {
+			return Charge{}, false
+		}
+		if method == MethodStandard && t.freeThreshold > 0 && parcel.Value >= t.freeThreshold {
+			price = 0
+		}
+		return Charge{Method: method, Zone: zone.Name, Price: price}, true
+	}
+	return Charge{}, false
+}
+
+// zone returns the zone of the country of parcel, then the zone of its region, and the
+// zone without countries or regions when no other has them
+func (t *Table) zone(parcel Parcel) *Zone {
+	if parcel.Country != "" {
+		for i, zone := range t.zones {
+			if contains(zone.Countries, parcel.Country) {
+				return &t.zones[i]
+			}
+		}
+	}
+	for i, zone := range t.zones {
+		if contains(zone.Regions, parcel.Region) {
+			return &t.zones[i]
+		}
+	}
+	return t.fallback
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// price returns the price of the first band parcel fits in
+func (r Rate) price(parcel Parcel) (float64, bool) {
+	measure := parcel.Weight
+	if r.Basis == BasisValue {
+		measure = parcel.Value
+	}
+	for _, band := range r.Bands {
+		if band.UpTo == 0 || measure <= band.UpTo {
+			return band.Price, true
+		}
+	}
+	return 0, false
+}
+
+// check validates the rates of the zone
+func (z Zone) check() error {
+	if strings.TrimSpace(z.Name) == "" {
+		return fmt.Errorf("zone without a name")
+	}
+	for _, country := range z.Countries {
+		if len(country) != 2 || strings.ToUpper(country) != country {
+			return fmt.Errorf("zone %q: country %q must be an upper case ISO 3166-1 alpha-2 code", z.Name, country)
+		}
+	}
+	methods := map[string]bool{}
+	for _, rate := range z.Rates {
+		if !isMethod(rate.Method) {
+			return fmt.Errorf("zone %q: unknown method %q, must be one of: %s", z.Name, rate.Method, strings.Join(Methods, ", "))
+		}
+		if methods[rate.Method] {
+			return fmt.Errorf("zone %q: method %q has two rates", z.Name, rate.Method)
+		}
+		methods[rate.Method] = true
+		if rate.Basis != BasisWeight && rate.Basis != BasisValue {
+			return fmt.Errorf("zone %q: %s: basis %q must be %q or %q", z.Name, rate.Method, rate.Basis, BasisWeight, BasisValue)
+		}
+		if len(rate.Bands) == 0 {
+			return fmt.Errorf("zone %q: %s: no bands", z.Name, rate.Method)
+		}
+		for i, band := range rate.Bands {
+			if band.Price < 0 {
+				return fmt.Errorf("zone %q: %s: band %d has a negative price", z.Name, rate.Method, i+1)
+			}
+			if band.UpTo < 0 || (band.UpTo == 0 && i != len(rate.Bands)-1) {
+				return fmt.Errorf("zone %q: %s: band %d must have a positive up_to, only the last band may have none", z.Name, rate.Method, i+1)
+			}
+			if i > 0 && band.UpTo != 0 && band.UpTo <= rate.Bands[i-1].UpTo {
+				return fmt.Errorf("zone %q: %s: band %d must go above band %d", z.Name, rate.Method, i+1, i)
+			}
+		}
+	}
+	return nil
+}
+
+// isMethod reports whether method is a known shipping method
+func isMethod(method string) bool {
+	for _, m := range Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package shipping
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testZones ships by weight at home and by order value abroad, pickup is only offered at home
+var testZones = []Zone{
+	{Name: "domestic", Regions: []string{"in"}, Rates: []Rate{
+		{Method: MethodStandard, Basis: BasisWeight, Bands: []Band{{UpTo: 1, Price: 3}, {UpTo: 5, Price: 6}, {UpTo: 20, Price: 12}}},
+		{Method: MethodExpress, Basis: BasisWeight, Bands: []Band{{UpTo: 5, Price: 10}, {Price: 25}}},
+		{Method: MethodPickup, Basis: BasisValue, Bands: []Band{{Price: 0}}},
+	}},
+	{Name: "international", Rates: []Rate{
+		{Method: MethodStandard, Basis: BasisValue, Bands: []Band{{UpTo: 100, Price: 15}, {Price: 30}}},
+	}},
+}
+
+func TestPriceFollowsZoneMethodAndBand(t *testing.T) {
+	table, err := New(50, testZones)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		parcel Parcel
+		want   Charge
+		ok     bool
+	}{
+		{"light parcel", MethodStandard, Parcel{Region: "in", Weight: 0.5, Value: 20}, Charge{MethodStandard, "domestic", 3}, true},
+		{"band limit is included", MethodStandard, Parcel{Region: "in", Weight: 5, Value: 20}, Charge{MethodStandard, "domestic", 6}, true},
+		{"free above the threshold", MethodStandard, Parcel{Region: "in", Weight: 5, Value: 50}, Charge{MethodStandard, "domestic", 0}, true},
+		{"too heavy", MethodStandard, Parcel{Region: "in", Weight: 21, Value: 20}, Charge{}, false},
+		{"express is never free", MethodExpress, Parcel{Region: "in", Weight: 8, Value: 500}, Charge{MethodExpress, "domestic", 25}, true},
+		{"pickup", MethodPickup, Parcel{Region: "in", Weight: 8, Value: 20}, Charge{MethodPickup, "domestic", 0}, true},
+		{"by value abroad", MethodStandard, Parcel{Region: "us-east", Weight: 30, Value: 40}, Charge{MethodStandard, "international", 15}, true},
+		{"customer without a region", MethodStandard, Parcel{Weight: 1, Value: 100}, Charge{MethodStandard, "international", 0}, true},
+		{"method not offered in the zone", MethodPickup, Parcel{Region: "us-east", Value: 20}, Charge{}, false},
+		{"unknown method", "drone", Parcel{Region: "in", Value: 20}, Charge{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := table.Price(tc.method, tc.parcel)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("Price(%s, %+v) = %+v, %v, want %+v, %v", tc.method, tc.parcel, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestDefaultZonesOnlyChargeExpress(t *testing.T) {
+	table, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range Methods {
+		charge, ok := table.Price(method, Parcel{Region: "anywhere", Weight: 100, Value: 10})
+		want := 0.0
+		if method == MethodExpress {
+			want = 9.99
+		}
+		if !ok || charge.Price != want {
+			t.Errorf("default %s charge = %+v, %v, want %v", method, charge, ok, want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  string
+	}{
+		{"unknown method", `{"zones":[{"name":"eu","rates":[{"method":"drone","basis":"weight","bands":[{"price":1}]}]}]}`, "unknown method"},
+		{"unknown basis", `{"zones":[{"name":"eu","rates":[{"method":"standard","basis":"volume","bands":[{"price":1}]}]}]}`, "basis"},
+		{"open band before the last", `{"zones":[{"name":"eu","rates":[{"method":"standard","basis":"weight","bands":[{"price":1},{"up_to":5,"price":2}]}]}]}`, "only the last band"},
+		{"bands going down", `{"zones":[{"name":"eu","rates":[{"method":"standard","basis":"weight","bands":[{"up_to":5,"price":1},{"up_to":2,"price":2}]}]}]}`, "must go above"},
+		{"two fallback zones", `{"zones":[{"name":"a"},{"name":"b"}]}`, "both have no countries or regions"},
+		{"country in two zones", `{"zones":[{"name":"a","countries":["DE"]},{"name":"b","countries":["DE"]}]}`, `country "DE"`},
+		{"lower case country", `{"zones":[{"name":"a","countries":["de"]}]}`, "upper case"},
+		{"region in two zones", `{"zones":[{"name":"a","regions":["in"]},{"name":"b","regions":["in"]}]}`, `region "in"`},
+		{"negative threshold", `{"free_shipping_threshold":-1}`, "negative"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.json")
+			if err := os.WriteFile(path, []byte(tc.table), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
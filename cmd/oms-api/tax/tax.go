@@ -0,0 +1,147 @@
+// Package tax holds the tax rates of the jurisdictions orders ship to. Every item has a
+// tax category, a jurisdiction is a country or a region of a country, and it taxes every
+// category at its own rate. Catalog prices either exclude tax, which is then added on
+// top of them, or include it, in which case the tax is the part of the price it makes up.
+package tax
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Tax categories of items, shipping is taxed in the shipping category
+const (
+	CategoryStandard = "standard"
+	CategoryReduced  = "reduced"  // The standard rate in the jurisdictions without a reduced rate
+	CategoryZero     = "zero"     // Taxed at zero
+	CategoryExempt   = "exempt"   // Not taxed
+	CategoryShipping = "shipping" // The standard rate in the jurisdictions without a shipping rate
+)
+
+// Categories lists the tax categories of items
+var Categories = []string{CategoryStandard, CategoryReduced, CategoryZero, CategoryExempt}
+
+// Jurisdiction is the rates of a country, or of a region of it when Region is set.
+// Rates are fractions, 0.2 for 20%, by category.
+type Jurisdiction struct {
+	Country string             `json:"country"` // ISO 3166-1 alpha-2 code, in upper case
+	Region  string             `json:"region"`  // The state of the shipping address, the whole country when empty
+	Rates   map[string]float64 `json:"rates"`
+}
+
+// Table holds the jurisdictions and whether catalog prices include tax
+type Table struct {
+	pricesIncludeTax bool
+	jurisdictions    map[string]Jurisdiction // By country and region
+}
+
+// file is the format of a tax rate file
+type file struct {
+	PricesIncludeTax bool           `json:"prices_include_tax"`
+	Jurisdictions    []Jurisdiction `json:"jurisdictions"`
+}
+
+// New creates a tax table. Without jurisdictions nothing is taxed.
+func New(pricesIncludeTax bool, jurisdictions []Jurisdiction) (*Table, error) {
+	t := &Table{pricesIncludeTax: pricesIncludeTax, jurisdictions: map[string]Jurisdiction{}}
+	for _, j := range jurisdictions {
+		if len(j.Country) != 2 || strings.ToUpper(j.Country) != j.Country {
+			return nil, fmt.Errorf("jurisdiction country %q must be an upper case ISO 3166-1 alpha-2 code", j.Country)
+		}
+		name := j.name()
+		if _, ok := j.Rates[CategoryStandard]; !ok {
+			return nil, fmt.Errorf("jurisdiction %s: no standard rate", name)
+		}
+		for category, rate := range j.Rates {
+			if category != CategoryShipping && !IsCategory(category) {
+				return nil, fmt.Errorf("jurisdiction %s: unknown category %q, must be one of: %s, %s", name, category, strings.Join(Categories, ", "), CategoryShipping)
+			}
+			if rate < 0 || rate >= 1 {
+				return nil, fmt.Errorf("jurisdiction %s: %s rate %v must be at least 0 and below 1", name, category, rate)
+			}
+		}
+		key := jurisdictionKey(j.Country, j.Region)
+		if _, ok := t.jurisdictions[key]; ok {
+			return nil, fmt.Errorf("jurisdiction %s is listed twice", name)
+		}
+		t.jurisdictions[key] = j
+	}
+	return t, nil
+}
+
+// Load reads a tax rate file. Without a path nothing is taxed.
+func Load(path string) (*Table, error) {
+	if path == "" {
+		return New(false, nil)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse tax rates %s: %w", path, err)
+	}
+	table, err := New(f.PricesIncludeTax, f.Jurisdictions)
+	if err != nil {
+		return nil, fmt.Errorf("tax rates %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// PricesIncludeTax reports whether catalog prices and shipping charges include tax
+func (t *Table) PricesIncludeTax() bool {
+	return t.pricesIncludeTax
+}
+
+// Rate returns the rate of category in the region of country, or in the whole country
+// when the region has no rates of its own. Destinations without rates are not taxed.
+func (t *Table) Rate(country, region, category string) float64 {
+	if category == CategoryZero || category == CategoryExempt {
+		return 0
+	}
+	j, ok := t.jurisdictions[jurisdictionKey(country, region)]
+	if !ok {
+		if j, ok = t.jurisdictions[jurisdictionKey(country, "")]; !ok {
+			return 0
+		}
+	}
+	if rate, ok := j.Rates[category]; ok {
+		return rate
+	}
+	return j.Rates[CategoryStandard]
+}
+
+// Amount returns the tax of amount at rate, rounded to cents. When prices include tax
+// it is the part of amount the tax makes up, otherwise the tax added on top of it.
+func (t *Table) Amount(amount, rate float64) float64 {
+	if t.pricesIncludeTax {
+		return math.Round(amount*rate/(1+rate)*100) / 100
+	}
+	return math.Round(amount*rate*100) / 100
+}
+
+// IsCategory reports whether category is a tax category of items
+func IsCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func (j Jurisdiction) name() string {
+	if j.Region == "" {
+		return j.Country
+	}
+	return j.Country + "/" + j.Region
+}
+
+// jurisdictionKey is the key of the rates of region in country, regions are matched in any case
+func jurisdictionKey(country, region string) string {
+	return country + "/" + strings.ToUpper(strings.TrimSpace(region))
+}
@@ -0,0 +1,94 @@
+package tax
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testJurisdictions = []Jurisdiction{
+	{Country: "GB", Rates: map[string]float64{"standard": 0.2, "reduced": 0.05}},
+	{Country: "US", Region: "CA", Rates: map[string]float64{"standard": 0.0725, "shipping": 0}},
+	{Country: "US", Rates: map[string]float64{"standard": 0.05}},
+}
+
+func TestRateFollowsJurisdictionAndCategory(t *testing.T) {
+	table, err := New(false, testJurisdictions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                      string
+		country, region, category string
+		want                      float64
+	}{
+		{"standard", "GB", "", CategoryStandard, 0.2},
+		{"reduced", "GB", "", CategoryReduced, 0.05},
+		{"shipping falls back to standard", "GB", "", CategoryShipping, 0.2},
+		{"zero rated", "GB", "", CategoryZero, 0},
+		{"exempt", "GB", "", CategoryExempt, 0},
+		{"region of the country", "US", "ca", CategoryStandard, 0.0725},
+		{"shipping of the region", "US", "CA", CategoryShipping, 0},
+		{"reduced falls back to standard", "US", "CA", CategoryReduced, 0.0725},
+		{"region without rates", "US", "NY", CategoryStandard, 0.05},
+		{"country without rates", "FR", "", CategoryStandard, 0},
+		{"no address", "", "", CategoryStandard, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := table.Rate(tc.country, tc.region, tc.category); got != tc.want {
+				t.Errorf("Rate(%q, %q, %q) = %v, want %v", tc.country, tc.region, tc.category, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAmountIsRoundedToCents(t *testing.T) {
+	exclusive, _ := New(false, nil)
+	inclusive, _ := New(true, nil)
+	tests := []struct {
+		table        *Table
+		amount, rate float64
+		want         float64
+	}{
+		{exclusive, 100, 0.2, 20},
+		{exclusive, 9.99, 0.0725, 0.72},
+		{exclusive, 0.05, 0.05, 0},
+		{inclusive, 120, 0.2, 20},
+		{inclusive, 9.99, 0.2, 1.67},
+		{inclusive, 10, 0, 0},
+	}
+	for _, tc := range tests {
+		if got := tc.table.Amount(tc.amount, tc.rate); got != tc.want {
+			t.Errorf("Amount(%v, %v) with prices including tax %v = %v, want %v", tc.amount, tc.rate, tc.table.PricesIncludeTax(), got, tc.want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  string
+	}{
+		{"lower case country", `{"jurisdictions":[{"country":"gb","rates":{"standard":0.2}}]}`, "upper case"},
+		{"no standard rate", `{"jurisdictions":[{"country":"GB","rates":{"reduced":0.05}}]}`, "no standard rate"},
+		{"unknown category", `{"jurisdictions":[{"country":"GB","rates":{"standard":0.2,"luxury":0.3}}]}`, "unknown category"},
+		{"rate as a percentage", `{"jurisdictions":[{"country":"GB","rates":{"standard":20}}]}`, "below 1"},
+		{"listed twice", `{"jurisdictions":[{"country":"US","region":"CA","rates":{"standard":0.07}},{"country":"US","region":"ca","rates":{"standard":0.07}}]}`, "twice"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tax.json")
+			if err := os.WriteFile(path, []byte(tc.table), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package utils
+
+import "strings"
+
+// NormalizeEmail returns the canonical form used to store and compare email addresses
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
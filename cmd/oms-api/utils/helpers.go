@@ -1,9 +0,0 @@
-package utils
-
-import "log"
-
-func LogError(err error) {
-	if err != nil {
-		log.Println("Error: ", err)
-	}
-}
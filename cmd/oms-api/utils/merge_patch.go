@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+)
+
+// MergePatchContentType is the media type of a JSON Merge Patch document (RFC 7396)
+const MergePatchContentType = "application/merge-patch+json"
+
+// MergePatch holds the members of a merge patch document keyed by field name
+type MergePatch map[string]json.RawMessage
+
+// Has reports whether the patch document contains the given field
+func (p MergePatch) Has(field string) bool {
+	_, ok := p[field]
+	return ok
+}
+
+// ReadMergePatch reads a merge patch document from the request body and makes sure
+// it only touches the mutable and optional fields. A null member means "remove" in
+// RFC 7396, so it is only accepted for the optional fields, which can also be patched.
+func ReadMergePatch(c *gin.Context, mutable []string, optional ...string) (MergePatch, error) {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != MergePatchContentType {
+		return nil, apperrors.New(http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType, "Content type must be "+MergePatchContentType)
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, apperrors.Wrap(err, http.StatusBadRequest, apperrors.CodeInvalidPatch, "Unable to read patch document")
+	}
+
+	var patch MergePatch
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, apperrors.BadRequest(apperrors.CodeInvalidPatch, "Patch document must be a JSON object")
+	}
+
+	allowed := make(map[string]bool, len(mutable)+len(optional))
+	for _, field := range mutable {
+		allowed[field] = false
+	}
+	for _, field := range optional {
+		allowed[field] = true
+	}
+	for field, value := range patch {
+		removable, ok := allowed[field]
+		if !ok {
+			return nil, apperrors.BadRequest(apperrors.CodeInvalidPatch, fmt.Sprintf("Field %q cannot be patched", field))
+		}
+		if !removable && bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			return nil, apperrors.BadRequest(apperrors.CodeInvalidPatch, fmt.Sprintf("Field %q cannot be removed", field))
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply merges the patch into target, which should already hold the current values.
+// Members that are not present in the patch leave the corresponding target fields untouched.
+func (p MergePatch) Apply(target interface{}) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return apperrors.Internal(err, "Unable to apply patch")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return apperrors.Wrap(err, http.StatusBadRequest, apperrors.CodeInvalidPatch, apperrors.TypeMismatchMessage(typeErr))
+		}
+		return apperrors.Wrap(err, http.StatusBadRequest, apperrors.CodeInvalidPatch, "Patch document does not match the resource")
+	}
+	return nil
+}
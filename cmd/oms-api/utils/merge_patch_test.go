@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+)
+
+// patchContext returns a context for a PATCH request with the content type and body
+func patchContext(contentType, body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return c
+}
+
+// expectAppError fails unless err is an *apperrors.Error with status and code
+func expectAppError(t *testing.T, err error, status int, code apperrors.Code) {
+	t.Helper()
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Status != status || appErr.Code != code {
+		t.Fatalf("got %v, want %d %s", err, status, code)
+	}
+}
+
+func TestReadMergePatch(t *testing.T) {
+	patch, err := ReadMergePatch(patchContext(MergePatchContentType+"; charset=utf-8", `{"name":"Ada"}`), []string{"name"}, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !patch.Has("name") || patch.Has("email") {
+		t.Fatalf("patch %v, want only the name", patch)
+	}
+	// Optional fields are removed with null
+	if patch, err = ReadMergePatch(patchContext(MergePatchContentType, `{"email":null}`), []string{"name"}, "email"); err != nil || !patch.Has("email") {
+		t.Fatalf("removing an optional field returned %v, %v", patch, err)
+	}
+
+	for name, tc := range map[string]struct {
+		contentType, body string
+		status            int
+		code              apperrors.Code
+	}{
+		"plain JSON":      {"application/json", `{"name":"Ada"}`, http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType},
+		"no content type": {"", `{"name":"Ada"}`, http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType},
+		"array":           {MergePatchContentType, `["name"]`, http.StatusBadRequest, apperrors.CodeInvalidPatch},
+		"null document":   {MergePatchContentType, `null`, http.StatusBadRequest, apperrors.CodeInvalidPatch},
+		"broken JSON":     {MergePatchContentType, `{"name":`, http.StatusBadRequest, apperrors.CodeInvalidPatch},
+		"immutable field": {MergePatchContentType, `{"id":5}`, http.StatusBadRequest, apperrors.CodeInvalidPatch},
+		"removed field":   {MergePatchContentType, `{"name": null }`, http.StatusBadRequest, apperrors.CodeInvalidPatch},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadMergePatch(patchContext(tc.contentType, tc.body), []string{"name"}, "email")
+			expectAppError(t, err, tc.status, tc.code)
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+	target := user{Name: "Ada", Email: "ada@example.com", Age: 36}
+	patch := MergePatch{"name": []byte(`"Ada Lovelace"`)}
+	if err := patch.Apply(&target); err != nil {
+		t.Fatal(err)
+	}
+	if target != (user{Name: "Ada Lovelace", Email: "ada@example.com", Age: 36}) {
+		t.Fatalf("patched %+v, want only the name changed", target)
+	}
+
+	err := MergePatch{"age": []byte(`"old"`)}.Apply(&target)
+	expectAppError(t, err, http.StatusBadRequest, apperrors.CodeInvalidPatch)
+	if appErr := apperrors.From(err); appErr.Message != `Field "age" must be an integer` {
+		t.Errorf("message %q, want the JSON type of the field", appErr.Message)
+	}
+	err = MergePatch{"nickname": []byte(`"Ada"`)}.Apply(&target)
+	expectAppError(t, err, http.StatusBadRequest, apperrors.CodeInvalidPatch)
+	if target.Age != 36 {
+		t.Errorf("age %d after rejected patches, want 36", target.Age)
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/address"
+)
+
+var setupValidatorOnce sync.Once
+
+// SetupValidator configures the validator used by Gin binding so that field errors
+// are reported with their JSON names and the custom tags used by the request DTOs exist.
+func SetupValidator() {
+	setupValidatorOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		_ = v.RegisterValidation("notblank", validators.NotBlank)
+		_ = v.RegisterValidation("country", func(fl validator.FieldLevel) bool {
+			return address.Supported(fl.Field().String())
+		})
+		// postal_code checks the field against the Country field of the same struct. It
+		// passes for unsupported countries, the country tag reports those.
+		_ = v.RegisterValidation("postal_code", func(fl validator.FieldLevel) bool {
+			country := reflect.Indirect(fl.Parent()).FieldByName("Country")
+			if country.Kind() != reflect.String || !address.Supported(country.String()) {
+				return true
+			}
+			return address.ValidPostalCode(country.String(), fl.Field().String())
+		})
+	})
+}
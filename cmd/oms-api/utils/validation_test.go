@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/keyurKalariya/OMS/cmd/oms-api/apperrors"
+)
+
+type testAddress struct {
+	Name       string `json:"name" binding:"notblank"`
+	Country    string `json:"country" binding:"required,country"`
+	PostalCode string `json:"postal_code" binding:"postal_code"`
+}
+
+type testOrder struct {
+	Address testAddress `json:"address"`
+	Items   []testLine  `json:"items" binding:"required,min=1,dive"`
+}
+
+type testLine struct {
+	Quantity int `json:"quantity" binding:"gt=0"`
+}
+
+// fieldErrors validates v with the Gin validator and returns the field errors
+func fieldErrors(t *testing.T, v interface{}) []apperrors.FieldError {
+	t.Helper()
+	SetupValidator()
+	err := binding.Validator.ValidateStruct(v)
+	if err == nil {
+		return nil
+	}
+	return apperrors.Validation(err).Fields
+}
+
+func TestValidatorReportsJSONNames(t *testing.T) {
+	got := fieldErrors(t, &testOrder{
+		Address: testAddress{Name: " ", Country: "US", PostalCode: "94105"},
+		Items:   []testLine{{Quantity: 1}, {Quantity: 0}},
+	})
+	want := []apperrors.FieldError{
+		{Field: "address.name", Rule: "notblank", Message: "must not be blank"},
+		{Field: "items[1].quantity", Rule: "gt", Message: "must be greater than 0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("field errors %+v, want %+v", got, want)
+	}
+}
+
+func TestCountriesAndPostalCodes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		address testAddress
+		rules   []string
+	}{
+		"valid":                     {testAddress{Name: "Ada", Country: "us", PostalCode: "94105"}, nil},
+		"wrong postal code":         {testAddress{Name: "Ada", Country: "US", PostalCode: "9410"}, []string{"postal_code"}},
+		"country without codes":     {testAddress{Name: "Ada", Country: "HK"}, nil},
+		"code where there are none": {testAddress{Name: "Ada", Country: "HK", PostalCode: "999077"}, []string{"postal_code"}},
+		// The country rule reports the country, the postal code is not checked against it
+		"unsupported country": {testAddress{Name: "Ada", Country: "XX", PostalCode: "anything"}, []string{"country"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var rules []string
+			for _, fieldErr := range fieldErrors(t, &tc.address) {
+				rules = append(rules, fieldErr.Rule)
+			}
+			if !reflect.DeepEqual(rules, tc.rules) {
+				t.Fatalf("failed rules %v, want %v", rules, tc.rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"payment.captured","data":{"provider_reference":"mock_auth_1"}}`)
+	signedAt := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	header := Sign("whsec_test", signedAt, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", "whsec_test", header, body, signedAt.Add(time.Minute), nil},
+		{"slightly early clock", "whsec_test", header, body, signedAt.Add(-time.Minute), nil},
+		{"other secret", "whsec_other", header, body, signedAt, ErrInvalidSignature},
+		{"changed body", "whsec_test", header, []byte(`{"id":"evt_2"}`), signedAt, ErrInvalidSignature},
+		{"replayed later", "whsec_test", header, body, signedAt.Add(6 * time.Minute), ErrTimestampOutOfTolerance},
+		{"from the future", "whsec_test", header, body, signedAt.Add(-6 * time.Minute), ErrTimestampOutOfTolerance},
+		{"no header", "whsec_test", "", body, signedAt, ErrMissingSignature},
+		{"no signature", "whsec_test", "t=1717243200", body, signedAt, ErrMissingSignature},
+		{"rotated secret", "whsec_test", header + ",v1=" + signature("whsec_old", "1717243200", body), body, signedAt, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, DefaultTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedTimestampCannotBeChanged(t *testing.T) {
+	body := []byte(`{}`)
+	header := Sign("whsec_test", time.Unix(1717243200, 0), body)
+	forged := "t=1717243500" + header[len("t=1717243200"):]
+	if err := Verify("whsec_test", forged, body, time.Unix(1717243500, 0), DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify with a moved timestamp = %v, want ErrInvalidSignature", err)
+	}
+}
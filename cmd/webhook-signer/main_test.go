@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keyurKalariya/OMS/cmd/oms-api/webhook"
+)
+
+func TestSignedOutputVerifies(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-secret", "whsec_test", "-id", "evt_1", "-type", webhook.EventPaymentRefunded, "-reference", "mock_auth_1", "-amount", "5"}
+	if err := run(args, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	header, body, ok := strings.Cut(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if !ok || !strings.HasPrefix(header, webhook.SignatureHeader+": ") {
+		t.Fatalf("output %q, want the signature header and the body", out.String())
+	}
+	signature := strings.TrimPrefix(header, webhook.SignatureHeader+": ")
+	if err := webhook.Verify("whsec_test", signature, []byte(body), time.Now(), webhook.DefaultTolerance); err != nil {
+		t.Fatalf("signature of the output does not verify: %v", err)
+	}
+	if want := `{"id":"evt_1","type":"payment.refunded","data":{"provider_reference":"mock_auth_1","amount":5}}`; body != want {
+		t.Errorf("body %s, want %s", body, want)
+	}
+}
+
+func TestSignsStandardInput(t *testing.T) {
+	var out bytes.Buffer
+	body := `{"id":"evt_2","type":"payment.failed","data":{"provider_reference":"mock_auth_1"}}`
+	if err := run([]string{"-secret", "whsec_test", "-body", "-", "-time", "2024-06-01T12:00:00Z"}, strings.NewReader(body), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := webhook.SignatureHeader + ": " + webhook.Sign("whsec_test", time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC), []byte(body)) + "\n" + body + "\n"
+	if out.String() != want {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+}